@@ -13,10 +13,15 @@ func NewRootCmd() *cobra.Command {
 		Use:   "extuml",
 		Short: "Generate glTF from .extuml 3D UML diagrams",
 		Long:  "extuml is a CLI to render .extuml 3D UML diagrams into glTF 2.0 files.",
+		// Execute prints the error itself
+		SilenceErrors: true,
 	}
 
 	// Subcommands
 	root.AddCommand(InitGenerateCmd())
+	root.AddCommand(InitExportCmd())
+	root.AddCommand(InitFmtCmd())
+	root.AddCommand(InitLSPCmd())
 
 	return root
 }
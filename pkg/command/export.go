@@ -0,0 +1,119 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/config"
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/repository"
+	"github.com/extuml/extuml/pkg/usecase"
+	"github.com/spf13/cobra"
+)
+
+// InitExportCmd creates the 'export' subcommand which writes a diagram back
+// as .extuml, Mermaid or PlantUML source.
+func InitExportCmd() *cobra.Command {
+	var (
+		outputPath string
+		diagFormat string
+		inputFmt   string
+		outputFmt  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export <input>",
+		Short: "Export a diagram as .extuml, Mermaid or PlantUML source",
+		Long: "Export a diagram in any input format as canonical .extuml source, a Mermaid\n" +
+			"classDiagram or a PlantUML class diagram.\n\n" +
+			"The format follows the output extension (.extuml, .mmd, .md, .puml) unless\n" +
+			"--to is given; Mermaid written to Markdown is wrapped in a ```mermaid block.\n" +
+			"Use -o - to write to stdout. An input with several diagrams yields one\n" +
+			"numbered output per diagram (out-1.mmd, out-2.mmd, ...).",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+				return fmt.Errorf("input path is required")
+			}
+
+			if strings.TrimSpace(outputPath) == "" {
+				return fmt.Errorf("output path is required (--output)")
+			}
+
+			if diagFormat != DiagnosticsText && diagFormat != DiagnosticsJSON {
+				return fmt.Errorf("unknown diagnostics format %q (expected text or json)", diagFormat)
+			}
+			if diagFormat == DiagnosticsJSON && outputPath == repository.Stdout {
+				return fmt.Errorf("--diagnostics-format json cannot be combined with --output -")
+			}
+
+			format, err := inputFormat(inputFmt)
+			if err != nil {
+				return err
+			}
+			target, err := exportFormat(outputFmt)
+			if err != nil {
+				return err
+			}
+
+			// Arguments are valid; failures from here on are not usage errors
+			cmd.SilenceUsage = true
+
+			return RunExport(args[0], outputPath, diagFormat, format, target)
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "output file path, or - for stdout")
+	cmd.Flags().StringVar(&outputFmt, "to", "auto", "output format: auto (by file extension), extuml, mermaid or plantuml")
+	cmd.Flags().StringVar(&inputFmt, "input-format", "auto", "input format: auto (by file extension), extuml, mermaid, plantuml, json or yaml")
+	cmd.Flags().StringVar(&diagFormat, "diagnostics-format", DiagnosticsText, "diagnostics output format: text (stderr) or json (stdout)")
+	cmd.Flags().SetNormalizeFunc(fromAlias)
+
+	return cmd
+}
+
+// exportFormat converts the --to flag to a usecase format
+func exportFormat(name string) (string, error) {
+	switch name {
+	case "auto":
+		return usecase.FormatAuto, nil
+	case usecase.FormatExtuml, usecase.FormatMermaid, usecase.FormatPlantUML:
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (expected auto, extuml, mermaid or plantuml)", name)
+}
+
+// RunExport executes the export command logic. Diagnostics are reported as by
+// RunGenerate; nothing else is printed when the output goes to stdout.
+func RunExport(inputPath, outputPath, diagFormat, inputFormat, outputFormat string) error {
+	cfg := config.NewConfig()
+
+	if _, statErr := os.Stat(inputPath); statErr != nil {
+		return fmt.Errorf("input file not found: %w", statErr)
+	}
+
+	written, diags, err := cfg.ExportCtrl.Export(inputPath, outputPath, inputFormat, outputFormat)
+
+	out, werr := reportDiagnostics(diags, diagFormat)
+	if werr != nil {
+		return werr
+	}
+
+	if err != nil {
+		var list diagnostic.List
+		if errors.As(err, &list) {
+			return fmt.Errorf("export: %s: %s", inputPath, list.Summary())
+		}
+		return fmt.Errorf("export: %w", err)
+	}
+
+	if outputPath == repository.Stdout {
+		return nil
+	}
+	for _, path := range written {
+		fmt.Fprintf(out, "Successfully exported: %s\n", path)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/extuml/extuml/pkg/config"
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/repository"
+	"github.com/spf13/cobra"
+)
+
+// InitFmtCmd creates the 'fmt' subcommand which rewrites .extuml files in the
+// canonical layout, like gofmt.
+func InitFmtCmd() *cobra.Command {
+	var (
+		check      bool
+		diff       bool
+		diagFormat string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "fmt [path ...]",
+		Short: "Format .extuml files in the canonical layout",
+		Long: "Rewrite .extuml files in the canonical layout: two-space indentation, single\n" +
+			"blank lines, class members ordered attributes before operations, and uniform\n" +
+			"spacing in declarations. Comments are kept.\n\n" +
+			"Directories are searched for .extuml files. Without a path, or with -, the\n" +
+			"source is read from stdin and written to stdout.\n\n" +
+			"--check lists the files that are not formatted and fails if there are any;\n" +
+			"--diff prints the changes as a unified diff. Neither rewrites a file.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{repository.Stdout}
+			}
+
+			if diagFormat != DiagnosticsText && diagFormat != DiagnosticsJSON {
+				return fmt.Errorf("unknown diagnostics format %q (expected text or json)", diagFormat)
+			}
+			for _, path := range args {
+				if diagFormat == DiagnosticsJSON && path == repository.Stdout && !check && !diff {
+					return fmt.Errorf("--diagnostics-format json cannot be combined with formatting stdin")
+				}
+			}
+
+			// Arguments are valid; failures from here on are not usage errors
+			cmd.SilenceUsage = true
+
+			return RunFmt(args, check, diff, diagFormat)
+		},
+	}
+
+	cmd.Flags().BoolVar(&check, "check", false, "list files that are not formatted and exit non-zero if there are any")
+	cmd.Flags().BoolVar(&diff, "diff", false, "print the changes as a unified diff instead of rewriting files")
+	cmd.Flags().StringVar(&diagFormat, "diagnostics-format", DiagnosticsText, "diagnostics output format: text (stderr) or json (stdout)")
+
+	return cmd
+}
+
+// RunFmt executes the fmt command logic. Files are rewritten unless check
+// or diff is set; diagnostics are reported as by RunGenerate.
+func RunFmt(paths []string, check, diff bool, diagFormat string) error {
+	cfg := config.NewConfig()
+
+	write := !check && !diff
+	files, diags, err := cfg.FmtCtrl.Format(paths, write)
+
+	out, werr := reportDiagnostics(diags, diagFormat)
+	if werr != nil {
+		return werr
+	}
+
+	unformatted := 0
+	for _, file := range files {
+		if !file.Changed() {
+			continue
+		}
+		unformatted++
+		if check {
+			fmt.Fprintln(out, file.Path)
+		}
+		if diff {
+			out.Write(file.Diff())
+		}
+		if write && file.Path != repository.Stdout {
+			fmt.Fprintf(out, "Formatted: %s\n", file.Path)
+		}
+	}
+
+	if err != nil {
+		var list diagnostic.List
+		if errors.As(err, &list) {
+			return fmt.Errorf("fmt: %s", list.Summary())
+		}
+		return fmt.Errorf("fmt: %w", err)
+	}
+	if check && unformatted > 0 {
+		return fmt.Errorf("fmt: %d file(s) not formatted", unformatted)
+	}
+	return nil
+}
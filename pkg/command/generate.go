@@ -1,12 +1,23 @@
 package command
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/extuml/extuml/pkg/config"
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/usecase"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Diagnostics output formats
+const (
+	DiagnosticsText = "text"
+	DiagnosticsJSON = "json"
 )
 
 // InitGenerateCmd creates the 'generate' subcommand which parses .extuml and
@@ -16,12 +27,23 @@ func InitGenerateCmd() *cobra.Command {
 		extumlPath string
 		outputPath string
 		htmlOutput string
+		diagFormat string
+		inputFmt   string
+		layout     = usecase.DefaultLayoutOptions()
 	)
 
 	cmd := &cobra.Command{
 		Use:   "generate",
 		Short: "Generate a .extuml diagram to .gl (glTF JSON)",
-		Long:  "Generate a .extuml 3D UML diagram into a glTF 2.0 .gl JSON file.",
+		Long: "Generate a .extuml 3D UML diagram into a glTF 2.0 .gl JSON file.\n\n" +
+			"Mermaid class diagrams (.mmd, .mermaid) and the ```mermaid classDiagram blocks of\n" +
+			"Markdown files (.md) are imported as well, as are PlantUML class diagrams (.puml)\n" +
+			"and documents in the JSON form of the model (.json, .yaml), which are validated\n" +
+			"against its JSON Schema.\n" +
+			"An input with several diagrams yields one numbered output per diagram\n" +
+			"(out-1.gl, out-2.gl, ...).\n\n" +
+			"Classifiers of class diagrams are placed by a force-directed layout that is\n" +
+			"deterministic for a given --layout-seed; --layout grid restores the rows.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if extumlPath == "" && len(args) > 0 {
 				extumlPath = args[0]
@@ -35,7 +57,23 @@ func InitGenerateCmd() *cobra.Command {
 				return fmt.Errorf("output path is required (--output)")
 			}
 
-			if err := RunGenerate(extumlPath, outputPath, htmlOutput); err != nil {
+			if diagFormat != DiagnosticsText && diagFormat != DiagnosticsJSON {
+				return fmt.Errorf("unknown diagnostics format %q (expected text or json)", diagFormat)
+			}
+
+			format, err := inputFormat(inputFmt)
+			if err != nil {
+				return err
+			}
+
+			if err := layout.Validate(); err != nil {
+				return err
+			}
+
+			// Arguments are valid; failures from here on are not usage errors
+			cmd.SilenceUsage = true
+
+			if err := RunGenerate(extumlPath, outputPath, htmlOutput, diagFormat, format, layout); err != nil {
 				return err
 			}
 
@@ -43,15 +81,45 @@ func InitGenerateCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&extumlPath, "extuml", "e", "", "path to .extuml DSL file")
+	cmd.Flags().StringVarP(&extumlPath, "extuml", "e", "", "path to .extuml DSL file (or Mermaid, Markdown, PlantUML, JSON or YAML input)")
 	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "output .gl (glTF JSON) file path")
 	cmd.Flags().StringVar(&htmlOutput, "html-output", "", "output HTML viewer file path (optional)")
+	cmd.Flags().StringVar(&inputFmt, "input-format", "auto", "input format: auto (by file extension), extuml, mermaid, plantuml, json or yaml")
+	cmd.Flags().StringVar(&diagFormat, "diagnostics-format", DiagnosticsText, "diagnostics output format: text (stderr) or json (stdout)")
+	cmd.Flags().StringVar(&layout.Algorithm, "layout", layout.Algorithm, "class diagram layout: force or grid")
+	cmd.Flags().Int64Var(&layout.Seed, "layout-seed", layout.Seed, "seed of the force layout; equal seeds give equal layouts")
+	cmd.Flags().IntVar(&layout.Iterations, "layout-iterations", layout.Iterations, "maximum number of force layout iterations (at least 1)")
+	cmd.Flags().Float64Var(&layout.Energy, "layout-energy", layout.Energy, "energy below which the force layout stops early; 0 runs every iteration")
+
+	// --from is the original name of --input-format
+	cmd.Flags().SetNormalizeFunc(fromAlias)
 
 	return cmd
 }
 
-// RunGenerate executes the generate command logic
-func RunGenerate(extumlPath, outputPath, htmlOutput string) error {
+// inputFormat converts the --input-format flag to a usecase input format
+func inputFormat(name string) (string, error) {
+	switch name {
+	case "auto":
+		return usecase.FormatAuto, nil
+	case usecase.FormatExtuml, usecase.FormatMermaid, usecase.FormatPlantUML, usecase.FormatJSON, usecase.FormatYAML:
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown input format %q (expected auto, extuml, mermaid, plantuml, json or yaml)", name)
+}
+
+// fromAlias normalises the --from flag to --input-format
+func fromAlias(f *pflag.FlagSet, name string) pflag.NormalizedName {
+	if name == "from" {
+		name = "input-format"
+	}
+	return pflag.NormalizedName(name)
+}
+
+// RunGenerate executes the generate command logic. Diagnostics are written to
+// stderr as text, or to stdout as JSON in which case progress messages move to
+// stderr so that stdout stays machine-readable.
+func RunGenerate(extumlPath, outputPath, htmlOutput, diagFormat, format string, layout usecase.LayoutOptions) error {
 	// Create config
 	cfg := config.NewConfig()
 
@@ -61,13 +129,39 @@ func RunGenerate(extumlPath, outputPath, htmlOutput string) error {
 	}
 
 	// Execute generation via controller
-	if err := cfg.GenerateCtrl.Generate(extumlPath, outputPath, htmlOutput); err != nil {
+	written, diags, err := cfg.GenerateCtrl.Generate(extumlPath, outputPath, htmlOutput, format, layout)
+
+	out, werr := reportDiagnostics(diags, diagFormat)
+	if werr != nil {
+		return werr
+	}
+
+	if err != nil {
+		// The diagnostics have been printed above, so only summarise them
+		var list diagnostic.List
+		if errors.As(err, &list) {
+			return fmt.Errorf("generate: %s: %s", extumlPath, list.Summary())
+		}
 		return fmt.Errorf("generate: %w", err)
 	}
 
-	fmt.Printf("Successfully generated: %s\n", outputPath)
-	if htmlOutput != "" {
-		fmt.Printf("Successfully generated: %s\n", htmlOutput)
+	for _, path := range written {
+		fmt.Fprintf(out, "Successfully generated: %s\n", path)
 	}
 	return nil
 }
+
+// reportDiagnostics writes diags in the given format and returns where
+// progress messages go: stdout, unless it carries the JSON diagnostics
+func reportDiagnostics(diags diagnostic.List, diagFormat string) (io.Writer, error) {
+	if diagFormat == DiagnosticsJSON {
+		if err := diagnostic.WriteJSON(os.Stdout, diags); err != nil {
+			return nil, fmt.Errorf("write diagnostics: %w", err)
+		}
+		return os.Stderr, nil
+	}
+	if err := diagnostic.WriteText(os.Stderr, diags); err != nil {
+		return nil, fmt.Errorf("write diagnostics: %w", err)
+	}
+	return os.Stdout, nil
+}
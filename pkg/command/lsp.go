@@ -0,0 +1,40 @@
+package command
+
+import (
+	"os"
+
+	"github.com/extuml/extuml/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// InitLSPCmd creates the 'lsp' subcommand which runs a Language Server
+// Protocol server for .extuml files over stdio.
+func InitLSPCmd() *cobra.Command {
+	var stdio bool
+
+	cmd := &cobra.Command{
+		Use:   "lsp",
+		Short: "Run a language server for .extuml files over stdio",
+		Long: "Run a Language Server Protocol server for .extuml files, speaking JSON-RPC on\n" +
+			"stdin and stdout. It reports diagnostics as you type and provides completion,\n" +
+			"hover, go to definition, find references, document symbols, rename and\n" +
+			"formatting. Point your editor's LSP client at `extuml lsp`.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return RunLSP()
+		},
+	}
+
+	// Editors commonly pass --stdio; it is the only transport
+	cmd.Flags().BoolVar(&stdio, "stdio", true, "communicate over stdin and stdout")
+
+	return cmd
+}
+
+// RunLSP serves the language server on stdin and stdout until the editor
+// exits it
+func RunLSP() error {
+	cfg := config.NewConfig()
+	return cfg.LSPCtrl.Serve(os.Stdin, os.Stdout)
+}
@@ -11,28 +11,55 @@ import (
 // Config holds all dependencies for the application
 type Config struct {
 	ExtumlRepo   repository.ExtumlRepository
+	MermaidRepo  repository.MermaidRepository
+	PlantUMLRepo repository.PlantUMLRepository
+	DocumentRepo repository.DocumentRepository
 	GLTFRepo     repository.GLTFRepository
 	HTMLRepo     repository.HTMLRepository
+	SourceRepo   repository.SourceRepository
 	GenerateUC   usecase.GenerateUsecase
 	GenerateCtrl controller.GenerateController
+	ExportUC     usecase.ExportUsecase
+	ExportCtrl   controller.ExportController
+	FmtUC        usecase.FmtUsecase
+	FmtCtrl      controller.FmtController
+	LSPCtrl      controller.LSPController
 }
 
 // NewConfig creates and wires all dependencies
 func NewConfig() *Config {
 	extumlRepo := repository.NewExtumlRepository()
+	mermaidRepo := repository.NewMermaidRepository()
+	plantUMLRepo := repository.NewPlantUMLRepository()
+	documentRepo := repository.NewDocumentRepository()
 	gltfRepo := repository.NewGLTFRepository()
 	htmlRepo, err := repository.NewHTMLRepository()
 	if err != nil {
 		log.Fatalf("failed to create HTML repository: %v", err)
 	}
-	generateUC := usecase.NewGenerateUsecase(extumlRepo, gltfRepo, htmlRepo)
+	generateUC := usecase.NewGenerateUsecase(extumlRepo, mermaidRepo, plantUMLRepo, documentRepo, gltfRepo, htmlRepo)
 	generateCtrl := controller.NewGenerateController(generateUC)
+	sourceRepo := repository.NewSourceRepository()
+	exportUC := usecase.NewExportUsecase(extumlRepo, mermaidRepo, plantUMLRepo, documentRepo, sourceRepo)
+	exportCtrl := controller.NewExportController(exportUC)
+	fmtUC := usecase.NewFmtUsecase(sourceRepo)
+	fmtCtrl := controller.NewFmtController(fmtUC)
+	lspCtrl := controller.NewLSPController(sourceRepo)
 
 	return &Config{
 		ExtumlRepo:   extumlRepo,
+		MermaidRepo:  mermaidRepo,
+		PlantUMLRepo: plantUMLRepo,
+		DocumentRepo: documentRepo,
 		GLTFRepo:     gltfRepo,
 		HTMLRepo:     htmlRepo,
+		SourceRepo:   sourceRepo,
 		GenerateUC:   generateUC,
 		GenerateCtrl: generateCtrl,
+		ExportUC:     exportUC,
+		ExportCtrl:   exportCtrl,
+		FmtUC:        fmtUC,
+		FmtCtrl:      fmtCtrl,
+		LSPCtrl:      lspCtrl,
 	}
 }
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/usecase"
+)
+
+// ExportController defines interface for export command handling
+type ExportController interface {
+	Export(inputPath, outputPath, inputFormat, outputFormat string) ([]string, diagnostic.List, error)
+}
+
+type exportControllerImpl struct {
+	usecase usecase.ExportUsecase
+}
+
+// NewExportController creates a new export controller
+func NewExportController(uc usecase.ExportUsecase) ExportController {
+	return &exportControllerImpl{
+		usecase: uc,
+	}
+}
+
+func (c *exportControllerImpl) Export(inputPath, outputPath, inputFormat, outputFormat string) ([]string, diagnostic.List, error) {
+	if inputPath == "" || outputPath == "" {
+		return nil, nil, fmt.Errorf("input and output paths are required")
+	}
+
+	written, diags, err := c.usecase.Execute(inputPath, outputPath, inputFormat, outputFormat)
+	if err != nil {
+		return written, diags, fmt.Errorf("export failed: %w", err)
+	}
+
+	return written, diags, nil
+}
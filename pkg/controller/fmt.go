@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/usecase"
+)
+
+// FmtController defines interface for fmt command handling
+type FmtController interface {
+	Format(paths []string, write bool) ([]usecase.FormattedFile, diagnostic.List, error)
+}
+
+type fmtControllerImpl struct {
+	usecase usecase.FmtUsecase
+}
+
+// NewFmtController creates a new fmt controller
+func NewFmtController(uc usecase.FmtUsecase) FmtController {
+	return &fmtControllerImpl{
+		usecase: uc,
+	}
+}
+
+func (c *fmtControllerImpl) Format(paths []string, write bool) ([]usecase.FormattedFile, diagnostic.List, error) {
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("at least one path is required")
+	}
+
+	files, diags, err := c.usecase.Execute(paths, write)
+	if err != nil {
+		return files, diags, fmt.Errorf("fmt failed: %w", err)
+	}
+
+	return files, diags, nil
+}
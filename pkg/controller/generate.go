@@ -3,12 +3,13 @@ package controller
 import (
 	"fmt"
 
+	"github.com/extuml/extuml/pkg/diagnostic"
 	"github.com/extuml/extuml/pkg/usecase"
 )
 
 // GenerateController defines interface for generate command handling
 type GenerateController interface {
-	Generate(extumlPath, outputPath, htmlOutput string) error
+	Generate(inputPath, outputPath, htmlOutput, format string, layout usecase.LayoutOptions) ([]string, diagnostic.List, error)
 }
 
 type generateControllerImpl struct {
@@ -22,14 +23,15 @@ func NewGenerateController(uc usecase.GenerateUsecase) GenerateController {
 	}
 }
 
-func (c *generateControllerImpl) Generate(extumlPath, outputPath, htmlOutput string) error {
-	if extumlPath == "" || outputPath == "" {
-		return fmt.Errorf("extuml and output paths are required")
+func (c *generateControllerImpl) Generate(inputPath, outputPath, htmlOutput, format string, layout usecase.LayoutOptions) ([]string, diagnostic.List, error) {
+	if inputPath == "" || outputPath == "" {
+		return nil, nil, fmt.Errorf("input and output paths are required")
 	}
 
-	if err := c.usecase.Execute(extumlPath, outputPath, htmlOutput); err != nil {
-		return fmt.Errorf("generate failed: %w", err)
+	written, diags, err := c.usecase.Execute(inputPath, outputPath, htmlOutput, format, layout)
+	if err != nil {
+		return written, diags, fmt.Errorf("generate failed: %w", err)
 	}
 
-	return nil
+	return written, diags, nil
 }
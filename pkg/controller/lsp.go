@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/extuml/extuml/pkg/lsp"
+	"github.com/extuml/extuml/pkg/repository"
+)
+
+// LSPController defines interface for lsp command handling
+type LSPController interface {
+	Serve(in io.Reader, out io.Writer) error
+}
+
+type lspControllerImpl struct {
+	sourceRepo repository.SourceRepository
+}
+
+// NewLSPController creates a new language server controller. Included files
+// that are not open in the editor are read through sourceRepo.
+func NewLSPController(sourceRepo repository.SourceRepository) LSPController {
+	return &lspControllerImpl{
+		sourceRepo: sourceRepo,
+	}
+}
+
+func (c *lspControllerImpl) Serve(in io.Reader, out io.Writer) error {
+	if err := lsp.NewServer(c.sourceRepo.Read).Serve(in, out); err != nil {
+		return fmt.Errorf("language server failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package diagnostic
+
+// Severity is the severity of a diagnostic
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Syntax errors (E1xx)
+const (
+	CodeMissingHeader        = "E100"
+	CodeUnterminatedString   = "E101"
+	CodeUnterminatedBlock    = "E102"
+	CodeUnexpectedToken      = "E103"
+	CodeUnexpectedCloseBrace = "E104"
+	CodeExpected             = "E105"
+	CodeMemberOutsideBlock   = "E106"
+	CodeInvalidIdentifier    = "E107"
+	CodeInvalidDirective     = "E108"
+	CodeMalformedDocument    = "E109"
+	CodeUnknownDiagramType   = "E110"
+)
+
+// Semantic errors (E2xx)
+const (
+	CodeDuplicateElement       = "E200"
+	CodeIncludeCycle           = "E201"
+	CodeIncludeNotFound        = "E202"
+	CodeInvalidAnnotationValue = "E203"
+	CodeInvalidColor           = "E204"
+	CodeSchemaViolation        = "E205"
+	CodeIncludeDiagramType     = "E206"
+	CodeUnknownPackageChild    = "E207"
+	CodeDuplicatePackageChild  = "E208"
+	CodePackageCycle           = "E209"
+)
+
+// Warnings (W2xx)
+const (
+	CodeUnknownRelationshipTarget = "W200"
+	CodeUnknownNoteAnchor         = "W201"
+	CodeUnknownAnnotation         = "W202"
+	CodeDuplicateMember           = "W203"
+	CodeUnknownSetting            = "W204"
+	CodeUnknownPlacementTarget    = "W205"
+	CodeAmbiguousReference        = "W206"
+	CodeUnknownStyleProperty      = "W207"
+	CodeUnknownStyleClass         = "W208"
+	CodeUnknownStyleTarget        = "W209"
+	CodeUnsupportedStatement      = "W210"
+	CodeUnbalancedActivation      = "W211"
+)
+
+// Entry describes a diagnostic code
+type Entry struct {
+	Code     string
+	Severity Severity
+	Title    string
+}
+
+var catalogue = map[string]Entry{
+	CodeMissingHeader:        {CodeMissingHeader, SeverityError, "missing DSL header"},
+	CodeUnterminatedString:   {CodeUnterminatedString, SeverityError, "unterminated string"},
+	CodeUnterminatedBlock:    {CodeUnterminatedBlock, SeverityError, "unterminated block"},
+	CodeUnexpectedToken:      {CodeUnexpectedToken, SeverityError, "unexpected token"},
+	CodeUnexpectedCloseBrace: {CodeUnexpectedCloseBrace, SeverityError, "unmatched closing brace"},
+	CodeExpected:             {CodeExpected, SeverityError, "missing token"},
+	CodeMemberOutsideBlock:   {CodeMemberOutsideBlock, SeverityError, "member outside block"},
+	CodeInvalidIdentifier:    {CodeInvalidIdentifier, SeverityError, "invalid identifier"},
+	CodeInvalidDirective:     {CodeInvalidDirective, SeverityError, "invalid directive"},
+	CodeMalformedDocument:    {CodeMalformedDocument, SeverityError, "malformed JSON or YAML document"},
+	CodeUnknownDiagramType:   {CodeUnknownDiagramType, SeverityError, "unknown diagram type"},
+
+	CodeDuplicateElement:       {CodeDuplicateElement, SeverityError, "duplicate element"},
+	CodeIncludeCycle:           {CodeIncludeCycle, SeverityError, "include cycle"},
+	CodeIncludeNotFound:        {CodeIncludeNotFound, SeverityError, "included file not readable"},
+	CodeInvalidAnnotationValue: {CodeInvalidAnnotationValue, SeverityError, "invalid annotation value"},
+	CodeInvalidColor:           {CodeInvalidColor, SeverityError, "invalid colour"},
+	CodeSchemaViolation:        {CodeSchemaViolation, SeverityError, "document does not match the schema"},
+	CodeIncludeDiagramType:     {CodeIncludeDiagramType, SeverityError, "included diagram of another type"},
+	CodeUnknownPackageChild:    {CodeUnknownPackageChild, SeverityError, "unknown package child"},
+	CodeDuplicatePackageChild:  {CodeDuplicatePackageChild, SeverityError, "element in several packages"},
+	CodePackageCycle:           {CodePackageCycle, SeverityError, "package cycle"},
+
+	CodeUnknownRelationshipTarget: {CodeUnknownRelationshipTarget, SeverityWarning, "unknown relationship target"},
+	CodeUnknownNoteAnchor:         {CodeUnknownNoteAnchor, SeverityWarning, "unknown note anchor"},
+	CodeUnknownAnnotation:         {CodeUnknownAnnotation, SeverityWarning, "unknown annotation"},
+	CodeDuplicateMember:           {CodeDuplicateMember, SeverityWarning, "duplicate member"},
+	CodeUnknownSetting:            {CodeUnknownSetting, SeverityWarning, "unknown setting"},
+	CodeUnknownPlacementTarget:    {CodeUnknownPlacementTarget, SeverityWarning, "unknown placement target"},
+	CodeAmbiguousReference:        {CodeAmbiguousReference, SeverityWarning, "ambiguous reference"},
+	CodeUnknownStyleProperty:      {CodeUnknownStyleProperty, SeverityWarning, "unsupported style property"},
+	CodeUnknownStyleClass:         {CodeUnknownStyleClass, SeverityWarning, "unknown classDef"},
+	CodeUnknownStyleTarget:        {CodeUnknownStyleTarget, SeverityWarning, "unknown style target"},
+	CodeUnsupportedStatement:      {CodeUnsupportedStatement, SeverityWarning, "unsupported statement"},
+	CodeUnbalancedActivation:      {CodeUnbalancedActivation, SeverityWarning, "unbalanced activation"},
+}
+
+// Lookup returns the catalogue entry for code. Unknown codes are errors.
+func Lookup(code string) Entry {
+	if entry, ok := catalogue[code]; ok {
+		return entry
+	}
+	return Entry{Code: code, Severity: SeverityError}
+}
@@ -0,0 +1,135 @@
+package diagnostic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Position is a position in a source file. Line and Column are 1-based;
+// Column counts bytes. Offset is the 0-based byte offset.
+type Position struct {
+	File   string
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Span is the half-open source range [Start, End)
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// Diagnostic is a problem found in a source file
+type Diagnostic struct {
+	Code     string
+	Severity Severity
+	Message  string
+	Span     Span
+
+	// SourceLine is the text of the line the diagnostic starts on, if known
+	SourceLine string
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s %s: %s", d.Span.Start, d.Severity, d.Code, d.Message)
+}
+
+// List is a list of diagnostics. As an error it reports every entry.
+type List []*Diagnostic
+
+// Add appends a diagnostic whose severity comes from the catalogue entry for code
+func (l *List) Add(span Span, code, format string, args ...any) {
+	*l = append(*l, &Diagnostic{
+		Code:     code,
+		Severity: Lookup(code).Severity,
+		Message:  fmt.Sprintf(format, args...),
+		Span:     span,
+	})
+}
+
+func (l List) Error() string {
+	msgs := make([]string, len(l))
+	for i, d := range l {
+		msgs[i] = d.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Sort orders the list by file and position
+func (l List) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Span.Start, l[j].Span.Start
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// Count returns the number of errors and warnings in the list
+func (l List) Count() (errors, warnings int) {
+	for _, d := range l {
+		if d.Severity == SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	return errors, warnings
+}
+
+// Summary returns the counts in the list, e.g. "2 errors, 1 warning"
+func (l List) Summary() string {
+	errors, warnings := l.Count()
+	return fmt.Sprintf("%s, %s", plural(errors, "error"), plural(warnings, "warning"))
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// HasErrors reports whether any diagnostic is an error
+func (l List) HasErrors() bool {
+	errors, _ := l.Count()
+	return errors > 0
+}
+
+// Err returns the list as an error if it contains errors, or nil if it only
+// holds warnings
+func (l List) Err() error {
+	if !l.HasErrors() {
+		return nil
+	}
+	return l
+}
+
+// AttachSource fills in SourceLine for every diagnostic reported against file
+func (l List) AttachSource(file string, src []byte) {
+	var lines []string
+	for _, d := range l {
+		if d.Span.Start.File != file || d.SourceLine != "" {
+			continue
+		}
+		if lines == nil {
+			lines = strings.Split(string(src), "\n")
+		}
+		if n := d.Span.Start.Line; n >= 1 && n <= len(lines) {
+			d.SourceLine = strings.TrimRight(lines[n-1], "\r")
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package diagnostic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteText writes diagnostics in a compiler-style layout with the offending
+// source line and a caret under the reported span, followed by a summary:
+//
+//	model.extuml:3:1: error[E102]: class "Foo" is not closed (missing '}')
+//	  3 | class Foo {
+//	    | ^^^^^
+func WriteText(w io.Writer, list List) error {
+	for _, d := range list {
+		if _, err := fmt.Fprintf(w, "%s: %s[%s]: %s\n", d.Span.Start, d.Severity, d.Code, d.Message); err != nil {
+			return err
+		}
+		if d.SourceLine == "" {
+			continue
+		}
+
+		gutter := strconv.Itoa(d.Span.Start.Line)
+		pad := strings.Repeat(" ", len(gutter))
+		if _, err := fmt.Fprintf(w, "  %s | %s\n  %s | %s\n", gutter, d.SourceLine, pad, caret(d)); err != nil {
+			return err
+		}
+	}
+
+	if len(list) == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintln(w, list.Summary())
+	return err
+}
+
+// caret returns the marker line for a diagnostic, reusing the source line's
+// leading characters as padding so that tabs stay aligned
+func caret(d *Diagnostic) string {
+	col := d.Span.Start.Column - 1
+	if col > len(d.SourceLine) {
+		col = len(d.SourceLine)
+	}
+	var pad strings.Builder
+	for _, c := range d.SourceLine[:col] {
+		if c == '\t' {
+			pad.WriteByte('\t')
+		} else {
+			pad.WriteByte(' ')
+		}
+	}
+
+	width := 1
+	if d.Span.End.Line == d.Span.Start.Line && d.Span.End.Column > d.Span.Start.Column {
+		width = d.Span.End.Column - d.Span.Start.Column
+	}
+	return pad.String() + strings.Repeat("^", width)
+}
+
+// jsonDiagnostic is the machine-readable form of a Diagnostic
+type jsonDiagnostic struct {
+	Code      string   `json:"code"`
+	Severity  Severity `json:"severity"`
+	Title     string   `json:"title,omitempty"`
+	Message   string   `json:"message"`
+	File      string   `json:"file"`
+	Line      int      `json:"line"`
+	Column    int      `json:"column"`
+	EndLine   int      `json:"endLine"`
+	EndColumn int      `json:"endColumn"`
+	Source    string   `json:"source,omitempty"`
+}
+
+type jsonReport struct {
+	Diagnostics []jsonDiagnostic `json:"diagnostics"`
+	Errors      int              `json:"errors"`
+	Warnings    int              `json:"warnings"`
+}
+
+// WriteJSON writes diagnostics as a single JSON document suitable for CI
+// annotations
+func WriteJSON(w io.Writer, list List) error {
+	report := jsonReport{Diagnostics: []jsonDiagnostic{}}
+	for _, d := range list {
+		end := d.Span.End
+		if end.Line == 0 {
+			end = d.Span.Start
+		}
+		report.Diagnostics = append(report.Diagnostics, jsonDiagnostic{
+			Code:      d.Code,
+			Severity:  d.Severity,
+			Title:     Lookup(d.Code).Title,
+			Message:   d.Message,
+			File:      d.Span.Start.File,
+			Line:      d.Span.Start.Line,
+			Column:    d.Span.Start.Column,
+			EndLine:   end.Line,
+			EndColumn: end.Column,
+			Source:    d.SourceLine,
+		})
+	}
+	report.Errors, report.Warnings = list.Count()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,215 @@
+package exporter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// DSL writes doc as canonical .extuml source. Lowering the source yields doc
+// again, except that notes and relationships are numbered note_1, rel_1, ...
+// as the DSL numbers them and that a package lists only the elements whose
+// IDs it qualifies as children.
+func DSL(doc *extuml.Document) []byte {
+	w := &writer{unit: "  "}
+	writeHeader(w, doc)
+	w.line("extuml classDiagram3D")
+
+	if names := classDefNames(doc); len(names) > 0 {
+		w.line("")
+		for _, name := range names {
+			w.line("classDef %s %s", name, styleProps(doc.ClassDefs[name]))
+		}
+	}
+
+	// Bodies and package blocks are set apart by blank lines at the top level
+	spaced := true
+	separate := func(multiline bool) {
+		if w.indent == 0 && (spaced || multiline) {
+			w.line("")
+		}
+		spaced = multiline
+	}
+	var styled []classifier
+	walk(declarations(doc),
+		func(p *decl) {
+			separate(true)
+			w.line("package %s {", p.local())
+			w.indent++
+		},
+		func(p *decl) {
+			w.indent--
+			w.line("}")
+			spaced = true
+		},
+		func(d *decl) {
+			if d.kind == kindNote {
+				separate(false)
+				w.line("%s", dslNote(doc.Elements.Notes[d.index]))
+				return
+			}
+			c := classifierOf(doc.Elements, d)
+			body := dslBody(c)
+			separate(len(body) > 0)
+			w.block(dslHead(c), body)
+			if c.style != nil && *c.style != (extuml.Style{}) {
+				styled = append(styled, c)
+			}
+		})
+
+	if doc.Elements != nil && len(doc.Elements.Relationships) > 0 {
+		w.line("")
+		for _, rel := range doc.Elements.Relationships {
+			if line, ok := relationship(rel, func(id string) string { return id }, parser.Quote); ok {
+				w.line("%s", line)
+			}
+		}
+	}
+	if len(styled) > 0 {
+		w.line("")
+		for _, c := range styled {
+			w.line("style %s %s", c.id, styleProps(*c.style))
+		}
+	}
+	return w.buf.Bytes()
+}
+
+// dslHead formats the declaration line of a classifier, such as
+// `abstract class Repository<T>:::hot as "Store" <<Entity>>`
+func dslHead(c classifier) string {
+	var b strings.Builder
+	if c.abstract {
+		b.WriteString("abstract ")
+	}
+	if c.final {
+		b.WriteString("final ")
+	}
+	b.WriteString(c.kind + " " + c.local())
+	if len(c.typeParams) > 0 {
+		b.WriteString("<" + strings.Join(c.typeParams, ", ") + ">")
+	}
+	for _, class := range c.styleClasses {
+		b.WriteString(":::" + class)
+	}
+	if c.name != c.local() && c.name != "" {
+		b.WriteString(" as " + parser.Quote(c.name))
+	}
+	if len(c.stereotypes) > 0 {
+		b.WriteString(" <<" + strings.Join(c.stereotypes, ", ") + ">>")
+	}
+	return b.String()
+}
+
+// dslBody returns the member and annotation lines of a classifier
+func dslBody(c classifier) []string {
+	var lines []string
+	for _, attr := range c.attributes {
+		lines = append(lines, dslAttribute(attr))
+	}
+	for _, op := range c.operations {
+		lines = append(lines, dslOperation(op, c.kind == kindInterface))
+	}
+	lines = append(lines, c.literals...)
+
+	if c.url != "" {
+		lines = append(lines, "@url: "+c.url)
+	}
+	if p := c.placement; p != nil {
+		if p.Position != nil {
+			lines = append(lines, fmt.Sprintf("@pos: %s, %s, %s", number(p.Position[0]), number(p.Position[1]), number(p.Position[2])))
+		}
+		if p.Layer != nil {
+			lines = append(lines, fmt.Sprintf("@layer: %d", *p.Layer))
+		}
+		if p.Near != "" {
+			lines = append(lines, "@near: "+p.Near)
+		}
+	}
+	return lines
+}
+
+// dslAttribute formats `+name: Type [0..*]$ = default`. A type ending in a
+// sized array such as `cache[0..*]` would read back as a multiplicity when
+// it ends the declaration, so it is then written in Mermaid form
+// (`+cache[0..*] name`).
+func dslAttribute(attr extuml.Attribute) string {
+	s := extuml.VisibilitySymbol(attr.Visibility)
+	sized := strings.HasSuffix(attr.Type, "]") && !strings.HasSuffix(attr.Type, "[]")
+	switch {
+	case sized && attr.Multiplicity == "":
+		s += attr.Type + " " + attr.Name
+	case attr.Type != "":
+		s += attr.Name + ": " + attr.Type
+	default:
+		s += attr.Name
+	}
+	if attr.Multiplicity != "" {
+		s += " [" + attr.Multiplicity + "]"
+	}
+	if attr.Static {
+		s += "$"
+	}
+	if attr.Default != "" {
+		s += " = " + attr.Default
+	}
+	return s
+}
+
+// dslOperation formats `+name(in p: Type = default)$*: ReturnType`. An
+// interface member written without parentheses has no parameter list and is
+// written back the same way.
+func dslOperation(op extuml.Operation, bare bool) string {
+	s := extuml.VisibilitySymbol(op.Visibility)
+	if bare && op.Parameters == nil && op.ReturnType == "" {
+		if op.Static {
+			s += "static "
+		}
+		if op.Abstract {
+			s += "abstract "
+		}
+		return s + op.Name
+	}
+
+	params := make([]string, len(op.Parameters))
+	for i, param := range op.Parameters {
+		p := param.Name
+		if param.Type != "" {
+			p += ": " + param.Type
+		}
+		if param.Direction != "" {
+			p = param.Direction + " " + p
+		}
+		if param.Default != "" {
+			p += " = " + param.Default
+		}
+		params[i] = p
+	}
+	s += op.Name + "(" + strings.Join(params, ", ") + ")"
+	if op.Static {
+		s += "$"
+	}
+	if op.Abstract {
+		s += "*"
+	}
+	if op.ReturnType != "" {
+		s += ": " + op.ReturnType
+	}
+	return s
+}
+
+// dslNote formats `note for Anchor "text"`
+func dslNote(note extuml.Note) string {
+	text := noteText(parser.Quote(note.Text))
+	if note.Anchor == "" {
+		return "note " + text
+	}
+	return fmt.Sprintf("note for %s %s", note.Anchor, text)
+}
+
+// number formats a coordinate so that it parses back to the same value
+func number(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,211 @@
+// Package exporter serialises an extuml.Document as diagram source: the
+// .extuml DSL, a Mermaid classDiagram or a PlantUML class diagram.
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// classifier is the common view of a class, interface or enum
+type classifier struct {
+	*decl
+	name         string
+	url          string
+	stereotypes  []string
+	abstract     bool
+	final        bool
+	typeParams   []string
+	attributes   []extuml.Attribute
+	operations   []extuml.Operation
+	literals     []string
+	placement    *extuml.Placement
+	styleClasses []string
+	style        *extuml.Style
+}
+
+// classifierOf returns the classifier declared by d
+func classifierOf(e *extuml.Elements, d *decl) classifier {
+	switch d.kind {
+	case kindInterface:
+		c := e.Interfaces[d.index]
+		return classifier{decl: d, name: c.Name, url: c.URL, stereotypes: c.Stereotypes, typeParams: c.TypeParameters,
+			operations: c.Operations, placement: c.Placement, styleClasses: c.StyleClasses, style: c.Style}
+	case kindEnum:
+		c := e.Enums[d.index]
+		return classifier{decl: d, name: c.Name, url: c.URL, stereotypes: c.Stereotypes,
+			literals: c.Literals, placement: c.Placement, styleClasses: c.StyleClasses, style: c.Style}
+	default:
+		c := e.Classes[d.index]
+		return classifier{decl: d, name: c.Name, url: c.URL, stereotypes: c.Stereotypes, abstract: c.Abstract, final: c.Final,
+			typeParams: c.TypeParameters, attributes: c.Attributes, operations: c.Operations, placement: c.Placement,
+			styleClasses: c.StyleClasses, style: c.Style}
+	}
+}
+
+// shortName returns the last segment of a qualified ID
+func shortName(id string) string {
+	return id[strings.LastIndex(id, ".")+1:]
+}
+
+// classifierNames returns the function naming classifiers in notations
+// where names are not qualified by their package: the short name where it is
+// unique and qualified(id) otherwise. Anything else keeps its ID.
+func classifierNames(order []*decl, qualified func(id string) string) func(id string) string {
+	count := map[string]int{}
+	for _, d := range order {
+		if d.kind != kindPackage && d.kind != kindNote {
+			count[shortName(d.id)]++
+		}
+	}
+	names := map[string]string{}
+	for _, d := range order {
+		if d.kind == kindPackage || d.kind == kindNote {
+			continue
+		}
+		if short := shortName(d.id); count[short] == 1 {
+			names[d.id] = short
+		} else {
+			names[d.id] = qualified(d.id)
+		}
+	}
+	return func(id string) string {
+		if name, ok := names[id]; ok {
+			return name
+		}
+		return id
+	}
+}
+
+// arrow is how a relationship kind is written. The decorated end of a
+// relationship is To, so an arrow decorating its left-hand side is written
+// from To to From.
+type arrow struct {
+	operator      string
+	decoratesLeft bool
+}
+
+// arrows follow the orientation the Mermaid documentation uses, which all
+// three notations accept
+var arrows = map[string]arrow{
+	extuml.RelInheritance: {"<|--", true},
+	extuml.RelComposition: {"*--", true},
+	extuml.RelAggregation: {"o--", true},
+	extuml.RelRealization: {"..|>", false},
+	extuml.RelAssociation: {"-->", false},
+	extuml.RelDependency:  {"..>", false},
+	extuml.RelLink:        {"--", false},
+	extuml.RelDashedLink:  {"..", false},
+}
+
+// relationship formats rel with name giving the name each endpoint is
+// written as and quote the quoted form of a multiplicity; ok is false for
+// an unknown relationship kind
+func relationship(rel extuml.Relationship, name, quote func(string) string) (line string, ok bool) {
+	a, ok := arrows[rel.Type]
+	if !ok {
+		return "", false
+	}
+	left, right := name(rel.From), name(rel.To)
+	leftMult, rightMult := rel.FromMultiplicity, rel.ToMultiplicity
+	if a.decoratesLeft {
+		left, right = right, left
+		leftMult, rightMult = rightMult, leftMult
+	}
+
+	var b strings.Builder
+	b.WriteString(left)
+	if leftMult != "" {
+		b.WriteString(" " + quote(leftMult))
+	}
+	b.WriteString(" " + a.operator)
+	if rightMult != "" {
+		b.WriteString(" " + quote(rightMult))
+	}
+	b.WriteString(" " + right)
+	if rel.Label != "" {
+		b.WriteString(" : " + rel.Label)
+	}
+	return b.String(), true
+}
+
+// styleProps formats the colours of s as `fill:#f66,stroke:#900`
+func styleProps(s extuml.Style) string {
+	var props []string
+	for _, prop := range []struct{ name, value string }{
+		{"fill", s.Fill}, {"stroke", s.Stroke}, {"color", s.Color},
+	} {
+		if prop.value != "" {
+			props = append(props, prop.name+":"+prop.value)
+		}
+	}
+	return strings.Join(props, ",")
+}
+
+// classDefNames returns the names of the classDefs of doc that set a
+// colour, sorted
+func classDefNames(doc *extuml.Document) []string {
+	var names []string
+	for name, style := range doc.ClassDefs {
+		if style != (extuml.Style{}) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// noteText writes the line breaks of a note as `\n`, which all three
+// notations expand
+func noteText(text string) string {
+	return strings.ReplaceAll(text, "\n", `\n`)
+}
+
+// plainQuote quotes s for PlantUML, which has no escape for a quote inside
+// a string
+func plainQuote(s string) string {
+	return `"` + s + `"`
+}
+
+// mermaidQuote quotes s for Mermaid, writing the quotes inside it as the
+// `#quot;` entity
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
+
+// writer accumulates indented lines of source
+type writer struct {
+	buf    bytes.Buffer
+	unit   string // one level of indentation
+	indent int
+}
+
+// line writes one line at the current indentation; an empty format writes
+// a blank line
+func (w *writer) line(format string, args ...any) {
+	if format != "" {
+		w.buf.WriteString(strings.Repeat(w.unit, w.indent))
+		fmt.Fprintf(&w.buf, format, args...)
+	}
+	w.buf.WriteByte('\n')
+}
+
+// block writes head followed by lines indented in braces, or head alone if
+// there are no lines
+func (w *writer) block(head string, lines []string) {
+	if len(lines) == 0 {
+		w.line("%s", head)
+		return
+	}
+	w.line("%s {", head)
+	w.indent++
+	for _, l := range lines {
+		w.line("%s", l)
+	}
+	w.indent--
+	w.line("}")
+}
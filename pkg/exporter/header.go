@@ -0,0 +1,109 @@
+package exporter
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// writeHeader writes the front matter holding the diagram metadata and
+// config, both shared by the DSL and Mermaid. A config that would not read
+// back the same from front matter, because it holds lists or strings that
+// look like numbers, is written as an `%%{ init: ... }%%` directive instead.
+func writeHeader(w *writer, doc *extuml.Document) {
+	var lines []string
+	if m := doc.Meta; m != nil {
+		for _, entry := range []struct{ key, value string }{
+			{"title", m.Title}, {"author", m.Author}, {"id", m.ID},
+		} {
+			if entry.value != "" {
+				lines = append(lines, entry.key+": "+frontMatterValue(entry.value))
+			}
+		}
+	}
+
+	directive := ""
+	if len(doc.Config) > 0 {
+		if config, ok := frontMatterConfig(doc.Config, "  "); ok {
+			lines = append(lines, "config:")
+			lines = append(lines, config...)
+		} else if data, err := json.Marshal(doc.Config); err == nil {
+			directive = "%%{init: " + string(data) + "}%%"
+		}
+	}
+
+	if len(lines) > 0 {
+		w.line("---")
+		for _, l := range lines {
+			w.line("%s", l)
+		}
+		w.line("---")
+	}
+	if directive != "" {
+		w.line("%s", directive)
+	}
+}
+
+// frontMatterConfig returns the nested `key: value` lines of config, or
+// false if a value would read back as something else
+func frontMatterConfig(config map[string]any, indent string) ([]string, bool) {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var lines []string
+	for _, key := range keys {
+		if key == "" || key != strings.TrimSpace(key) || strings.ContainsAny(key, ".:#") {
+			return nil, false
+		}
+		switch value := config[key].(type) {
+		case map[string]any:
+			nested, ok := frontMatterConfig(value, indent+"  ")
+			if !ok || len(nested) == 0 {
+				return nil, false
+			}
+			lines = append(lines, indent+key+":")
+			lines = append(lines, nested...)
+		case string:
+			if looksScalar(value) {
+				return nil, false
+			}
+			lines = append(lines, indent+key+": "+frontMatterValue(value))
+		case bool:
+			lines = append(lines, indent+key+": "+strconv.FormatBool(value))
+		case float64:
+			if math.IsInf(value, 0) || math.IsNaN(value) {
+				return nil, false
+			}
+			lines = append(lines, indent+key+": "+strconv.FormatFloat(value, 'g', -1, 64))
+		default:
+			return nil, false
+		}
+	}
+	return lines, true
+}
+
+// looksScalar reports whether the front matter reads s as a bool or number
+func looksScalar(s string) bool {
+	if s == "true" || s == "false" {
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// frontMatterValue quotes s if the front matter would otherwise trim it or
+// strip quotes from it
+func frontMatterValue(s string) string {
+	quoted := len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0]
+	if s == "" || s != strings.TrimSpace(s) || quoted {
+		return `"` + s + `"`
+	}
+	return s
+}
@@ -0,0 +1,189 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// Mermaid writes doc as a Mermaid classDiagram for 2D viewing, for example in
+// GitHub Markdown. Mermaid has no nested namespaces, placement or display
+// names for packages, so classifiers are grouped by their innermost package
+// and placement is dropped. Classes are named by their short name where it is
+// unique and by their ID with `_` for `.` otherwise.
+func Mermaid(doc *extuml.Document) []byte {
+	w := &writer{unit: "    "}
+	writeHeader(w, doc)
+	w.line("classDiagram")
+
+	e := doc.Elements
+	if e == nil {
+		e = &extuml.Elements{}
+	}
+	order := declarations(doc)
+	name := classifierNames(order, mermaidIdent)
+
+	// Namespaces cannot be reopened, so the classifiers of each are written
+	// together where the first of them is declared
+	var namespaces []*decl
+	members := map[*decl][]classifier{}
+	var notes []extuml.Note
+	for _, d := range order {
+		switch d.kind {
+		case kindPackage:
+		case kindNote:
+			notes = append(notes, e.Notes[d.index])
+		default:
+			if _, seen := members[d.parent]; !seen {
+				namespaces = append(namespaces, d.parent)
+			}
+			members[d.parent] = append(members[d.parent], classifierOf(e, d))
+		}
+	}
+	var classifiers []classifier
+	for _, ns := range namespaces {
+		if ns != nil {
+			w.line("namespace %s {", mermaidIdent(ns.id))
+			w.indent++
+		}
+		for _, c := range members[ns] {
+			w.block(mermaidHead(c, name(c.id)), mermaidBody(c))
+			classifiers = append(classifiers, c)
+		}
+		if ns != nil {
+			w.indent--
+			w.line("}")
+		}
+	}
+
+	for _, rel := range e.Relationships {
+		if line, ok := relationship(rel, name, mermaidQuote); ok {
+			w.line("%s", line)
+		}
+	}
+	for _, note := range notes {
+		if note.Anchor == "" {
+			w.line("note %s", mermaidQuote(noteText(note.Text)))
+		} else {
+			w.line("note for %s %s", name(note.Anchor), mermaidQuote(noteText(note.Text)))
+		}
+	}
+	for _, c := range classifiers {
+		if c.url != "" {
+			w.line(`click %s href "%s"`, name(c.id), c.url)
+		}
+	}
+	for _, n := range classDefNames(doc) {
+		w.line("classDef %s %s", n, styleProps(doc.ClassDefs[n]))
+	}
+	for _, c := range classifiers {
+		for _, class := range c.styleClasses {
+			w.line(`cssClass "%s" %s`, name(c.id), class)
+		}
+		if c.style != nil && *c.style != (extuml.Style{}) {
+			w.line("style %s %s", name(c.id), styleProps(*c.style))
+		}
+	}
+	return w.buf.Bytes()
+}
+
+// mermaidIdent replaces the dots of a qualified ID, which Mermaid names
+// cannot contain
+func mermaidIdent(id string) string {
+	return strings.ReplaceAll(id, ".", "_")
+}
+
+// mermaidHead formats `class Name["Display"]~T~`
+func mermaidHead(c classifier, name string) string {
+	head := "class " + name
+	if c.name != name && c.name != "" {
+		head += fmt.Sprintf(`["%s"]`, c.name)
+	}
+	if len(c.typeParams) > 0 {
+		head += "~" + mermaidType(strings.Join(c.typeParams, ",")) + "~"
+	}
+	return head
+}
+
+// mermaidBody returns the annotations and members of a classifier
+func mermaidBody(c classifier) []string {
+	var annotations []string
+	switch {
+	case c.kind == kindInterface:
+		annotations = append(annotations, "interface")
+	case c.kind == kindEnum:
+		annotations = append(annotations, "enumeration")
+	case c.abstract:
+		annotations = append(annotations, "abstract")
+	}
+	if c.final {
+		annotations = append(annotations, "final")
+	}
+
+	var lines []string
+	for _, a := range append(annotations, c.stereotypes...) {
+		lines = append(lines, "<<"+a+">>")
+	}
+	for _, attr := range c.attributes {
+		s := extuml.VisibilitySymbol(attr.Visibility)
+		if attr.Type != "" {
+			s += mermaidType(attr.Type) + " "
+		}
+		s += attr.Name
+		if attr.Multiplicity != "" {
+			s += " [" + attr.Multiplicity + "]"
+		}
+		if attr.Static {
+			s += "$"
+		}
+		if attr.Default != "" {
+			s += " = " + attr.Default
+		}
+		lines = append(lines, s)
+	}
+	for _, op := range c.operations {
+		lines = append(lines, mermaidOperation(op, c.kind == kindInterface))
+	}
+	return append(lines, c.literals...)
+}
+
+// mermaidOperation formats `+name(Type p) ReturnType$`, with the classifier
+// at the end as Mermaid expects. An interface member without a parameter
+// list is written without parentheses, as in the DSL.
+func mermaidOperation(op extuml.Operation, bare bool) string {
+	s := extuml.VisibilitySymbol(op.Visibility) + op.Name
+	if !bare || op.Parameters != nil || op.ReturnType != "" || op.Static || op.Abstract {
+		params := make([]string, len(op.Parameters))
+		for i, param := range op.Parameters {
+			p := param.Name
+			if param.Type != "" {
+				p = mermaidType(param.Type) + " " + p
+			}
+			if param.Direction != "" {
+				p = param.Direction + " " + p
+			}
+			if param.Default != "" {
+				p += " = " + param.Default
+			}
+			params[i] = p
+		}
+		s += "(" + strings.Join(params, ", ") + ")"
+	}
+	if op.ReturnType != "" {
+		s += " " + mermaidType(op.ReturnType)
+	}
+	if op.Static {
+		s += "$"
+	}
+	if op.Abstract {
+		s += "*"
+	}
+	return s
+}
+
+// mermaidType writes the generics of a type with tildes, as in
+// `Map~K,V~`
+func mermaidType(typ string) string {
+	return strings.NewReplacer("<", "~", ">", "~", ", ", ",").Replace(typ)
+}
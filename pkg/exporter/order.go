@@ -0,0 +1,189 @@
+package exporter
+
+import (
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// Declaration kinds
+const (
+	kindClass     = "class"
+	kindInterface = "interface"
+	kindEnum      = "enum"
+	kindPackage   = "package"
+	kindNote      = "note"
+)
+
+// decl is a classifier, package or note to be declared
+type decl struct {
+	kind   string
+	index  int // in the element list of its kind
+	id     string
+	parent *decl // the package the declaration is written in, or nil
+}
+
+// local returns the name d is declared with inside its package
+func (d *decl) local() string {
+	if d.parent == nil {
+		return d.id
+	}
+	return strings.TrimPrefix(d.id, d.parent.id+".")
+}
+
+// path returns the packages enclosing d, outermost first, followed by d
+// itself if it is a package
+func (d *decl) path() []*decl {
+	var path []*decl
+	p := d.parent
+	if d.kind == kindPackage {
+		p = d
+	}
+	for ; p != nil; p = p.parent {
+		path = append([]*decl{p}, path...)
+	}
+	return path
+}
+
+// declarations returns the classifiers, packages and notes of doc in an order
+// that lowers back to the same element lists: each list keeps its order and
+// each package declares its children in order. An element is written inside
+// a package that lists it as a child, provided its ID is qualified by the
+// package's; any other element is declared at the top level.
+func declarations(doc *extuml.Document) []*decl {
+	e := doc.Elements
+	if e == nil {
+		return nil
+	}
+
+	var all []*decl
+	byKind := map[string][]*decl{}
+	elements := map[string]*decl{} // classifiers and packages by ID
+	notes := map[string]*decl{}
+	add := func(kind string, i int, id string) {
+		d := &decl{kind: kind, index: i, id: id}
+		all = append(all, d)
+		byKind[kind] = append(byKind[kind], d)
+		index := elements
+		if kind == kindNote {
+			index = notes
+		}
+		if _, dup := index[id]; !dup {
+			index[id] = d
+		}
+	}
+	for i, c := range e.Classes {
+		add(kindClass, i, c.ID)
+	}
+	for i, c := range e.Interfaces {
+		add(kindInterface, i, c.ID)
+	}
+	for i, c := range e.Enums {
+		add(kindEnum, i, c.ID)
+	}
+	for i, p := range e.Packages {
+		add(kindPackage, i, p.ID)
+	}
+	for i, n := range e.Notes {
+		add(kindNote, i, n.ID)
+	}
+
+	// Each chain must be declared in order
+	after := map[*decl][]*decl{}
+	preds := map[*decl]int{}
+	chain := func(list []*decl) {
+		for i := 1; i < len(list); i++ {
+			after[list[i-1]] = append(after[list[i-1]], list[i])
+			preds[list[i]]++
+		}
+	}
+	for _, list := range byKind {
+		chain(list)
+	}
+	for _, p := range byKind[kindPackage] {
+		list := []*decl{p}
+		for _, id := range e.Packages[p.index].Children {
+			child, ok := elements[id]
+			if !ok || !strings.HasPrefix(id, p.id+".") {
+				child, ok = notes[id]
+			}
+			if !ok || child.parent != nil || child == p {
+				continue
+			}
+			child.parent = p
+			list = append(list, child)
+		}
+		chain(list)
+	}
+
+	// Among the declarations that may come next, prefer those needing the
+	// fewest package blocks to be closed, then the order of the lists
+	var order []*decl
+	done := map[*decl]bool{}
+	var open []*decl
+	for len(order) < len(all) {
+		var next *decl
+		best := -1
+		for _, d := range all {
+			if done[d] || preds[d] > 0 {
+				continue
+			}
+			if shared := commonPrefix(open, d.path()); shared > best {
+				next, best = d, shared
+			}
+		}
+		if next == nil {
+			// Only inconsistent children lists form cycles; break them in
+			// list order
+			for _, d := range all {
+				if !done[d] {
+					next = d
+					break
+				}
+			}
+		}
+		done[next] = true
+		order = append(order, next)
+		for _, d := range after[next] {
+			preds[d]--
+		}
+		open = next.path()
+	}
+	return order
+}
+
+// commonPrefix returns the number of packages a and b start with in common
+func commonPrefix(a, b []*decl) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// walk calls visit for each classifier and note in order, and open and
+// close around them for the package blocks they are written in. Every
+// package is opened where it is declared and reopened where later elements
+// need it.
+func walk(order []*decl, open, close, visit func(d *decl)) {
+	var stack []*decl
+	for _, d := range order {
+		path := d.path()
+		shared := commonPrefix(stack, path)
+		for len(stack) > shared {
+			close(stack[len(stack)-1])
+			stack = stack[:len(stack)-1]
+		}
+		for _, p := range path[shared:] {
+			open(p)
+			stack = append(stack, p)
+		}
+		if d.kind != kindPackage {
+			visit(d)
+		}
+	}
+	for len(stack) > 0 {
+		close(stack[len(stack)-1])
+		stack = stack[:len(stack)-1]
+	}
+}
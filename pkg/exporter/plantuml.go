@@ -0,0 +1,154 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// PlantUML writes doc as a PlantUML class diagram. PlantUML colours only the
+// background of a class, so of the styles just the fill is kept, and the
+// config, placement and URLs are dropped. Classes are referred to by their
+// short name where it is unique and by their ID otherwise.
+func PlantUML(doc *extuml.Document) []byte {
+	w := &writer{unit: "  "}
+	w.line("@startuml")
+	if doc.Meta != nil && doc.Meta.Title != "" {
+		w.line("title %s", doc.Meta.Title)
+	}
+
+	e := doc.Elements
+	if e == nil {
+		e = &extuml.Elements{}
+	}
+	order := declarations(doc)
+	name := classifierNames(order, func(id string) string { return id })
+
+	floating := 0
+	walk(order,
+		func(p *decl) {
+			w.line("package %s {", p.local())
+			w.indent++
+		},
+		func(p *decl) {
+			w.indent--
+			w.line("}")
+		},
+		func(d *decl) {
+			if d.kind == kindNote {
+				note := e.Notes[d.index]
+				if note.Anchor == "" {
+					floating++
+					w.line(`note "%s" as N%d`, noteText(note.Text), floating)
+				} else {
+					w.line("note right of %s : %s", name(note.Anchor), noteText(note.Text))
+				}
+				return
+			}
+			c := classifierOf(e, d)
+			w.block(plantUMLHead(c, fill(doc, c)), plantUMLBody(c))
+		})
+
+	for _, rel := range e.Relationships {
+		if line, ok := relationship(rel, name, plainQuote); ok {
+			w.line("%s", line)
+		}
+	}
+	w.line("@enduml")
+	return w.buf.Bytes()
+}
+
+// fill returns the fill colour of c after its classDefs and style
+func fill(doc *extuml.Document, c classifier) string {
+	var style extuml.Style
+	for _, class := range c.styleClasses {
+		style = style.Merge(doc.ClassDefs[class])
+	}
+	if c.style != nil {
+		style = style.Merge(*c.style)
+	}
+	return style.Fill
+}
+
+// plantUMLHead formats `abstract class "Display" as Name<T> <<Entity>> #pink`
+func plantUMLHead(c classifier, fill string) string {
+	var b strings.Builder
+	if c.abstract {
+		b.WriteString("abstract ")
+	}
+	b.WriteString(c.kind + " ")
+	if c.name != c.local() && c.name != "" {
+		fmt.Fprintf(&b, `"%s" as `, c.name)
+	}
+	b.WriteString(c.local())
+	if len(c.typeParams) > 0 {
+		b.WriteString("<" + strings.Join(c.typeParams, ", ") + ">")
+	}
+	stereotypes := c.stereotypes
+	if c.final {
+		stereotypes = append([]string{"final"}, stereotypes...)
+	}
+	for _, st := range stereotypes {
+		b.WriteString(" <<" + st + ">>")
+	}
+	if fill != "" {
+		b.WriteString(" #" + strings.TrimPrefix(fill, "#"))
+	}
+	return b.String()
+}
+
+// plantUMLBody returns the members of a classifier with PlantUML's
+// `{static}` and `{abstract}` modifiers
+func plantUMLBody(c classifier) []string {
+	var lines []string
+	for _, attr := range c.attributes {
+		s := extuml.VisibilitySymbol(attr.Visibility)
+		if attr.Static {
+			s += "{static} "
+		}
+		s += attr.Name
+		if attr.Type != "" {
+			s += " : " + attr.Type
+		}
+		if attr.Multiplicity != "" {
+			s += " [" + attr.Multiplicity + "]"
+		}
+		if attr.Default != "" {
+			s += " = " + attr.Default
+		}
+		lines = append(lines, s)
+	}
+	for _, op := range c.operations {
+		s := extuml.VisibilitySymbol(op.Visibility)
+		if op.Static {
+			s += "{static} "
+		}
+		if op.Abstract {
+			s += "{abstract} "
+		}
+		s += op.Name
+		if c.kind != kindInterface || op.Parameters != nil || op.ReturnType != "" {
+			params := make([]string, len(op.Parameters))
+			for i, param := range op.Parameters {
+				p := param.Name
+				if param.Type != "" {
+					p += " : " + param.Type
+				}
+				if param.Direction != "" {
+					p = param.Direction + " " + p
+				}
+				if param.Default != "" {
+					p += " = " + param.Default
+				}
+				params[i] = p
+			}
+			s += "(" + strings.Join(params, ", ") + ")"
+		}
+		if op.ReturnType != "" {
+			s += " : " + op.ReturnType
+		}
+		lines = append(lines, s)
+	}
+	return append(lines, c.literals...)
+}
@@ -0,0 +1,34 @@
+package formatter
+
+import "github.com/extuml/extuml/pkg/parser"
+
+// activityEntry prints a node, partition or flow of an activity diagram
+func (p *printer) activityEntry(decl parser.Decl, depth int) {
+	switch d := decl.(type) {
+	case *parser.ActivityNodeDecl:
+		s := d.Kind
+		if d.Name.Name != d.Kind {
+			s += " " + d.Name.Name
+		}
+		if d.Alias != "" {
+			s += ` as ` + parser.Quote(d.Alias)
+		}
+		for _, class := range d.Classes {
+			s += ":::" + class.Name
+		}
+		p.line(depth, s)
+	case *parser.PartitionDecl:
+		head := "partition " + d.Name.Name
+		if d.Alias != "" {
+			head += ` as ` + parser.Quote(d.Alias)
+		}
+		p.block(head, d.Decls, d.Start.Offset, d.End.Offset, depth)
+		p.line(depth, "}")
+	case *parser.FlowDecl:
+		s := d.From.Name + " --> " + d.To.Name
+		if d.Label != "" {
+			s += " : " + d.Label
+		}
+		p.line(depth, s)
+	}
+}
@@ -0,0 +1,166 @@
+package formatter
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// Order of the lines in a classifier body
+const (
+	rankStereotype = iota
+	rankAttribute
+	rankOperation
+	rankAnnotation
+	rankTrailing // comments after the last line
+)
+
+// bodyGroup is a line of a classifier body with the comments above it
+type bodyGroup struct {
+	lines []entry
+	rank  int
+	gap   bool // preceded by a blank line in the source
+}
+
+// hasBody reports whether d is written with a body. An empty body is
+// dropped, so `class A {` `}` becomes `class A`.
+func (p *printer) hasBody(d *parser.ClassifierDecl) bool {
+	if len(d.Members) > 0 || len(d.Annotations) > 0 || len(bodyStereotypes(d)) > 0 {
+		return true
+	}
+	for _, e := range p.loose {
+		if off := e.span.Start.Offset; off >= d.Start.Offset && off < d.End.Offset {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyStereotypes returns the stereotypes written on lines of their own in
+// the body rather than on the declaration line
+func bodyStereotypes(d *parser.ClassifierDecl) []*parser.Stereotype {
+	var list []*parser.Stereotype
+	for _, st := range d.Stereotypes {
+		if st.Start.Line != d.Name.Start.Line {
+			list = append(list, st)
+		}
+	}
+	return list
+}
+
+func (p *printer) classifier(d *parser.ClassifierDecl, depth int) {
+	head := p.classifierHead(d)
+	if !p.hasBody(d) {
+		p.line(depth, head)
+		return
+	}
+
+	p.line(depth, head+" {")
+	for i, g := range p.bodyGroups(d) {
+		for j, e := range g.lines {
+			if j > 0 && !adjacent(g.lines[j-1], e) || j == 0 && i > 0 && g.gap {
+				p.buf.WriteByte('\n')
+			}
+			p.line(depth+1, e.text)
+		}
+	}
+	p.line(depth, "}")
+}
+
+// bodyGroups returns the lines of the body of d in canonical order. A blank
+// line is kept only between groups that stay next to each other.
+func (p *printer) bodyGroups(d *parser.ClassifierDecl) []bodyGroup {
+	type ranked struct {
+		entry
+		rank int
+	}
+	var lines []ranked
+	for _, st := range bodyStereotypes(d) {
+		lines = append(lines, ranked{entry{span: st.Span, text: "<<" + strings.Join(st.Names, ", ") + ">>"}, rankStereotype})
+	}
+	for _, m := range d.Members {
+		rank := rankAttribute
+		// In classes, as in lowering, members with a parameter list are
+		// operations
+		if d.Kind == "class" && parser.IsOperation(m.Text) {
+			rank = rankOperation
+		}
+		lines = append(lines, ranked{entry{span: m.Span, text: m.Text}, rank})
+	}
+	for _, ann := range d.Annotations {
+		text := "@" + ann.Name
+		if ann.Value != "" {
+			text += ": " + ann.Value
+		}
+		lines = append(lines, ranked{entry{span: ann.Span, text: text}, rankAnnotation})
+	}
+	for _, e := range p.take(d.Start.Offset, d.End.Offset, nil) {
+		lines = append(lines, ranked{e, -1})
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].span.Start.Offset < lines[j].span.Start.Offset
+	})
+
+	var groups []bodyGroup
+	var pending []entry
+	var last *entry
+	gap := false
+	for i, l := range lines {
+		if len(pending) == 0 && last != nil {
+			gap = !adjacent(*last, l.entry)
+		}
+		pending = append(pending, l.entry)
+		last = &lines[i].entry
+		if l.rank >= 0 {
+			groups = append(groups, bodyGroup{lines: pending, rank: l.rank, gap: gap})
+			pending = nil
+		}
+	}
+	if len(pending) > 0 {
+		groups = append(groups, bodyGroup{lines: pending, rank: rankTrailing, gap: gap})
+	}
+
+	// Keep a blank line only where the groups around it were not moved
+	next := make([]int, len(groups))
+	for i := range groups {
+		next[i] = i
+	}
+	sort.SliceStable(next, func(i, j int) bool { return groups[next[i]].rank < groups[next[j]].rank })
+	ordered := make([]bodyGroup, len(groups))
+	for i, g := range next {
+		ordered[i] = groups[g]
+		ordered[i].gap = groups[g].gap && i > 0 && next[i-1] == g-1
+	}
+	return ordered
+}
+
+// classifierHead formats the declaration line, such as
+// `abstract class Repository<T>:::hot as "Store" <<Entity>>`
+func (p *printer) classifierHead(d *parser.ClassifierDecl) string {
+	var b strings.Builder
+	for _, m := range d.Modifiers {
+		b.WriteString(m.Name + " ")
+	}
+	b.WriteString(d.Kind + " " + d.Name.Name)
+	if params := d.TypeParams; params != nil {
+		// Mermaid's `~T~` nests with `~` as well, so it is kept as written
+		if p.src[params.Start.Offset] == '~' {
+			b.WriteString("~" + params.Text + "~")
+		} else {
+			b.WriteString("<" + params.Text + ">")
+		}
+	}
+	for _, class := range d.Classes {
+		b.WriteString(":::" + class.Name)
+	}
+	if d.Alias != "" {
+		b.WriteString(` as ` + parser.Quote(d.Alias))
+	}
+	for _, st := range d.Stereotypes {
+		if st.Start.Line == d.Name.Start.Line {
+			b.WriteString(" <<" + strings.Join(st.Names, ", ") + ">>")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,49 @@
+package formatter
+
+import (
+	"strings"
+
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// componentEntry prints a component, node, artifact, port, interface use
+// or connector of a component or deployment diagram
+func (p *printer) componentEntry(decl parser.Decl, depth int) {
+	switch d := decl.(type) {
+	case *parser.ComponentDecl:
+		head := d.Kind + " " + d.Name.Name
+		if d.Alias != "" {
+			head += ` as ` + parser.Quote(d.Alias)
+		}
+		for _, s := range d.Stereotypes {
+			head += " <<" + s + ">>"
+		}
+		for _, class := range d.Classes {
+			head += ":::" + class.Name
+		}
+		if !d.Body {
+			p.line(depth, head)
+			return
+		}
+		p.block(head, d.Decls, d.Start.Offset, d.End.Offset, depth)
+		p.line(depth, "}")
+	case *parser.PortDecl:
+		p.line(depth, "port "+d.Name.Name)
+	case *parser.InterfaceUseDecl:
+		keyword := "requires"
+		if d.Provided {
+			keyword = "provides"
+		}
+		names := make([]string, len(d.Names))
+		for i, name := range d.Names {
+			names[i] = name.Name
+		}
+		p.line(depth, keyword+" "+strings.Join(names, ", "))
+	case *parser.ConnectorDecl:
+		s := d.From.Name + " " + d.Operator + " " + d.To.Name
+		if d.Label != "" {
+			s += " : " + d.Label
+		}
+		p.line(depth, s)
+	}
+}
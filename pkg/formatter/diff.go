@@ -0,0 +1,170 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change
+const context = 3
+
+// Diff returns a unified diff turning before into after, as printed by
+// `extuml fmt --diff`, or nil if they are equal
+func Diff(beforeName, afterName string, before, after []byte) []byte {
+	if bytes.Equal(before, after) {
+		return nil
+	}
+	ops := diffLines(splitLines(before), splitLines(after))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", beforeName, afterName)
+	for start := 0; start < len(ops); {
+		// Find the next change and extend the hunk while changes are close
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				if i-last > 2*context {
+					break
+				}
+				last = i
+			}
+		}
+		from, to := max(first-context, 0), min(last+context+1, len(ops))
+		writeHunk(&b, ops, from, to)
+		start = to
+	}
+	return b.Bytes()
+}
+
+// edit is one line of an edit script: ' ' kept, '-' deleted or '+' inserted
+type edit struct {
+	kind byte
+	line string
+}
+
+// writeHunk writes ops[from:to] with its `@@ -l,s +l,s @@` header
+func writeHunk(b *bytes.Buffer, ops []edit, from, to int) {
+	oldLine, newLine := 1, 1
+	for _, op := range ops[:from] {
+		if op.kind != '+' {
+			oldLine++
+		}
+		if op.kind != '-' {
+			newLine++
+		}
+	}
+	oldCount, newCount := 0, 0
+	for _, op := range ops[from:to] {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+	for _, op := range ops[from:to] {
+		b.WriteByte(op.kind)
+		b.WriteString(op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(line, count int) string {
+	switch count {
+	case 0:
+		// An empty range names the line before it
+		return fmt.Sprintf("%d,0", line-1)
+	case 1:
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// splitLines splits src after each line break, so that a missing final
+// line break shows as a change
+func splitLines(src []byte) []string {
+	if len(src) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(src), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns a shortest edit script from a to b using Myers'
+// algorithm
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	limit := n + m
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+
+	// trace[d] holds v[-d-1 .. d+1] as it was before round d
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			x := v[offset+k-1] + 1
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the trace of diffLines back from the end of both inputs
+func backtrack(a, b []string, trace [][]int) []edit {
+	var ops []edit
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+		k := x - y
+		prev := k - 1
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			prev = k + 1
+		}
+		prevX := at(prev)
+		prevY := prevX - prev
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, edit{' ', a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, edit{'+', b[y-1]})
+			} else {
+				ops = append(ops, edit{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
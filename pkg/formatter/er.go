@@ -0,0 +1,53 @@
+package formatter
+
+import (
+	"strings"
+
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// erEntry prints an entity, column, schema or relationship of an ER diagram
+func (p *printer) erEntry(decl parser.Decl, depth int) {
+	switch d := decl.(type) {
+	case *parser.EntityDecl:
+		head := "entity " + d.Name.Name
+		if d.Alias != "" {
+			head += ` as ` + parser.Quote(d.Alias)
+		}
+		for _, class := range d.Classes {
+			head += ":::" + class.Name
+		}
+		if !d.Body {
+			p.line(depth, head)
+			return
+		}
+		columns := make([]parser.Decl, len(d.Columns))
+		for i, c := range d.Columns {
+			columns[i] = c
+		}
+		p.block(head, columns, d.Start.Offset, d.End.Offset, depth)
+		p.line(depth, "}")
+	case *parser.ColumnDecl:
+		s := d.Type + " " + d.Name
+		if len(d.Keys) > 0 {
+			s += " " + strings.Join(d.Keys, ", ")
+		}
+		if d.Comment != "" {
+			s += " " + parser.Quote(d.Comment)
+		}
+		p.line(depth, s)
+	case *parser.SchemaDecl:
+		head := d.Kind + " " + d.Name.Name
+		if d.Alias != "" {
+			head += ` as ` + parser.Quote(d.Alias)
+		}
+		p.block(head, d.Decls, d.Start.Offset, d.End.Offset, depth)
+		p.line(depth, "}")
+	case *parser.ERRelationshipDecl:
+		s := d.Left.Name + " " + d.Operator + " " + d.Right.Name
+		if d.Label != "" {
+			s += " : " + d.Label
+		}
+		p.line(depth, s)
+	}
+}
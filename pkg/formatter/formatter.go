@@ -0,0 +1,360 @@
+// Package formatter rewrites .extuml source in its canonical layout, as
+// gofmt does for Go. Unlike the DSL exporter it works on the syntax tree, so
+// comments, includes and the order of declarations are kept.
+package formatter
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// indent is one level of indentation
+const indent = "  "
+
+// Source formats .extuml source. A file with syntax errors is not formatted:
+// its diagnostics are returned with a nil result.
+func Source(filename string, src []byte) ([]byte, diagnostic.List) {
+	file, diags := parser.Parse(filename, src)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return Format(file, src), diags
+}
+
+// Format prints a parsed file in the canonical layout:
+//
+//   - blocks are indented by two spaces per level
+//   - runs of blank lines are collapsed, and blank lines at the start or end
+//     of a block are dropped
+//   - at the top level, the header and declarations with a body are set
+//     apart by blank lines, together with the comments directly above them
+//   - class members are ordered stereotypes, attributes, operations and
+//     annotations, each keeping the comments above it
+//   - declaration lines are spaced uniformly, e.g. `A "1" --> "*" B : label`
+//
+// src is the source file was parsed from. Member lines, notes, front matter
+// and directives keep their text.
+func Format(file *parser.File, src []byte) []byte {
+	p := &printer{src: src}
+	for _, c := range file.Comments {
+		p.loose = append(p.loose, entry{span: c.Span, text: strings.TrimRight(c.Text, " \t\r"), comment: true})
+	}
+	for _, d := range file.Directives {
+		p.loose = append(p.loose, entry{span: d.Span, text: p.verbatim(d.Span)})
+	}
+
+	var top []entry
+	if file.FrontMatter != nil {
+		top = append(top, entry{span: *file.FrontMatter, text: p.verbatim(*file.FrontMatter)})
+	}
+	for _, m := range file.Meta {
+		// Entries of the front matter are printed with it
+		if file.FrontMatter == nil || m.Start.Offset >= file.FrontMatter.End.Offset {
+			top = append(top, entry{span: m.Span, text: p.metaLine(m)})
+		}
+	}
+	if h := file.Header; h != nil {
+		text := strings.TrimSpace("extuml " + h.DiagramType)
+		top = append(top, entry{span: h.Span, text: text, spaceAfter: true})
+	}
+	decls := p.declEntries(file.Decls)
+	top = append(top, decls...)
+	top = append(top, p.take(0, len(src)+1, decls)...)
+	sortEntries(top)
+
+	p.entries(top, 0, true)
+	return p.buf.Bytes()
+}
+
+// entry is one element of a block, printed on one or more lines
+type entry struct {
+	span       parser.Span
+	decl       parser.Decl
+	text       string // the line itself, if not a declaration
+	comment    bool
+	block      bool // a declaration with a body
+	spaceAfter bool // followed by a blank line at the top level
+}
+
+type printer struct {
+	src   []byte
+	buf   bytes.Buffer
+	loose []entry // comments and directives not yet placed in a block
+}
+
+func sortEntries(list []entry) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].span.Start.Offset < list[j].span.Start.Offset
+	})
+}
+
+// take removes and returns the comments and directives in [start, end)
+// that are not inside one of the children
+func (p *printer) take(start, end int, children []entry) []entry {
+	var taken, rest []entry
+	for _, e := range p.loose {
+		if off := e.span.Start.Offset; off >= start && off < end && !inside(off, children) {
+			taken = append(taken, e)
+		} else {
+			rest = append(rest, e)
+		}
+	}
+	p.loose = rest
+	return taken
+}
+
+func inside(off int, children []entry) bool {
+	for _, c := range children {
+		if off >= c.span.Start.Offset && off < c.span.End.Offset {
+			return true
+		}
+	}
+	return false
+}
+
+// declEntries returns the entries for decls, leaving the comments inside
+// each declaration for when its body is printed
+func (p *printer) declEntries(decls []parser.Decl) []entry {
+	var list []entry
+	for _, decl := range decls {
+		e := entry{decl: decl}
+		switch d := decl.(type) {
+		case *parser.ClassifierDecl:
+			e.span = d.Span
+			e.block = p.hasBody(d)
+		case *parser.PackageDecl:
+			e.span = d.Span
+			e.block = true
+		case *parser.NoteDecl:
+			e.span = d.Span
+		case *parser.RelationshipDecl:
+			e.span = d.Span
+		case *parser.IncludeDecl:
+			e.span = d.Span
+		case *parser.ClassDefDecl:
+			e.span = d.Span
+		case *parser.StyleDecl:
+			e.span = d.Span
+		case *parser.CSSClassDecl:
+			e.span = d.Span
+		case *parser.ParticipantDecl:
+			e.span = d.Span
+		case *parser.MessageDecl:
+			e.span = d.Span
+		case *parser.ActivationDecl:
+			e.span = d.Span
+		case *parser.SequenceNoteDecl:
+			e.span = d.Span
+		case *parser.ZoneDecl:
+			e.span = d.Span
+			e.block = true
+		case *parser.FragmentDecl:
+			e.span = d.Span
+			e.block = true
+		case *parser.StateDecl:
+			e.span = d.Span
+			e.block = d.Regions != nil
+		case *parser.TransitionDecl:
+			e.span = d.Span
+		case *parser.EntityDecl:
+			e.span = d.Span
+			e.block = d.Body
+		case *parser.ColumnDecl:
+			e.span = d.Span
+		case *parser.SchemaDecl:
+			e.span = d.Span
+			e.block = true
+		case *parser.ERRelationshipDecl:
+			e.span = d.Span
+		case *parser.ComponentDecl:
+			e.span = d.Span
+			e.block = d.Body
+		case *parser.PortDecl:
+			e.span = d.Span
+		case *parser.InterfaceUseDecl:
+			e.span = d.Span
+		case *parser.ConnectorDecl:
+			e.span = d.Span
+		case *parser.ActivityNodeDecl:
+			e.span = d.Span
+		case *parser.PartitionDecl:
+			e.span = d.Span
+			e.block = true
+		case *parser.FlowDecl:
+			e.span = d.Span
+		default:
+			// Mermaid-only statements do not occur in .extuml files
+			continue
+		}
+		list = append(list, e)
+	}
+	return list
+}
+
+// entries prints the entries of a block in order. Blank lines in the source
+// are kept, collapsed to one; at the top level blocks are also set apart,
+// together with the comments directly above them.
+func (p *printer) entries(list []entry, depth int, top bool) {
+	// leads[i] reports whether entry i is a comment attached to a block below
+	leads := make([]bool, len(list))
+	for i := len(list) - 2; i >= 0; i-- {
+		next := list[i+1]
+		leads[i] = list[i].comment && adjacent(list[i], next) && (next.block || leads[i+1])
+	}
+	for i, e := range list {
+		if i > 0 {
+			prev := list[i-1]
+			spaced := top && (prev.block || prev.spaceAfter || (e.block || leads[i]) && !leads[i-1])
+			if !adjacent(prev, e) || spaced {
+				p.buf.WriteByte('\n')
+			}
+		}
+		p.entry(e, depth)
+	}
+}
+
+// adjacent reports whether b starts on the line after a ends
+func adjacent(a, b entry) bool {
+	return b.span.Start.Line <= a.span.End.Line+1
+}
+
+func (p *printer) entry(e entry, depth int) {
+	switch d := e.decl.(type) {
+	case nil:
+		p.line(depth, e.text)
+	case *parser.ClassifierDecl:
+		p.classifier(d, depth)
+	case *parser.PackageDecl:
+		p.block("package "+d.Name.Name, d.Decls, d.Start.Offset, d.End.Offset, depth)
+		p.line(depth, "}")
+	case *parser.NoteDecl:
+		p.note(d, depth)
+	case *parser.RelationshipDecl:
+		p.line(depth, relationship(d))
+	case *parser.IncludeDecl:
+		keyword := "include"
+		if bytes.HasPrefix(p.src[d.Start.Offset:], []byte("import")) {
+			keyword = "import"
+		}
+		p.line(depth, keyword+" "+parser.Quote(d.Path))
+	case *parser.ClassDefDecl:
+		names := make([]string, len(d.Names))
+		for i, name := range d.Names {
+			names[i] = name.Name
+		}
+		p.line(depth, "classDef "+strings.Join(names, ",")+" "+styleProps(d.Props))
+	case *parser.StyleDecl:
+		p.line(depth, "style "+d.Target.Name+" "+styleProps(d.Props))
+	case *parser.CSSClassDecl:
+		targets := make([]string, len(d.Targets))
+		for i, target := range d.Targets {
+			targets[i] = target.Name
+		}
+		p.line(depth, `cssClass "`+strings.Join(targets, ",")+`" `+d.Class.Name)
+	case *parser.StateDecl, *parser.TransitionDecl:
+		p.stateEntry(d, depth)
+	case *parser.EntityDecl, *parser.ColumnDecl, *parser.SchemaDecl, *parser.ERRelationshipDecl:
+		p.erEntry(d, depth)
+	case *parser.ComponentDecl, *parser.PortDecl, *parser.InterfaceUseDecl, *parser.ConnectorDecl:
+		p.componentEntry(d, depth)
+	case *parser.ActivityNodeDecl, *parser.PartitionDecl, *parser.FlowDecl:
+		p.activityEntry(d, depth)
+	default:
+		p.sequenceEntry(d, depth)
+	}
+}
+
+// line writes text indented to depth. Only the first line of a multi-line
+// text is indented.
+func (p *printer) line(depth int, text string) {
+	p.buf.WriteString(strings.Repeat(indent, depth))
+	p.buf.WriteString(text)
+	p.buf.WriteByte('\n')
+}
+
+// verbatim returns the source of span with trailing whitespace removed from
+// each line
+func (p *printer) verbatim(span parser.Span) string {
+	lines := strings.Split(string(p.src[span.Start.Offset:span.End.Offset]), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// metaLine formats a top-level `title: value` line, keeping the value as
+// written
+func (p *printer) metaLine(m *parser.MetaEntry) string {
+	_, value, _ := strings.Cut(string(p.src[m.Start.Offset:m.End.Offset]), ":")
+	if value = strings.TrimSpace(value); value == "" {
+		return m.Key + ":"
+	}
+	return m.Key + ": " + value
+}
+
+// note formats `note for Anchor "text"`. The lines of a multi-line note are
+// indented one level deeper than the note; the DSL trims them anyway.
+func (p *printer) note(d *parser.NoteDecl, depth int) {
+	head := "note "
+	if d.Anchor != nil {
+		head += "for " + d.Anchor.Name + " "
+	}
+	p.noteText(head, d.Span, depth)
+}
+
+// noteText writes head followed by the quoted text of the note at span as
+// written, so that `\n` escapes and line breaks stay as they are
+func (p *printer) noteText(head string, span parser.Span, depth int) {
+	src := p.src[span.Start.Offset:span.End.Offset]
+	quoted := string(src[bytes.IndexByte(src, '"'):])
+	lines := strings.Split(quoted[1:len(quoted)-1], "\n")
+	if len(lines) == 1 {
+		p.line(depth, head+quoted)
+		return
+	}
+	var b strings.Builder
+	b.WriteString(head + `"` + strings.TrimRight(lines[0], " \t\r"))
+	for _, l := range lines[1 : len(lines)-1] {
+		b.WriteString("\n")
+		if l = strings.TrimSpace(l); l != "" {
+			b.WriteString(strings.Repeat(indent, depth+1) + l)
+		}
+	}
+	b.WriteString("\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		b.WriteString(strings.Repeat(indent, depth+1) + last + `"`)
+	} else {
+		b.WriteString(strings.Repeat(indent, depth) + `"`)
+	}
+	p.line(depth, b.String())
+}
+
+// relationship formats `Left "1" --> "*" Right : label`
+func relationship(d *parser.RelationshipDecl) string {
+	s := d.Left.Name
+	if d.LeftMult != "" {
+		s += " " + parser.Quote(d.LeftMult)
+	}
+	s += " " + d.Operator
+	if d.RightMult != "" {
+		s += " " + parser.Quote(d.RightMult)
+	}
+	s += " " + d.Right.Name
+	if d.Label != "" {
+		s += " : " + d.Label
+	}
+	return s
+}
+
+// styleProps formats the properties of a classDef or style statement
+func styleProps(props []*parser.StyleProp) string {
+	parts := make([]string, len(props))
+	for i, prop := range props {
+		parts[i] = prop.Name + ":" + prop.Value
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,109 @@
+package formatter
+
+import (
+	"strings"
+
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// sequenceEntry prints a statement of a sequence diagram
+func (p *printer) sequenceEntry(decl parser.Decl, depth int) {
+	switch d := decl.(type) {
+	case *parser.ParticipantDecl:
+		s := d.Kind + " " + d.Name.Name
+		if d.Alias != "" {
+			s += ` as ` + parser.Quote(d.Alias)
+		}
+		for _, class := range d.Classes {
+			s += ":::" + class.Name
+		}
+		p.line(depth, s)
+	case *parser.ZoneDecl:
+		head := "zone " + d.Name.Name
+		if d.Alias != "" {
+			head += ` as ` + parser.Quote(d.Alias)
+		}
+		p.block(head, d.Decls, d.Start.Offset, d.End.Offset, depth)
+		p.line(depth, "}")
+	case *parser.MessageDecl:
+		p.line(depth, message(d))
+	case *parser.ActivationDecl:
+		keyword := "deactivate "
+		if d.Activate {
+			keyword = "activate "
+		}
+		p.line(depth, keyword+d.Target.Name)
+	case *parser.FragmentDecl:
+		p.fragment(d, depth)
+	case *parser.SequenceNoteDecl:
+		targets := make([]string, len(d.Targets))
+		for i, target := range d.Targets {
+			targets[i] = target.Name
+		}
+		head := "note over "
+		if d.Position != "over" {
+			head = "note " + d.Position + " of "
+		}
+		p.noteText(head+strings.Join(targets, ", ")+" ", d.Span, depth)
+	}
+}
+
+// block prints `head {` and the statements of a block whose source spans
+// [start, end), without its closing brace
+func (p *printer) block(head string, decls []parser.Decl, start, end, depth int) {
+	p.line(depth, head+" {")
+	p.body(decls, start, end, depth+1)
+}
+
+// body prints decls at depth together with the comments and directives
+// in [start, end) around them
+func (p *printer) body(decls []parser.Decl, start, end, depth int) {
+	children := p.declEntries(decls)
+	children = append(children, p.take(start, end, children)...)
+	sortEntries(children)
+	p.entries(children, depth, false)
+}
+
+// fragment prints an alt, loop or opt block, with each `else` on the line
+// of the brace closing the operand before it. Comments between a brace and
+// the `else` after it move into the else block.
+func (p *printer) fragment(d *parser.FragmentDecl, depth int) {
+	start := d.Start.Offset
+	for i, o := range d.Operands {
+		head := d.Kind
+		if i > 0 {
+			head = "} else"
+		}
+		if o.Guard != "" {
+			head += " " + guard(o.Guard)
+		}
+		p.block(head, o.Decls, start, o.End.Offset, depth)
+		start = o.End.Offset
+	}
+	p.line(depth, "}")
+}
+
+// guard formats the guard of an operand, quoting it if it holds a brace.
+// A guard already holding quotes keeps its braces inside strings, so it is
+// kept as it is.
+func guard(text string) string {
+	if strings.ContainsAny(text, "{}") && !strings.Contains(text, `"`) {
+		return parser.Quote(text)
+	}
+	return text
+}
+
+// message formats `From ->> +To : label`
+func message(d *parser.MessageDecl) string {
+	s := d.From.Name + " " + d.Operator + " "
+	if d.Activate {
+		s += "+"
+	} else if d.Deactivate {
+		s += "-"
+	}
+	s += d.To.Name
+	if d.Label != "" {
+		s += " : " + d.Label
+	}
+	return s
+}
@@ -0,0 +1,44 @@
+package formatter
+
+import (
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// stateEntry prints a state or transition of a state diagram
+func (p *printer) stateEntry(decl parser.Decl, depth int) {
+	switch d := decl.(type) {
+	case *parser.StateDecl:
+		head := "state " + d.Name.Name
+		if d.Alias != "" {
+			head += ` as ` + parser.Quote(d.Alias)
+		}
+		if d.Kind != "" {
+			head += " <<" + d.Kind + ">>"
+		}
+		for _, class := range d.Classes {
+			head += ":::" + class.Name
+		}
+		if d.Regions == nil {
+			p.line(depth, head)
+			return
+		}
+
+		// Regions after the first start with a `--` line
+		p.line(depth, head+" {")
+		start := d.Start.Offset
+		for i, r := range d.Regions {
+			if i > 0 {
+				p.line(depth+1, "--")
+			}
+			p.body(r.Decls, start, r.End.Offset, depth+1)
+			start = r.End.Offset
+		}
+		p.line(depth, "}")
+	case *parser.TransitionDecl:
+		s := d.From.Name + " --> " + d.To.Name
+		if d.Label != "" {
+			s += " : " + d.Label
+		}
+		p.line(depth, s)
+	}
+}
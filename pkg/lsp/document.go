@@ -0,0 +1,192 @@
+package lsp
+
+import (
+	"bytes"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// document is a text document open in the editor, analysed after every
+// change
+type document struct {
+	uri     string
+	path    string // file name in spans, against which includes are resolved
+	version int
+	text    []byte
+
+	file    *parser.File
+	doc     *extuml.Document
+	symbols *parser.Symbols
+	diags   diagnostic.List
+	sources map[string][]byte // the text of the document and of every file it includes
+}
+
+// analyse parses and lowers the document, reading included files with read
+func (d *document) analyse(read parser.ReadFunc) {
+	d.sources = map[string][]byte{d.path: d.text}
+	readFile := func(name string) ([]byte, error) {
+		src, err := read(name)
+		if err == nil {
+			d.sources[name] = src
+		}
+		return src, err
+	}
+
+	file, diags := parser.Parse(d.path, d.text)
+	diags = append(diags, parser.ResolveIncludes(file, readFile)...)
+	doc, symbols, lowerDiags := parser.Resolve(file)
+	d.file, d.doc, d.symbols = file, doc, symbols
+	d.diags = append(diags, lowerDiags...)
+}
+
+// diagnostics converts the diagnostics reported against the document itself
+func (d *document) diagnostics() []Diagnostic {
+	list := []Diagnostic{}
+	for _, diag := range d.diags {
+		if diag.Span.Start.File != d.path {
+			continue
+		}
+		severity := SeverityError
+		if diag.Severity == diagnostic.SeverityWarning {
+			severity = SeverityWarning
+		}
+		list = append(list, Diagnostic{
+			Range:    d.rangeOf(diag.Span),
+			Severity: severity,
+			Code:     diag.Code,
+			Source:   "extuml",
+			Message:  diag.Message,
+		})
+	}
+	return list
+}
+
+// includes reports whether the document read the file at path
+func (d *document) includes(path string) bool {
+	_, ok := d.sources[path]
+	return ok && path != d.path
+}
+
+// rangeOf converts a span in the document or one of its included files
+func (d *document) rangeOf(span parser.Span) Range {
+	src := d.sources[span.Start.File]
+	return Range{Start: position(src, span.Start.Offset), End: position(src, span.End.Offset)}
+}
+
+// location converts a span to a location in the file it belongs to
+func (d *document) location(span parser.Span) Location {
+	uri := d.uri
+	if span.Start.File != d.path {
+		uri = pathToURI(span.Start.File)
+	}
+	return Location{URI: uri, Range: d.rangeOf(span)}
+}
+
+// symbolAt returns the ID of the element named at offset, by its
+// declaration or a reference, and the name found there. References to
+// elements without a declaration, such as packages or an unnamed start
+// node, are ignored.
+func (d *document) symbolAt(offset int) (string, parser.Ident, bool) {
+	at := func(name parser.Ident) bool {
+		return name.Start.File == d.path && name.Start.Offset <= offset && offset <= name.End.Offset
+	}
+	for _, decl := range d.symbols.Declarations {
+		if at(decl.Name) {
+			return decl.ID, decl.Name, true
+		}
+	}
+	for _, ref := range d.symbols.References {
+		if _, ok := d.symbols.Lookup(ref.ID); ok && at(ref.Name) {
+			return ref.ID, ref.Name, true
+		}
+	}
+	return "", parser.Ident{}, false
+}
+
+// position converts a byte offset in src to a protocol position
+func position(src []byte, offset int) Position {
+	offset = min(max(offset, 0), len(src))
+	start := bytes.LastIndexByte(src[:offset], '\n') + 1
+	return Position{
+		Line:      bytes.Count(src[:offset], []byte("\n")),
+		Character: utf16Len(src[start:offset]),
+	}
+}
+
+// offsetOf converts a protocol position to a byte offset in src. Positions
+// past the end of a line or of the file are clamped to it.
+func offsetOf(src []byte, pos Position) int {
+	start := 0
+	for line := 0; line < pos.Line; line++ {
+		i := bytes.IndexByte(src[start:], '\n')
+		if i < 0 {
+			return len(src)
+		}
+		start += i + 1
+	}
+	units := 0
+	for off := start; off < len(src); {
+		if units >= pos.Character || src[off] == '\n' {
+			return off
+		}
+		r, size := utf8.DecodeRune(src[off:])
+		units += utf16.RuneLen(r)
+		off += size
+	}
+	return len(src)
+}
+
+func utf16Len(b []byte) int {
+	n := 0
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		n += utf16.RuneLen(r)
+		b = b[size:]
+	}
+	return n
+}
+
+// uriToPath returns the file path of a file: URI. Other URIs, such as those
+// of unsaved documents, are used as the name unchanged.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	// file:///C:/dir on Windows
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+// pathToURI returns the file: URI of a path
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// lastSegment returns the span of the last segment of a qualified name, the
+// part a rename replaces
+func lastSegment(name parser.Ident) parser.Span {
+	span := name.Span
+	if i := strings.LastIndex(name.Name, "."); i >= 0 {
+		span.Start.Offset += i + 1
+		span.Start.Column += i + 1
+	}
+	return span
+}
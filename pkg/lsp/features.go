@@ -0,0 +1,555 @@
+package lsp
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/formatter"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// keywords start the declarations of the DSL in every kind of diagram
+var keywords = []string{"note", "include", "import", "classDef", "style", "cssClass"}
+
+// diagramKeywords start the declarations particular to a kind of diagram, by
+// the diagram type of the header
+var diagramKeywords = map[string][]string{
+	"activityDiagram3D":   {"action", "decision", "merge", "fork", "join", "object", "start", "end", "partition"},
+	"classDiagram3D":      {"class", "interface", "enum", "abstract", "final", "package"},
+	"componentDiagram3D":  {"component", "port", "provides", "requires"},
+	"deploymentDiagram3D": {"node", "device", "environment", "cluster", "namespace", "pod", "container", "component", "artifact", "port", "provides", "requires"},
+	"erDiagram3D":         {"entity", "schema", "tablespace"},
+	"sequenceDiagram3D":   {"participant", "actor", "zone", "activate", "deactivate", "alt", "loop", "opt", "else"},
+	"stateDiagram3D":      {"state"},
+}
+
+// builtinTypes are offered for member types besides the declared classifiers
+var builtinTypes = []string{
+	"bool", "boolean", "byte", "char", "date", "double", "float", "int",
+	"long", "short", "string", "void", "List", "Map", "Set",
+}
+
+// annotations are the names allowed after `@` in a classifier body
+var annotations = []string{"url", "pos", "layer", "near"}
+
+// identifier matches a valid new classifier name
+var identifier = regexp.MustCompile(`^[\pL_][\pL\pN_]*$`)
+
+// completion proposes keywords at the start of a line, types in member
+// lines and classifier names where a relationship, note or style names one.
+// Only class diagrams have classifiers, members and annotations; the other
+// kinds of diagram get their own keywords and style classes.
+func (d *document) completion(offset int) []CompletionItem {
+	lineStart := bytes.LastIndexByte(d.text[:offset], '\n') + 1
+	prefix := strings.TrimLeft(string(d.text[lineStart:offset]), " \t")
+	word := prefix[len(strings.TrimRightFunc(prefix, isNameRune)):]
+	before := strings.TrimSpace(strings.TrimSuffix(prefix, word))
+	first, _, _ := strings.Cut(before, " ")
+
+	items := []CompletionItem{}
+	diagramType, class := d.diagramType()
+	switch {
+	case strings.HasPrefix(prefix, "%%"):
+		return items
+	case strings.HasSuffix(before, ":::") || first == "cssClass" && strings.Count(before, `"`) == 2:
+		return d.classDefItems(items)
+	case before == "":
+		if d.file.Header == nil {
+			for _, name := range sortedKeys(diagramKeywords) {
+				items = append(items, CompletionItem{Label: "extuml " + name, Kind: CompletionSnippet, Detail: "header"})
+			}
+		}
+		for _, kw := range append(diagramKeywords[diagramType], keywords...) {
+			items = append(items, CompletionItem{Label: kw, Kind: CompletionKeyword})
+		}
+		if !class {
+			return items
+		}
+		// A relationship starts with a classifier name
+		return d.classifierItems(items)
+	case !class:
+		return items
+	case before == "@":
+		for _, name := range annotations {
+			items = append(items, CompletionItem{Label: name, Kind: CompletionProperty, Detail: "annotation"})
+		}
+		return items
+	case strings.HasPrefix(before, "@near"):
+		return d.classifierItems(items)
+	case d.inBody(lineStart):
+		// Member lines name types
+		for _, name := range builtinTypes {
+			items = append(items, CompletionItem{Label: name, Kind: CompletionTypeParam, Detail: "type"})
+		}
+		return d.classifierItems(items)
+	}
+
+	switch first {
+	case "class", "interface", "enum", "abstract", "final", "package", "include", "import", "classDef":
+		// A new name or a path follows
+		return items
+	}
+	return d.classifierItems(items)
+}
+
+// diagramType returns the diagram type of the header and whether the
+// document is a class diagram. Documents without a header or with an unknown
+// type are parsed as class diagrams, as are Mermaid and PlantUML ones.
+func (d *document) diagramType() (string, bool) {
+	if h := d.file.Header; h != nil {
+		if _, ok := diagramKeywords[h.DiagramType]; ok {
+			return h.DiagramType, h.DiagramType == "classDiagram3D"
+		}
+	}
+	return "classDiagram3D", true
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// classifierItems appends the declared classifiers, by short name where it
+// is unique
+func (d *document) classifierItems(items []CompletionItem) []CompletionItem {
+	names := d.classifierNames()
+	for _, decl := range d.symbols.Declarations {
+		kind := CompletionClass
+		switch decl.Kind {
+		case "interface":
+			kind = CompletionInterface
+		case "enum":
+			kind = CompletionEnum
+		}
+		items = append(items, CompletionItem{Label: names[decl.ID], Kind: kind, Detail: decl.Kind + " " + decl.ID})
+	}
+	return items
+}
+
+// classifierNames returns the name each classifier is referred to by: its
+// short name if no other classifier shares it, and its ID otherwise
+func (d *document) classifierNames() map[string]string {
+	count := map[string]int{}
+	for _, decl := range d.symbols.Declarations {
+		count[shortName(decl.ID)]++
+	}
+	names := map[string]string{}
+	for _, decl := range d.symbols.Declarations {
+		names[decl.ID] = decl.ID
+		if count[shortName(decl.ID)] == 1 {
+			names[decl.ID] = shortName(decl.ID)
+		}
+	}
+	return names
+}
+
+func (d *document) classDefItems(items []CompletionItem) []CompletionItem {
+	names := make([]string, 0, len(d.doc.ClassDefs))
+	for name := range d.doc.ClassDefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		items = append(items, CompletionItem{Label: name, Kind: CompletionProperty, Detail: "classDef"})
+	}
+	return items
+}
+
+// inBody reports whether the line starting at offset is inside a classifier
+// body. The text is scanned rather than the syntax tree so that bodies still
+// being typed, and not yet closed, count.
+func (d *document) inBody(offset int) bool {
+	var open []bool // for each open block, whether it is a classifier body
+	for _, line := range strings.Split(string(d.text[:offset]), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "%%"):
+		case strings.HasPrefix(line, "}"):
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		case strings.HasSuffix(line, "{"):
+			first, _, _ := strings.Cut(line, " ")
+			open = append(open, first != "package")
+		}
+	}
+	return len(open) > 0 && open[len(open)-1]
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7f
+}
+
+func shortName(id string) string {
+	return id[strings.LastIndex(id, ".")+1:]
+}
+
+// hover shows the declaration of the element named at offset: the whole
+// declaration of a classifier, and the keyword and name of other elements
+func (d *document) hover(offset int) *Hover {
+	id, name, ok := d.symbolAt(offset)
+	if !ok {
+		return nil
+	}
+	decl, _ := d.symbols.Lookup(id)
+	r := d.rangeOf(name.Span)
+	c, ok := decl.Decl.(*parser.ClassifierDecl)
+	if !ok {
+		value := "```extuml\n" + decl.Kind + " " + decl.Name.Name + "\n```\n\n`" + id + "`"
+		return &Hover{Contents: MarkupContent{Kind: "markdown", Value: value}, Range: &r}
+	}
+
+	var b strings.Builder
+	b.WriteString("```extuml\n")
+	for _, m := range c.Modifiers {
+		b.WriteString(m.Name + " ")
+	}
+	b.WriteString(c.Kind + " " + c.Name.Name)
+	if c.TypeParams != nil {
+		b.WriteString("<" + c.TypeParams.Text + ">")
+	}
+	if c.Alias != "" {
+		b.WriteString(` as "` + c.Alias + `"`)
+	}
+	var stereotypes []string
+	for _, st := range c.Stereotypes {
+		stereotypes = append(stereotypes, st.Names...)
+	}
+	if len(stereotypes) > 0 {
+		b.WriteString(" <<" + strings.Join(stereotypes, ", ") + ">>")
+	}
+	if len(c.Members) > 0 {
+		b.WriteString(" {\n")
+		for _, m := range c.Members {
+			b.WriteString("  " + m.Text + "\n")
+		}
+		b.WriteString("}")
+	}
+	b.WriteString("\n```\n\n`" + id + "`")
+	for _, ann := range c.Annotations {
+		if ann.Name == "url" && ann.Value != "" {
+			b.WriteString(" · " + ann.Value)
+		}
+	}
+
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: b.String()}, Range: &r}
+}
+
+// definition returns the declaration of the element named at offset, or
+// the file an include at offset names
+func (d *document) definition(offset int) []Location {
+	if id, _, ok := d.symbolAt(offset); ok {
+		decl, _ := d.symbols.Lookup(id)
+		return []Location{d.location(decl.Name.Span)}
+	}
+	if inc := includeAt(d.file.Decls, offset); inc != nil {
+		path := filepath.Join(filepath.Dir(d.path), inc.Path)
+		return []Location{{URI: pathToURI(path)}}
+	}
+	return nil
+}
+
+// includeAt returns the include whose path is at offset
+func includeAt(decls []parser.Decl, offset int) *parser.IncludeDecl {
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *parser.IncludeDecl:
+			if d.PathSpan.Start.Offset <= offset && offset <= d.PathSpan.End.Offset {
+				return d
+			}
+		case *parser.PackageDecl:
+			if inc := includeAt(d.Decls, offset); inc != nil {
+				return inc
+			}
+		}
+	}
+	return nil
+}
+
+// references returns every use of the element named at offset
+func (d *document) references(offset int, includeDeclaration bool) []Location {
+	id, _, ok := d.symbolAt(offset)
+	if !ok {
+		return nil
+	}
+	var locs []Location
+	if includeDeclaration {
+		decl, _ := d.symbols.Lookup(id)
+		locs = append(locs, d.location(decl.Name.Span))
+	}
+	for _, ref := range d.symbols.References {
+		if ref.ID == id {
+			locs = append(locs, d.location(ref.Name.Span))
+		}
+	}
+	return locs
+}
+
+// prepareRename returns the range a rename at offset replaces: the last
+// segment of the element name there
+func (d *document) prepareRename(offset int) *Range {
+	if _, name, ok := d.symbolAt(offset); ok {
+		r := d.rangeOf(lastSegment(name))
+		return &r
+	}
+	return nil
+}
+
+// rename renames the element named at offset in its declaration and every
+// reference. Qualified references keep their package prefix.
+func (d *document) rename(offset int, newName string) (*WorkspaceEdit, *responseError) {
+	id, _, ok := d.symbolAt(offset)
+	if !ok {
+		return nil, &responseError{codeRequestFailed, "no element to rename here"}
+	}
+	if !identifier.MatchString(newName) {
+		return nil, &responseError{codeInvalidParams, "invalid name " + `"` + newName + `"`}
+	}
+
+	decl, _ := d.symbols.Lookup(id)
+	names := []parser.Ident{decl.Name}
+	for _, ref := range d.symbols.References {
+		if ref.ID == id {
+			names = append(names, ref.Name)
+		}
+	}
+	edit := &WorkspaceEdit{Changes: map[string][]TextEdit{}}
+	for _, name := range names {
+		loc := d.location(lastSegment(name))
+		edit.Changes[loc.URI] = append(edit.Changes[loc.URI], TextEdit{Range: loc.Range, NewText: newName})
+	}
+	return edit, nil
+}
+
+// documentSymbols returns the outline of the document: packages,
+// classifiers with their members, and notes in class diagrams; the elements
+// of the other diagrams in their zones, schemas, partitions, composite
+// states and enclosing elements. An element declared by its first use is
+// listed where that use is.
+func (d *document) documentSymbols() []DocumentSymbol {
+	o := &outline{d: d, ids: map[parser.Decl]string{}}
+	for _, decl := range d.symbols.Declarations {
+		if decl.Decl != nil {
+			o.ids[decl.Decl] = decl.ID
+		} else {
+			o.implicit = append(o.implicit, decl)
+		}
+	}
+	return o.symbols(d.file.Decls)
+}
+
+// outline builds the document symbols of a document
+type outline struct {
+	d        *document
+	ids      map[parser.Decl]string // declaration -> ID of the element it declares
+	implicit []parser.Declaration   // elements declared by their first use
+}
+
+func (o *outline) symbols(decls []parser.Decl) []DocumentSymbol {
+	list := []DocumentSymbol{}
+	for _, decl := range decls {
+		switch c := decl.(type) {
+		case *parser.PackageDecl:
+			list = append(list, o.group(c.Name, c.Span, SymbolPackage, c.Decls))
+		case *parser.ZoneDecl:
+			list = append(list, o.group(c.Name, c.Span, SymbolNamespace, c.Decls))
+		case *parser.SchemaDecl:
+			list = append(list, o.group(c.Name, c.Span, SymbolNamespace, c.Decls))
+		case *parser.PartitionDecl:
+			list = append(list, o.group(c.Name, c.Span, SymbolNamespace, c.Decls))
+		case *parser.ClassifierDecl:
+			list = append(list, o.element(c, c.Kind, c.Name, c.Span, o.d.memberSymbols(c, o.ids[c])))
+		case *parser.ParticipantDecl:
+			list = append(list, o.element(c, c.Kind, c.Name, c.Span, nil))
+		case *parser.StateDecl:
+			var children []DocumentSymbol
+			for _, r := range c.Regions {
+				children = append(children, o.symbols(r.Decls)...)
+			}
+			list = append(list, o.element(c, "state", c.Name, c.Span, children))
+		case *parser.EntityDecl:
+			var columns []DocumentSymbol
+			for _, col := range c.Columns {
+				r := o.d.rangeOf(col.Span)
+				columns = append(columns, DocumentSymbol{Name: col.Name, Detail: col.Type, Kind: SymbolField, Range: r, SelectionRange: r})
+			}
+			list = append(list, o.element(c, "entity", c.Name, c.Span, columns))
+		case *parser.ComponentDecl:
+			list = append(list, o.element(c, c.Kind, c.Name, c.Span, o.symbols(c.Decls)))
+		case *parser.PortDecl:
+			list = append(list, o.element(c, "port", c.Name, c.Span, nil))
+		case *parser.ActivityNodeDecl:
+			list = append(list, o.element(c, c.Kind, c.Name, c.Span, nil))
+		case *parser.FragmentDecl:
+			// The steps of a fragment are listed with those around it
+			for _, operand := range c.Operands {
+				list = append(list, o.symbols(operand.Decls)...)
+			}
+		case *parser.NoteDecl:
+			name := "note"
+			if c.Anchor != nil {
+				name += " for " + c.Anchor.Name
+			}
+			list = append(list, o.note(name, c.Text, c.Span))
+		case *parser.SequenceNoteDecl:
+			name := "note " + c.Position
+			if c.Position != "over" {
+				name += " of"
+			}
+			var targets []string
+			for _, target := range c.Targets {
+				targets = append(targets, target.Name)
+			}
+			list = append(list, o.implicitSymbols(decl)...)
+			list = append(list, o.note(name+" "+strings.Join(targets, ", "), c.Text, c.Span))
+		default:
+			list = append(list, o.implicitSymbols(decl)...)
+		}
+	}
+	return list
+}
+
+// group returns the entry of a package, zone, schema or partition
+func (o *outline) group(name parser.Ident, span parser.Span, kind int, decls []parser.Decl) DocumentSymbol {
+	return DocumentSymbol{
+		Name:           name.Name,
+		Kind:           kind,
+		Range:          o.d.rangeOf(span),
+		SelectionRange: o.d.rangeOf(name.Span),
+		Children:       o.symbols(decls),
+	}
+}
+
+// element returns the entry of the element decl declares with the given
+// keyword
+func (o *outline) element(decl parser.Decl, kind string, name parser.Ident, span parser.Span, children []DocumentSymbol) DocumentSymbol {
+	return DocumentSymbol{
+		Name:           name.Name,
+		Detail:         o.ids[decl],
+		Kind:           symbolKind(kind),
+		Range:          o.d.rangeOf(span),
+		SelectionRange: o.d.rangeOf(name.Span),
+		Children:       children,
+	}
+}
+
+// note returns the entry of a note, detailed by its first line
+func (o *outline) note(name, text string, span parser.Span) DocumentSymbol {
+	text, _, _ = strings.Cut(strings.TrimSpace(text), "\n")
+	return DocumentSymbol{
+		Name:           name,
+		Detail:         text,
+		Kind:           SymbolString,
+		Range:          o.d.rangeOf(span),
+		SelectionRange: o.d.rangeOf(span),
+	}
+}
+
+// implicitSymbols returns the entries of the elements declared by their
+// use in decl, such as the participants of a message
+func (o *outline) implicitSymbols(decl parser.Decl) []DocumentSymbol {
+	span := parser.SpanOf(decl)
+	var list []DocumentSymbol
+	for _, e := range o.implicit {
+		name := e.Name
+		if name.Start.File == span.Start.File && span.Start.Offset <= name.Start.Offset && name.End.Offset <= span.End.Offset {
+			r := o.d.rangeOf(name.Span)
+			list = append(list, DocumentSymbol{Name: name.Name, Detail: e.ID, Kind: symbolKind(e.Kind), Range: r, SelectionRange: r})
+		}
+	}
+	return list
+}
+
+// symbolKind returns the symbol kind of an element declared with keyword
+func symbolKind(keyword string) int {
+	switch keyword {
+	case "class":
+		return SymbolClass
+	case "interface":
+		return SymbolInterface
+	case "enum":
+		return SymbolEnum
+	case "participant", "actor", "state", "object":
+		return SymbolObject
+	case "entity":
+		return SymbolStruct
+	case "port":
+		return SymbolProperty
+	case "action", "decision", "merge", "fork", "join", "start", "end":
+		return SymbolFunction
+	}
+	// Components, artifacts and deployment nodes
+	return SymbolModule
+}
+
+// memberSymbols lists the members of a classifier by the names lowering
+// parsed from them
+func (d *document) memberSymbols(c *parser.ClassifierDecl, id string) []DocumentSymbol {
+	type member struct {
+		name string
+		kind int
+	}
+	var members []member
+	for _, class := range d.doc.Elements.Classes {
+		if class.ID != id {
+			continue
+		}
+		// Lowering keeps the order of each kind of member
+		attrs, ops := class.Attributes, class.Operations
+		for _, m := range c.Members {
+			isOp := parser.IsOperation(m.Text)
+			if isOp && len(ops) > 0 {
+				members = append(members, member{ops[0].Name, SymbolMethod})
+				ops = ops[1:]
+			} else if !isOp && len(attrs) > 0 {
+				members = append(members, member{attrs[0].Name, SymbolField})
+				attrs = attrs[1:]
+			}
+		}
+	}
+	for _, iface := range d.doc.Elements.Interfaces {
+		if iface.ID == id {
+			for _, op := range iface.Operations {
+				members = append(members, member{op.Name, SymbolMethod})
+			}
+		}
+	}
+	for _, enum := range d.doc.Elements.Enums {
+		if enum.ID == id {
+			for _, literal := range enum.Literals {
+				members = append(members, member{literal, SymbolEnumMember})
+			}
+		}
+	}
+	if len(members) != len(c.Members) {
+		// Not lowered, as for a duplicate declaration
+		return nil
+	}
+
+	list := make([]DocumentSymbol, len(c.Members))
+	for i, m := range c.Members {
+		r := d.rangeOf(m.Span)
+		list[i] = DocumentSymbol{Name: members[i].name, Detail: m.Text, Kind: members[i].kind, Range: r, SelectionRange: r}
+	}
+	return list
+}
+
+// formatting replaces the document by its canonical layout. Documents with
+// syntax errors are left alone.
+func (d *document) formatting() []TextEdit {
+	formatted, _ := formatter.Source(d.path, d.text)
+	if formatted == nil || bytes.Equal(formatted, d.text) {
+		return []TextEdit{}
+	}
+	return []TextEdit{{
+		Range:   Range{End: position(d.text, len(d.text))},
+		NewText: string(formatted),
+	}}
+}
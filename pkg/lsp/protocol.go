@@ -0,0 +1,221 @@
+package lsp
+
+// The subset of the Language Server Protocol 3.17 types the server uses.
+// Positions count UTF-16 code units, as the protocol requires.
+
+// Position is a zero-based line and character offset
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is the half-open range [Start, End)
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range in a document
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// TextEdit replaces a range of a document
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// Diagnostic severities
+const (
+	SeverityError   = 1
+	SeverityWarning = 2
+)
+
+// Diagnostic is a problem reported for a document
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+// PublishDiagnosticsParams are sent with textDocument/publishDiagnostics
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Version     *int         `json:"version,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// TextDocumentIdentifier names a document
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+// TextDocumentItem is a document opened in the editor
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+// VersionedTextDocumentIdentifier names a version of a document
+type VersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+}
+
+// TextDocumentContentChangeEvent is a change to a document. Without a range
+// Text replaces the whole document.
+type TextDocumentContentChangeEvent struct {
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
+}
+
+// DidOpenTextDocumentParams are sent with textDocument/didOpen
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+// DidChangeTextDocumentParams are sent with textDocument/didChange
+type DidChangeTextDocumentParams struct {
+	TextDocument   VersionedTextDocumentIdentifier  `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+// DidCloseTextDocumentParams are sent with textDocument/didClose
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// TextDocumentPositionParams name a position in a document
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// ReferenceParams are sent with textDocument/references
+type ReferenceParams struct {
+	TextDocumentPositionParams
+	Context struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+}
+
+// RenameParams are sent with textDocument/rename
+type RenameParams struct {
+	TextDocumentPositionParams
+	NewName string `json:"newName"`
+}
+
+// WorkspaceEdit holds the edits of a rename, by document URI
+type WorkspaceEdit struct {
+	Changes map[string][]TextEdit `json:"changes"`
+}
+
+// DocumentFormattingParams are sent with textDocument/formatting
+type DocumentFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DocumentSymbolParams are sent with textDocument/documentSymbol
+type DocumentSymbolParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// Symbol kinds
+const (
+	SymbolModule     = 2
+	SymbolNamespace  = 3
+	SymbolPackage    = 4
+	SymbolClass      = 5
+	SymbolMethod     = 6
+	SymbolProperty   = 7
+	SymbolField      = 8
+	SymbolEnum       = 10
+	SymbolInterface  = 11
+	SymbolFunction   = 12
+	SymbolString     = 15
+	SymbolObject     = 19
+	SymbolEnumMember = 22
+	SymbolStruct     = 23
+)
+
+// DocumentSymbol is an entry of the document outline
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           int              `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+// Completion item kinds
+const (
+	CompletionClass     = 7
+	CompletionInterface = 8
+	CompletionProperty  = 10
+	CompletionEnum      = 13
+	CompletionKeyword   = 14
+	CompletionSnippet   = 15
+	CompletionTypeParam = 25
+)
+
+// CompletionItem is one completion proposal
+type CompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// MarkupContent is Markdown shown on hover
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Hover is the result of textDocument/hover
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+// InitializeResult is the result of initialize
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   ServerInfo         `json:"serverInfo"`
+}
+
+// ServerInfo names the server
+type ServerInfo struct {
+	Name string `json:"name"`
+}
+
+// ServerCapabilities lists the features the server provides
+type ServerCapabilities struct {
+	TextDocumentSync           int                `json:"textDocumentSync"`
+	CompletionProvider         *CompletionOptions `json:"completionProvider,omitempty"`
+	HoverProvider              bool               `json:"hoverProvider"`
+	DefinitionProvider         bool               `json:"definitionProvider"`
+	ReferencesProvider         bool               `json:"referencesProvider"`
+	DocumentSymbolProvider     bool               `json:"documentSymbolProvider"`
+	RenameProvider             *RenameOptions     `json:"renameProvider,omitempty"`
+	DocumentFormattingProvider bool               `json:"documentFormattingProvider"`
+}
+
+// CompletionOptions configure completion
+type CompletionOptions struct {
+	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
+}
+
+// RenameOptions configure rename
+type RenameOptions struct {
+	PrepareProvider bool `json:"prepareProvider"`
+}
+
+// syncFull is TextDocumentSyncKind.Full: every change sends the whole text
+const syncFull = 1
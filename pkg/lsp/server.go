@@ -0,0 +1,240 @@
+// Package lsp implements a Language Server Protocol server for .extuml
+// files: diagnostics, completion, hover, go-to-definition, references,
+// document symbols, rename and formatting.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// Server is a language server speaking LSP over a byte stream, usually
+// stdin and stdout. Documents are synchronised in full and analysed after
+// every change.
+type Server struct {
+	read parser.ReadFunc
+	conn *conn
+	docs map[string]*document // by URI
+
+	initialized bool
+	shutdown    bool
+}
+
+// NewServer creates a server that reads included files not open in the
+// editor with read
+func NewServer(read parser.ReadFunc) *Server {
+	return &Server{read: read, docs: map[string]*document{}}
+}
+
+// Serve handles the messages read from in, writing to out, until the client
+// sends exit or in ends. Exiting without a shutdown request is an error.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.conn = newConn(in, out)
+	for {
+		body, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.conn.reply(nil, nil, &responseError{codeParseError, "invalid JSON: " + err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit without shutdown")
+			}
+			return nil
+		}
+
+		if msg.ID == nil {
+			if err := s.notification(msg.Method, msg.Params); err != nil {
+				return err
+			}
+			continue
+		}
+		result, rerr := s.call(msg.Method, msg.Params)
+		if err := s.conn.reply(msg.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+// call answers a request. A panic in a handler fails the request rather
+// than the server.
+func (s *Server) call(method string, params json.RawMessage) (result any, rerr *responseError) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, rerr = nil, &responseError{codeInternalError, fmt.Sprintf("%s: %v", method, r)}
+		}
+	}()
+
+	if method == "initialize" {
+		s.initialized = true
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:           syncFull,
+				CompletionProvider:         &CompletionOptions{TriggerCharacters: []string{"@", ":", " "}},
+				HoverProvider:              true,
+				DefinitionProvider:         true,
+				ReferencesProvider:         true,
+				DocumentSymbolProvider:     true,
+				RenameProvider:             &RenameOptions{PrepareProvider: true},
+				DocumentFormattingProvider: true,
+			},
+			ServerInfo: ServerInfo{Name: "extuml"},
+		}, nil
+	}
+	if !s.initialized {
+		return nil, &responseError{codeNotInitialized, "server not initialized"}
+	}
+
+	switch method {
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/completion":
+		return withDocument(s, params, func(d *document, p TextDocumentPositionParams) (any, *responseError) {
+			return d.completion(offsetOf(d.text, p.Position)), nil
+		})
+	case "textDocument/hover":
+		return withDocument(s, params, func(d *document, p TextDocumentPositionParams) (any, *responseError) {
+			return d.hover(offsetOf(d.text, p.Position)), nil
+		})
+	case "textDocument/definition":
+		return withDocument(s, params, func(d *document, p TextDocumentPositionParams) (any, *responseError) {
+			return d.definition(offsetOf(d.text, p.Position)), nil
+		})
+	case "textDocument/references":
+		return withDocument(s, params, func(d *document, p ReferenceParams) (any, *responseError) {
+			return d.references(offsetOf(d.text, p.Position), p.Context.IncludeDeclaration), nil
+		})
+	case "textDocument/documentSymbol":
+		return withDocument(s, params, func(d *document, p DocumentSymbolParams) (any, *responseError) {
+			return d.documentSymbols(), nil
+		})
+	case "textDocument/prepareRename":
+		return withDocument(s, params, func(d *document, p TextDocumentPositionParams) (any, *responseError) {
+			return d.prepareRename(offsetOf(d.text, p.Position)), nil
+		})
+	case "textDocument/rename":
+		return withDocument(s, params, func(d *document, p RenameParams) (any, *responseError) {
+			return d.rename(offsetOf(d.text, p.Position), p.NewName)
+		})
+	case "textDocument/formatting":
+		return withDocument(s, params, func(d *document, p DocumentFormattingParams) (any, *responseError) {
+			return d.formatting(), nil
+		})
+	}
+	return nil, &responseError{codeMethodNotFound, "method not supported: " + method}
+}
+
+// withDocument decodes the parameters of a request on a document and calls
+// f with the open document they name. Requests on documents that are not
+// open have a null result.
+func withDocument[P interface{ uri() string }](s *Server, params json.RawMessage, f func(*document, P) (any, *responseError)) (any, *responseError) {
+	var p P
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &responseError{codeInvalidParams, "invalid params: " + err.Error()}
+	}
+	d, ok := s.docs[p.uri()]
+	if !ok {
+		return nil, nil
+	}
+	return f(d, p)
+}
+
+func (p TextDocumentPositionParams) uri() string { return p.TextDocument.URI }
+func (p DocumentSymbolParams) uri() string       { return p.TextDocument.URI }
+func (p DocumentFormattingParams) uri() string   { return p.TextDocument.URI }
+
+// notification handles a notification; only failing to write is an error
+func (s *Server) notification(method string, params json.RawMessage) error {
+	if !s.initialized {
+		return nil
+	}
+	switch method {
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if json.Unmarshal(params, &p) != nil {
+			return nil
+		}
+		d := &document{uri: p.TextDocument.URI, path: uriToPath(p.TextDocument.URI), version: p.TextDocument.Version, text: []byte(p.TextDocument.Text)}
+		s.docs[d.uri] = d
+		return s.changed(d)
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if json.Unmarshal(params, &p) != nil {
+			return nil
+		}
+		d, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			return nil
+		}
+		for _, change := range p.ContentChanges {
+			if change.Range == nil {
+				d.text = []byte(change.Text)
+				continue
+			}
+			start, end := offsetOf(d.text, change.Range.Start), offsetOf(d.text, change.Range.End)
+			d.text = append(append(append([]byte(nil), d.text[:start]...), change.Text...), d.text[end:]...)
+		}
+		d.version = p.TextDocument.Version
+		return s.changed(d)
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if json.Unmarshal(params, &p) != nil {
+			return nil
+		}
+		delete(s.docs, p.TextDocument.URI)
+		return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	}
+	return nil
+}
+
+// changed analyses d and then every other open document including it, and
+// publishes their diagnostics
+func (s *Server) changed(d *document) error {
+	affected := []*document{d}
+	var uris []string
+	for uri, other := range s.docs {
+		if other != d && other.includes(d.path) {
+			uris = append(uris, uri)
+		}
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		affected = append(affected, s.docs[uri])
+	}
+
+	for _, doc := range affected {
+		doc.analyse(s.readSource)
+		version := doc.version
+		params := PublishDiagnosticsParams{URI: doc.uri, Version: &version, Diagnostics: doc.diagnostics()}
+		if err := s.conn.notify("textDocument/publishDiagnostics", params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSource returns the text of a file, as edited if it is open
+func (s *Server) readSource(path string) ([]byte, error) {
+	for _, d := range s.docs {
+		if d.path == path {
+			return d.text, nil
+		}
+	}
+	return s.read(path)
+}
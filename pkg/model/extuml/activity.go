@@ -0,0 +1,56 @@
+package extuml
+
+// Activity is the content of an activity diagram. Partitions do not qualify
+// the IDs of their nodes: a node names the partition it belongs to, and
+// flows cross freely between partitions.
+type Activity struct {
+	Nodes      []ActivityNode `json:"nodes"`
+	Flows      []Flow         `json:"flows"`
+	Partitions []Partition    `json:"partitions,omitempty"`
+	Notes      []Note         `json:"notes,omitempty"`
+}
+
+// Activity node kinds, each declared with the keyword of the same name
+const (
+	ActivityAction   = "action"
+	ActivityDecision = "decision"
+	ActivityMerge    = "merge"
+	ActivityFork     = "fork"
+	ActivityJoin     = "join"
+	ActivityObject   = "object"
+	ActivityStart    = "start"
+	ActivityEnd      = "end"
+)
+
+type ActivityNode struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"` // one of the Activity constants
+	Name         string   `json:"name,omitempty"`
+	Partition    string   `json:"partition,omitempty"` // ID of its partition
+	StyleClasses []string `json:"styleClasses,omitempty"`
+	Style        *Style   `json:"style,omitempty"`
+}
+
+// Flow kinds: a flow from or to an object node is an object flow
+const (
+	FlowControl = "control"
+	FlowObject  = "object"
+)
+
+// Flow is an edge `From --> To : [guard] label`, guard and label being
+// optional
+type Flow struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Guard string `json:"guard,omitempty"`
+	Label string `json:"label,omitempty"`
+}
+
+// Partition is a swimlane, drawn as a vertical plane of its own
+type Partition struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Nodes []string `json:"nodes"` // IDs of its nodes, in order
+}
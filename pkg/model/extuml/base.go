@@ -2,11 +2,36 @@ package extuml
 
 // Minimal structures to parse header if needed in future extensions.
 type Document struct {
-	Version  string    `json:"version"`
-	Meta     *Meta     `json:"meta,omitempty"`
-	Elements *Elements `json:"elements,omitempty"`
+	Version      string        `json:"version"`
+	Meta         *Meta         `json:"meta,omitempty"`
+	Diagram      string        `json:"diagram,omitempty"` // one of the Diagram constants
+	Elements     *Elements     `json:"elements,omitempty"`
+	Sequence     *Sequence     `json:"sequence,omitempty"`
+	StateMachine *StateMachine `json:"stateMachine,omitempty"`
+	ER           *ERModel      `json:"er,omitempty"`
+	Architecture *Architecture `json:"architecture,omitempty"` // component and deployment diagrams
+	Activity     *Activity     `json:"activity,omitempty"`
+
+	// Config holds diagram-level settings from the front matter `config:`
+	// block and `%%{ init: ... }%%` directives
+	Config map[string]any `json:"config,omitempty"`
+
+	// ClassDefs are the named styles declared with `classDef`
+	ClassDefs map[string]Style `json:"classDefs,omitempty"`
 }
 
+// Diagram kinds, the values of Document.Diagram. Class diagrams, whose
+// content is in Elements, leave it empty.
+const (
+	DiagramClass      = ""
+	DiagramSequence   = "sequence"
+	DiagramState      = "state"
+	DiagramER         = "er"
+	DiagramComponent  = "component"
+	DiagramDeployment = "deployment"
+	DiagramActivity   = "activity"
+)
+
 type Meta struct {
 	ID     string `json:"id,omitempty"`
 	Title  string `json:"title,omitempty"`
@@ -19,31 +44,58 @@ type Elements struct {
 	Enums      []Enum      `json:"enums,omitempty"`
 	Packages   []Package   `json:"packages,omitempty"`
 	Notes      []Note      `json:"notes,omitempty"`
+
+	Relationships []Relationship `json:"relationships,omitempty"`
 }
 
 type Class struct {
-	ID         string      `json:"id"`
-	Type       string      `json:"type"`
-	Name       string      `json:"name"`
-	URL        string      `json:"url,omitempty"`
-	Attributes []Attribute `json:"attributes,omitempty"`
-	Operations []Operation `json:"operations,omitempty"`
+	ID             string      `json:"id"`
+	Type           string      `json:"type"`
+	Name           string      `json:"name"`
+	URL            string      `json:"url,omitempty"`
+	Stereotypes    []string    `json:"stereotypes,omitempty"`
+	Abstract       bool        `json:"abstract,omitempty"`
+	Final          bool        `json:"final,omitempty"`
+	TypeParameters []string    `json:"typeParameters,omitempty"`
+	Attributes     []Attribute `json:"attributes,omitempty"`
+	Operations     []Operation `json:"operations,omitempty"`
+	Placement      *Placement  `json:"placement,omitempty"`
+	StyleClasses   []string    `json:"styleClasses,omitempty"`
+	Style          *Style      `json:"style,omitempty"`
 }
 
 type Interface struct {
-	ID         string      `json:"id"`
-	Type       string      `json:"type"`
-	Name       string      `json:"name"`
-	URL        string      `json:"url,omitempty"`
-	Operations []Operation `json:"operations,omitempty"`
+	ID             string      `json:"id"`
+	Type           string      `json:"type"`
+	Name           string      `json:"name"`
+	URL            string      `json:"url,omitempty"`
+	Stereotypes    []string    `json:"stereotypes,omitempty"`
+	TypeParameters []string    `json:"typeParameters,omitempty"`
+	Operations     []Operation `json:"operations,omitempty"`
+	Placement      *Placement  `json:"placement,omitempty"`
+	StyleClasses   []string    `json:"styleClasses,omitempty"`
+	Style          *Style      `json:"style,omitempty"`
 }
 
 type Enum struct {
-	ID       string   `json:"id"`
-	Type     string   `json:"type"`
-	Name     string   `json:"name"`
-	URL      string   `json:"url,omitempty"`
-	Literals []string `json:"literals,omitempty"`
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	Name         string     `json:"name"`
+	URL          string     `json:"url,omitempty"`
+	Stereotypes  []string   `json:"stereotypes,omitempty"`
+	Literals     []string   `json:"literals,omitempty"`
+	Placement    *Placement `json:"placement,omitempty"`
+	StyleClasses []string   `json:"styleClasses,omitempty"`
+	Style        *Style     `json:"style,omitempty"`
+}
+
+// Placement holds explicit layout hints from the `@pos`, `@layer` and `@near`
+// annotations. Unset fields leave the decision to automatic layout; an
+// explicit Position takes precedence over Near and Layer.
+type Placement struct {
+	Position *[3]float64 `json:"position,omitempty"` // absolute x, y, z
+	Layer    *int        `json:"layer,omitempty"`    // depth layer, 0 is the front
+	Near     string      `json:"near,omitempty"`     // ID of the element to sit next to
 }
 
 type Package struct {
@@ -60,12 +112,50 @@ type Note struct {
 	Anchor string `json:"anchor,omitempty"`
 }
 
+// Relationship kinds. The decorated end (arrowhead, triangle or diamond) is
+// always To; undecorated links keep the order they were written in.
+const (
+	RelInheritance = "inheritance"
+	RelRealization = "realization"
+	RelAssociation = "association"
+	RelAggregation = "aggregation"
+	RelComposition = "composition"
+	RelDependency  = "dependency"
+	RelLink        = "link"
+	RelDashedLink  = "dashedLink"
+)
+
+type Relationship struct {
+	ID               string `json:"id"`
+	Type             string `json:"type"`
+	From             string `json:"from"`
+	To               string `json:"to"`
+	Label            string `json:"label,omitempty"`
+	FromMultiplicity string `json:"fromMultiplicity,omitempty"`
+	ToMultiplicity   string `json:"toMultiplicity,omitempty"`
+}
+
 type Attribute struct {
-	Name string `json:"name"`
-	Type string `json:"type,omitempty"`
+	Name         string `json:"name"`
+	Type         string `json:"type,omitempty"`
+	Visibility   string `json:"visibility,omitempty"`
+	Static       bool   `json:"static,omitempty"`
+	Default      string `json:"default,omitempty"`
+	Multiplicity string `json:"multiplicity,omitempty"`
 }
 
 type Operation struct {
-	Name       string `json:"name"`
-	ReturnType string `json:"returnType,omitempty"`
+	Name       string      `json:"name"`
+	ReturnType string      `json:"returnType,omitempty"`
+	Visibility string      `json:"visibility,omitempty"`
+	Static     bool        `json:"static,omitempty"`
+	Abstract   bool        `json:"abstract,omitempty"`
+	Parameters []Parameter `json:"parameters,omitempty"`
+}
+
+type Parameter struct {
+	Name      string `json:"name"`
+	Type      string `json:"type,omitempty"`
+	Default   string `json:"default,omitempty"`
+	Direction string `json:"direction,omitempty"`
 }
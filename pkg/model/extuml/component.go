@@ -0,0 +1,83 @@
+package extuml
+
+// Architecture is the content of a component or deployment diagram.
+// Elements nested in a component or deployment node are qualified by its
+// ID, as classifiers are by their package, and so are ports by the element
+// they belong to.
+type Architecture struct {
+	Components []Component      `json:"components"`
+	Nodes      []DeploymentNode `json:"nodes,omitempty"`
+	Artifacts  []Artifact       `json:"artifacts,omitempty"`
+	Connectors []Connector      `json:"connectors"`
+	Notes      []Note           `json:"notes,omitempty"`
+}
+
+// Component is a component with its ports and the interfaces it provides
+// and requires, drawn as lollipops and sockets
+type Component struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"` // "component"
+	Name         string   `json:"name"`
+	Parent       string   `json:"parent,omitempty"` // ID of the enclosing component or node
+	Stereotypes  []string `json:"stereotypes,omitempty"`
+	Ports        []Port   `json:"ports,omitempty"`
+	Provides     []string `json:"provides,omitempty"`
+	Requires     []string `json:"requires,omitempty"`
+	StyleClasses []string `json:"styleClasses,omitempty"`
+	Style        *Style   `json:"style,omitempty"`
+}
+
+type Port struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Deployment node kinds. Clusters, namespaces, pods and containers nest in
+// that order; plain nodes, devices and execution environments nest freely.
+const (
+	NodeDefault     = "node"
+	NodeDevice      = "device"
+	NodeEnvironment = "environment"
+	NodeCluster     = "cluster"
+	NodeNamespace   = "namespace"
+	NodePod         = "pod"
+	NodeContainer   = "container"
+)
+
+// DeploymentNode is a node of a deployment diagram, drawn as a container
+// around the nodes, components and artifacts deployed to it
+type DeploymentNode struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"` // one of the Node constants
+	Name         string   `json:"name"`
+	Parent       string   `json:"parent,omitempty"` // ID of the enclosing node
+	Stereotypes  []string `json:"stereotypes,omitempty"`
+	Ports        []Port   `json:"ports,omitempty"`
+	StyleClasses []string `json:"styleClasses,omitempty"`
+	Style        *Style   `json:"style,omitempty"`
+}
+
+type Artifact struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"` // "artifact"
+	Name         string   `json:"name"`
+	Parent       string   `json:"parent,omitempty"` // ID of the enclosing node
+	Stereotypes  []string `json:"stereotypes,omitempty"`
+	StyleClasses []string `json:"styleClasses,omitempty"`
+	Style        *Style   `json:"style,omitempty"`
+}
+
+// Connector links two elements, or ports or interfaces of them. Its type is
+// one of the relationship kinds RelLink, RelDashedLink, RelAssociation (a
+// directed connector) and RelDependency.
+type Connector struct {
+	ID            string `json:"id"`
+	Type          string `json:"type"`
+	From          string `json:"from"`
+	FromPort      string `json:"fromPort,omitempty"`      // ID of the port at From
+	FromInterface string `json:"fromInterface,omitempty"` // name of the interface From provides or requires
+	To            string `json:"to"`
+	ToPort        string `json:"toPort,omitempty"`
+	ToInterface   string `json:"toInterface,omitempty"`
+	Label         string `json:"label,omitempty"`
+}
@@ -0,0 +1,66 @@
+package extuml
+
+// ERModel is the content of an entity-relationship diagram. Entities
+// declared in a schema or tablespace block are qualified by its ID, as
+// classifiers are by their package.
+type ERModel struct {
+	Entities      []Entity         `json:"entities"`
+	Relationships []ERRelationship `json:"relationships"`
+	Schemas       []Schema         `json:"schemas,omitempty"`
+	Notes         []Note           `json:"notes,omitempty"`
+}
+
+type Entity struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"` // "entity"
+	Name         string   `json:"name"`
+	Schema       string   `json:"schema,omitempty"` // ID of the enclosing schema
+	Columns      []Column `json:"columns"`
+	StyleClasses []string `json:"styleClasses,omitempty"`
+	Style        *Style   `json:"style,omitempty"`
+}
+
+// Column keys
+const (
+	KeyPrimary = "PK"
+	KeyForeign = "FK"
+	KeyUnique  = "UK"
+)
+
+type Column struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Keys    []string `json:"keys,omitempty"`
+	Comment string   `json:"comment,omitempty"`
+}
+
+// Cardinalities of the ends of a relationship, written in crow's foot
+// notation as `|o`, `||`, `}o` and `}|` on the left and mirrored on the
+// right
+const (
+	CardinalityZeroOrOne  = "zeroOrOne"
+	CardinalityExactlyOne = "exactlyOne"
+	CardinalityZeroOrMore = "zeroOrMore"
+	CardinalityOneOrMore  = "oneOrMore"
+)
+
+// ERRelationship is a relationship between two entities. An identifying
+// relationship, written with `--`, is drawn solid; `..` is non-identifying
+// and dashed.
+type ERRelationship struct {
+	ID              string `json:"id"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	FromCardinality string `json:"fromCardinality"`
+	ToCardinality   string `json:"toCardinality"`
+	Identifying     bool   `json:"identifying"`
+	Label           string `json:"label,omitempty"`
+}
+
+// Schema groups entities, drawn at a depth of their own
+type Schema struct {
+	ID       string   `json:"id"`
+	Type     string   `json:"type"` // "schema" or "tablespace"
+	Name     string   `json:"name"`
+	Entities []string `json:"entities"`
+}
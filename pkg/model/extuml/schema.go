@@ -0,0 +1,10 @@
+package extuml
+
+import _ "embed"
+
+// DocumentSchema is the JSON Schema of Document, which .json and .yaml inputs
+// are validated against. It is published with the viewer as
+// document.schema.json.
+//
+//go:embed document.schema.json
+var DocumentSchema []byte
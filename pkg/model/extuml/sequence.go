@@ -0,0 +1,108 @@
+package extuml
+
+// Sequence is the content of a sequence diagram. Time is counted in steps:
+// every message and note, every fragment header and `else`, and the end of
+// every fragment takes one step, in the order they are written.
+type Sequence struct {
+	Participants []Participant  `json:"participants"`
+	Zones        []Zone         `json:"zones,omitempty"`
+	Messages     []Message      `json:"messages"`
+	Activations  []Activation   `json:"activations,omitempty"`
+	Fragments    []Fragment     `json:"fragments,omitempty"`
+	Notes        []SequenceNote `json:"notes,omitempty"`
+	Steps        int            `json:"steps"` // number of steps
+}
+
+// Participant kinds
+const (
+	ParticipantDefault = "participant"
+	ParticipantActor   = "actor"
+)
+
+type Participant struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"` // ParticipantDefault or ParticipantActor
+	Name         string   `json:"name"`
+	Zone         string   `json:"zone,omitempty"` // ID of the enclosing zone
+	StyleClasses []string `json:"styleClasses,omitempty"`
+	Style        *Style   `json:"style,omitempty"`
+}
+
+// Zone groups participants, such as the services of one deployment zone,
+// at a depth of their own
+type Zone struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	Name         string   `json:"name"`
+	Participants []string `json:"participants"`
+}
+
+// Message kinds
+const (
+	MessageSynchronous  = "synchronous"
+	MessageAsynchronous = "asynchronous"
+	MessageReturn       = "return"
+)
+
+type Message struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label,omitempty"`
+	Step  int    `json:"step"`
+}
+
+// Activation is an activation bar on a participant's lifeline from step
+// Start to step End. Level counts the activations of the same participant
+// it is nested in.
+type Activation struct {
+	Participant string `json:"participant"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Level       int    `json:"level,omitempty"`
+}
+
+// Fragment kinds
+const (
+	FragmentAlt  = "alt"
+	FragmentLoop = "loop"
+	FragmentOpt  = "opt"
+)
+
+// Fragment is a combined fragment framing the steps from its header at
+// Start to its end at End. Participants lists the participants taking part
+// in it, in diagram order, and Level the fragments it is nested in.
+type Fragment struct {
+	ID           string    `json:"id"`
+	Kind         string    `json:"kind"`
+	Start        int       `json:"start"`
+	End          int       `json:"end"`
+	Level        int       `json:"level,omitempty"`
+	Operands     []Operand `json:"operands"`
+	Participants []string  `json:"participants"`
+}
+
+// Operand is one guarded part of a fragment, from the step of its header
+// or `else` at Start to End
+type Operand struct {
+	Guard string `json:"guard,omitempty"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Note positions relative to the participants of a sequence note
+const (
+	NoteOver  = "over"
+	NoteLeft  = "left"
+	NoteRight = "right"
+)
+
+type SequenceNote struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	Text         string   `json:"text"`
+	Position     string   `json:"position"`
+	Participants []string `json:"participants"`
+	Step         int      `json:"step"`
+}
@@ -0,0 +1,44 @@
+package extuml
+
+// StateMachine is the content of a state machine diagram. States nested in
+// a composite state name it as their Parent, and Region tells which of its
+// concurrent regions they belong to.
+type StateMachine struct {
+	States      []State      `json:"states"`
+	Transitions []Transition `json:"transitions"`
+	Notes       []Note       `json:"notes,omitempty"`
+}
+
+// State kinds. Initial and final pseudo-states are written `[*]`; choice,
+// fork and join nodes are states marked `<<choice>>`, `<<fork>>` or
+// `<<join>>`.
+const (
+	StateSimple  = "state"
+	StateInitial = "initial"
+	StateFinal   = "final"
+	StateChoice  = "choice"
+	StateFork    = "fork"
+	StateJoin    = "join"
+)
+
+type State struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	Name         string   `json:"name,omitempty"`
+	Parent       string   `json:"parent,omitempty"`  // ID of the enclosing composite state
+	Region       int      `json:"region,omitempty"`  // index of the region of Parent
+	Regions      int      `json:"regions,omitempty"` // number of regions of a composite state
+	StyleClasses []string `json:"styleClasses,omitempty"`
+	Style        *Style   `json:"style,omitempty"`
+}
+
+// Transition is a transition labelled `event [guard] / action`, each part
+// being optional
+type Transition struct {
+	ID     string `json:"id"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Event  string `json:"event,omitempty"`
+	Guard  string `json:"guard,omitempty"`
+	Action string `json:"action,omitempty"`
+}
@@ -0,0 +1,146 @@
+package extuml
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Style holds the colours set by `classDef`, `:::`, `cssClass` and `style`.
+// Colours are CSS hex values (`#f66`, `#ff6666`, `#ff666680`) or one of the
+// names in NamedColors; empty fields are inherited.
+//
+// Classifiers list the classDefs applied with `:::` or `cssClass` in
+// StyleClasses, in order, and keep the colours of `style` statements, which
+// override them, in Style.
+type Style struct {
+	Fill   string `json:"fill,omitempty"`   // translucent fill inside the wireframe
+	Stroke string `json:"stroke,omitempty"` // wireframe colour
+	Color  string `json:"color,omitempty"`  // label text colour
+}
+
+// Merge returns s with the fields set in other overriding its own
+func (s Style) Merge(other Style) Style {
+	if other.Fill != "" {
+		s.Fill = other.Fill
+	}
+	if other.Stroke != "" {
+		s.Stroke = other.Stroke
+	}
+	if other.Color != "" {
+		s.Color = other.Color
+	}
+	return s
+}
+
+// Theme is a named palette providing the default style of each element kind
+type Theme struct {
+	Class        Style
+	Interface    Style
+	Enum         Style
+	Package      Style // Fill is used for the translucent volume
+	Note         Style
+	Relationship Style
+	Text         string // label colour, empty for the viewer's default
+	Background   string // viewer background, empty for the viewer's default
+}
+
+// DefaultTheme is used when no theme is selected
+const DefaultTheme = "default"
+
+// Themes are the palettes selectable with `config.theme` in the front matter
+// or `%%{ init: { "theme": "dark" } }%%`
+var Themes = map[string]Theme{
+	DefaultTheme: {
+		Class:        Style{Stroke: "#3399cc"},
+		Interface:    Style{Stroke: "#cc9933"},
+		Enum:         Style{Stroke: "#99cc99"},
+		Package:      Style{Fill: "#9999e6"},
+		Note:         Style{Stroke: "#e6cc4d"},
+		Relationship: Style{Stroke: "#4d4d4d"},
+	},
+	"dark": {
+		Class:        Style{Stroke: "#4fc3f7"},
+		Interface:    Style{Stroke: "#ffb74d"},
+		Enum:         Style{Stroke: "#81c784"},
+		Package:      Style{Fill: "#7986cb"},
+		Note:         Style{Stroke: "#fff176"},
+		Relationship: Style{Stroke: "#b0bec5"},
+		Text:         "#e0f2f1",
+		Background:   "#1e1e24",
+	},
+	"forest": {
+		Class:        Style{Stroke: "#2e7d32"},
+		Interface:    Style{Stroke: "#827717"},
+		Enum:         Style{Stroke: "#558b2f"},
+		Package:      Style{Fill: "#a5d6a7"},
+		Note:         Style{Stroke: "#c0ca33"},
+		Relationship: Style{Stroke: "#33691e"},
+		Text:         "#1b3d1f",
+		Background:   "#f1f8e9",
+	},
+	"neutral": {
+		Class:        Style{Stroke: "#424242"},
+		Interface:    Style{Stroke: "#616161"},
+		Enum:         Style{Stroke: "#757575"},
+		Package:      Style{Fill: "#bdbdbd"},
+		Note:         Style{Stroke: "#9e9e9e"},
+		Relationship: Style{Stroke: "#212121"},
+		Text:         "#212121",
+		Background:   "#fafafa",
+	},
+}
+
+// NamedColors are the colour names accepted besides hex values
+var NamedColors = map[string]string{
+	"black":       "#000000",
+	"white":       "#ffffff",
+	"gray":        "#808080",
+	"grey":        "#808080",
+	"red":         "#ff0000",
+	"green":       "#008000",
+	"blue":        "#0000ff",
+	"yellow":      "#ffff00",
+	"orange":      "#ffa500",
+	"purple":      "#800080",
+	"pink":        "#ffc0cb",
+	"brown":       "#a52a2a",
+	"cyan":        "#00ffff",
+	"magenta":     "#ff00ff",
+	"transparent": "#00000000",
+}
+
+// ParseColor converts a colour to RGBA factors in [0, 1]. The alpha
+// factor is 1 unless the hex value has an alpha component.
+func ParseColor(s string) (rgba [4]float64, ok bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if hex, named := NamedColors[s]; named {
+		s = hex
+	}
+	if !strings.HasPrefix(s, "#") {
+		return rgba, false
+	}
+	hex := s[1:]
+	switch len(hex) {
+	case 3, 4:
+		// Short form: each digit is doubled
+		var long strings.Builder
+		for _, c := range hex {
+			long.WriteRune(c)
+			long.WriteRune(c)
+		}
+		hex = long.String()
+	case 6, 8:
+	default:
+		return rgba, false
+	}
+
+	rgba[3] = 1
+	for i := 0; i < len(hex)/2; i++ {
+		v, err := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return [4]float64{}, false
+		}
+		rgba[i] = float64(v) / 255
+	}
+	return rgba, true
+}
@@ -0,0 +1,38 @@
+package extuml
+
+// Member visibilities
+const (
+	VisibilityPublic    = "public"
+	VisibilityPrivate   = "private"
+	VisibilityProtected = "protected"
+	VisibilityPackage   = "package"
+)
+
+// Parameter directions
+const (
+	DirectionIn    = "in"
+	DirectionOut   = "out"
+	DirectionInOut = "inout"
+)
+
+var visibilitySymbols = map[string]string{
+	VisibilityPublic:    "+",
+	VisibilityPrivate:   "-",
+	VisibilityProtected: "#",
+	VisibilityPackage:   "~",
+}
+
+// VisibilitySymbol returns the UML marker (+ - # ~) for a visibility, or ""
+func VisibilitySymbol(visibility string) string {
+	return visibilitySymbols[visibility]
+}
+
+// VisibilityFromSymbol returns the visibility for a UML marker, or ""
+func VisibilityFromSymbol(symbol byte) string {
+	for visibility, s := range visibilitySymbols {
+		if s[0] == symbol {
+			return visibility
+		}
+	}
+	return ""
+}
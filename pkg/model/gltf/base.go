@@ -54,6 +54,7 @@ type Material struct {
 	Name                 string                `json:"name,omitempty"`
 	PbrMetallicRoughness *PbrMetallicRoughness `json:"pbrMetallicRoughness,omitempty"`
 	EmissiveFactor       []float64             `json:"emissiveFactor,omitempty"`
+	AlphaMode            string                `json:"alphaMode,omitempty"`
 	DoubleSided          bool                  `json:"doubleSided,omitempty"`
 }
 
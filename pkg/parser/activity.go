@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// activityKinds are the keywords declaring the nodes of an activity diagram
+var activityKinds = map[string]bool{
+	extuml.ActivityAction:   true,
+	extuml.ActivityDecision: true,
+	extuml.ActivityMerge:    true,
+	extuml.ActivityFork:     true,
+	extuml.ActivityJoin:     true,
+	extuml.ActivityObject:   true,
+	extuml.ActivityStart:    true,
+	extuml.ActivityEnd:      true,
+}
+
+// parseActivityDecls parses the statements of an activity diagram up to the
+// end of file or, inside a partition, up to its closing '}'. what describes
+// the partition opened at open and is empty at the top level.
+func (p *parser) parseActivityDecls(open Span, what string) (decls []Decl, end Pos, closed bool) {
+	for {
+		p.skipBlank()
+		t := p.peek()
+		switch {
+		case t.Kind == TokenEOF:
+			if what != "" {
+				p.diags.Add(open, diagnostic.CodeUnterminatedBlock, "%s is not closed (missing '}')", what)
+			}
+			return decls, t.Span.Start, false
+		case t.Kind == TokenRBrace:
+			if what != "" {
+				p.advance()
+				return decls, t.Span.End, true
+			}
+			p.diags.Add(t.Span, diagnostic.CodeUnexpectedCloseBrace, "unexpected '}' without an open block")
+			p.skipLine()
+		default:
+			if d := p.parseActivityDecl(what == ""); d != nil {
+				decls = append(decls, d)
+			}
+		}
+	}
+}
+
+// parseActivityDecl parses one statement of an activity diagram; top
+// reports whether it is at the top level of the file
+func (p *parser) parseActivityDecl(top bool) Decl {
+	t := p.peek()
+	if t.Kind == TokenIdent && p.peekAt(1).Kind == TokenArrow {
+		if d := p.parseFlow(); d != nil {
+			return d
+		}
+		return nil
+	}
+
+	if t.Kind == TokenIdent {
+		next := p.peekAt(1)
+		switch {
+		case activityKinds[t.Text] && (next.Kind == TokenIdent || t.Text == extuml.ActivityStart || t.Text == extuml.ActivityEnd):
+			if d := p.parseActivityNode(); d != nil {
+				return d
+			}
+			return nil
+		case t.Text == "partition" && next.Kind == TokenIdent:
+			if !top {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "a partition cannot be nested in a partition")
+				p.skipLine()
+				return nil
+			}
+			if d := p.parsePartition(); d != nil {
+				return d
+			}
+			return nil
+		case t.Text == "note":
+			if d := p.parseNote(); d != nil {
+				return d
+			}
+			return nil
+		case (t.Text == "include" || t.Text == "import") && p.isIncludeStart():
+			if !top {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "%s is only allowed at the top level", t.Text)
+				p.skipLine()
+				return nil
+			}
+			if d := p.parseInclude(); d != nil {
+				return d
+			}
+			return nil
+		case (t.Text == "classDef" || t.Text == "style" || t.Text == "cssClass") && p.isStyleStart():
+			if d := p.parseStyleDecl(); d != nil {
+				return d
+			}
+			return nil
+		case top && metaKeys[t.Text] && next.Kind == TokenPunct && next.Text == ":":
+			p.parseMetaLine()
+			return nil
+		}
+	}
+
+	p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "unexpected %s; expected an activity node, partition or flow", t.describe())
+	p.skipLine()
+	return nil
+}
+
+// parseActivityNode parses `kind Name [as "Display Name"]` followed by an
+// optional `:::class`. `start` and `end` may stand alone.
+func (p *parser) parseActivityNode() *ActivityNodeDecl {
+	kw := p.advance()
+	d := &ActivityNodeDecl{Span: kw.Span, Kind: kw.Text}
+	if t := p.peek(); t.Kind == TokenIdent && t.Text != "as" {
+		name, ok := p.expectName(kw.Text + " name")
+		if !ok {
+			p.skipLine()
+			return nil
+		}
+		d.Name = name
+		d.End = name.End
+	} else if kw.Text == extuml.ActivityStart || kw.Text == extuml.ActivityEnd {
+		d.Name = Ident{Span: kw.Span, Name: kw.Text}
+	} else {
+		p.diags.Add(t.Span, diagnostic.CodeExpected, "expected %s name, found %s", kw.Text, t.describe())
+		p.skipLine()
+		return nil
+	}
+
+	for {
+		t := p.peek()
+		if t.Kind == TokenIdent && t.Text == "as" && d.Alias == "" {
+			alias, end, ok := p.parseDisplayName()
+			if !ok {
+				p.skipLine()
+				return d
+			}
+			d.Alias, d.End = alias, end
+		} else if p.isClassShorthand() {
+			class := p.parseClassShorthand()
+			d.Classes = append(d.Classes, class)
+			d.End = class.End
+		} else {
+			break
+		}
+	}
+	p.expectLineEnd()
+	return d
+}
+
+// parsePartition parses `partition Name [as "Display Name"] { ... }`
+func (p *parser) parsePartition() *PartitionDecl {
+	kw := p.advance()
+	d := &PartitionDecl{Span: kw.Span}
+	name, ok := p.expectName("partition name")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Name = name
+	d.End = name.End
+	if t := p.peek(); t.Kind == TokenIdent && t.Text == "as" {
+		alias, end, ok := p.parseDisplayName()
+		if !ok {
+			p.skipLine()
+			return nil
+		}
+		d.Alias, d.End = alias, end
+	}
+
+	if t := p.peek(); t.Kind != TokenLBrace {
+		p.diags.Add(t.Span, diagnostic.CodeExpected, "expected '{' after partition name, found %s", t.describe())
+		p.skipLine()
+		return d
+	}
+	p.advance()
+	p.expectLineEnd()
+
+	var closed bool
+	d.Decls, d.End, closed = p.parseActivityDecls(d.Span, fmt.Sprintf("partition %q", name.Name))
+	if closed {
+		p.expectLineEnd()
+	}
+	return d
+}
+
+// parseFlow parses `From --> To [: [guard] label]`
+func (p *parser) parseFlow() *FlowDecl {
+	from := p.advance()
+	d := &FlowDecl{Span: from.Span, From: Ident{Span: from.Span, Name: from.Text}}
+
+	if op := p.advance(); op.Text != "-->" {
+		p.diags.Add(op.Span, diagnostic.CodeUnexpectedToken,
+			"operator %q cannot be used in an activity diagram (expected -->)", op.Text)
+		p.skipLine()
+		return nil
+	}
+	to, ok := p.expectIdent("flow target")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.To = to
+	d.End = to.End
+
+	if t := p.peek(); t.Kind == TokenPunct && t.Text == ":" {
+		p.advance()
+		label, span := p.restOfLine()
+		d.Label = label
+		if label != "" {
+			d.End = span.End
+		}
+		return d
+	}
+	p.expectLineEnd()
+	return d
+}
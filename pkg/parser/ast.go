@@ -0,0 +1,412 @@
+package parser
+
+// File is the root of a parsed .extuml source file
+type File struct {
+	Span
+	Name        string
+	FrontMatter *Span // the `---` block, if any
+	Meta        []*MetaEntry
+	Directives  []*Directive
+	Header      *Header
+	Decls       []Decl
+	Comments    []*Comment
+}
+
+// Header is the `extuml classDiagram3D` or `extuml sequenceDiagram3D` line
+type Header struct {
+	Span
+	DiagramType string
+}
+
+// MetaEntry is a `key: value` setting from the front matter or a top-level
+// `title:`, `author:` or `id:` line. Nested front matter keys are joined with
+// dots, as in `config.theme`.
+type MetaEntry struct {
+	Span
+	Key   string
+	Value string
+}
+
+// Directive is a `%%{ name: value }%%` block such as
+// `%%{ init: { "theme": "dark" } }%%`
+type Directive struct {
+	Span
+	Name  string
+	Value string
+}
+
+// Comment is a `%%` line comment
+type Comment struct {
+	Span
+	Text string
+}
+
+// Ident is a (possibly qualified) name with its position
+type Ident struct {
+	Span
+	Name string
+}
+
+// Decl is a top-level or package-level declaration
+type Decl interface {
+	declSpan() Span
+}
+
+// ClassifierDecl is a `class`, `interface` or `enum` declaration
+type ClassifierDecl struct {
+	Span
+	Modifiers   []Ident // `abstract` or `final` before `class`
+	Kind        string  // "class", "interface" or "enum"
+	Name        Ident
+	Alias       string // display name from `as "..."` or `["..."]`
+	TypeParams  *TypeParams
+	Stereotypes []*Stereotype
+	Classes     []Ident // classDefs applied with `:::`
+	Members     []*Member
+	Annotations []*Annotation
+}
+
+// TypeParams is the generic parameter list after a classifier name, written
+// `~T~` (Mermaid) or `<T>`. Text holds the raw list between the delimiters.
+type TypeParams struct {
+	Span
+	Text string
+}
+
+// Stereotype is a `<<name>>` on the declaration line or in the body. Several
+// names may be given separated by commas.
+type Stereotype struct {
+	Span
+	Names []string
+}
+
+// Member is one line in a classifier body, kept as raw text and parsed by
+// the member grammar during lowering
+type Member struct {
+	Span
+	Text string
+}
+
+// Annotation is an `@name: value` line in a classifier body
+type Annotation struct {
+	Span
+	Name  string
+	Value string
+}
+
+// PackageDecl is a `package name { ... }` block
+type PackageDecl struct {
+	Span
+	Name  Ident
+	Decls []Decl
+}
+
+// NoteDecl is a `note [for Anchor] "text"` statement
+type NoteDecl struct {
+	Span
+	Anchor *Ident
+	Text   string
+}
+
+// RelationshipDecl is a relationship line such as `A "1" --> "*" B : label`
+type RelationshipDecl struct {
+	Span
+	Left      Ident
+	LeftMult  string
+	Operator  string
+	Right     Ident
+	RightMult string
+	Label     string
+}
+
+// IncludeDecl is an `include "path"` (or `import "path"`) statement. File is
+// set by ResolveIncludes; it stays nil for files that were already included
+// elsewhere or could not be read.
+type IncludeDecl struct {
+	Span
+	Path     string
+	PathSpan Span
+	File     *File
+}
+
+// ClassDefDecl is a `classDef name[,name...] fill:#f66,stroke:#900`
+// statement defining a named style
+type ClassDefDecl struct {
+	Span
+	Names []Ident
+	Props []*StyleProp
+}
+
+// StyleDecl is a `style Element fill:#f66` statement styling one element
+type StyleDecl struct {
+	Span
+	Target Ident
+	Props  []*StyleProp
+}
+
+// CSSClassDecl is a `cssClass "A,B" name` statement applying a classDef to
+// elements declared elsewhere
+type CSSClassDecl struct {
+	Span
+	Targets []Ident
+	Class   Ident
+}
+
+// StyleProp is one `name:value` pair of a classDef or style statement
+type StyleProp struct {
+	Span
+	Name  string
+	Value string
+}
+
+// MemberDecl is a Mermaid `Class : member` line adding a member to a class
+type MemberDecl struct {
+	Span
+	Target Ident
+	Member *Member
+}
+
+// AnnotationDecl is a Mermaid `<<annotation>> Class` line
+type AnnotationDecl struct {
+	Span
+	Stereotype *Stereotype
+	Target     Ident
+}
+
+// ClickDecl is a Mermaid `click Class href "url"` or `link Class "url"` line
+type ClickDecl struct {
+	Span
+	Target Ident
+	URL    string
+}
+
+// ParticipantDecl is a `participant Name` or `actor Name` declaration in a
+// sequence diagram
+type ParticipantDecl struct {
+	Span
+	Kind    string // "participant" or "actor"
+	Name    Ident
+	Alias   string  // display name from `as "..."`
+	Classes []Ident // classDefs applied with `:::`
+}
+
+// ZoneDecl is a `zone name { ... }` block of participants, such as the
+// services of one deployment zone, drawn at a depth of their own
+type ZoneDecl struct {
+	Span
+	Name  Ident
+	Alias string
+	Decls []Decl
+}
+
+// MessageDecl is a message line such as `A ->> +B : label`. A `+` before
+// the receiver activates it and a `-` deactivates the sender.
+type MessageDecl struct {
+	Span
+	From       Ident
+	Operator   string
+	To         Ident
+	Activate   bool
+	Deactivate bool
+	Label      string
+}
+
+// ActivationDecl is an `activate Name` or `deactivate Name` statement
+type ActivationDecl struct {
+	Span
+	Activate bool
+	Target   Ident
+}
+
+// FragmentDecl is an `alt`, `loop` or `opt` block. An `alt` block may be
+// followed by `else guard { ... }` operands.
+type FragmentDecl struct {
+	Span
+	Kind     string
+	Operands []*Operand
+}
+
+// Operand is one guarded block of a fragment. Guard is the text between the
+// keyword and the '{'.
+type Operand struct {
+	Span
+	Guard string
+	Decls []Decl
+}
+
+// SequenceNoteDecl is a `note over A, B "text"`, `note left of A "text"` or
+// `note right of A "text"` statement in a sequence diagram
+type SequenceNoteDecl struct {
+	Span
+	Position string // "over", "left" or "right"
+	Targets  []Ident
+	Text     string
+}
+
+// StateDecl is a `state Name` declaration in a state diagram. A
+// `<<choice>>`, `<<fork>>` or `<<join>>` stereotype sets Kind, and a state
+// with a body is a composite state whose regions are separated by `--`
+// lines.
+type StateDecl struct {
+	Span
+	Name    Ident
+	Alias   string // display name from `as "..."` or `state "..." as Name`
+	Kind    string // "" or the stereotype name
+	Classes []Ident
+	Regions []*Region // nil for a state without a body
+}
+
+// Region is one concurrent region of a composite state
+type Region struct {
+	Span
+	Decls []Decl
+}
+
+// TransitionDecl is a transition line such as `Idle --> Busy : start`.
+// `[*]` as From or To is the initial or final pseudo-state of the
+// enclosing region.
+type TransitionDecl struct {
+	Span
+	From  Ident
+	To    Ident
+	Label string
+}
+
+// EntityDecl is an `entity Name { ... }` declaration in an ER diagram, or
+// `Name { ... }` as Mermaid writes it
+type EntityDecl struct {
+	Span
+	Name    Ident
+	Alias   string
+	Classes []Ident
+	Columns []*ColumnDecl
+	Body    bool // declared with braces, even if empty
+}
+
+// ColumnDecl is a column line `type name PK, FK "comment"`
+type ColumnDecl struct {
+	Span
+	Type    string
+	Name    string
+	Keys    []string
+	Comment string
+}
+
+// SchemaDecl is a `schema name { ... }` or `tablespace name { ... }` block
+// of entities
+type SchemaDecl struct {
+	Span
+	Kind  string // "schema" or "tablespace"
+	Name  Ident
+	Alias string
+	Decls []Decl
+}
+
+// ERRelationshipDecl is a relationship line such as `A ||--o{ B : label`
+type ERRelationshipDecl struct {
+	Span
+	Left     Ident
+	Operator string
+	Right    Ident
+	Label    string
+}
+
+// ComponentDecl is a component, artifact or deployment node such as
+// `pod api { ... }` in a component or deployment diagram
+type ComponentDecl struct {
+	Span
+	Kind        string // "component", "artifact" or a deployment node kind
+	Name        Ident
+	Alias       string
+	Stereotypes []string
+	Classes     []Ident
+	Decls       []Decl
+	Body        bool // declared with braces, even if empty
+}
+
+// PortDecl is a `port name` line in the body of a component or node
+type PortDecl struct {
+	Span
+	Name Ident
+}
+
+// InterfaceUseDecl is a `provides A, B` or `requires A, B` line in the body
+// of a component
+type InterfaceUseDecl struct {
+	Span
+	Provided bool
+	Names    []Ident
+}
+
+// ConnectorDecl is a connector line such as `api.http --> db.sql : SQL`
+type ConnectorDecl struct {
+	Span
+	From     Ident
+	Operator string
+	To       Ident
+	Label    string
+}
+
+// ActivityNodeDecl declares a node of an activity diagram with the keyword
+// of its kind, such as `action Pay as "Pay order"`. `start` and `end` may
+// be written without a name, which is then the keyword itself.
+type ActivityNodeDecl struct {
+	Span
+	Kind    string
+	Name    Ident
+	Alias   string
+	Classes []Ident
+}
+
+// PartitionDecl is a `partition Name { ... }` swimlane of an activity
+// diagram
+type PartitionDecl struct {
+	Span
+	Name  Ident
+	Alias string
+	Decls []Decl
+}
+
+// FlowDecl is a flow line such as `Check --> Ship : [in stock]`
+type FlowDecl struct {
+	Span
+	From  Ident
+	To    Ident
+	Label string
+}
+
+// SpanOf returns the source span of the declaration d
+func SpanOf(d Decl) Span {
+	return d.declSpan()
+}
+
+func (d *ClassifierDecl) declSpan() Span     { return d.Span }
+func (d *PackageDecl) declSpan() Span        { return d.Span }
+func (d *NoteDecl) declSpan() Span           { return d.Span }
+func (d *RelationshipDecl) declSpan() Span   { return d.Span }
+func (d *IncludeDecl) declSpan() Span        { return d.Span }
+func (d *ClassDefDecl) declSpan() Span       { return d.Span }
+func (d *StyleDecl) declSpan() Span          { return d.Span }
+func (d *CSSClassDecl) declSpan() Span       { return d.Span }
+func (d *MemberDecl) declSpan() Span         { return d.Span }
+func (d *AnnotationDecl) declSpan() Span     { return d.Span }
+func (d *ClickDecl) declSpan() Span          { return d.Span }
+func (d *ParticipantDecl) declSpan() Span    { return d.Span }
+func (d *ZoneDecl) declSpan() Span           { return d.Span }
+func (d *MessageDecl) declSpan() Span        { return d.Span }
+func (d *ActivationDecl) declSpan() Span     { return d.Span }
+func (d *FragmentDecl) declSpan() Span       { return d.Span }
+func (d *SequenceNoteDecl) declSpan() Span   { return d.Span }
+func (d *StateDecl) declSpan() Span          { return d.Span }
+func (d *TransitionDecl) declSpan() Span     { return d.Span }
+func (d *EntityDecl) declSpan() Span         { return d.Span }
+func (d *ColumnDecl) declSpan() Span         { return d.Span }
+func (d *SchemaDecl) declSpan() Span         { return d.Span }
+func (d *ERRelationshipDecl) declSpan() Span { return d.Span }
+func (d *ComponentDecl) declSpan() Span      { return d.Span }
+func (d *PortDecl) declSpan() Span           { return d.Span }
+func (d *InterfaceUseDecl) declSpan() Span   { return d.Span }
+func (d *ConnectorDecl) declSpan() Span      { return d.Span }
+func (d *ActivityNodeDecl) declSpan() Span   { return d.Span }
+func (d *PartitionDecl) declSpan() Span      { return d.Span }
+func (d *FlowDecl) declSpan() Span           { return d.Span }
@@ -0,0 +1,278 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// nodeRanks orders the deployment node kinds that nest in a fixed order;
+// the other node kinds have rank 0 and nest freely
+var nodeRanks = map[string]int{
+	extuml.NodeDefault:     0,
+	extuml.NodeDevice:      0,
+	extuml.NodeEnvironment: 0,
+	extuml.NodeCluster:     1,
+	extuml.NodeNamespace:   2,
+	extuml.NodePod:         3,
+	extuml.NodeContainer:   4,
+}
+
+// connectorOperators maps the operators of connectors to the relationship
+// kind they are drawn as
+var connectorOperators = map[string]string{
+	"--":  extuml.RelLink,
+	"..":  extuml.RelDashedLink,
+	"-->": extuml.RelAssociation,
+	"..>": extuml.RelDependency,
+}
+
+// componentBlock describes where a statement of a component or deployment
+// diagram appears: the kind of the enclosing declaration, "" at the top
+// level, and the kind of the innermost cluster, namespace, pod or container
+// around it
+type componentBlock struct {
+	kind   string
+	ranked string
+}
+
+// parseComponentDecls parses the statements of a component or deployment
+// diagram up to the end of file or, inside a component or node, up to its
+// closing '}'. what describes the block opened at open and is empty at the
+// top level.
+func (p *parser) parseComponentDecls(open Span, what string, block componentBlock) (decls []Decl, end Pos, closed bool) {
+	for {
+		p.skipBlank()
+		t := p.peek()
+		switch {
+		case t.Kind == TokenEOF:
+			if what != "" {
+				p.diags.Add(open, diagnostic.CodeUnterminatedBlock, "%s is not closed (missing '}')", what)
+			}
+			return decls, t.Span.Start, false
+		case t.Kind == TokenRBrace:
+			if what != "" {
+				p.advance()
+				return decls, t.Span.End, true
+			}
+			p.diags.Add(t.Span, diagnostic.CodeUnexpectedCloseBrace, "unexpected '}' without an open block")
+			p.skipLine()
+		default:
+			if d := p.parseComponentDecl(block); d != nil {
+				decls = append(decls, d)
+			}
+		}
+	}
+}
+
+// parseComponentDecl parses one statement of a component or deployment
+// diagram
+func (p *parser) parseComponentDecl(block componentBlock) Decl {
+	t := p.peek()
+	top := block.kind == ""
+	if t.Kind == TokenIdent && p.peekAt(1).Kind == TokenArrow {
+		if d := p.parseConnector(); d != nil {
+			return d
+		}
+		return nil
+	}
+
+	if t.Kind == TokenIdent {
+		next := p.peekAt(1)
+		_, isNode := nodeRanks[t.Text]
+		switch {
+		case (t.Text == "component" || t.Text == "artifact" || isNode) && next.Kind == TokenIdent:
+			if msg := p.misplacedComponent(t.Text, block); msg != "" {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "%s", msg)
+				p.skipLine()
+				return nil
+			}
+			if d := p.parseComponent(block); d != nil {
+				return d
+			}
+			return nil
+		case t.Text == "port" && next.Kind == TokenIdent:
+			if top || block.kind == "artifact" {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "a port can only be declared in a component or node")
+				p.skipLine()
+				return nil
+			}
+			p.advance()
+			name, ok := p.expectName("port name")
+			if !ok {
+				p.skipLine()
+				return nil
+			}
+			d := &PortDecl{Span: Span{Start: t.Span.Start, End: name.End}, Name: name}
+			p.expectLineEnd()
+			return d
+		case (t.Text == "provides" || t.Text == "requires") && next.Kind == TokenIdent:
+			if block.kind != "component" {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "%s is only allowed in the body of a component", t.Text)
+				p.skipLine()
+				return nil
+			}
+			return p.parseInterfaceUse()
+		case t.Text == "note":
+			if d := p.parseNote(); d != nil {
+				return d
+			}
+			return nil
+		case (t.Text == "include" || t.Text == "import") && p.isIncludeStart():
+			if !top {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "%s is only allowed at the top level", t.Text)
+				p.skipLine()
+				return nil
+			}
+			if d := p.parseInclude(); d != nil {
+				return d
+			}
+			return nil
+		case (t.Text == "classDef" || t.Text == "style" || t.Text == "cssClass") && p.isStyleStart():
+			if d := p.parseStyleDecl(); d != nil {
+				return d
+			}
+			return nil
+		case top && metaKeys[t.Text] && next.Kind == TokenPunct && next.Text == ":":
+			p.parseMetaLine()
+			return nil
+		}
+	}
+
+	p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "unexpected %s; expected a component, node or connector", t.describe())
+	p.skipLine()
+	return nil
+}
+
+// misplacedComponent returns why a declaration of the given kind cannot
+// appear in block, or "" if it can
+func (p *parser) misplacedComponent(kind string, block componentBlock) string {
+	_, isNode := nodeRanks[kind]
+	switch {
+	case (isNode || kind == "artifact") && p.diagram != extuml.DiagramDeployment:
+		return fmt.Sprintf("a %s can only be declared in a deploymentDiagram3D", kind)
+	case isNode && block.kind == "component":
+		return fmt.Sprintf("a %s cannot be nested in a component", kind)
+	case isNode && nodeRanks[kind] > 0 && nodeRanks[kind] <= nodeRanks[block.ranked]:
+		return fmt.Sprintf("a %s cannot be nested in a %s", kind, block.ranked)
+	case kind == "artifact" && block.kind == "component":
+		return "an artifact cannot be nested in a component"
+	}
+	return ""
+}
+
+// parseComponent parses `kind Name [as "Display Name"]`, followed by
+// optional stereotypes, `:::class` and a body
+func (p *parser) parseComponent(block componentBlock) *ComponentDecl {
+	kw := p.advance()
+	d := &ComponentDecl{Span: kw.Span, Kind: kw.Text}
+	name, ok := p.expectName(kw.Text + " name")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Name = name
+	d.End = name.End
+
+	for {
+		t := p.peek()
+		if t.Kind == TokenIdent && t.Text == "as" && d.Alias == "" {
+			alias, end, ok := p.parseDisplayName()
+			if !ok {
+				p.skipLine()
+				return d
+			}
+			d.Alias, d.End = alias, end
+		} else if t.Kind == TokenStereotype {
+			p.advance()
+			d.Stereotypes = append(d.Stereotypes, newStereotype(t).Names...)
+			d.End = t.Span.End
+		} else if p.isClassShorthand() {
+			class := p.parseClassShorthand()
+			d.Classes = append(d.Classes, class)
+			d.End = class.End
+		} else {
+			break
+		}
+	}
+
+	if t := p.peek(); t.Kind != TokenLBrace {
+		p.expectLineEnd()
+		return d
+	}
+	brace := p.advance()
+	if d.Kind == "artifact" {
+		p.diags.Add(brace.Span, diagnostic.CodeUnexpectedToken, "an artifact cannot have a body")
+	}
+	p.expectLineEnd()
+	d.Body = true
+
+	inner := componentBlock{kind: d.Kind, ranked: block.ranked}
+	if nodeRanks[d.Kind] > 0 {
+		inner.ranked = d.Kind
+	}
+	var closed bool
+	d.Decls, d.End, closed = p.parseComponentDecls(d.Span, fmt.Sprintf("%s %q", d.Kind, name.Name), inner)
+	if closed {
+		p.expectLineEnd()
+	}
+	return d
+}
+
+// parseInterfaceUse parses `provides A, B` or `requires A, B`
+func (p *parser) parseInterfaceUse() *InterfaceUseDecl {
+	kw := p.advance()
+	d := &InterfaceUseDecl{Span: kw.Span, Provided: kw.Text == "provides"}
+	for {
+		name, ok := p.expectIdent("interface name")
+		if !ok {
+			p.skipLine()
+			return d
+		}
+		d.Names = append(d.Names, name)
+		d.End = name.End
+		if t := p.peek(); t.Kind != TokenPunct || t.Text != "," {
+			break
+		}
+		p.advance()
+	}
+	p.expectLineEnd()
+	return d
+}
+
+// parseConnector parses `From --> To [: label]`, where either end may be a
+// port written as `Element.port`
+func (p *parser) parseConnector() *ConnectorDecl {
+	from := p.advance()
+	d := &ConnectorDecl{Span: from.Span, From: Ident{Span: from.Span, Name: from.Text}}
+
+	op := p.advance()
+	if _, ok := connectorOperators[op.Text]; !ok {
+		p.diags.Add(op.Span, diagnostic.CodeUnexpectedToken,
+			"operator %q cannot be used in a %s diagram (expected --, .., --> or ..>)", op.Text, p.diagram)
+		p.skipLine()
+		return nil
+	}
+	d.Operator = op.Text
+
+	to, ok := p.expectIdent("connector target")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.To = to
+	d.End = to.End
+
+	if t := p.peek(); t.Kind == TokenPunct && t.Text == ":" {
+		p.advance()
+		label, span := p.restOfLine()
+		d.Label = label
+		if label != "" {
+			d.End = span.End
+		}
+		return d
+	}
+	p.expectLineEnd()
+	return d
+}
@@ -0,0 +1,440 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseJSONDocument reads a Document written as JSON and validates it against
+// the Document schema. Problems are reported at the offending value with its
+// JSON path, e.g. `$.elements.classes[0].id`. The document is nil if there
+// are errors.
+func ParseJSONDocument(filename string, src []byte) (*extuml.Document, diagnostic.List) {
+	r := newDocumentReader(filename, src)
+	if err := json.Unmarshal(src, new(any)); err != nil {
+		var syntaxErr *json.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			r.diags.Add(r.span(0, 0), diagnostic.CodeMalformedDocument, "invalid JSON: %v", err)
+			return nil, r.diags
+		}
+		off := max(int(syntaxErr.Offset)-1, 0)
+		r.diags.Add(r.span(off, off+1), diagnostic.CodeMalformedDocument, "invalid JSON: %v", err)
+		return nil, r.diags
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+	return loadDocument(r.jsonNode(dec))
+}
+
+// ParseYAMLDocuments reads the Documents of a YAML stream, one for each
+// `---` separated document, validated as ParseJSONDocument does
+func ParseYAMLDocuments(filename string, src []byte) ([]*extuml.Document, diagnostic.List) {
+	r := newDocumentReader(filename, src)
+	var nodes []*docNode
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			r.yamlError(err)
+			return nil, r.diags
+		}
+		nodes = append(nodes, r.yamlNode(&doc))
+	}
+	if len(r.diags) > 0 {
+		return nil, r.diags
+	}
+	if len(nodes) == 0 {
+		r.diags.Add(r.span(0, 0), diagnostic.CodeMalformedDocument, "the file holds no YAML document")
+		return nil, r.diags
+	}
+
+	var (
+		docs  []*extuml.Document
+		diags diagnostic.List
+	)
+	for _, n := range nodes {
+		doc, docDiags := loadDocument(n)
+		diags = append(diags, docDiags...)
+		docs = append(docs, doc)
+	}
+	return docs, diags
+}
+
+// diagramProperties are the Document properties holding the diagrams the
+// schema does not cover, with the name of their diagram type in messages
+var diagramProperties = []struct{ name, diagram string }{
+	{"sequence", "sequence"},
+	{"stateMachine", "state"},
+	{"er", "ER"},
+	{"architecture", "component or deployment"},
+	{"activity", "activity"},
+}
+
+// checkDiagramType reports documents holding anything but a class diagram,
+// which is all the schema and the checks after it cover
+func checkDiagramType(n *docNode) diagnostic.List {
+	var diags diagnostic.List
+	for i, key := range n.keys {
+		name := key.value.(string)
+		diagram := ""
+		if name == "diagram" {
+			if value, ok := n.items[i].value.(string); ok && value != extuml.DiagramClass {
+				diagram = value
+			}
+		}
+		for _, p := range diagramProperties {
+			if name == p.name {
+				diagram = p.diagram
+			}
+		}
+		if diagram != "" {
+			diags.Add(key.span, diagnostic.CodeSchemaViolation,
+				"$%s: only class diagrams can be read from JSON and YAML documents, not %s diagrams", pathKey(name), diagram)
+		}
+	}
+	return diags
+}
+
+// loadDocument validates a document and decodes it into the model
+func loadDocument(n *docNode) (*extuml.Document, diagnostic.List) {
+	if diags := checkDiagramType(n); diags.HasErrors() {
+		return nil, diags
+	}
+	diags := validateDocument(n)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	data, err := json.Marshal(n.data())
+	var doc extuml.Document
+	if err == nil {
+		err = json.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		diags.Add(n.span, diagnostic.CodeSchemaViolation, "$: %v", err)
+		return nil, diags
+	}
+	diags = append(diags, checkDocument(&doc, n)...)
+	return &doc, diags
+}
+
+// Kinds of docNode, named as JSON Schema types
+const (
+	kindObject  = "object"
+	kindArray   = "array"
+	kindString  = "string"
+	kindNumber  = "number"
+	kindInteger = "integer"
+	kindBoolean = "boolean"
+	kindNull    = "null"
+)
+
+// docNode is a JSON value read from a JSON or YAML document, with its
+// position in the file
+type docNode struct {
+	kind  string
+	value any        // of scalars: a string, json.Number, bool or nil
+	keys  []*docNode // of objects: the property names, in order
+	items []*docNode // of objects: the property values; of arrays: the items
+	span  Span
+}
+
+// is reports whether the node is of the given JSON Schema type
+func (n *docNode) is(kind string) bool {
+	return n.kind == kind || kind == kindNumber && n.kind == kindInteger
+}
+
+// get returns the value of an object's property, or nil
+func (n *docNode) get(name string) *docNode {
+	if n == nil {
+		return nil
+	}
+	for i, key := range n.keys {
+		if key.value == name {
+			return n.items[i]
+		}
+	}
+	return nil
+}
+
+// at returns the node at a path of property names and array indices, or nil
+func (n *docNode) at(steps ...any) *docNode {
+	for _, step := range steps {
+		switch s := step.(type) {
+		case string:
+			n = n.get(s)
+		case int:
+			if n == nil || s >= len(n.items) {
+				return nil
+			}
+			n = n.items[s]
+		}
+	}
+	return n
+}
+
+// data returns the node as a plain Go value for encoding/json
+func (n *docNode) data() any {
+	switch n.kind {
+	case kindObject:
+		m := make(map[string]any, len(n.keys))
+		for i, key := range n.keys {
+			m[key.value.(string)] = n.items[i].data()
+		}
+		return m
+	case kindArray:
+		s := make([]any, len(n.items))
+		for i, item := range n.items {
+			s[i] = item.data()
+		}
+		return s
+	}
+	return n.value
+}
+
+// String shows the value in messages
+func (n *docNode) String() string {
+	switch n.kind {
+	case kindString:
+		return strconv.Quote(n.value.(string))
+	case kindObject, kindArray:
+		return "an " + n.kind
+	}
+	return fmt.Sprint(n.value)
+}
+
+// jsonPath formats a path of property names and array indices, e.g.
+// `$.elements.classes[0].id`
+func jsonPath(steps ...any) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, step := range steps {
+		switch s := step.(type) {
+		case string:
+			b.WriteString(pathKey(s))
+		case int:
+			fmt.Fprintf(&b, "[%d]", s)
+		}
+	}
+	return b.String()
+}
+
+// documentReader builds docNodes from JSON tokens or YAML nodes
+type documentReader struct {
+	file       string
+	src        []byte
+	lineStarts []int // offset of the first byte of each line
+	diags      diagnostic.List
+
+	aliases map[*yaml.Node]bool // anchors whose values are being read
+}
+
+func newDocumentReader(filename string, src []byte) *documentReader {
+	r := &documentReader{file: filename, src: src, lineStarts: []int{0}, aliases: map[*yaml.Node]bool{}}
+	for i, c := range src {
+		if c == '\n' {
+			r.lineStarts = append(r.lineStarts, i+1)
+		}
+	}
+	return r
+}
+
+// pos returns the position of a byte offset
+func (r *documentReader) pos(off int) Pos {
+	off = min(max(off, 0), len(r.src))
+	line := sort.Search(len(r.lineStarts), func(i int) bool { return r.lineStarts[i] > off })
+	return Pos{File: r.file, Offset: off, Line: line, Column: off - r.lineStarts[line-1] + 1}
+}
+
+func (r *documentReader) span(start, end int) Span {
+	return Span{Start: r.pos(start), End: r.pos(end)}
+}
+
+// jsonNode reads the next value from dec, which reads valid JSON
+func (r *documentReader) jsonNode(dec *json.Decoder) *docNode {
+	// The decoder stops after a token; separators are consumed with the next one
+	start := int(dec.InputOffset())
+	for start < len(r.src) && strings.IndexByte(" \t\r\n,:", r.src[start]) >= 0 {
+		start++
+	}
+	tok, _ := dec.Token()
+	n := &docNode{span: r.span(start, int(dec.InputOffset()))}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			n.kind = kindObject
+			for dec.More() {
+				n.keys = append(n.keys, r.jsonNode(dec))
+				n.items = append(n.items, r.jsonNode(dec))
+			}
+		} else {
+			n.kind = kindArray
+			for dec.More() {
+				n.items = append(n.items, r.jsonNode(dec))
+			}
+		}
+		dec.Token() // the closing delimiter
+	case string:
+		n.kind, n.value = kindString, t
+	case json.Number:
+		n.kind, n.value = kindNumber, t
+		if !strings.ContainsAny(t.String(), ".eE") {
+			n.kind = kindInteger
+		}
+	case bool:
+		n.kind, n.value = kindBoolean, t
+	default:
+		n.kind = kindNull
+	}
+	return n
+}
+
+// yamlNode converts a YAML node, resolving aliases, to a docNode
+func (r *documentReader) yamlNode(y *yaml.Node) *docNode {
+	switch y.Kind {
+	case yaml.DocumentNode:
+		if len(y.Content) == 0 {
+			return &docNode{kind: kindNull, span: r.yamlSpan(y)}
+		}
+		return r.yamlNode(y.Content[0])
+	case yaml.AliasNode:
+		if r.aliases[y.Alias] {
+			r.diags.Add(r.yamlSpan(y), diagnostic.CodeMalformedDocument, "alias *%s refers to a value that contains it", y.Value)
+			return &docNode{kind: kindNull, span: r.yamlSpan(y)}
+		}
+		r.aliases[y.Alias] = true
+		defer delete(r.aliases, y.Alias)
+		return r.yamlNode(y.Alias)
+	}
+
+	n := &docNode{span: r.yamlSpan(y)}
+	switch y.Kind {
+	case yaml.MappingNode:
+		n.kind = kindObject
+		for i := 0; i+1 < len(y.Content); i += 2 {
+			key := y.Content[i]
+			n.keys = append(n.keys, &docNode{kind: kindString, value: key.Value, span: r.yamlSpan(key)})
+			n.items = append(n.items, r.yamlNode(y.Content[i+1]))
+		}
+	case yaml.SequenceNode:
+		n.kind = kindArray
+		for _, item := range y.Content {
+			n.items = append(n.items, r.yamlNode(item))
+		}
+	default:
+		r.yamlScalar(y, n)
+	}
+	return n
+}
+
+// yamlScalar sets the kind and value of n from a YAML scalar. Tags without a
+// JSON counterpart, such as the !!timestamp of an unquoted date, are strings.
+func (r *documentReader) yamlScalar(y *yaml.Node, n *docNode) {
+	var value any
+	if err := y.Decode(&value); err != nil {
+		n.kind, n.value = kindString, y.Value
+		return
+	}
+	switch v := value.(type) {
+	case int, int64, uint64:
+		n.kind, n.value = kindInteger, json.Number(fmt.Sprint(v))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			n.kind, n.value = kindString, y.Value
+			return
+		}
+		n.kind, n.value = kindNumber, json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		n.kind, n.value = kindBoolean, v
+	case nil:
+		n.kind = kindNull
+	default:
+		n.kind, n.value = kindString, y.Value
+	}
+}
+
+// yamlSpan returns the span of a YAML node. Its column counts characters,
+// not bytes; scalars on a single line span their source text.
+func (r *documentReader) yamlSpan(y *yaml.Node) Span {
+	if y.Line < 1 || y.Line > len(r.lineStarts) {
+		return r.span(0, 0)
+	}
+	start := r.lineStarts[y.Line-1]
+	for i := 1; i < y.Column && start < len(r.src) && r.src[start] != '\n'; i++ {
+		_, size := utf8.DecodeRune(r.src[start:])
+		start += size
+	}
+	if y.Kind != yaml.ScalarNode {
+		return r.span(start, start)
+	}
+	return r.span(start, r.scalarEnd(start, y))
+}
+
+// scalarEnd returns the offset just past a YAML scalar starting at start, or
+// start if it does not end on the same line
+func (r *documentReader) scalarEnd(start int, y *yaml.Node) int {
+	line := r.src[start:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	switch {
+	case y.Style&yaml.DoubleQuotedStyle != 0:
+		for i := 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				return start + i + 1
+			}
+		}
+	case y.Style&yaml.SingleQuotedStyle != 0:
+		for i := 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				return start + i + 1
+			}
+		}
+	case y.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0:
+		if bytes.HasPrefix(line, []byte(y.Value)) {
+			return start + len(y.Value)
+		}
+	}
+	return start
+}
+
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// yamlError reports a YAML syntax error at the line it names
+func (r *documentReader) yamlError(err error) {
+	msg, line := err.Error(), 1
+	if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+		line, _ = strconv.Atoi(m[1])
+		msg = m[2]
+	} else {
+		msg = strings.TrimPrefix(msg, "yaml: ")
+	}
+	start := r.lineStarts[min(max(line, 1), len(r.lineStarts))-1]
+	r.diags.Add(r.span(start, start), diagnostic.CodeMalformedDocument, "invalid YAML: %s", msg)
+}
@@ -0,0 +1,226 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// documentChecker fills in the defaults of a decoded document and checks
+// what the schema cannot express: unique IDs, references, the package tree
+// and colours
+type documentChecker struct {
+	doc      *extuml.Document
+	root     *docNode
+	declared map[string][]any // element IDs, with the path of their declaration
+	diags    diagnostic.List
+}
+
+// checkDocument completes doc, decoded from the validated node n, and reports
+// the problems the DSL lowerer would
+func checkDocument(doc *extuml.Document, n *docNode) diagnostic.List {
+	if doc.Elements == nil {
+		doc.Elements = &extuml.Elements{}
+	}
+	c := &documentChecker{doc: doc, root: n, declared: map[string][]any{}}
+	c.declare()
+	c.checkReferences()
+	c.checkPackages()
+	c.checkStyles()
+	return c.diags
+}
+
+// add reports a problem with the value at a path
+func (c *documentChecker) add(code string, steps []any, format string, args ...any) {
+	n := c.root.at(steps...)
+	if n == nil {
+		n = c.root
+	}
+	c.diags.Add(n.span, code, "%s: %s", jsonPath(steps...), fmt.Sprintf(format, args...))
+}
+
+// declare fills in the type and default name of every element, numbers the
+// notes and relationships without an ID and records the element IDs
+func (c *documentChecker) declare() {
+	elements := c.doc.Elements
+	for i := range elements.Classes {
+		e := &elements.Classes[i]
+		e.Type, e.Name = "class", defaultName(e.Name, e.ID)
+		c.declareID(e.ID, "classes", i)
+	}
+	for i := range elements.Interfaces {
+		e := &elements.Interfaces[i]
+		e.Type, e.Name = "interface", defaultName(e.Name, e.ID)
+		c.declareID(e.ID, "interfaces", i)
+	}
+	for i := range elements.Enums {
+		e := &elements.Enums[i]
+		e.Type, e.Name = "enum", defaultName(e.Name, e.ID)
+		c.declareID(e.ID, "enums", i)
+	}
+	for i := range elements.Packages {
+		e := &elements.Packages[i]
+		e.Type, e.Name = "package", defaultName(e.Name, e.ID)
+		c.declareID(e.ID, "packages", i)
+	}
+	for i := range elements.Notes {
+		e := &elements.Notes[i]
+		e.Type = "note"
+		if e.ID == "" {
+			e.ID = fmt.Sprintf("note_%d", i+1)
+		}
+	}
+	for i := range elements.Relationships {
+		if e := &elements.Relationships[i]; e.ID == "" {
+			e.ID = fmt.Sprintf("rel_%d", i+1)
+		}
+	}
+}
+
+// defaultName returns name, or the last segment of the qualified id if the
+// name is empty
+func defaultName(name, id string) string {
+	if name != "" {
+		return name
+	}
+	return id[strings.LastIndex(id, ".")+1:]
+}
+
+func (c *documentChecker) declareID(id, list string, i int) {
+	steps := []any{"elements", list, i, "id"}
+	if first, dup := c.declared[id]; dup {
+		c.add(diagnostic.CodeDuplicateElement, steps, "ID %q is already declared at %s", id, c.root.at(first...).span.Start)
+		return
+	}
+	c.declared[id] = steps
+}
+
+// checkReferences warns about relationship ends, note anchors and @near
+// targets that name no element
+func (c *documentChecker) checkReferences() {
+	elements := c.doc.Elements
+	for i, rel := range elements.Relationships {
+		c.checkReference(rel.From, diagnostic.CodeUnknownRelationshipTarget, "relationship endpoint", "relationships", i, "from")
+		c.checkReference(rel.To, diagnostic.CodeUnknownRelationshipTarget, "relationship endpoint", "relationships", i, "to")
+	}
+	for i, note := range elements.Notes {
+		if note.Anchor != "" {
+			c.checkReference(note.Anchor, diagnostic.CodeUnknownNoteAnchor, "note anchor", "notes", i, "anchor")
+		}
+	}
+	placements := func(list string, i int, p *extuml.Placement) {
+		if p != nil && p.Near != "" {
+			c.checkReference(p.Near, diagnostic.CodeUnknownPlacementTarget, "placement target", list, i, "placement", "near")
+		}
+	}
+	for i, e := range elements.Classes {
+		placements("classes", i, e.Placement)
+	}
+	for i, e := range elements.Interfaces {
+		placements("interfaces", i, e.Placement)
+	}
+	for i, e := range elements.Enums {
+		placements("enums", i, e.Placement)
+	}
+}
+
+func (c *documentChecker) checkReference(id, code, what string, steps ...any) {
+	if _, ok := c.declared[id]; !ok {
+		c.add(code, append([]any{"elements"}, steps...), "%s %q is not declared", what, id)
+	}
+}
+
+// checkPackages checks that package children name declared elements, that
+// each element has at most one package and that no package contains itself,
+// which the nesting of DSL blocks guarantees
+func (c *documentChecker) checkPackages() {
+	packages := c.doc.Elements.Packages
+	listed := map[string][]any{} // child ID to the path that lists it
+	parent := map[string]string{}
+	for i, pkg := range packages {
+		for j, child := range pkg.Children {
+			steps := []any{"elements", "packages", i, "children", j}
+			if _, ok := c.declared[child]; !ok {
+				c.add(diagnostic.CodeUnknownPackageChild, steps, "package child %q is not declared", child)
+				continue
+			}
+			if first, dup := listed[child]; dup {
+				c.add(diagnostic.CodeDuplicatePackageChild, steps, "%q is already a child of package %q at %s",
+					child, parent[child], c.root.at(first...).span.Start)
+				continue
+			}
+			listed[child], parent[child] = steps, pkg.ID
+		}
+	}
+
+	// Each package has at most one parent, so following the parents from a
+	// package ends, comes back to it or loops through other packages, which
+	// the length of the chain bounds. A cycle is reported once, where its
+	// first package is listed.
+	reported := map[string]bool{}
+	for _, pkg := range packages {
+		if reported[pkg.ID] {
+			continue
+		}
+		chain, id := []string{pkg.ID}, parent[pkg.ID]
+		for id != "" && id != pkg.ID && len(chain) <= len(packages) {
+			chain, id = append(chain, id), parent[id]
+		}
+		if id != pkg.ID {
+			continue
+		}
+		// The chain runs from child to parent; report it from parent to child
+		chain = append(chain, id)
+		for k, l := 0, len(chain)-1; k < l; k, l = k+1, l-1 {
+			chain[k], chain[l] = chain[l], chain[k]
+		}
+		for _, member := range chain {
+			reported[member] = true
+		}
+		c.add(diagnostic.CodePackageCycle, listed[pkg.ID], "package %q contains itself: %s", pkg.ID, strings.Join(chain, " > "))
+	}
+}
+
+// checkStyles checks the colours of classDefs and styles and warns about
+// style classes without a classDef
+func (c *documentChecker) checkStyles() {
+	for name, style := range c.doc.ClassDefs {
+		c.checkStyle(&style, "classDefs", name)
+	}
+	elements := c.doc.Elements
+	styled := func(list string, i int, classes []string, style *extuml.Style) {
+		for j, name := range classes {
+			if _, ok := c.doc.ClassDefs[name]; !ok {
+				c.add(diagnostic.CodeUnknownStyleClass, []any{"elements", list, i, "styleClasses", j}, "classDef %q is not defined", name)
+			}
+		}
+		if style != nil {
+			c.checkStyle(style, "elements", list, i, "style")
+		}
+	}
+	for i, e := range elements.Classes {
+		styled("classes", i, e.StyleClasses, e.Style)
+	}
+	for i, e := range elements.Interfaces {
+		styled("interfaces", i, e.StyleClasses, e.Style)
+	}
+	for i, e := range elements.Enums {
+		styled("enums", i, e.StyleClasses, e.Style)
+	}
+}
+
+func (c *documentChecker) checkStyle(style *extuml.Style, steps ...any) {
+	for _, prop := range []struct{ name, value string }{
+		{"fill", style.Fill}, {"stroke", style.Stroke}, {"color", style.Color},
+	} {
+		if prop.value == "" {
+			continue
+		}
+		if _, ok := extuml.ParseColor(prop.value); !ok {
+			c.add(diagnostic.CodeInvalidColor, append(steps, prop.name),
+				"invalid colour %q: expected #rgb, #rrggbb, #rrggbbaa or a colour name", prop.value)
+		}
+	}
+}
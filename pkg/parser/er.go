@@ -0,0 +1,316 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// columnKeys are the key markers a column may carry
+var columnKeys = map[string]bool{"PK": true, "FK": true, "UK": true}
+
+// leftCardinalities and rightCardinalities map the two ends of a crow's foot
+// operator, as in `||--o{`, to the cardinality they stand for
+var (
+	leftCardinalities = map[string]string{
+		"|o": extuml.CardinalityZeroOrOne,
+		"||": extuml.CardinalityExactlyOne,
+		"}o": extuml.CardinalityZeroOrMore,
+		"}|": extuml.CardinalityOneOrMore,
+	}
+	rightCardinalities = map[string]string{
+		"o|": extuml.CardinalityZeroOrOne,
+		"||": extuml.CardinalityExactlyOne,
+		"o{": extuml.CardinalityZeroOrMore,
+		"|{": extuml.CardinalityOneOrMore,
+	}
+)
+
+// parseERDecls parses the statements of an ER diagram up to the end of file
+// or, inside a schema, up to its closing '}'. what describes the block
+// opened at open and is empty at the top level.
+func (p *parser) parseERDecls(open Span, what string) (decls []Decl, end Pos, closed bool) {
+	for {
+		p.skipBlank()
+		t := p.peek()
+		switch {
+		case t.Kind == TokenEOF:
+			if what != "" {
+				p.diags.Add(open, diagnostic.CodeUnterminatedBlock, "%s is not closed (missing '}')", what)
+			}
+			return decls, t.Span.Start, false
+		case t.Kind == TokenRBrace:
+			if what != "" {
+				p.advance()
+				return decls, t.Span.End, true
+			}
+			p.diags.Add(t.Span, diagnostic.CodeUnexpectedCloseBrace, "unexpected '}' without an open block")
+			p.skipLine()
+		default:
+			if d := p.parseERDecl(what == ""); d != nil {
+				decls = append(decls, d)
+			}
+		}
+	}
+}
+
+// parseERDecl parses one statement of an ER diagram; top reports whether it
+// is at the top level of the file
+func (p *parser) parseERDecl(top bool) Decl {
+	t := p.peek()
+	if t.Kind == TokenIdent && p.erOperatorAt(t.Span.End.Offset) != "" {
+		if d := p.parseERRelationship(); d != nil {
+			return d
+		}
+		return nil
+	}
+
+	if t.Kind == TokenIdent {
+		next := p.peekAt(1)
+		switch {
+		case t.Text == "entity" && next.Kind == TokenIdent:
+			if d := p.parseEntity(true); d != nil {
+				return d
+			}
+			return nil
+		case (t.Text == "schema" || t.Text == "tablespace") && next.Kind == TokenIdent:
+			if !top {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "a %s can only be declared at the top level", t.Text)
+				p.skipLine()
+				return nil
+			}
+			if d := p.parseSchema(); d != nil {
+				return d
+			}
+			return nil
+		case t.Text == "note":
+			if d := p.parseNote(); d != nil {
+				return d
+			}
+			return nil
+		case (t.Text == "include" || t.Text == "import") && p.isIncludeStart():
+			if !top {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "%s is only allowed at the top level", t.Text)
+				p.skipLine()
+				return nil
+			}
+			if d := p.parseInclude(); d != nil {
+				return d
+			}
+			return nil
+		case (t.Text == "classDef" || t.Text == "style" || t.Text == "cssClass") && p.isStyleStart():
+			if d := p.parseStyleDecl(); d != nil {
+				return d
+			}
+			return nil
+		case top && metaKeys[t.Text] && next.Kind == TokenPunct && next.Text == ":":
+			p.parseMetaLine()
+			return nil
+		case next.Kind == TokenLBrace || p.isClassShorthandAt(1):
+			// Mermaid declares entities without a keyword
+			if d := p.parseEntity(false); d != nil {
+				return d
+			}
+			return nil
+		}
+	}
+
+	p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "unexpected %s; expected an entity or relationship", t.describe())
+	p.skipLine()
+	return nil
+}
+
+// erOperatorAt returns the crow's foot operator, such as `||--o{`, starting
+// at offset after optional blanks, or "" if there is none
+func (p *parser) erOperatorAt(offset int) string {
+	for offset < len(p.src) && (p.src[offset] == ' ' || p.src[offset] == '\t') {
+		offset++
+	}
+	if offset+6 > len(p.src) {
+		return ""
+	}
+	op := string(p.src[offset : offset+6])
+	if _, ok := leftCardinalities[op[:2]]; !ok {
+		return ""
+	}
+	if _, ok := rightCardinalities[op[4:]]; !ok || (op[2:4] != "--" && op[2:4] != "..") {
+		return ""
+	}
+	return op
+}
+
+// parseERRelationship parses `Left ||--o{ Right [: label]`. The lexer splits
+// the operator into several tokens, which are consumed up to its end.
+func (p *parser) parseERRelationship() *ERRelationshipDecl {
+	left := p.advance()
+	d := &ERRelationshipDecl{Span: left.Span, Left: Ident{Span: left.Span, Name: left.Text}}
+	d.Operator = p.erOperatorAt(left.Span.End.Offset)
+	end := strings.Index(string(p.src[left.Span.End.Offset:]), d.Operator) + left.Span.End.Offset + len(d.Operator)
+	for t := p.peek(); t.Kind != TokenEOF && t.Span.Start.Offset < end; t = p.peek() {
+		p.advance()
+	}
+
+	right, ok := p.expectIdent("related entity")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Right = right
+	d.End = right.End
+
+	if t := p.peek(); t.Kind == TokenPunct && t.Text == ":" {
+		p.advance()
+		label, span := p.restOfLine()
+		d.Label = unquote(label)
+		if label != "" {
+			d.End = span.End
+		}
+		return d
+	}
+	p.expectLineEnd()
+	return d
+}
+
+// parseEntity parses an entity with an optional `as "Display Name"`,
+// `:::class` and body of columns. keyword reports whether the declaration
+// starts with `entity`.
+func (p *parser) parseEntity(keyword bool) *EntityDecl {
+	start := p.peek()
+	if keyword {
+		p.advance()
+	}
+	d := &EntityDecl{Span: start.Span}
+	name, ok := p.expectName("entity name")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Name = name
+	d.End = name.End
+
+	for {
+		if t := p.peek(); t.Kind == TokenIdent && t.Text == "as" && d.Alias == "" {
+			alias, end, ok := p.parseDisplayName()
+			if !ok {
+				p.skipLine()
+				return d
+			}
+			d.Alias, d.End = alias, end
+		} else if p.isClassShorthand() {
+			class := p.parseClassShorthand()
+			d.Classes = append(d.Classes, class)
+			d.End = class.End
+		} else {
+			break
+		}
+	}
+
+	if t := p.peek(); t.Kind != TokenLBrace {
+		p.expectLineEnd()
+		return d
+	}
+	p.advance()
+	p.expectLineEnd()
+	d.Body = true
+
+	for {
+		p.skipBlank()
+		t := p.peek()
+		switch t.Kind {
+		case TokenEOF:
+			p.diags.Add(d.Span, diagnostic.CodeUnterminatedBlock, "entity %q is not closed (missing '}')", name.Name)
+			d.End = t.Span.Start
+			return d
+		case TokenRBrace:
+			p.advance()
+			d.End = t.Span.End
+			p.expectLineEnd()
+			return d
+		}
+		text, span := p.restOfLine()
+		if c := p.parseColumn(text, span); c != nil {
+			d.Columns = append(d.Columns, c)
+		}
+	}
+}
+
+// parseColumn parses the text of a column line: a type, which may hold
+// parenthesised blanks as in `decimal(10, 2)`, a name, key markers separated
+// by commas and an optional quoted comment
+func (p *parser) parseColumn(text string, span Span) *ColumnDecl {
+	c := &ColumnDecl{Span: span}
+	rest := text
+	if strings.HasSuffix(rest, `"`) {
+		if open := strings.Index(rest, `"`); open < len(rest)-1 {
+			c.Comment = unescapeString(rest[open+1 : len(rest)-1])
+			rest = rest[:open]
+		}
+	}
+
+	depth, i := 0, 0
+	for ; i < len(rest); i++ {
+		switch rest[i] {
+		case '(':
+			depth++
+		case ')':
+			depth = max(depth-1, 0)
+		}
+		if depth == 0 && (rest[i] == ' ' || rest[i] == '\t') {
+			break
+		}
+	}
+	c.Type = rest[:i]
+	fields := strings.FieldsFunc(rest[i:], func(r rune) bool { return r == ' ' || r == '\t' || r == ',' })
+	if len(fields) == 0 {
+		p.diags.Add(span, diagnostic.CodeExpected, "expected a column name after the type %q", c.Type)
+		return nil
+	}
+	c.Name = fields[0]
+	for _, key := range fields[1:] {
+		if !columnKeys[key] {
+			p.diags.Add(span, diagnostic.CodeUnexpectedToken, "unknown column key %q (expected PK, FK or UK)", key)
+			continue
+		}
+		c.Keys = append(c.Keys, key)
+	}
+	return c
+}
+
+// parseSchema parses a `schema name { ... }` or `tablespace name { ... }`
+// block of entities, relationships and notes
+func (p *parser) parseSchema() *SchemaDecl {
+	kw := p.advance()
+	d := &SchemaDecl{Span: kw.Span, Kind: kw.Text}
+	name, ok := p.expectName(kw.Text + " name")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Name = name
+	d.End = name.End
+	if t := p.peek(); t.Kind == TokenIdent && t.Text == "as" {
+		alias, end, ok := p.parseDisplayName()
+		if !ok {
+			p.skipLine()
+			return nil
+		}
+		d.Alias, d.End = alias, end
+	}
+
+	if t := p.peek(); t.Kind != TokenLBrace {
+		p.diags.Add(t.Span, diagnostic.CodeExpected, "expected '{' after %s name, found %s", kw.Text, t.describe())
+		p.skipLine()
+		return d
+	}
+	p.advance()
+	p.expectLineEnd()
+
+	var closed bool
+	d.Decls, d.End, closed = p.parseERDecls(d.Span, fmt.Sprintf("%s %q", kw.Text, name.Name))
+	if closed {
+		p.expectLineEnd()
+	}
+	return d
+}
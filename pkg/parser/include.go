@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// ReadFunc returns the source of the file at path
+type ReadFunc func(path string) ([]byte, error)
+
+type includeResolver struct {
+	read  ReadFunc
+	seen  map[string]bool
+	diags diagnostic.List
+}
+
+// ResolveIncludes parses every file included from file, recursively, and
+// attaches it to its IncludeDecl. Include paths are relative to the including
+// file. Each file is included only once, at its first include, and include
+// cycles are reported. Diagnostics keep the position in the file they were
+// found in.
+func ResolveIncludes(file *File, read ReadFunc) diagnostic.List {
+	root := filepath.Clean(file.Name)
+	r := &includeResolver{read: read, seen: map[string]bool{root: true}}
+	r.resolve(file, []string{root})
+	r.diags.Sort()
+	return r.diags
+}
+
+// resolve resolves the includes of file; stack holds the chain of files
+// that led to it
+func (r *includeResolver) resolve(file *File, stack []string) {
+	r.resolveDecls(file, file.Decls, stack)
+}
+
+func (r *includeResolver) resolveDecls(from *File, decls []Decl, stack []string) {
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *PackageDecl:
+			r.resolveDecls(from, d.Decls, stack)
+		case *IncludeDecl:
+			r.include(from, d, stack)
+		}
+	}
+}
+
+func (r *includeResolver) include(from *File, d *IncludeDecl, stack []string) {
+	path := d.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(from.Name), path)
+	}
+	path = filepath.Clean(path)
+
+	for i, p := range stack {
+		if p == path {
+			chain := append(append([]string{}, stack[i:]...), path)
+			r.diags.Add(d.PathSpan, diagnostic.CodeIncludeCycle, "include cycle: %s", strings.Join(chain, " -> "))
+			return
+		}
+	}
+	if r.seen[path] {
+		return
+	}
+	r.seen[path] = true
+
+	src, err := r.read(path)
+	if err != nil {
+		r.diags.Add(d.PathSpan, diagnostic.CodeIncludeNotFound, "cannot include %q: %v", d.Path, err)
+		return
+	}
+
+	file, diags := Parse(path, src)
+	r.diags = append(r.diags, diags...)
+	// A sequence diagram cannot include classes, nor a class diagram
+	// participants
+	if want, got := diagramOf(from), diagramOf(file); want != got {
+		r.diags.Add(d.PathSpan, diagnostic.CodeIncludeDiagramType, "cannot include %q: it is a %s diagram, not a %s diagram",
+			d.Path, diagramName(got), diagramName(want))
+		return
+	}
+	d.File = file
+	r.resolve(file, append(stack, path))
+}
+
+// diagramOf returns the kind of diagram in file, one of the
+// extuml.Diagram constants
+func diagramOf(file *File) string {
+	if file.Header == nil {
+		return extuml.DiagramClass
+	}
+	return diagramTypes[file.Header.DiagramType]
+}
+
+// diagramName names a kind of diagram in messages
+func diagramName(diagram string) string {
+	if diagram == extuml.DiagramClass {
+		return "class"
+	}
+	return diagram
+}
@@ -0,0 +1,335 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// arrowOperators lists relationship operators and the message arrows of
+// sequence diagrams, longest first so that the lexer always takes the
+// longest match
+var arrowOperators = []string{
+	"<|--", "<|..", "--|>", "..|>", "-->>",
+	"*--", "<--", "<..", "--*", "-->", "..>", "->>", "--)",
+	"--", "..", "->", "-)",
+}
+
+type lexer struct {
+	file   string
+	src    []byte
+	offset int
+	line   int
+	col    int
+	tokens []Token
+
+	// First token text on the current line, used to allow multi-line note strings
+	firstWord string
+	lineEmpty bool
+	sawCode   bool // a token other than a newline has been emitted
+}
+
+// Lex splits src into tokens. It never fails: malformed input produces
+// TokenPunct tokens or unterminated strings that the parser reports.
+func Lex(file string, src []byte) []Token {
+	l := &lexer{file: file, src: src, line: 1, col: 1, lineEmpty: true}
+	for l.offset < len(l.src) {
+		l.next()
+	}
+	start := l.pos()
+	l.tokens = append(l.tokens, Token{Kind: TokenEOF, Span: Span{Start: start, End: start}})
+	return l.tokens
+}
+
+func (l *lexer) pos() Pos {
+	return Pos{File: l.file, Offset: l.offset, Line: l.line, Column: l.col}
+}
+
+func (l *lexer) peekRune(ahead int) rune {
+	off := l.offset
+	for i := 0; ; i++ {
+		if off >= len(l.src) {
+			return 0
+		}
+		r, size := utf8.DecodeRune(l.src[off:])
+		if i == ahead {
+			return r
+		}
+		off += size
+	}
+}
+
+func (l *lexer) advance() rune {
+	r, size := utf8.DecodeRune(l.src[l.offset:])
+	l.offset += size
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col += size
+	}
+	return r
+}
+
+func (l *lexer) emit(kind TokenKind, start Pos) *Token {
+	text := string(l.src[start.Offset:l.offset])
+	l.tokens = append(l.tokens, Token{Kind: kind, Text: text, Span: Span{Start: start, End: l.pos()}})
+	if kind != TokenNewline {
+		l.sawCode = true
+		if l.lineEmpty {
+			l.firstWord = text
+		}
+		l.lineEmpty = false
+	} else {
+		l.lineEmpty = true
+		l.firstWord = ""
+	}
+	return &l.tokens[len(l.tokens)-1]
+}
+
+func (l *lexer) next() {
+	r := l.peekRune(0)
+	start := l.pos()
+
+	switch {
+	case r == '\n':
+		l.advance()
+		l.emit(TokenNewline, start)
+	case r == ' ' || r == '\t' || r == '\r':
+		l.advance()
+	case r == '-' && !l.sawCode && l.lineEmpty && l.lineIs("---"):
+		l.lexDelimited(start, "---", "\n---", TokenFrontMatter)
+	case r == '%' && l.matchAt("%%{") && l.lineEmpty:
+		l.lexDelimited(start, "%%{", "}%%", TokenDirective)
+	case r == '%' && l.peekRune(1) == '%' && l.lineEmpty:
+		for l.offset < len(l.src) && l.peekRune(0) != '\n' {
+			l.advance()
+		}
+		l.emit(TokenComment, start)
+	case r == '"':
+		l.lexString(start)
+	case r == '<' && l.matchAt("<<") && l.lineHas(">>"):
+		l.lexStereotype(start)
+	case r == '{':
+		l.advance()
+		l.emit(TokenLBrace, start)
+	case r == '}':
+		l.advance()
+		l.emit(TokenRBrace, start)
+	case r == '@' && isIdentStart(l.peekRune(1)):
+		l.advance()
+		for isIdentPart(l.peekRune(0)) {
+			l.advance()
+		}
+		l.emit(TokenAnnotation, start)
+	case r == 'o' && l.matchAt("o--") && !l.lineEmpty && l.prevIsSpace():
+		l.offset += 3
+		l.col += 3
+		l.emit(TokenArrow, start)
+	case isIdentStart(r):
+		l.lexIdent(start)
+	case unicode.IsDigit(r):
+		for unicode.IsDigit(l.peekRune(0)) {
+			l.advance()
+		}
+		if l.peekRune(0) == '.' && unicode.IsDigit(l.peekRune(1)) {
+			l.advance()
+			for unicode.IsDigit(l.peekRune(0)) {
+				l.advance()
+			}
+		}
+		l.emit(TokenNumber, start)
+	default:
+		if op := l.matchArrow(); op != "" {
+			l.offset += len(op)
+			l.col += len(op)
+			l.emit(TokenArrow, start)
+			return
+		}
+		l.advance()
+		l.emit(TokenPunct, start)
+	}
+}
+
+// lexString reads a double-quoted string, in which `\"` stands for a quote
+// and `\\` for a backslash. Strings end at the line break unless they
+// belong to a note, whose text may span several lines and in which `\n`
+// stands for a line break.
+func (l *lexer) lexString(start Pos) {
+	multiline := l.firstWord == "note"
+	l.advance() // opening quote
+	var value strings.Builder
+	terminated := false
+	for l.offset < len(l.src) {
+		r := l.peekRune(0)
+		if r == '"' {
+			l.advance()
+			terminated = true
+			break
+		}
+		if r == '\n' && !multiline {
+			break
+		}
+		if next := l.peekRune(1); r == '\\' && (next == '"' || next == '\\') {
+			l.advance()
+		} else if r == '\\' && next == 'n' && multiline {
+			l.advance()
+			l.advance()
+			value.WriteByte('\n')
+			continue
+		}
+		value.WriteRune(l.advance())
+	}
+	tok := l.emit(TokenString, start)
+	tok.Value = value.String()
+	tok.Unterminated = !terminated
+}
+
+// stringEscaper escapes the quotes and backslashes of a string
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// Quote returns s as a double-quoted string that lexes back to s
+func Quote(s string) string {
+	return `"` + stringEscaper.Replace(s) + `"`
+}
+
+// unescapeString undoes stringEscaper for text read from a line rather than
+// lexed as a string
+func unescapeString(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// lexDelimited reads a block from open to close, which may span lines. The
+// token's Value is the text in between.
+func (l *lexer) lexDelimited(start Pos, open, close string, kind TokenKind) {
+	for range open {
+		l.advance()
+	}
+	inner := l.offset
+	innerEnd := len(l.src)
+	terminated := false
+	for l.offset < len(l.src) {
+		if l.matchAt(close) {
+			innerEnd = l.offset
+			for range close {
+				l.advance()
+			}
+			terminated = true
+			break
+		}
+		l.advance()
+	}
+	// The closing `---` of front matter owns the rest of its line
+	if terminated && kind == TokenFrontMatter {
+		for l.offset < len(l.src) && l.peekRune(0) != '\n' {
+			l.advance()
+		}
+	}
+	value := string(l.src[inner:innerEnd])
+	tok := l.emit(kind, start)
+	tok.Value = value
+	tok.Unterminated = !terminated
+}
+
+// lexStereotype reads a `<<name>>` stereotype; Value is the trimmed name
+func (l *lexer) lexStereotype(start Pos) {
+	l.advance()
+	l.advance()
+	inner := l.offset
+	for !l.matchAt(">>") {
+		l.advance()
+	}
+	value := strings.TrimSpace(string(l.src[inner:l.offset]))
+	l.advance()
+	l.advance()
+	tok := l.emit(TokenStereotype, start)
+	tok.Value = value
+}
+
+// lineHas reports whether s occurs between the offset and the end of the line
+func (l *lexer) lineHas(s string) bool {
+	end := l.offset
+	for end < len(l.src) && l.src[end] != '\n' {
+		end++
+	}
+	return strings.Contains(string(l.src[l.offset:end]), s)
+}
+
+// lineIs reports whether the current line, from the offset on and ignoring
+// trailing whitespace, is exactly s
+func (l *lexer) lineIs(s string) bool {
+	end := l.offset
+	for end < len(l.src) && l.src[end] != '\n' {
+		end++
+	}
+	return strings.TrimRight(string(l.src[l.offset:end]), " \t\r") == s
+}
+
+func (l *lexer) lexIdent(start Pos) {
+	for {
+		r := l.peekRune(0)
+		if isIdentPart(r) {
+			l.advance()
+			continue
+		}
+		// A dot continues a qualified name only when a name segment follows
+		if r == '.' && isIdentStart(l.peekRune(1)) {
+			l.advance()
+			continue
+		}
+		break
+	}
+	l.emit(TokenIdent, start)
+}
+
+// matchArrow returns the relationship operator at the current offset, if any
+func (l *lexer) matchArrow() string {
+	// `--o` is aggregation only when the `o` is not the start of a name
+	if l.matchAt("--o") && !isIdentPart(l.runeAtByte(l.offset+3)) {
+		return "--o"
+	}
+	for _, op := range arrowOperators {
+		if l.matchAt(op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func (l *lexer) matchAt(s string) bool {
+	return strings.HasPrefix(string(l.src[l.offset:min(len(l.src), l.offset+len(s))]), s)
+}
+
+func (l *lexer) runeAtByte(off int) rune {
+	if off >= len(l.src) {
+		return 0
+	}
+	r, _ := utf8.DecodeRune(l.src[off:])
+	return r
+}
+
+func (l *lexer) prevIsSpace() bool {
+	if l.offset == 0 {
+		return true
+	}
+	c := l.src[l.offset-1]
+	return c == ' ' || c == '\t' || c == '"'
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
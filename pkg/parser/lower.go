@@ -0,0 +1,530 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// relationshipOperator describes the kind of a relationship arrow and whether
+// its decoration sits on the left-hand operand.
+type relationshipOperator struct {
+	kind          string
+	decoratesLeft bool
+}
+
+var relationshipOperators = map[string]relationshipOperator{
+	"<|--": {extuml.RelInheritance, true},
+	"<|..": {extuml.RelRealization, true},
+	"*--":  {extuml.RelComposition, true},
+	"o--":  {extuml.RelAggregation, true},
+	"<--":  {extuml.RelAssociation, true},
+	"<..":  {extuml.RelDependency, true},
+	"--|>": {extuml.RelInheritance, false},
+	"..|>": {extuml.RelRealization, false},
+	"--*":  {extuml.RelComposition, false},
+	"--o":  {extuml.RelAggregation, false},
+	"-->":  {extuml.RelAssociation, false},
+	"..>":  {extuml.RelDependency, false},
+	"--":   {extuml.RelLink, false},
+	"..":   {extuml.RelDashedLink, false},
+}
+
+type lowerer struct {
+	doc   *extuml.Document
+	diags diagnostic.List
+
+	declared map[string]Span // classifier ID -> name of its declaration
+	packages map[string]int  // package ID -> index in doc.Elements.Packages, or in the schemas or partitions of ER and activity diagrams
+	refs     []reference
+	seq      *sequenceState // nil in class diagrams
+
+	ports map[string]string // port ID -> ID of its owner, in component and deployment diagrams
+
+	classUses []Ident // classDef names applied with `:::` or `cssClass`
+	symbols   Symbols
+}
+
+// reference is a use of a classifier name resolved once every declaration
+// has been lowered. scope is the ID of the package it appears in, and set
+// stores the resolved ID.
+type reference struct {
+	name  Ident
+	scope string
+	code  string
+	what  string
+	set   func(id string)
+}
+
+// Lower converts a parsed File into an extuml.Document
+func Lower(file *File) (*extuml.Document, diagnostic.List) {
+	l := lower(file)
+	return l.doc, l.diags
+}
+
+// Resolve lowers file like Lower and also returns the classifier
+// declarations and the references to them, for editor tooling
+func Resolve(file *File) (*extuml.Document, *Symbols, diagnostic.List) {
+	l := lower(file)
+	return l.doc, &l.symbols, l.diags
+}
+
+func lower(file *File) *lowerer {
+	l := &lowerer{
+		doc:      &extuml.Document{Version: "0.1"},
+		declared: map[string]Span{},
+		packages: map[string]int{},
+	}
+	l.lowerMeta(file)
+	switch diagramOf(file) {
+	case extuml.DiagramSequence:
+		l.lowerSequence(file.Decls)
+	case extuml.DiagramState:
+		l.lowerStateMachine(file.Decls)
+	case extuml.DiagramER:
+		l.lowerER(file.Decls)
+	case extuml.DiagramComponent, extuml.DiagramDeployment:
+		l.lowerArchitecture(diagramOf(file), file.Decls)
+	case extuml.DiagramActivity:
+		l.lowerActivity(file.Decls)
+	default:
+		l.doc.Elements = &extuml.Elements{
+			Classes:    []extuml.Class{},
+			Interfaces: []extuml.Interface{},
+			Enums:      []extuml.Enum{},
+			Packages:   []extuml.Package{},
+			Notes:      []extuml.Note{},
+
+			Relationships: []extuml.Relationship{},
+		}
+		l.lowerDecls(file.Decls, -1)
+	}
+	l.resolveRefs()
+	l.checkClassUses()
+	l.diags.Sort()
+	return l
+}
+
+// lowerDecls lowers decls into the document; parent is the index of the
+// enclosing package in doc.Elements.Packages, or -1 at the top level
+func (l *lowerer) lowerDecls(decls []Decl, parent int) {
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *ClassifierDecl:
+			// Classifiers are qualified by their package's ID like nested
+			// packages, so equal names in different packages do not collide
+			id := l.qualify(parent, d.Name.Name)
+			if first, dup := l.declared[id]; dup {
+				l.diags.Add(d.Name.Span, diagnostic.CodeDuplicateElement,
+					"%s %q is already declared at %s", d.Kind, id, first.Start)
+				continue
+			}
+			if _, dup := l.packages[id]; dup {
+				l.diags.Add(d.Name.Span, diagnostic.CodeDuplicateElement,
+					"%s %q has the same ID as a package", d.Kind, id)
+				continue
+			}
+			l.declared[id] = d.Name.Span
+			l.declareSymbol(id, d.Kind, d.Name, d)
+			l.addChild(parent, l.lowerClassifier(d, id, l.scope(parent)))
+		case *PackageDecl:
+			id := l.qualify(parent, d.Name.Name)
+			// A package declared again is reopened and its members merged
+			if idx, ok := l.packages[id]; ok {
+				l.lowerDecls(d.Decls, idx)
+				continue
+			}
+			if first, dup := l.declared[id]; dup {
+				l.diags.Add(d.Name.Span, diagnostic.CodeDuplicateElement,
+					"package %q has the same ID as the classifier declared at %s", id, first.Start)
+				continue
+			}
+			l.addChild(parent, id)
+			l.doc.Elements.Packages = append(l.doc.Elements.Packages, extuml.Package{
+				ID:       id,
+				Type:     "package",
+				Name:     d.Name.Name,
+				Children: []string{},
+			})
+			l.packages[id] = len(l.doc.Elements.Packages) - 1
+			l.lowerDecls(d.Decls, l.packages[id])
+		case *NoteDecl:
+			l.addChild(parent, l.lowerNote(d, &l.doc.Elements.Notes, l.scope(parent)))
+		case *RelationshipDecl:
+			l.lowerRelationship(d, l.scope(parent))
+		case *ClassDefDecl:
+			l.lowerClassDef(d)
+		case *StyleDecl:
+			l.lowerStyle(d, l.scope(parent))
+		case *CSSClassDecl:
+			l.lowerCSSClass(d, l.scope(parent))
+		case *IncludeDecl:
+			// Included declarations belong to the including scope
+			if d.File != nil {
+				l.lowerDecls(d.File.Decls, parent)
+			}
+		}
+	}
+}
+
+// resolveRefs replaces the names used in references by the IDs they refer
+// to, and warns about names that are never declared or match several
+// classifiers
+func (l *lowerer) resolveRefs() {
+	for _, ref := range l.refs {
+		ids := l.resolve(ref.name.Name, ref.scope)
+		resolved := Reference{Name: ref.name}
+		if len(ids) == 1 {
+			resolved.ID = ids[0]
+		}
+		l.symbols.References = append(l.symbols.References, resolved)
+		switch len(ids) {
+		case 0:
+			l.diags.Add(ref.name.Span, ref.code, "%s %q is not declared", ref.what, ref.name.Name)
+		case 1:
+			ref.set(ids[0])
+		default:
+			l.diags.Add(ref.name.Span, diagnostic.CodeAmbiguousReference,
+				"%s %q is ambiguous: it matches %s", ref.what, ref.name.Name, strings.Join(ids, ", "))
+		}
+	}
+}
+
+// declareSymbol records the declaration of the element id for editor
+// tooling; decl is nil if name declares it implicitly
+func (l *lowerer) declareSymbol(id, kind string, name Ident, decl Decl) {
+	l.symbols.Declarations = append(l.symbols.Declarations, Declaration{ID: id, Kind: kind, Name: name, Decl: decl})
+}
+
+// useSymbol records name as a reference to the element id for editor
+// tooling
+func (l *lowerer) useSymbol(name Ident, id string) {
+	l.symbols.References = append(l.symbols.References, Reference{Name: name, ID: id})
+}
+
+// resolve looks name up relative to scope and each enclosing package, then
+// as an absolute ID. Failing that, a name matching the end of exactly one
+// qualified ID refers to it; all matches are returned so that ambiguous
+// names can be reported.
+func (l *lowerer) resolve(name, scope string) []string {
+	for {
+		id := qualifiedID(scope, name)
+		if _, ok := l.declared[id]; ok {
+			return []string{id}
+		}
+		if scope == "" {
+			break
+		}
+		scope = scope[:max(strings.LastIndex(scope, "."), 0)]
+	}
+
+	var ids []string
+	for id := range l.declared {
+		if strings.HasSuffix(id, "."+name) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// scope returns the ID of the package at index parent, or "" at the top level
+func (l *lowerer) scope(parent int) string {
+	if parent < 0 {
+		return ""
+	}
+	return l.doc.Elements.Packages[parent].ID
+}
+
+// qualify returns the ID of an element called name declared in the package
+// at index parent
+func (l *lowerer) qualify(parent int, name string) string {
+	return qualifiedID(l.scope(parent), name)
+}
+
+// qualifiedID joins a package ID and a name
+func qualifiedID(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// addChild records id as a member of the package at index parent
+func (l *lowerer) addChild(parent int, id string) {
+	if parent < 0 {
+		return
+	}
+	pkg := &l.doc.Elements.Packages[parent]
+	pkg.Children = append(pkg.Children, id)
+}
+
+// lowerClassifier appends a class, interface or enum with the given ID and
+// returns the ID. scope is the ID of the enclosing package, used to resolve
+// @near targets.
+func (l *lowerer) lowerClassifier(d *ClassifierDecl, id, scope string) string {
+	name := d.Name.Name
+	if d.Alias != "" {
+		name = d.Alias
+	}
+	url := ""
+	var placement *extuml.Placement
+	for _, ann := range d.Annotations {
+		switch ann.Name {
+		case "url":
+			url = ann.Value
+		case "pos", "layer", "near":
+			if placement == nil {
+				placement = &extuml.Placement{}
+			}
+			l.lowerPlacement(placement, ann, scope)
+		default:
+			l.diags.Add(ann.Span, diagnostic.CodeUnknownAnnotation, "unknown annotation @%s is ignored", ann.Name)
+		}
+	}
+	members := map[string]bool{}
+	stereotypes, abstract, final := stereotypesOf(d)
+	typeParams := typeParametersOf(d)
+	var classes []string
+	for _, class := range d.Classes {
+		classes = append(classes, class.Name)
+		l.classUses = append(l.classUses, class)
+	}
+
+	switch d.Kind {
+	case "interface":
+		iface := extuml.Interface{
+			ID:             id,
+			Type:           "interface",
+			Name:           name,
+			URL:            url,
+			Stereotypes:    stereotypes,
+			TypeParameters: typeParams,
+			Operations:     []extuml.Operation{},
+			Placement:      placement,
+			StyleClasses:   classes,
+		}
+		for _, m := range d.Members {
+			op := memberParser{m.Span, &l.diags}.operation(stripComment(m.Text))
+			l.checkDuplicateMember(members, m, "operation", operationKey(op))
+			iface.Operations = append(iface.Operations, op)
+		}
+		l.doc.Elements.Interfaces = append(l.doc.Elements.Interfaces, iface)
+	case "enum":
+		enum := extuml.Enum{
+			ID:           id,
+			Type:         "enum",
+			Name:         name,
+			URL:          url,
+			Stereotypes:  stereotypes,
+			Literals:     []string{},
+			Placement:    placement,
+			StyleClasses: classes,
+		}
+		for _, m := range d.Members {
+			// Remove trailing comma if present
+			literal := strings.TrimSuffix(stripComment(m.Text), ",")
+			l.checkDuplicateMember(members, m, "literal", literal)
+			enum.Literals = append(enum.Literals, literal)
+		}
+		l.doc.Elements.Enums = append(l.doc.Elements.Enums, enum)
+	default:
+		class := extuml.Class{
+			ID:             id,
+			Type:           "class",
+			Name:           name,
+			URL:            url,
+			Stereotypes:    stereotypes,
+			Abstract:       abstract,
+			Final:          final,
+			TypeParameters: typeParams,
+			Attributes:     []extuml.Attribute{},
+			Operations:     []extuml.Operation{},
+			Placement:      placement,
+			StyleClasses:   classes,
+		}
+		for _, m := range d.Members {
+			text, p := stripComment(m.Text), memberParser{m.Span, &l.diags}
+			if IsOperation(text) {
+				op := p.operation(text)
+				l.checkDuplicateMember(members, m, "operation", operationKey(op))
+				class.Operations = append(class.Operations, op)
+			} else {
+				attr := p.attribute(text)
+				l.checkDuplicateMember(members, m, "attribute", attr.Name)
+				class.Attributes = append(class.Attributes, attr)
+			}
+		}
+		l.doc.Elements.Classes = append(l.doc.Elements.Classes, class)
+	}
+	return id
+}
+
+// stereotypesOf collects the stereotypes of d in order, without duplicates.
+// On classes `abstract` and `final`, written either as modifiers or as
+// stereotypes, become flags instead.
+func stereotypesOf(d *ClassifierDecl) (names []string, abstract, final bool) {
+	seen := map[string]bool{}
+	add := func(name string) {
+		switch {
+		case d.Kind == "class" && name == "abstract":
+			abstract = true
+		case d.Kind == "class" && name == "final":
+			final = true
+		case !seen[name]:
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, mod := range d.Modifiers {
+		add(mod.Name)
+	}
+	for _, st := range d.Stereotypes {
+		for _, name := range st.Names {
+			add(name)
+		}
+	}
+	return names, abstract, final
+}
+
+// typeParametersOf splits the generic parameter list of d
+func typeParametersOf(d *ClassifierDecl) []string {
+	if d.TypeParams == nil || d.TypeParams.Text == "" {
+		return nil
+	}
+	var params []string
+	for _, param := range splitTopLevel(normalizeGenerics(d.TypeParams.Text), ',') {
+		params = append(params, strings.TrimSpace(param))
+	}
+	return params
+}
+
+// lowerPlacement applies a @pos, @layer or @near annotation to p
+func (l *lowerer) lowerPlacement(p *extuml.Placement, ann *Annotation, scope string) {
+	switch ann.Name {
+	case "pos":
+		fields := strings.FieldsFunc(ann.Value, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+		var pos [3]float64
+		ok := len(fields) == 3
+		for i := 0; ok && i < 3; i++ {
+			var err error
+			pos[i], err = strconv.ParseFloat(fields[i], 64)
+			ok = err == nil
+		}
+		if !ok {
+			l.diags.Add(ann.Span, diagnostic.CodeInvalidAnnotationValue, "invalid @pos %q: expected three numbers x, y, z", ann.Value)
+			return
+		}
+		p.Position = &pos
+	case "layer":
+		layer, err := strconv.Atoi(ann.Value)
+		if err != nil {
+			l.diags.Add(ann.Span, diagnostic.CodeInvalidAnnotationValue, "invalid @layer %q: expected an integer", ann.Value)
+			return
+		}
+		p.Layer = &layer
+	case "near":
+		if ann.Value == "" {
+			l.diags.Add(ann.Span, diagnostic.CodeInvalidAnnotationValue, "@near needs the name of an element")
+			return
+		}
+		p.Near = ann.Value
+		// The value ends the annotation's line
+		target := Ident{Span: Span{Start: shiftPos(ann.End, -len(ann.Value)), End: ann.End}, Name: ann.Value}
+		l.refs = append(l.refs, reference{target, scope, diagnostic.CodeUnknownPlacementTarget, "@near target",
+			func(id string) { p.Near = id }})
+	}
+}
+
+// checkDuplicateMember warns when key has already been seen in the same
+// classifier body
+func (l *lowerer) checkDuplicateMember(seen map[string]bool, m *Member, what, key string) {
+	if seen[what+" "+key] {
+		l.diags.Add(m.Span, diagnostic.CodeDuplicateMember, "duplicate %s %q", what, key)
+		return
+	}
+	seen[what+" "+key] = true
+}
+
+// operationKey identifies an operation by name and parameter types, so that
+// overloads are not reported as duplicates
+func operationKey(op extuml.Operation) string {
+	types := make([]string, len(op.Parameters))
+	for i, param := range op.Parameters {
+		types[i] = param.Type
+	}
+	return op.Name + "(" + strings.Join(types, ", ") + ")"
+}
+
+func (l *lowerer) lowerRelationship(d *RelationshipDecl, scope string) {
+	op, ok := relationshipOperators[d.Operator]
+	if !ok {
+		return
+	}
+
+	rel := extuml.Relationship{
+		ID:               fmt.Sprintf("rel_%d", len(l.doc.Elements.Relationships)+1),
+		Type:             op.kind,
+		From:             d.Left.Name,
+		To:               d.Right.Name,
+		Label:            d.Label,
+		FromMultiplicity: d.LeftMult,
+		ToMultiplicity:   d.RightMult,
+	}
+
+	// Normalise so that the decorated end is always To
+	if op.decoratesLeft {
+		rel.From, rel.To = rel.To, rel.From
+		rel.FromMultiplicity, rel.ToMultiplicity = rel.ToMultiplicity, rel.FromMultiplicity
+	}
+
+	idx := len(l.doc.Elements.Relationships)
+	l.doc.Elements.Relationships = append(l.doc.Elements.Relationships, rel)
+
+	// Endpoints are resolved to IDs once every classifier is known
+	left := func(id string) { l.doc.Elements.Relationships[idx].From = id }
+	right := func(id string) { l.doc.Elements.Relationships[idx].To = id }
+	if op.decoratesLeft {
+		left, right = right, left
+	}
+	l.refs = append(l.refs,
+		reference{d.Left, scope, diagnostic.CodeUnknownRelationshipTarget, "relationship endpoint", left},
+		reference{d.Right, scope, diagnostic.CodeUnknownRelationshipTarget, "relationship endpoint", right})
+}
+
+// lowerNote appends the note d declares to notes and returns its ID. Its
+// anchor is resolved relative to scope once every element is declared.
+func (l *lowerer) lowerNote(d *NoteDecl, notes *[]extuml.Note, scope string) string {
+	note := extuml.Note{
+		ID:   fmt.Sprintf("note_%d", len(*notes)+1),
+		Type: "note",
+		Text: noteText(d.Text),
+	}
+	if d.Anchor != nil {
+		note.Anchor = d.Anchor.Name
+		idx := len(*notes)
+		l.refs = append(l.refs, reference{*d.Anchor, scope, diagnostic.CodeUnknownNoteAnchor, "note anchor",
+			func(id string) { (*notes)[idx].Anchor = id }})
+	}
+	*notes = append(*notes, note)
+	return note.ID
+}
+
+// noteText trims the indentation of multi-line note bodies
+func noteText(raw string) string {
+	lines := strings.Split(raw, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	if len(lines) > 1 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	return strings.Join(lines, "\n")
+}
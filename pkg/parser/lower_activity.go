@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// lowerActivity lowers the declarations of an activity diagram. Nodes are
+// declared first so that flows may precede their declaration; names that
+// name no node are declared as actions in the partition using them.
+// Partitions share the namespace of nodes but do not qualify their IDs.
+func (l *lowerer) lowerActivity(decls []Decl) {
+	l.doc.Diagram = extuml.DiagramActivity
+	l.doc.Activity = &extuml.Activity{
+		Nodes: []extuml.ActivityNode{},
+		Flows: []extuml.Flow{},
+	}
+	l.declareActivityNodes(decls, "")
+	l.lowerFlows(decls, "")
+}
+
+// declareActivityNodes declares the partitions and nodes in decls, which
+// belong to the partition with ID partition, or to none if it is empty
+func (l *lowerer) declareActivityNodes(decls []Decl, partition string) {
+	act := l.doc.Activity
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *PartitionDecl:
+			id := d.Name.Name
+			if first, dup := l.declared[id]; dup {
+				l.diags.Add(d.Name.Span, diagnostic.CodeDuplicateElement, "partition %q is already declared at %s", id, first.Start)
+				continue
+			}
+			l.declared[id] = d.Name.Span
+			name := id
+			if d.Alias != "" {
+				name = d.Alias
+			}
+			l.packages[id] = len(act.Partitions)
+			act.Partitions = append(act.Partitions, extuml.Partition{ID: id, Name: name, Nodes: []string{}})
+			l.declareActivityNodes(d.Decls, id)
+		case *ActivityNodeDecl:
+			id := d.Name.Name
+			if first, dup := l.declared[id]; dup {
+				l.diags.Add(d.Name.Span, diagnostic.CodeDuplicateElement, "%s %q is already declared at %s", d.Kind, id, first.Start)
+				continue
+			}
+			l.declared[id] = d.Name.Span
+			node := extuml.ActivityNode{ID: id, Type: d.Kind, Partition: partition}
+			if d.Alias != "" {
+				node.Name = d.Alias
+			} else if d.Name.Name != d.Kind {
+				node.Name = d.Name.Name
+			}
+			// An unnamed start or end has only its keyword, which cannot be
+			// renamed
+			if d.Name.Name != d.Kind {
+				l.declareSymbol(id, d.Kind, d.Name, d)
+			}
+			for _, class := range d.Classes {
+				node.StyleClasses = append(node.StyleClasses, class.Name)
+				l.classUses = append(l.classUses, class)
+			}
+			l.addActivityNode(node)
+		case *IncludeDecl:
+			if d.File != nil {
+				l.declareActivityNodes(d.File.Decls, partition)
+			}
+		}
+	}
+}
+
+// addActivityNode adds node to the activity and to its partition
+func (l *lowerer) addActivityNode(node extuml.ActivityNode) {
+	act := l.doc.Activity
+	act.Nodes = append(act.Nodes, node)
+	if node.Partition != "" {
+		p := &act.Partitions[l.packages[node.Partition]]
+		p.Nodes = append(p.Nodes, node.ID)
+	}
+}
+
+// lowerFlows lowers the flows, notes and styles in decls, which belong to
+// the partition with ID partition
+func (l *lowerer) lowerFlows(decls []Decl, partition string) {
+	act := l.doc.Activity
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *PartitionDecl:
+			if l.declared[d.Name.Name] != d.Name.Span {
+				// A duplicate, already reported
+				continue
+			}
+			l.lowerFlows(d.Decls, d.Name.Name)
+		case *FlowDecl:
+			from, okFrom := l.activityNode(d.From, partition)
+			to, okTo := l.activityNode(d.To, partition)
+			if !okFrom || !okTo {
+				continue
+			}
+			flow := extuml.Flow{
+				ID:   fmt.Sprintf("flow_%d", len(act.Flows)+1),
+				Type: extuml.FlowControl,
+				From: from.ID,
+				To:   to.ID,
+			}
+			if from.Type == extuml.ActivityObject || to.Type == extuml.ActivityObject {
+				flow.Type = extuml.FlowObject
+			}
+			flow.Guard, flow.Label = splitFlowLabel(d.Label)
+			act.Flows = append(act.Flows, flow)
+		case *NoteDecl:
+			l.lowerNote(d, &act.Notes, "")
+		case *ClassDefDecl:
+			l.lowerClassDef(d)
+		case *StyleDecl:
+			l.lowerStyle(d, "")
+		case *CSSClassDecl:
+			l.lowerCSSClass(d, "")
+		case *IncludeDecl:
+			if d.File != nil {
+				l.lowerFlows(d.File.Decls, partition)
+			}
+		}
+	}
+}
+
+// activityNode returns the node that one end of a flow in partition names,
+// declaring an action in partition if no node or partition has that name.
+// ok is false if the name is a partition or a qualified name.
+func (l *lowerer) activityNode(name Ident, partition string) (node extuml.ActivityNode, ok bool) {
+	act := l.doc.Activity
+	for _, n := range act.Nodes {
+		if n.ID == name.Name {
+			l.useSymbol(name, n.ID)
+			return n, true
+		}
+	}
+	if _, isPartition := l.packages[name.Name]; isPartition {
+		l.diags.Add(name.Span, diagnostic.CodeUnknownRelationshipTarget, "flow end %q is a partition, not a node", name.Name)
+		return node, false
+	}
+	if strings.Contains(name.Name, ".") {
+		l.diags.Add(name.Span, diagnostic.CodeUnknownRelationshipTarget, "flow end %q is not declared", name.Name)
+		return node, false
+	}
+
+	l.declared[name.Name] = name.Span
+	l.declareSymbol(name.Name, extuml.ActivityAction, name, nil)
+	node = extuml.ActivityNode{ID: name.Name, Type: extuml.ActivityAction, Name: name.Name, Partition: partition}
+	l.addActivityNode(node)
+	return node, true
+}
+
+// splitFlowLabel splits `[guard] label`; both parts are optional
+func splitFlowLabel(label string) (guard, rest string) {
+	rest = label
+	if open := strings.Index(label, "["); open >= 0 {
+		if end := strings.LastIndex(label, "]"); end > open {
+			guard = strings.TrimSpace(label[open+1 : end])
+			rest = label[:open] + label[end+1:]
+		}
+	}
+	return guard, strings.TrimSpace(rest)
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// lowerArchitecture lowers the declarations of a component or deployment
+// diagram. Components, nodes, artifacts and their ports are declared first
+// so that connectors may precede their declaration; names that resolve to
+// no element or interface are declared as components in the block using
+// them.
+func (l *lowerer) lowerArchitecture(diagram string, decls []Decl) {
+	l.doc.Diagram = diagram
+	l.doc.Architecture = &extuml.Architecture{
+		Components: []extuml.Component{},
+		Connectors: []extuml.Connector{},
+	}
+	l.ports = map[string]string{}
+	l.declareComponents(decls, "")
+	l.lowerConnectors(decls, "")
+}
+
+// declareComponents declares the components, nodes and artifacts in decls,
+// which belong to the element with ID parent, or to the top level if it is
+// empty
+func (l *lowerer) declareComponents(decls []Decl, parent string) {
+	arch := l.doc.Architecture
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *ComponentDecl:
+			id := qualifiedID(parent, d.Name.Name)
+			if first, dup := l.declared[id]; dup {
+				l.diags.Add(d.Name.Span, diagnostic.CodeDuplicateElement, "%s %q is already declared at %s", d.Kind, id, first.Start)
+				continue
+			}
+			l.declared[id] = d.Name.Span
+			l.declareSymbol(id, d.Kind, d.Name, d)
+			name := d.Name.Name
+			if d.Alias != "" {
+				name = d.Alias
+			}
+			var classes []string
+			for _, class := range d.Classes {
+				classes = append(classes, class.Name)
+				l.classUses = append(l.classUses, class)
+			}
+			ports := l.declarePorts(d.Decls, id)
+
+			switch d.Kind {
+			case "component":
+				c := extuml.Component{ID: id, Type: d.Kind, Name: name, Parent: parent, Stereotypes: d.Stereotypes, Ports: ports, StyleClasses: classes}
+				for _, decl := range d.Decls {
+					if use, ok := decl.(*InterfaceUseDecl); ok {
+						for _, iface := range use.Names {
+							if use.Provided {
+								c.Provides = append(c.Provides, iface.Name)
+							} else {
+								c.Requires = append(c.Requires, iface.Name)
+							}
+						}
+					}
+				}
+				arch.Components = append(arch.Components, c)
+			case "artifact":
+				arch.Artifacts = append(arch.Artifacts, extuml.Artifact{ID: id, Type: d.Kind, Name: name, Parent: parent, Stereotypes: d.Stereotypes, StyleClasses: classes})
+			default:
+				arch.Nodes = append(arch.Nodes, extuml.DeploymentNode{ID: id, Type: d.Kind, Name: name, Parent: parent, Stereotypes: d.Stereotypes, Ports: ports, StyleClasses: classes})
+			}
+			l.declareComponents(d.Decls, id)
+		case *IncludeDecl:
+			if d.File != nil {
+				l.declareComponents(d.File.Decls, parent)
+			}
+		}
+	}
+}
+
+// declarePorts declares the ports in decls, which belong to the element
+// with ID owner, and returns them
+func (l *lowerer) declarePorts(decls []Decl, owner string) []extuml.Port {
+	var ports []extuml.Port
+	for _, decl := range decls {
+		d, ok := decl.(*PortDecl)
+		if !ok {
+			continue
+		}
+		id := qualifiedID(owner, d.Name.Name)
+		if first, dup := l.declared[id]; dup {
+			l.diags.Add(d.Name.Span, diagnostic.CodeDuplicateElement, "port %q is already declared at %s", id, first.Start)
+			continue
+		}
+		l.declared[id] = d.Name.Span
+		l.declareSymbol(id, "port", d.Name, d)
+		l.ports[id] = owner
+		ports = append(ports, extuml.Port{ID: id, Name: d.Name.Name})
+	}
+	return ports
+}
+
+// lowerConnectors lowers the connectors, notes and styles in decls, which
+// belong to the element with ID parent
+func (l *lowerer) lowerConnectors(decls []Decl, parent string) {
+	arch := l.doc.Architecture
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *ComponentDecl:
+			id := qualifiedID(parent, d.Name.Name)
+			if l.declared[id] != d.Name.Span {
+				// A duplicate, already reported
+				continue
+			}
+			l.lowerConnectors(d.Decls, id)
+		case *ConnectorDecl:
+			from, fromPort, fromInterface, okFrom := l.connectorEnd(d.From, parent)
+			to, toPort, toInterface, okTo := l.connectorEnd(d.To, parent)
+			if !okFrom || !okTo {
+				continue
+			}
+			arch.Connectors = append(arch.Connectors, extuml.Connector{
+				ID:            fmt.Sprintf("connector_%d", len(arch.Connectors)+1),
+				Type:          connectorOperators[d.Operator],
+				From:          from,
+				FromPort:      fromPort,
+				FromInterface: fromInterface,
+				To:            to,
+				ToPort:        toPort,
+				ToInterface:   toInterface,
+				Label:         d.Label,
+			})
+		case *NoteDecl:
+			l.lowerNote(d, &arch.Notes, parent)
+		case *ClassDefDecl:
+			l.lowerClassDef(d)
+		case *StyleDecl:
+			l.lowerStyle(d, parent)
+		case *CSSClassDecl:
+			l.lowerCSSClass(d, parent)
+		case *IncludeDecl:
+			if d.File != nil {
+				l.lowerConnectors(d.File.Decls, parent)
+			}
+		}
+	}
+}
+
+// connectorEnd returns the element and, for a port, the port ID or, for an
+// interface, the interface name that one end of a connector in the element
+// with ID parent names. A simple name that resolves to no element is looked
+// up among the interfaces the components provide, then among those they
+// require, and is otherwise declared as a component in parent; ok is false
+// if the name is ambiguous or names an unknown port.
+func (l *lowerer) connectorEnd(name Ident, parent string) (id, port, iface string, ok bool) {
+	switch ids := l.resolve(name.Name, parent); len(ids) {
+	case 0:
+	case 1:
+		l.useSymbol(name, ids[0])
+		if owner, isPort := l.ports[ids[0]]; isPort {
+			return owner, ids[0], "", true
+		}
+		return ids[0], "", "", true
+	default:
+		l.diags.Add(name.Span, diagnostic.CodeAmbiguousReference,
+			"connector end %q is ambiguous: it matches %s", name.Name, strings.Join(ids, ", "))
+		return "", "", "", false
+	}
+
+	if strings.Contains(name.Name, ".") {
+		l.diags.Add(name.Span, diagnostic.CodeUnknownRelationshipTarget, "connector end %q is not declared", name.Name)
+		return "", "", "", false
+	}
+	for _, provided := range []bool{true, false} {
+		switch owners := l.interfaceOwners(name.Name, provided); len(owners) {
+		case 0:
+			continue
+		case 1:
+			return owners[0], "", name.Name, true
+		default:
+			l.diags.Add(name.Span, diagnostic.CodeAmbiguousReference,
+				"connector end %q is ambiguous: it is an interface of %s", name.Name, strings.Join(owners, ", "))
+			return "", "", "", false
+		}
+	}
+
+	id = qualifiedID(parent, name.Name)
+	l.declared[id] = name.Span
+	l.declareSymbol(id, "component", name, nil)
+	l.doc.Architecture.Components = append(l.doc.Architecture.Components, extuml.Component{
+		ID:     id,
+		Type:   "component",
+		Name:   name.Name,
+		Parent: parent,
+	})
+	return id, "", "", true
+}
+
+// interfaceOwners returns the IDs of the components that provide, or
+// require, the interface called name
+func (l *lowerer) interfaceOwners(name string, provided bool) []string {
+	var owners []string
+	for _, c := range l.doc.Architecture.Components {
+		names := c.Requires
+		if provided {
+			names = c.Provides
+		}
+		if slices.Contains(names, name) {
+			owners = append(owners, c.ID)
+		}
+	}
+	return owners
+}
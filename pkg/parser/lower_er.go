@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// lowerER lowers the declarations of an ER diagram. Entities are declared
+// first so that relationships may precede their declaration; names that
+// resolve to no entity are declared, without columns, in the schema using
+// them.
+func (l *lowerer) lowerER(decls []Decl) {
+	l.doc.Diagram = extuml.DiagramER
+	l.doc.ER = &extuml.ERModel{
+		Entities:      []extuml.Entity{},
+		Relationships: []extuml.ERRelationship{},
+	}
+	l.declareEntities(decls, -1)
+	l.lowerERRelationships(decls, -1)
+}
+
+// schemaID returns the ID of the schema at index schema, or "" at the top
+// level
+func (l *lowerer) schemaID(schema int) string {
+	if schema < 0 {
+		return ""
+	}
+	return l.doc.ER.Schemas[schema].ID
+}
+
+// declareEntities declares the schemas and entities in decls; schema is the
+// index of the enclosing schema in doc.ER.Schemas, or -1 at the top level
+func (l *lowerer) declareEntities(decls []Decl, schema int) {
+	er := l.doc.ER
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *EntityDecl:
+			id := qualifiedID(l.schemaID(schema), d.Name.Name)
+			if first, dup := l.declared[id]; dup {
+				l.diags.Add(d.Name.Span, diagnostic.CodeDuplicateElement, "entity %q is already declared at %s", id, first.Start)
+				continue
+			}
+			if _, dup := l.packages[id]; dup {
+				l.diags.Add(d.Name.Span, diagnostic.CodeDuplicateElement, "entity %q has the same ID as a schema", id)
+				continue
+			}
+			l.declared[id] = d.Name.Span
+			l.declareSymbol(id, "entity", d.Name, d)
+			l.addEntity(d, id, schema)
+		case *SchemaDecl:
+			id := d.Name.Name
+			// A schema declared again is reopened and its entities merged
+			if idx, ok := l.packages[id]; ok {
+				l.declareEntities(d.Decls, idx)
+				continue
+			}
+			if first, dup := l.declared[id]; dup {
+				l.diags.Add(d.Name.Span, diagnostic.CodeDuplicateElement,
+					"%s %q has the same ID as the entity declared at %s", d.Kind, id, first.Start)
+				continue
+			}
+			s := extuml.Schema{ID: id, Type: d.Kind, Name: d.Name.Name, Entities: []string{}}
+			if d.Alias != "" {
+				s.Name = d.Alias
+			}
+			er.Schemas = append(er.Schemas, s)
+			l.packages[id] = len(er.Schemas) - 1
+			l.declareEntities(d.Decls, l.packages[id])
+		case *IncludeDecl:
+			if d.File != nil {
+				l.declareEntities(d.File.Decls, schema)
+			}
+		}
+	}
+}
+
+// addEntity appends the entity declared by d with the given ID to the
+// schema at index schema
+func (l *lowerer) addEntity(d *EntityDecl, id string, schema int) {
+	er := l.doc.ER
+	entity := extuml.Entity{
+		ID:      id,
+		Type:    "entity",
+		Name:    d.Name.Name,
+		Schema:  l.schemaID(schema),
+		Columns: []extuml.Column{},
+	}
+	if d.Alias != "" {
+		entity.Name = d.Alias
+	}
+	seen := map[string]bool{}
+	for _, c := range d.Columns {
+		if seen[c.Name] {
+			l.diags.Add(c.Span, diagnostic.CodeDuplicateMember, "duplicate column %q in entity %q", c.Name, id)
+		}
+		seen[c.Name] = true
+		entity.Columns = append(entity.Columns, extuml.Column{Name: c.Name, Type: c.Type, Keys: c.Keys, Comment: c.Comment})
+	}
+	for _, class := range d.Classes {
+		entity.StyleClasses = append(entity.StyleClasses, class.Name)
+		l.classUses = append(l.classUses, class)
+	}
+	er.Entities = append(er.Entities, entity)
+	if schema >= 0 {
+		er.Schemas[schema].Entities = append(er.Schemas[schema].Entities, id)
+	}
+}
+
+// lowerERRelationships lowers the relationships, notes and styles in decls,
+// which belong to the schema at index schema
+func (l *lowerer) lowerERRelationships(decls []Decl, schema int) {
+	er := l.doc.ER
+	scope := l.schemaID(schema)
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *SchemaDecl:
+			if idx, ok := l.packages[d.Name.Name]; ok {
+				l.lowerERRelationships(d.Decls, idx)
+			}
+		case *ERRelationshipDecl:
+			from, okFrom := l.entity(d.Left, schema)
+			to, okTo := l.entity(d.Right, schema)
+			if !okFrom || !okTo {
+				continue
+			}
+			er.Relationships = append(er.Relationships, extuml.ERRelationship{
+				ID:              fmt.Sprintf("rel_%d", len(er.Relationships)+1),
+				From:            from,
+				To:              to,
+				FromCardinality: leftCardinalities[d.Operator[:2]],
+				ToCardinality:   rightCardinalities[d.Operator[4:]],
+				Identifying:     d.Operator[2:4] == "--",
+				Label:           d.Label,
+			})
+		case *NoteDecl:
+			l.lowerNote(d, &er.Notes, scope)
+		case *ClassDefDecl:
+			l.lowerClassDef(d)
+		case *StyleDecl:
+			l.lowerStyle(d, scope)
+		case *CSSClassDecl:
+			l.lowerCSSClass(d, scope)
+		case *IncludeDecl:
+			if d.File != nil {
+				l.lowerERRelationships(d.File.Decls, schema)
+			}
+		}
+	}
+}
+
+// entity returns the ID of the entity a relationship in the schema at index
+// schema names, declaring it there if no entity matches. ok is false if the
+// name is ambiguous.
+func (l *lowerer) entity(name Ident, schema int) (id string, ok bool) {
+	switch ids := l.resolve(name.Name, l.schemaID(schema)); len(ids) {
+	case 0:
+	case 1:
+		l.useSymbol(name, ids[0])
+		return ids[0], true
+	default:
+		l.diags.Add(name.Span, diagnostic.CodeAmbiguousReference,
+			"entity %q is ambiguous: it matches %s", name.Name, strings.Join(ids, ", "))
+		return "", false
+	}
+
+	id = qualifiedID(l.schemaID(schema), name.Name)
+	if _, clash := l.packages[id]; clash {
+		l.diags.Add(name.Span, diagnostic.CodeUnknownRelationshipTarget, "%q is a schema, not an entity", name.Name)
+		return "", false
+	}
+	l.declared[id] = name.Span
+	l.declareSymbol(id, "entity", name, nil)
+	l.addEntity(&EntityDecl{Span: name.Span, Name: name}, id, schema)
+	return id, true
+}
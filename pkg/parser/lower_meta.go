@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// lowerMeta fills the document's Meta and Config from the front matter,
+// top-level settings and directives
+func (l *lowerer) lowerMeta(file *File) {
+	meta := &extuml.Meta{}
+	config := map[string]any{}
+
+	for _, entry := range file.Meta {
+		switch {
+		case entry.Key == "title":
+			meta.Title = entry.Value
+		case entry.Key == "author":
+			meta.Author = entry.Value
+		case entry.Key == "id":
+			meta.ID = entry.Value
+		case strings.HasPrefix(entry.Key, "config."):
+			if entry.Key == "config.theme" {
+				l.checkTheme(entry.Span, entry.Value)
+			}
+			setPath(config, strings.Split(strings.TrimPrefix(entry.Key, "config."), "."), scalar(entry.Value))
+		default:
+			l.diags.Add(entry.Span, diagnostic.CodeUnknownSetting, "unknown front matter key %q is ignored", entry.Key)
+		}
+	}
+
+	for _, d := range file.Directives {
+		if d.Name != "init" && d.Name != "initialize" {
+			l.diags.Add(d.Span, diagnostic.CodeUnknownSetting, "unknown directive %q is ignored", d.Name)
+			continue
+		}
+		var values map[string]any
+		if err := json.Unmarshal([]byte(jsonQuotes(d.Value)), &values); err != nil {
+			l.diags.Add(d.Span, diagnostic.CodeInvalidDirective, "invalid %s directive: %v", d.Name, err)
+			continue
+		}
+		if theme, ok := values["theme"]; ok {
+			l.checkTheme(d.Span, theme)
+		}
+		mergeConfig(config, values)
+	}
+
+	if *meta != (extuml.Meta{}) {
+		l.doc.Meta = meta
+	}
+	if len(config) > 0 {
+		l.doc.Config = config
+	}
+}
+
+// setPath stores value in nested maps along path
+func setPath(m map[string]any, path []string, value any) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := m[key].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			m[key] = child
+		}
+		m = child
+	}
+	m[path[len(path)-1]] = value
+}
+
+// mergeConfig deep-merges src into dst; later values win
+func mergeConfig(dst, src map[string]any) {
+	for key, value := range src {
+		if sub, ok := value.(map[string]any); ok {
+			if existing, ok := dst[key].(map[string]any); ok {
+				mergeConfig(existing, sub)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+// scalar converts a front matter value to a bool or number where it looks
+// like one
+func scalar(s string) any {
+	if s == "true" || s == "false" {
+		return s == "true"
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// jsonQuotes turns the single-quoted strings Mermaid allows in directives
+// into JSON strings
+func jsonQuotes(s string) string {
+	var b strings.Builder
+	inDouble := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			b.WriteByte(c)
+			i++
+			c = s[i]
+		case c == '"':
+			inDouble = !inDouble
+		case c == '\'' && !inDouble:
+			c = '"'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,253 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// messageOperators maps the arrows of a sequence diagram to message kinds:
+// solid arrows are calls, arrows ending in `)` are asynchronous and dashed
+// arrows are replies
+var messageOperators = map[string]string{
+	"->>":  extuml.MessageSynchronous,
+	"->":   extuml.MessageSynchronous,
+	"-)":   extuml.MessageAsynchronous,
+	"--)":  extuml.MessageAsynchronous,
+	"-->>": extuml.MessageReturn,
+	"-->":  extuml.MessageReturn,
+}
+
+// sequenceState is the state of lowering a sequence diagram
+type sequenceState struct {
+	step   int                         // the next free step
+	zones  map[string]int              // zone ID -> index in Zones
+	active map[string][]openActivation // activations not yet ended, by participant
+	frames []map[string]bool           // participants used in each enclosing fragment
+}
+
+// openActivation is an activation whose end is not known yet
+type openActivation struct {
+	index int // in Activations
+	span  Span
+}
+
+// lowerSequence lowers the declarations of a sequence diagram. Participants
+// are declared first, in order, so that messages may precede their
+// declaration; names used without one are declared implicitly when first
+// used, as in Mermaid.
+func (l *lowerer) lowerSequence(decls []Decl) {
+	l.doc.Diagram = extuml.DiagramSequence
+	l.doc.Sequence = &extuml.Sequence{
+		Participants: []extuml.Participant{},
+		Messages:     []extuml.Message{},
+	}
+	l.seq = &sequenceState{zones: map[string]int{}, active: map[string][]openActivation{}}
+
+	l.declareParticipants(decls, "")
+	l.lowerSteps(decls)
+
+	// Activations still open end with the diagram
+	seq := l.doc.Sequence
+	for _, p := range seq.Participants {
+		for _, open := range l.seq.active[p.ID] {
+			l.diags.Add(open.span, diagnostic.CodeUnbalancedActivation, "%q is activated but never deactivated", p.ID)
+			seq.Activations[open.index].End = max(l.seq.step-1, seq.Activations[open.index].Start)
+		}
+	}
+	seq.Steps = l.seq.step
+}
+
+// declareParticipants declares the participants and zones in decls; zone
+// is the ID of the enclosing zone, if any
+func (l *lowerer) declareParticipants(decls []Decl, zone string) {
+	seq := l.doc.Sequence
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *ParticipantDecl:
+			id := d.Name.Name
+			if first, dup := l.declared[id]; dup {
+				l.diags.Add(d.Name.Span, diagnostic.CodeDuplicateElement, "%s %q is already declared at %s", d.Kind, id, first.Start)
+				continue
+			}
+			l.declared[id] = d.Name.Span
+			l.declareSymbol(id, d.Kind, d.Name, d)
+			name := id
+			if d.Alias != "" {
+				name = d.Alias
+			}
+			participant := extuml.Participant{ID: id, Type: d.Kind, Name: name, Zone: zone}
+			for _, class := range d.Classes {
+				participant.StyleClasses = append(participant.StyleClasses, class.Name)
+				l.classUses = append(l.classUses, class)
+			}
+			seq.Participants = append(seq.Participants, participant)
+			if zone != "" {
+				z := &seq.Zones[l.seq.zones[zone]]
+				z.Participants = append(z.Participants, id)
+			}
+		case *ZoneDecl:
+			// A zone declared again is reopened
+			id := d.Name.Name
+			if _, ok := l.seq.zones[id]; !ok {
+				l.seq.zones[id] = len(seq.Zones)
+				seq.Zones = append(seq.Zones, extuml.Zone{ID: id, Type: "zone", Name: id, Participants: []string{}})
+			}
+			if d.Alias != "" {
+				seq.Zones[l.seq.zones[id]].Name = d.Alias
+			}
+			l.declareParticipants(d.Decls, id)
+		case *IncludeDecl:
+			if d.File != nil {
+				l.declareParticipants(d.File.Decls, zone)
+			}
+		}
+	}
+}
+
+// lowerSteps lowers the messages, notes, activations and fragments in decls
+// in order, numbering their steps
+func (l *lowerer) lowerSteps(decls []Decl) {
+	seq := l.doc.Sequence
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *MessageDecl:
+			msg := extuml.Message{
+				ID:    fmt.Sprintf("msg_%d", len(seq.Messages)+1),
+				Kind:  messageOperators[d.Operator],
+				From:  l.participant(d.From),
+				To:    l.participant(d.To),
+				Label: d.Label,
+				Step:  l.seq.step,
+			}
+			seq.Messages = append(seq.Messages, msg)
+			if d.Activate {
+				l.activate(msg.To, msg.Step, d.Span)
+			}
+			if d.Deactivate {
+				l.deactivate(msg.From, msg.Step, d.Span)
+			}
+			l.seq.step++
+		case *ActivationDecl:
+			// The activation starts or ends with the step before, usually the
+			// message received or replied to
+			id := l.participant(d.Target)
+			step := max(l.seq.step-1, 0)
+			if d.Activate {
+				l.activate(id, step, d.Span)
+			} else {
+				l.deactivate(id, step, d.Span)
+			}
+		case *SequenceNoteDecl:
+			note := extuml.SequenceNote{
+				ID:       fmt.Sprintf("note_%d", len(seq.Notes)+1),
+				Type:     "note",
+				Text:     noteText(d.Text),
+				Position: d.Position,
+				Step:     l.seq.step,
+			}
+			for _, target := range d.Targets {
+				note.Participants = append(note.Participants, l.participant(target))
+			}
+			seq.Notes = append(seq.Notes, note)
+			l.seq.step++
+		case *FragmentDecl:
+			l.lowerFragment(d)
+		case *ClassDefDecl:
+			l.lowerClassDef(d)
+		case *StyleDecl:
+			l.lowerStyle(d, "")
+		case *CSSClassDecl:
+			l.lowerCSSClass(d, "")
+		case *IncludeDecl:
+			if d.File != nil {
+				l.lowerSteps(d.File.Decls)
+			}
+		}
+	}
+}
+
+// lowerFragment lowers a combined fragment. Its header, each `else` and its
+// end take a step each.
+func (l *lowerer) lowerFragment(d *FragmentDecl) {
+	seq := l.doc.Sequence
+	idx := len(seq.Fragments)
+	seq.Fragments = append(seq.Fragments, extuml.Fragment{
+		ID:       fmt.Sprintf("fragment_%d", idx+1),
+		Kind:     d.Kind,
+		Start:    l.seq.step,
+		Level:    len(l.seq.frames),
+		Operands: []extuml.Operand{},
+	})
+
+	used := map[string]bool{}
+	l.seq.frames = append(l.seq.frames, used)
+	var operands []extuml.Operand
+	for _, o := range d.Operands {
+		operand := extuml.Operand{Guard: o.Guard, Start: l.seq.step}
+		l.seq.step++
+		l.lowerSteps(o.Decls)
+		operand.End = l.seq.step - 1
+		operands = append(operands, operand)
+	}
+	l.seq.frames = l.seq.frames[:len(l.seq.frames)-1]
+
+	// A fragment without messages spans every participant
+	var participants []string
+	for _, p := range seq.Participants {
+		if used[p.ID] || len(used) == 0 {
+			participants = append(participants, p.ID)
+		}
+	}
+	f := &seq.Fragments[idx]
+	f.Operands = append(f.Operands, operands...)
+	f.End = l.seq.step
+	f.Participants = participants
+	l.seq.step++
+}
+
+// participant returns the ID of the participant called name, declaring it
+// if it has not been, and records its use in the enclosing fragments
+func (l *lowerer) participant(name Ident) string {
+	if _, ok := l.declared[name.Name]; ok {
+		l.useSymbol(name, name.Name)
+	} else {
+		l.declared[name.Name] = name.Span
+		l.declareSymbol(name.Name, extuml.ParticipantDefault, name, nil)
+		l.doc.Sequence.Participants = append(l.doc.Sequence.Participants, extuml.Participant{
+			ID:   name.Name,
+			Type: extuml.ParticipantDefault,
+			Name: name.Name,
+		})
+	}
+	for _, used := range l.seq.frames {
+		used[name.Name] = true
+	}
+	return name.Name
+}
+
+// activate starts an activation of the participant id at step
+func (l *lowerer) activate(id string, step int, span Span) {
+	seq := l.doc.Sequence
+	l.seq.active[id] = append(l.seq.active[id], openActivation{len(seq.Activations), span})
+	seq.Activations = append(seq.Activations, extuml.Activation{
+		Participant: id,
+		Start:       step,
+		End:         step,
+		Level:       len(l.seq.active[id]) - 1,
+	})
+}
+
+// deactivate ends the innermost activation of the participant id at step
+func (l *lowerer) deactivate(id string, step int, span Span) {
+	open := l.seq.active[id]
+	if len(open) == 0 {
+		l.diags.Add(span, diagnostic.CodeUnbalancedActivation, "%q is deactivated but not active", id)
+		return
+	}
+	last := open[len(open)-1]
+	l.seq.active[id] = open[:len(open)-1]
+	activation := &l.doc.Sequence.Activations[last.index]
+	activation.End = max(step, activation.Start)
+}
@@ -0,0 +1,213 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// regionKey identifies a region: the ID of its composite state, "" at the
+// top level, and its index
+type regionKey struct {
+	parent string
+	index  int
+}
+
+// lowerStateMachine lowers the declarations of a state diagram. States are
+// declared first so that transitions may precede their declaration; names
+// that resolve to no state are declared in the region using them, and each
+// region gets its own initial and final pseudo-states for `[*]`.
+func (l *lowerer) lowerStateMachine(decls []Decl) {
+	l.doc.Diagram = extuml.DiagramState
+	l.doc.StateMachine = &extuml.StateMachine{
+		States:      []extuml.State{},
+		Transitions: []extuml.Transition{},
+	}
+	l.declareStates(decls, regionKey{})
+	l.lowerTransitions(decls, regionKey{})
+}
+
+// declareStates declares the states in decls, which belong to region
+func (l *lowerer) declareStates(decls []Decl, region regionKey) {
+	sm := l.doc.StateMachine
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *StateDecl:
+			id := qualifiedID(region.parent, d.Name.Name)
+			if first, dup := l.declared[id]; dup {
+				l.diags.Add(d.Name.Span, diagnostic.CodeDuplicateElement, "state %q is already declared at %s", id, first.Start)
+				continue
+			}
+			l.declared[id] = d.Name.Span
+			l.declareSymbol(id, "state", d.Name, d)
+			state := extuml.State{
+				ID:      id,
+				Type:    extuml.StateSimple,
+				Name:    d.Name.Name,
+				Parent:  region.parent,
+				Region:  region.index,
+				Regions: len(d.Regions),
+			}
+			if d.Kind != "" {
+				state.Type = d.Kind
+			}
+			if d.Alias != "" {
+				state.Name = d.Alias
+			}
+			for _, class := range d.Classes {
+				state.StyleClasses = append(state.StyleClasses, class.Name)
+				l.classUses = append(l.classUses, class)
+			}
+			sm.States = append(sm.States, state)
+			for i, r := range d.Regions {
+				l.declareStates(r.Decls, regionKey{id, i})
+			}
+		case *IncludeDecl:
+			if d.File != nil {
+				l.declareStates(d.File.Decls, region)
+			}
+		}
+	}
+}
+
+// lowerTransitions lowers the transitions, notes and styles in decls,
+// which belong to region
+func (l *lowerer) lowerTransitions(decls []Decl, region regionKey) {
+	sm := l.doc.StateMachine
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *StateDecl:
+			id := qualifiedID(region.parent, d.Name.Name)
+			if l.declared[id] != d.Name.Span {
+				// A duplicate, already reported
+				continue
+			}
+			for i, r := range d.Regions {
+				l.lowerTransitions(r.Decls, regionKey{id, i})
+			}
+		case *TransitionDecl:
+			from, okFrom := l.state(d.From, region, extuml.StateInitial)
+			to, okTo := l.state(d.To, region, extuml.StateFinal)
+			if !okFrom || !okTo {
+				continue
+			}
+			event, guard, action := splitTransitionLabel(d.Label)
+			sm.Transitions = append(sm.Transitions, extuml.Transition{
+				ID:     fmt.Sprintf("transition_%d", len(sm.Transitions)+1),
+				From:   from,
+				To:     to,
+				Event:  event,
+				Guard:  guard,
+				Action: action,
+			})
+		case *NoteDecl:
+			l.lowerNote(d, &sm.Notes, region.parent)
+		case *ClassDefDecl:
+			l.lowerClassDef(d)
+		case *StyleDecl:
+			l.lowerStyle(d, region.parent)
+		case *CSSClassDecl:
+			l.lowerCSSClass(d, region.parent)
+		case *IncludeDecl:
+			if d.File != nil {
+				l.lowerTransitions(d.File.Decls, region)
+			}
+		}
+	}
+}
+
+// state returns the ID of the state a transition in region names,
+// declaring it in region if no state matches. `[*]` is the region's
+// pseudo-state of the given kind. ok is false if the name is ambiguous.
+func (l *lowerer) state(name Ident, region regionKey, pseudo string) (id string, ok bool) {
+	if name.Name == pseudoState {
+		return l.pseudoState(region, pseudo), true
+	}
+	switch ids := l.resolve(name.Name, region.parent); len(ids) {
+	case 0:
+	case 1:
+		l.useSymbol(name, ids[0])
+		return ids[0], true
+	default:
+		l.diags.Add(name.Span, diagnostic.CodeAmbiguousReference,
+			"state %q is ambiguous: it matches %s", name.Name, strings.Join(ids, ", "))
+		return "", false
+	}
+
+	id = qualifiedID(region.parent, name.Name)
+	l.declared[id] = name.Span
+	l.declareSymbol(id, "state", name, nil)
+	l.doc.StateMachine.States = append(l.doc.StateMachine.States, extuml.State{
+		ID:     id,
+		Type:   extuml.StateSimple,
+		Name:   name.Name,
+		Parent: region.parent,
+		Region: region.index,
+	})
+	return id, true
+}
+
+// pseudoState returns the ID of the initial or final pseudo-state of
+// region, adding it on first use. It is named after its kind, with the
+// number of the region after the first and a suffix if a state of that
+// name exists.
+func (l *lowerer) pseudoState(region regionKey, kind string) string {
+	sm := l.doc.StateMachine
+	for _, s := range sm.States {
+		if s.Type == kind && s.Parent == region.parent && s.Region == region.index {
+			return s.ID
+		}
+	}
+
+	base := kind
+	if region.index > 0 {
+		base = fmt.Sprintf("%s_%d", kind, region.index+1)
+	}
+	id := qualifiedID(region.parent, base)
+	for n := 2; ; n++ {
+		if _, taken := l.declared[id]; !taken {
+			break
+		}
+		id = qualifiedID(region.parent, fmt.Sprintf("%s_%d", base, n))
+	}
+	l.declared[id] = Span{}
+	sm.States = append(sm.States, extuml.State{
+		ID:     id,
+		Type:   kind,
+		Parent: region.parent,
+		Region: region.index,
+	})
+	return id
+}
+
+// splitTransitionLabel splits `event [guard] / action`; each part is
+// optional. The action starts at the first '/' outside the guard.
+func splitTransitionLabel(label string) (event, guard, action string) {
+	slash, depth := -1, 0
+	for i := 0; i < len(label) && slash < 0; i++ {
+		switch label[i] {
+		case '[':
+			depth++
+		case ']':
+			depth = max(depth-1, 0)
+		case '/':
+			if depth == 0 {
+				slash = i
+			}
+		}
+	}
+	if slash >= 0 {
+		label, action = label[:slash], strings.TrimSpace(label[slash+1:])
+	}
+
+	event = label
+	if open := strings.Index(label, "["); open >= 0 {
+		if end := strings.LastIndex(label, "]"); end > open {
+			guard = strings.TrimSpace(label[open+1 : end])
+			event = label[:open] + label[end+1:]
+		}
+	}
+	return strings.TrimSpace(event), guard, action
+}
@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// lowerClassDef merges a classDef into the document's named styles. A name
+// defined twice keeps the properties of both, the later ones winning.
+func (l *lowerer) lowerClassDef(d *ClassDefDecl) {
+	style := l.lowerStyleProps(d.Props)
+	if l.doc.ClassDefs == nil {
+		l.doc.ClassDefs = map[string]extuml.Style{}
+	}
+	for _, name := range d.Names {
+		l.doc.ClassDefs[name.Name] = l.doc.ClassDefs[name.Name].Merge(style)
+	}
+}
+
+// lowerStyle records a `style` statement, applied once its target is resolved
+func (l *lowerer) lowerStyle(d *StyleDecl, scope string) {
+	style := l.lowerStyleProps(d.Props)
+	l.refs = append(l.refs, reference{d.Target, scope, diagnostic.CodeUnknownStyleTarget, "style target",
+		func(id string) {
+			_, current := l.styleFields(id)
+			if current == nil {
+				return
+			}
+			if *current == nil {
+				*current = &extuml.Style{}
+			}
+			**current = (*current).Merge(style)
+		}})
+}
+
+// lowerCSSClass records a `cssClass` statement, applied once its targets are
+// resolved
+func (l *lowerer) lowerCSSClass(d *CSSClassDecl, scope string) {
+	l.classUses = append(l.classUses, d.Class)
+	for _, target := range d.Targets {
+		l.refs = append(l.refs, reference{target, scope, diagnostic.CodeUnknownStyleTarget, "cssClass target",
+			func(id string) {
+				if classes, _ := l.styleFields(id); classes != nil {
+					*classes = append(*classes, d.Class.Name)
+				}
+			}})
+	}
+}
+
+// lowerStyleProps converts style properties, reporting invalid colours and
+// properties that cannot be rendered
+func (l *lowerer) lowerStyleProps(props []*StyleProp) extuml.Style {
+	var style extuml.Style
+	for _, prop := range props {
+		var field *string
+		switch prop.Name {
+		case "fill":
+			field = &style.Fill
+		case "stroke":
+			field = &style.Stroke
+		case "color":
+			field = &style.Color
+		default:
+			l.diags.Add(prop.Span, diagnostic.CodeUnknownStyleProperty, "style property %q is not supported and is ignored", prop.Name)
+			continue
+		}
+		if _, ok := extuml.ParseColor(prop.Value); !ok {
+			l.diags.Add(prop.Span, diagnostic.CodeInvalidColor,
+				"invalid colour %q for %s: expected #rgb, #rrggbb, #rrggbbaa or a colour name", prop.Value, prop.Name)
+			continue
+		}
+		*field = prop.Value
+	}
+	return style
+}
+
+// checkClassUses warns about `:::` and `cssClass` uses of undefined classDefs
+func (l *lowerer) checkClassUses() {
+	for _, use := range l.classUses {
+		if _, ok := l.doc.ClassDefs[use.Name]; !ok {
+			l.diags.Add(use.Span, diagnostic.CodeUnknownStyleClass, "classDef %q is not defined", use.Name)
+		}
+	}
+}
+
+// checkTheme warns about a theme name without a palette
+func (l *lowerer) checkTheme(span Span, theme any) {
+	name, _ := theme.(string)
+	if _, ok := extuml.Themes[name]; !ok {
+		l.diags.Add(span, diagnostic.CodeUnknownSetting,
+			"unknown theme %q is ignored (expected default, dark, forest or neutral)", fmt.Sprint(theme))
+	}
+}
+
+// styleFields returns the style classes and style of the classifier,
+// participant, state, entity, component, node, artifact or activity node
+// with the given ID, or nils if there is none
+func (l *lowerer) styleFields(id string) (*[]string, **extuml.Style) {
+	if act := l.doc.Activity; act != nil {
+		for i := range act.Nodes {
+			if n := &act.Nodes[i]; n.ID == id {
+				return &n.StyleClasses, &n.Style
+			}
+		}
+		return nil, nil
+	}
+	if arch := l.doc.Architecture; arch != nil {
+		for i := range arch.Components {
+			if c := &arch.Components[i]; c.ID == id {
+				return &c.StyleClasses, &c.Style
+			}
+		}
+		for i := range arch.Nodes {
+			if n := &arch.Nodes[i]; n.ID == id {
+				return &n.StyleClasses, &n.Style
+			}
+		}
+		for i := range arch.Artifacts {
+			if a := &arch.Artifacts[i]; a.ID == id {
+				return &a.StyleClasses, &a.Style
+			}
+		}
+		return nil, nil
+	}
+	if er := l.doc.ER; er != nil {
+		for i := range er.Entities {
+			if e := &er.Entities[i]; e.ID == id {
+				return &e.StyleClasses, &e.Style
+			}
+		}
+		return nil, nil
+	}
+	if sm := l.doc.StateMachine; sm != nil {
+		for i := range sm.States {
+			if s := &sm.States[i]; s.ID == id {
+				return &s.StyleClasses, &s.Style
+			}
+		}
+		return nil, nil
+	}
+	if seq := l.doc.Sequence; seq != nil {
+		for i := range seq.Participants {
+			if p := &seq.Participants[i]; p.ID == id {
+				return &p.StyleClasses, &p.Style
+			}
+		}
+		return nil, nil
+	}
+	elements := l.doc.Elements
+	for i := range elements.Classes {
+		if c := &elements.Classes[i]; c.ID == id {
+			return &c.StyleClasses, &c.Style
+		}
+	}
+	for i := range elements.Interfaces {
+		if iface := &elements.Interfaces[i]; iface.ID == id {
+			return &iface.StyleClasses, &iface.Style
+		}
+	}
+	for i := range elements.Enums {
+		if enum := &elements.Enums[i]; enum.ID == id {
+			return &enum.StyleClasses, &enum.Style
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,388 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// memberParser parses the text of a member line, reporting what is
+// malformed in it at the span of the member
+type memberParser struct {
+	span  Span
+	diags *diagnostic.List
+}
+
+// operation parses `+name(params) : ReturnType` with optional `$`
+// (static) and `*` (abstract) markers after the parameter list or return type
+func (p memberParser) operation(line string) extuml.Operation {
+	var op extuml.Operation
+	line, op.Visibility, op.Static, op.Abstract = parseMemberPrefix(line)
+
+	open := strings.Index(line, "(")
+	if open == -1 {
+		op.Name = strings.TrimSpace(line)
+		return op
+	}
+	closeIdx := strings.LastIndex(line, ")")
+	if closeIdx < open {
+		p.diags.Add(p.span, diagnostic.CodeExpected, "expected ')' to close the parameter list of %q", strings.TrimSpace(line[:open]))
+		closeIdx = len(line)
+	}
+
+	op.Name = strings.TrimSpace(line[:open])
+	p.checkName("operation", op.Name)
+	op.Parameters = p.parameters(line[open+1 : closeIdx])
+
+	rest := ""
+	if closeIdx < len(line) {
+		rest = strings.TrimSpace(line[closeIdx+1:])
+	}
+
+	// Classifiers may appear directly after ')' or after the return type
+	for {
+		switch {
+		case strings.HasPrefix(rest, "$"):
+			op.Static = true
+			rest = strings.TrimSpace(rest[1:])
+			continue
+		case strings.HasPrefix(rest, "*"):
+			op.Abstract = true
+			rest = strings.TrimSpace(rest[1:])
+			continue
+		case strings.HasSuffix(rest, "$"):
+			op.Static = true
+			rest = strings.TrimSpace(rest[:len(rest)-1])
+			continue
+		case strings.HasSuffix(rest, "*"):
+			op.Abstract = true
+			rest = strings.TrimSpace(rest[:len(rest)-1])
+			continue
+		}
+		break
+	}
+
+	// The return type follows a colon, or a space in Mermaid
+	colon := strings.HasPrefix(rest, ":")
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, ":"))
+	switch fields := fieldsTopLevel(normalizeGenerics(rest)); {
+	case colon && len(fields) == 0:
+		p.diags.Add(p.span, diagnostic.CodeExpected, "expected the return type of %q after ':'", op.Name)
+	case len(fields) > 1:
+		p.diags.Add(p.span, diagnostic.CodeExpected, "expected the end of operation %q after its return type %q, found %q",
+			op.Name, fields[0], strings.Join(fields[1:], " "))
+		rest = fields[0]
+	}
+	op.ReturnType = normalizeGenerics(rest)
+	return op
+}
+
+// parameters parses a comma-separated parameter list such as
+// `in email: string, retries: int = 3`
+func (p memberParser) parameters(list string) []extuml.Parameter {
+	params := []extuml.Parameter{}
+	for _, raw := range splitTopLevel(list, ',') {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		var param extuml.Parameter
+		for _, dir := range []string{extuml.DirectionInOut, extuml.DirectionIn, extuml.DirectionOut} {
+			if strings.HasPrefix(raw, dir+" ") {
+				param.Direction = dir
+				raw = strings.TrimSpace(raw[len(dir):])
+				break
+			}
+		}
+
+		if idx := indexTopLevel(raw, '='); idx != -1 {
+			param.Default = strings.TrimSpace(raw[idx+1:])
+			raw = strings.TrimSpace(raw[:idx])
+		}
+
+		param.Name, param.Type = p.typedName("parameter", raw)
+		params = append(params, param)
+	}
+	return params
+}
+
+// attribute parses an attribute with optional visibility, static marker,
+// multiplicity (`[0..*]`) and default value (`= value`)
+func (p memberParser) attribute(line string) extuml.Attribute {
+	var attr extuml.Attribute
+	line, attr.Visibility, attr.Static, _ = parseMemberPrefix(line)
+
+	if idx := indexTopLevel(line, '='); idx != -1 {
+		attr.Default = strings.TrimSpace(line[idx+1:])
+		line = strings.TrimSpace(line[:idx])
+	}
+
+	if strings.HasSuffix(line, "$") {
+		attr.Static = true
+		line = strings.TrimSpace(strings.TrimSuffix(line, "$"))
+	}
+
+	// A non-empty trailing bracket is a multiplicity; `[]` stays part of the type
+	if strings.HasSuffix(line, "]") {
+		if open := strings.LastIndex(line, "["); open != -1 && open < len(line)-2 {
+			attr.Multiplicity = strings.TrimSpace(line[open+1 : len(line)-1])
+			line = strings.TrimSpace(line[:open])
+		}
+	}
+
+	attr.Name, attr.Type = p.typedName("attribute", line)
+	return attr
+}
+
+// typedName splits a declaration written either in UML form (`name: Type`)
+// or in Mermaid form (`Type name`). Generic types may use `List~String~` or
+// `Map<K, V>` notation and are normalised to angle brackets; a C-style
+// array suffix on the name (`names[]`) moves to the type.
+func (p memberParser) typedName(what, decl string) (name, typ string) {
+	decl = strings.TrimSpace(decl)
+	colon := indexTopLevel(decl, ':')
+	if colon != -1 {
+		// UML form: name: Type
+		name = strings.TrimSpace(decl[:colon])
+		typ = strings.TrimSpace(decl[colon+1:])
+	} else if fields := fieldsTopLevel(normalizeGenerics(decl)); len(fields) >= 2 {
+		// Mermaid form: Type name
+		name = fields[len(fields)-1]
+		typ = strings.Join(fields[:len(fields)-1], " ")
+	} else {
+		// Just name, no type
+		name = decl
+	}
+
+	for strings.HasSuffix(name, "[]") {
+		name = strings.TrimSpace(strings.TrimSuffix(name, "[]"))
+		typ += "[]"
+	}
+	p.checkName(what, name)
+	if colon != -1 && typ == "" {
+		p.diags.Add(p.span, diagnostic.CodeExpected, "expected the type of %s %q after ':'", what, name)
+	}
+	return name, normalizeGenerics(typ)
+}
+
+// checkName reports a missing or malformed member name
+func (p memberParser) checkName(what, name string) {
+	if name == "" {
+		p.diags.Add(p.span, diagnostic.CodeExpected, "expected the name of the %s", what)
+		return
+	}
+	for i, r := range name {
+		if i == 0 && !isIdentStart(r) || !isIdentPart(r) {
+			p.diags.Add(p.span, diagnostic.CodeInvalidIdentifier,
+				"invalid %s name %q: names must start with a letter and contain only letters, digits and '_'", what, name)
+			return
+		}
+	}
+}
+
+// IsOperation reports whether the text of a class member declares an
+// operation: its parameter list opens before any `:` or `=` outside
+// brackets and quotes, so that an attribute default such as `= now()`
+// keeps it an attribute. A trailing `%%` comment is ignored.
+func IsOperation(text string) bool {
+	text = stripComment(text)
+	depth := 0
+	inQuote := false
+	for i := 0; i < len(text); i++ {
+		if inQuote && text[i] == '\\' {
+			i++ // an escaped quote or backslash
+			continue
+		}
+		if text[i] == '"' {
+			inQuote = !inQuote
+			continue
+		}
+		if inQuote {
+			continue
+		}
+		switch text[i] {
+		case '<', '[', '{':
+			depth++
+		case '>', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		case '(':
+			if depth == 0 {
+				return true
+			}
+		case ':', '=':
+			if depth == 0 {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+// stripComment removes a trailing `%%` comment from a member line
+func stripComment(line string) string {
+	inQuote := false
+	for i := 0; i+1 < len(line); i++ {
+		switch {
+		case inQuote && line[i] == '\\':
+			i++ // an escaped quote or backslash
+		case line[i] == '"':
+			inQuote = !inQuote
+		case !inQuote && line[i] == '%' && line[i+1] == '%':
+			return strings.TrimSpace(line[:i])
+		}
+	}
+	return line
+}
+
+// normalizeGenerics rewrites Mermaid generics (`List~List~int~~`) to angle
+// bracket notation (`List<List<int>>`). A tilde between two word characters
+// opens a type argument list; any other tilde closes one.
+func normalizeGenerics(typ string) string {
+	if !strings.Contains(typ, "~") {
+		return typ
+	}
+	var b strings.Builder
+	for i := 0; i < len(typ); i++ {
+		if typ[i] != '~' {
+			b.WriteByte(typ[i])
+			continue
+		}
+		opens := i > 0 && isWordByte(typ[i-1]) && i+1 < len(typ) && typ[i+1] != '~' && typ[i+1] != ' ' && typ[i+1] != ','
+		if opens {
+			b.WriteByte('<')
+		} else {
+			b.WriteByte('>')
+		}
+	}
+	return b.String()
+}
+
+// isWordByte reports whether c can be part of an identifier or type name
+func isWordByte(c byte) bool {
+	return c == '_' || c == '.' || c == ']' || c == '>' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// fieldsTopLevel splits s on whitespace outside brackets and quotes
+func fieldsTopLevel(s string) []string {
+	var fields []string
+	for _, part := range splitTopLevel(strings.TrimSpace(s), ' ') {
+		if part = strings.TrimSpace(part); part != "" {
+			fields = append(fields, part)
+		}
+	}
+	return fields
+}
+
+// parseVisibility strips a leading visibility marker (+ - # ~)
+func parseVisibility(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return line, ""
+	}
+	if visibility := extuml.VisibilityFromSymbol(line[0]); visibility != "" {
+		return strings.TrimSpace(line[1:]), visibility
+	}
+	return line, ""
+}
+
+// parseMemberPrefix strips the visibility marker and the `static` /
+// `abstract` keywords, which may come in either order
+func parseMemberPrefix(line string) (rest, visibility string, static, abstract bool) {
+	rest, visibility = parseVisibility(line)
+	rest, static, abstract = parseModifierKeywords(rest)
+	if visibility == "" {
+		rest, visibility = parseVisibility(rest)
+	}
+	return rest, visibility, static, abstract
+}
+
+// parseModifierKeywords strips leading `static` / `abstract` keywords
+func parseModifierKeywords(line string) (rest string, static, abstract bool) {
+	rest = line
+	for {
+		switch {
+		case strings.HasPrefix(rest, "static "):
+			static = true
+			rest = strings.TrimSpace(strings.TrimPrefix(rest, "static "))
+			continue
+		case strings.HasPrefix(rest, "abstract "):
+			abstract = true
+			rest = strings.TrimSpace(strings.TrimPrefix(rest, "abstract "))
+			continue
+		}
+		return rest, static, abstract
+	}
+}
+
+// splitTopLevel splits s on sep, ignoring separators nested in brackets or
+// double-quoted strings
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	inQuote := false
+	for i := 0; i < len(s); i++ {
+		if inQuote && s[i] == '\\' {
+			i++ // an escaped quote or backslash
+			continue
+		}
+		if s[i] == '"' {
+			inQuote = !inQuote
+			continue
+		}
+		if inQuote {
+			continue
+		}
+		switch s[i] {
+		case '(', '<', '[', '{':
+			depth++
+		case ')', '>', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// indexTopLevel returns the index of the first sep outside brackets and
+// double-quoted strings, or -1
+func indexTopLevel(s string, sep byte) int {
+	depth := 0
+	inQuote := false
+	for i := 0; i < len(s); i++ {
+		if inQuote && s[i] == '\\' {
+			i++ // an escaped quote or backslash
+			continue
+		}
+		if s[i] == '"' {
+			inQuote = !inQuote
+			continue
+		}
+		if inQuote {
+			continue
+		}
+		switch s[i] {
+		case '(', '<', '[', '{':
+			depth++
+		case ')', '>', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		case sep:
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
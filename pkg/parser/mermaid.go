@@ -0,0 +1,267 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+)
+
+// ParseMermaid parses a Mermaid classDiagram into the same AST as Parse, so
+// that it can be lowered like an .extuml file. Statements that only exist in
+// Mermaid, such as `Class : member` or `click Class href "url"`, are folded
+// into the classes they target, and classes that are only used in
+// relationships are declared implicitly.
+func ParseMermaid(filename string, src []byte) (*File, diagnostic.List) {
+	p := &parser{src: src, tokens: Lex(filename, src), mermaid: true}
+	p.file = &File{Name: filename}
+	p.parseFile()
+	normalizeMermaid(p.file)
+	p.diags.Sort()
+	return p.file, p.diags
+}
+
+// parseMermaidHeader parses the `classDiagram` line of a Mermaid diagram
+func (p *parser) parseMermaidHeader() {
+	t := p.peek()
+	if t.Kind != TokenIdent || (t.Text != "classDiagram" && t.Text != "classDiagram-v2") {
+		p.diags.Add(t.Span, diagnostic.CodeMissingHeader, "Mermaid header not found (expected 'classDiagram')")
+		return
+	}
+	p.advance()
+	header := &Header{Span: t.Span, DiagramType: "classDiagram"}
+	// `classDiagram-v2` is lexed as the name followed by `-v2`
+	if next := p.peek(); next.Kind == TokenPunct && next.Text == "-" && next.Span.Start.Offset == t.Span.End.Offset {
+		p.advance()
+		if v := p.peek(); v.Kind == TokenIdent && v.Text == "v2" {
+			p.advance()
+		}
+	}
+	header.End = p.lastEnd
+	p.file.Header = header
+	p.expectLineEnd()
+}
+
+// parseMermaidDecl parses the statements that only exist in Mermaid. ok is
+// false if the line is an ordinary declaration left to parseDecl.
+func (p *parser) parseMermaidDecl() (d Decl, ok bool) {
+	t := p.peek()
+	switch t.Kind {
+	case TokenStereotype:
+		if d := p.parseAnnotationDecl(); d != nil {
+			return d, true
+		}
+		return nil, true
+	case TokenIdent:
+	default:
+		return nil, false
+	}
+
+	next := p.peekAt(1)
+	switch t.Text {
+	case "namespace":
+		if next.Kind != TokenIdent {
+			break
+		}
+		if d := p.parsePackage(); d != nil {
+			return d, true
+		}
+		return nil, true
+	case "direction":
+		// The layout is three-dimensional, so the direction has no meaning
+		if next.Kind == TokenIdent {
+			p.skipLine()
+			return nil, true
+		}
+	case "click", "link":
+		if next.Kind == TokenIdent {
+			if d := p.parseClickDecl(); d != nil {
+				return d, true
+			}
+			return nil, true
+		}
+	case "callback":
+		// JavaScript callbacks cannot be run by the viewer
+		if next.Kind == TokenIdent {
+			p.skipLine()
+			return nil, true
+		}
+	}
+
+	if next.Kind == TokenPunct && next.Text == ":" && !p.isClassShorthandAt(1) {
+		if d := p.parseMemberDecl(); d != nil {
+			return d, true
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+// isClassShorthandAt reports whether `:::` starts n tokens ahead
+func (p *parser) isClassShorthandAt(n int) bool {
+	colon := p.peekAt(n + 1)
+	return colon.Kind == TokenPunct && colon.Text == ":" && colon.Span.Start.Offset == p.peekAt(n).Span.End.Offset
+}
+
+// parseAnnotationDecl parses `<<interface>> Class`
+func (p *parser) parseAnnotationDecl() *AnnotationDecl {
+	t := p.advance()
+	target, ok := p.expectIdent("class name after the annotation")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	p.expectLineEnd()
+	return &AnnotationDecl{Span: Span{Start: t.Span.Start, End: target.End}, Stereotype: newStereotype(t), Target: target}
+}
+
+// parseMemberDecl parses `Class : +member`
+func (p *parser) parseMemberDecl() *MemberDecl {
+	name := p.advance()
+	p.advance()
+	text, span := p.restOfLine()
+	if text == "" {
+		p.diags.Add(span, diagnostic.CodeExpected, "expected a member after '%s :'", name.Text)
+		return nil
+	}
+	return &MemberDecl{
+		Span:   Span{Start: name.Span.Start, End: span.End},
+		Target: Ident{Span: name.Span, Name: name.Text},
+		Member: &Member{Span: span, Text: text},
+	}
+}
+
+// parseClickDecl parses `click Class href "url" ["tooltip"]` and
+// `link Class "url"`. `click Class call fn()` is skipped, as callbacks cannot
+// be run by the viewer.
+func (p *parser) parseClickDecl() *ClickDecl {
+	kw := p.advance()
+	target := p.advance()
+	if t := p.peek(); kw.Text == "click" && t.Kind == TokenIdent {
+		if t.Text != "href" {
+			p.skipLine()
+			return nil
+		}
+		p.advance()
+	}
+	url, ok := p.expectString("link URL")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	// The tooltip and link target are not used
+	p.skipLine()
+	return &ClickDecl{
+		Span:   Span{Start: kw.Span.Start, End: url.Span.End},
+		Target: Ident{Span: target.Span, Name: target.Text},
+		URL:    url.Value,
+	}
+}
+
+// normalizeMermaid folds the Mermaid-only statements into plain declarations
+// and applies the annotations that select the classifier kind
+func normalizeMermaid(file *File) {
+	for _, d := range normalizeImported(file, false) {
+		applyKindAnnotations(d)
+	}
+}
+
+// applyKindAnnotations turns the `<<interface>>` and `<<enumeration>>`
+// annotations of a Mermaid class into the classifier kind
+func applyKindAnnotations(d *ClassifierDecl) {
+	var kept []*Stereotype
+	for _, st := range d.Stereotypes {
+		var names []string
+		for _, name := range st.Names {
+			switch strings.ToLower(name) {
+			case "interface":
+				d.Kind = "interface"
+			case "enumeration", "enum":
+				d.Kind = "enum"
+			default:
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			st.Names = names
+			kept = append(kept, st)
+		}
+	}
+	d.Stereotypes = kept
+}
+
+// ExtractMermaidBlocks returns the class diagrams in the ```mermaid fenced
+// blocks of a Markdown document. Everything outside a block is replaced by
+// spaces, keeping line breaks, so that positions in diagnostics refer to the
+// Markdown file.
+func ExtractMermaidBlocks(src []byte) [][]byte {
+	var blocks [][]byte
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	offset := 0
+	for i := 0; i < len(lines); i++ {
+		fence, ok := mermaidFence(lines[i])
+		offset += len(lines[i])
+		if !ok {
+			continue
+		}
+
+		start, end := offset, len(src)
+		for i++; i < len(lines); i++ {
+			if trimmed := strings.TrimSpace(string(lines[i])); strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				end = offset
+				offset += len(lines[i])
+				break
+			}
+			offset += len(lines[i])
+		}
+		if !isClassDiagram(src[start:end]) {
+			continue
+		}
+
+		blocks = append(blocks, blankBefore(src[:end], start))
+	}
+	return blocks
+}
+
+// mermaidFence returns the fence of a line opening a ```mermaid block
+func mermaidFence(line []byte) (string, bool) {
+	trimmed := strings.TrimSpace(string(line))
+	for _, c := range []string{"`", "~"} {
+		fence := trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, c))]
+		if len(fence) < 3 {
+			continue
+		}
+		info := strings.Fields(trimmed[len(fence):])
+		return fence, len(info) > 0 && info[0] == "mermaid"
+	}
+	return "", false
+}
+
+// isClassDiagram reports whether the first statement of a Mermaid diagram,
+// after any front matter, directives and comments, is `classDiagram`
+func isClassDiagram(src []byte) bool {
+	inFrontMatter := false
+	for i, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "---" && (i == 0 || inFrontMatter):
+			inFrontMatter = !inFrontMatter
+		case inFrontMatter, line == "", strings.HasPrefix(line, "%%"):
+		default:
+			return strings.HasPrefix(line, "classDiagram")
+		}
+	}
+	return false
+}
+
+// blankBefore returns a copy of src with the first n bytes replaced by spaces,
+// keeping line breaks so that positions after them are unchanged
+func blankBefore(src []byte, n int) []byte {
+	blanked := bytes.Clone(src)
+	for i := range n {
+		if blanked[i] != '\n' {
+			blanked[i] = ' '
+		}
+	}
+	return blanked
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+)
+
+// metaKeys are the settings that may also be written as top-level lines
+var metaKeys = map[string]bool{
+	"title":  true,
+	"author": true,
+	"id":     true,
+}
+
+// parseMetaLine parses a top-level `title: ...` style line
+func (p *parser) parseMetaLine() {
+	key := p.advance()
+	p.advance() // ':'
+	value, span := p.restOfLine()
+	entry := &MetaEntry{Span: key.Span, Key: key.Text, Value: unquote(value)}
+	if value != "" {
+		entry.End = span.End
+	}
+	p.file.Meta = append(p.file.Meta, entry)
+}
+
+// parseFrontMatter parses the `key: value` lines of a front matter block.
+// Indented lines under a bare `key:` are nested, so
+//
+//	config:
+//	  theme: dark
+//
+// yields the entry `config.theme: dark`.
+func (p *parser) parseFrontMatter(t Token) {
+	if t.Unterminated {
+		open := t.Span.Start
+		end := open
+		end.Offset += 3
+		end.Column += 3
+		p.diags.Add(Span{Start: open, End: end}, diagnostic.CodeUnterminatedBlock, "front matter is not closed (missing '---')")
+		return
+	}
+
+	type parent struct {
+		indent int
+		key    string
+	}
+	var parents []parent
+
+	// The value starts right after the opening `---`
+	pos := t.Span.Start
+	pos.Offset += 3
+	pos.Column += 3
+	for i, line := range strings.Split(t.Value, "\n") {
+		if i > 0 {
+			pos.Line++
+			pos.Column = 1
+		}
+		lineStart := pos
+		pos.Offset += len(line) + 1
+
+		line = strings.TrimRight(line, " \t\r")
+		content := strings.TrimLeft(line, " \t")
+		if content == "" || strings.HasPrefix(content, "#") {
+			continue
+		}
+
+		indent := len(line) - len(content)
+		start, end := lineStart, lineStart
+		start.Offset += indent
+		start.Column += indent
+		end.Offset += len(line)
+		end.Column += len(line)
+		span := Span{Start: start, End: end}
+
+		colon := strings.Index(content, ":")
+		if colon <= 0 {
+			p.diags.Add(span, diagnostic.CodeInvalidDirective, "invalid front matter line %q (expected key: value)", content)
+			continue
+		}
+		key := strings.TrimSpace(content[:colon])
+		value := strings.TrimSpace(content[colon+1:])
+
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+		path := key
+		if len(parents) > 0 {
+			path = parents[len(parents)-1].key + "." + key
+		}
+
+		if value == "" {
+			parents = append(parents, parent{indent, path})
+			continue
+		}
+		p.file.Meta = append(p.file.Meta, &MetaEntry{Span: span, Key: path, Value: unquote(value)})
+	}
+}
+
+// parseDirective parses a `%%{ name: value }%%` block
+func (p *parser) parseDirective(t Token) {
+	if t.Unterminated {
+		p.diags.Add(t.Span, diagnostic.CodeUnterminatedBlock, "directive is not closed (missing '%s')", "}%%")
+		return
+	}
+
+	name, value, ok := strings.Cut(t.Value, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		p.diags.Add(t.Span, diagnostic.CodeInvalidDirective, "invalid directive: expected 'name: value' between '%s' and '%s'", "%%{", "}%%")
+		return
+	}
+	p.file.Directives = append(p.file.Directives, &Directive{Span: t.Span, Name: name, Value: strings.TrimSpace(value)})
+}
+
+// unquote strips matching single or double quotes around a value
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
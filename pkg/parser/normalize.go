@@ -0,0 +1,128 @@
+package parser
+
+import "strings"
+
+// importNormalizer rewrites the declarations of an imported Mermaid or
+// PlantUML diagram into the form the lowerer expects
+type importNormalizer struct {
+	scoped  bool                       // class names are qualified by their package
+	classes map[string]*ClassifierDecl // by key, the first declaration
+	byName  map[string]*ClassifierDecl // by unqualified name, the first declaration
+	merged  map[*ClassifierDecl]bool   // later declarations of the same class
+}
+
+// normalizeImported merges repeated class declarations, folds `Class : member`,
+// annotation and click statements into their classes and declares the classes
+// that are only referenced. Classes are identified by name alone, as in
+// Mermaid, or by their qualified name if scoped is set, as in PlantUML. It
+// returns every class by key.
+func normalizeImported(file *File, scoped bool) map[string]*ClassifierDecl {
+	n := &importNormalizer{
+		scoped:  scoped,
+		classes: map[string]*ClassifierDecl{},
+		byName:  map[string]*ClassifierDecl{},
+		merged:  map[*ClassifierDecl]bool{},
+	}
+	n.collect(file.Decls, "")
+	file.Decls = n.fold(file.Decls, "")
+	return n.classes
+}
+
+// key returns the key of a class declared as name in the package scope
+func (n *importNormalizer) key(scope, name string) string {
+	if !n.scoped {
+		return name
+	}
+	return qualifiedID(scope, name)
+}
+
+// collect records the classes declared anywhere and merges repeated
+// declarations, which Mermaid and PlantUML allow, into the first one
+func (n *importNormalizer) collect(decls []Decl, scope string) {
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *ClassifierDecl:
+			key := n.key(scope, d.Name.Name)
+			first, ok := n.classes[key]
+			if !ok {
+				n.add(key, d)
+				continue
+			}
+			if first.Alias == "" {
+				first.Alias = d.Alias
+			}
+			if first.TypeParams == nil {
+				first.TypeParams = d.TypeParams
+			}
+			first.Modifiers = append(first.Modifiers, d.Modifiers...)
+			first.Stereotypes = append(first.Stereotypes, d.Stereotypes...)
+			first.Classes = append(first.Classes, d.Classes...)
+			first.Members = append(first.Members, d.Members...)
+			first.Annotations = append(first.Annotations, d.Annotations...)
+			n.merged[d] = true
+		case *PackageDecl:
+			n.collect(d.Decls, qualifiedID(scope, d.Name.Name))
+		}
+	}
+}
+
+func (n *importNormalizer) add(key string, d *ClassifierDecl) {
+	n.classes[key] = d
+	if _, ok := n.byName[d.Name.Name]; !ok {
+		n.byName[d.Name.Name] = d
+	}
+}
+
+// fold moves member, annotation and click statements into their classes and
+// declares the classes that are only referenced, just before their first use
+func (n *importNormalizer) fold(decls []Decl, scope string) []Decl {
+	var out []Decl
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *ClassifierDecl:
+			if n.merged[d] {
+				continue
+			}
+		case *PackageDecl:
+			d.Decls = n.fold(d.Decls, qualifiedID(scope, d.Name.Name))
+		case *MemberDecl:
+			c := n.class(d.Target, scope, &out)
+			c.Members = append(c.Members, d.Member)
+			continue
+		case *AnnotationDecl:
+			c := n.class(d.Target, scope, &out)
+			c.Stereotypes = append(c.Stereotypes, d.Stereotype)
+			continue
+		case *ClickDecl:
+			c := n.class(d.Target, scope, &out)
+			c.Annotations = append(c.Annotations, &Annotation{Span: d.Span, Name: "url", Value: d.URL})
+			continue
+		case *RelationshipDecl:
+			n.class(d.Left, scope, &out)
+			n.class(d.Right, scope, &out)
+		}
+		out = append(out, decl)
+	}
+	return out
+}
+
+// class returns the class a name used in scope refers to, looking in the
+// enclosing packages first, and declares it in out if there is none
+func (n *importNormalizer) class(name Ident, scope string, out *[]Decl) *ClassifierDecl {
+	for s := scope; ; {
+		if d, ok := n.classes[n.key(s, name.Name)]; ok {
+			return d
+		}
+		if s == "" {
+			break
+		}
+		s = s[:max(strings.LastIndex(s, "."), 0)]
+	}
+	if d, ok := n.byName[name.Name]; ok {
+		return d
+	}
+	d := &ClassifierDecl{Span: name.Span, Kind: "class", Name: name}
+	n.add(n.key(scope, name.Name), d)
+	*out = append(*out, d)
+	return d
+}
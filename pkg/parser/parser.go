@@ -0,0 +1,716 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+type parser struct {
+	src     []byte
+	tokens  []Token
+	pos     int
+	lastEnd Pos // end of the last consumed token other than a newline
+	diags   diagnostic.List
+	file    *File
+	mermaid bool   // parsing a Mermaid classDiagram, see ParseMermaid
+	diagram string // the kind of diagram named in the header
+
+	notes map[string]*NoteDecl // PlantUML notes named with `as`
+}
+
+// Parse parses an .extuml source file into an AST. Parsing does not stop at
+// the first problem: every syntax error is collected, and the returned File
+// holds whatever could be recovered so that tooling can still use it.
+func Parse(filename string, src []byte) (*File, diagnostic.List) {
+	p := &parser{src: src, tokens: Lex(filename, src)}
+	p.file = &File{Name: filename}
+	p.parseFile()
+	p.diags.Sort()
+	return p.file, p.diags
+}
+
+func (p *parser) peek() Token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(n int) Token {
+	if p.pos+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+n]
+}
+
+func (p *parser) advance() Token {
+	t := p.tokens[p.pos]
+	if t.Kind != TokenEOF {
+		p.pos++
+	}
+	if t.Kind != TokenNewline {
+		p.lastEnd = t.Span.End
+	}
+	return t
+}
+
+// skipBlank skips empty lines and collects comments
+func (p *parser) skipBlank() {
+	for {
+		t := p.peek()
+		switch t.Kind {
+		case TokenNewline:
+			p.advance()
+		case TokenComment:
+			p.file.Comments = append(p.file.Comments, &Comment{Span: t.Span, Text: t.Text})
+			p.advance()
+		case TokenDirective:
+			p.advance()
+			p.parseDirective(t)
+		default:
+			return
+		}
+	}
+}
+
+// skipLine consumes the rest of the current line including its line break
+func (p *parser) skipLine() {
+	for {
+		t := p.advance()
+		if t.Kind == TokenNewline || t.Kind == TokenEOF {
+			return
+		}
+	}
+}
+
+// expectLineEnd reports any tokens left on the current line and skips them
+func (p *parser) expectLineEnd() {
+	if t := p.peek(); t.Kind != TokenNewline && t.Kind != TokenEOF {
+		p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "unexpected %s at end of statement", t.describe())
+	}
+	p.skipLine()
+}
+
+// restOfLine consumes the current line and returns its raw source text,
+// starting at the next token and with trailing whitespace removed
+func (p *parser) restOfLine() (string, Span) {
+	start := p.peek().Span.Start
+	end := start
+	for {
+		t := p.peek()
+		if t.Kind == TokenNewline || t.Kind == TokenEOF {
+			break
+		}
+		p.advance()
+		end = t.Span.End
+	}
+	p.skipLine()
+	return strings.TrimSpace(string(p.src[start.Offset:end.Offset])), Span{Start: start, End: end}
+}
+
+// expectIdent consumes an identifier or reports what was expected instead
+func (p *parser) expectIdent(what string) (Ident, bool) {
+	t := p.peek()
+	if t.Kind != TokenIdent {
+		p.diags.Add(t.Span, diagnostic.CodeExpected, "expected %s, found %s", what, t.describe())
+		return Ident{}, false
+	}
+	p.advance()
+	return Ident{Span: t.Span, Name: t.Text}, true
+}
+
+// expectName consumes the name of a declaration. Tokens glued to it, as in
+// `Foo-Bar` or `123Foo`, are reported as an invalid identifier but still
+// accepted as the name so that the declaration's body is parsed.
+func (p *parser) expectName(what string) (Ident, bool) {
+	t := p.peek()
+	if t.Kind != TokenIdent && t.Kind != TokenNumber {
+		return p.expectIdent(what)
+	}
+
+	end := t.Span.End
+	n := 1
+	for {
+		next := p.peekAt(n)
+		if next.Span.Start.Offset != end.Offset || !isNamePart(next) {
+			break
+		}
+		end = next.Span.End
+		n++
+	}
+	for i := 0; i < n; i++ {
+		p.advance()
+	}
+
+	name := Ident{Span: Span{Start: t.Span.Start, End: end}, Name: string(p.src[t.Span.Start.Offset:end.Offset])}
+	if n > 1 || t.Kind != TokenIdent {
+		p.diags.Add(name.Span, diagnostic.CodeInvalidIdentifier,
+			"invalid %s %q: names must start with a letter and contain only letters, digits, '_' and '.'", what, name.Name)
+	}
+	return name, true
+}
+
+// isNamePart reports whether t can be glued onto a malformed name
+func isNamePart(t Token) bool {
+	switch t.Kind {
+	case TokenIdent, TokenNumber, TokenArrow:
+		return true
+	case TokenPunct:
+		// `:` starts a label, `~` and `<` a type parameter list and `[` a
+		// display name
+		return t.Text != ":" && t.Text != "~" && t.Text != "<" && t.Text != "["
+	}
+	return false
+}
+
+// expectString consumes a string or reports what was expected instead
+func (p *parser) expectString(what string) (Token, bool) {
+	t := p.peek()
+	if t.Kind != TokenString {
+		p.diags.Add(t.Span, diagnostic.CodeExpected, "expected %s, found %s", what, t.describe())
+		return t, false
+	}
+	p.advance()
+	p.checkTerminated(t, what)
+	return t, true
+}
+
+// checkTerminated reports a string token without its closing quote
+func (p *parser) checkTerminated(t Token, what string) {
+	if t.Unterminated {
+		p.diags.Add(t.Span, diagnostic.CodeUnterminatedString, "%s is not terminated (missing closing '\"')", what)
+	}
+}
+
+// diagramTypes maps the diagram types of the `extuml` header to the kind of
+// document they describe
+var diagramTypes = map[string]string{
+	"activityDiagram3D":   extuml.DiagramActivity,
+	"classDiagram3D":      extuml.DiagramClass,
+	"componentDiagram3D":  extuml.DiagramComponent,
+	"deploymentDiagram3D": extuml.DiagramDeployment,
+	"erDiagram3D":         extuml.DiagramER,
+	"sequenceDiagram3D":   extuml.DiagramSequence,
+	"stateDiagram3D":      extuml.DiagramState,
+}
+
+// diagramTypeNames lists the diagram types of the header in sorted order,
+// as in "a, b or c"
+func diagramTypeNames() string {
+	names := make([]string, 0, len(diagramTypes))
+	for name := range diagramTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+func (p *parser) parseFile() {
+	p.skipBlank()
+	if t := p.peek(); t.Kind == TokenFrontMatter {
+		p.advance()
+		p.file.FrontMatter = &t.Span
+		p.parseFrontMatter(t)
+		p.skipBlank()
+	}
+
+	t := p.peek()
+	if p.mermaid {
+		p.parseMermaidHeader()
+	} else if t.Kind == TokenIdent && t.Text == "extuml" {
+		p.advance()
+		header := &Header{Span: t.Span}
+		if next := p.peek(); next.Kind == TokenIdent {
+			p.advance()
+			header.DiagramType = next.Text
+		}
+		header.End = p.lastEnd
+		p.file.Header = header
+		if kind, ok := diagramTypes[header.DiagramType]; ok {
+			p.diagram = kind
+		} else if header.DiagramType != "" {
+			p.diags.Add(header.Span, diagnostic.CodeUnknownDiagramType,
+				"unknown diagram type %q (expected %s)", header.DiagramType, diagramTypeNames())
+		}
+		p.expectLineEnd()
+	} else {
+		p.diags.Add(t.Span, diagnostic.CodeMissingHeader, "DSL header not found (expected 'extuml' followed by %s)", diagramTypeNames())
+	}
+
+	switch p.diagram {
+	case extuml.DiagramSequence:
+		p.file.Decls, _, _ = p.parseSequenceDecls(Span{}, "", blockTop)
+	case extuml.DiagramState:
+		p.file.Decls, _, _, _ = p.parseStateDecls(Span{}, "")
+	case extuml.DiagramER:
+		p.file.Decls, _, _ = p.parseERDecls(Span{}, "")
+	case extuml.DiagramComponent, extuml.DiagramDeployment:
+		p.file.Decls, _, _ = p.parseComponentDecls(Span{}, "", componentBlock{})
+	case extuml.DiagramActivity:
+		p.file.Decls, _, _ = p.parseActivityDecls(Span{}, "")
+	default:
+		p.file.Decls = p.parseDecls(nil)
+	}
+	p.file.Span = Span{Start: Pos{File: p.file.Name, Line: 1, Column: 1}, End: p.peek().Span.End}
+}
+
+// parseDecls parses declarations until the end of file or, inside a package,
+// until the closing '}'
+func (p *parser) parseDecls(pkg *PackageDecl) []Decl {
+	var decls []Decl
+	for {
+		p.skipBlank()
+		t := p.peek()
+		switch t.Kind {
+		case TokenEOF:
+			if pkg != nil {
+				p.diags.Add(pkg.Span, diagnostic.CodeUnterminatedBlock, "package %q is not closed (missing '}')", pkg.Name.Name)
+			}
+			return decls
+		case TokenRBrace:
+			if pkg != nil {
+				p.advance()
+				pkg.End = t.Span.End
+				p.expectLineEnd()
+				return decls
+			}
+			p.diags.Add(t.Span, diagnostic.CodeUnexpectedCloseBrace, "unexpected '}' without an open block")
+			p.skipLine()
+		default:
+			if d := p.parseDecl(pkg); d != nil {
+				decls = append(decls, d)
+			}
+		}
+	}
+}
+
+// parseDecl parses one declaration; pkg is the enclosing package, if any
+func (p *parser) parseDecl(pkg *PackageDecl) Decl {
+	t := p.peek()
+	if p.mermaid {
+		if d, ok := p.parseMermaidDecl(); ok {
+			return d
+		}
+	}
+	if t.Kind == TokenIdent {
+		switch t.Text {
+		case "class", "interface", "enum":
+			if d := p.parseClassifier(); d != nil {
+				return d
+			}
+			return nil
+		case "package":
+			if d := p.parsePackage(); d != nil {
+				return d
+			}
+			return nil
+		case "note":
+			if d := p.parseNote(); d != nil {
+				return d
+			}
+			return nil
+		case "abstract", "final":
+			if p.isModifiedClassStart() {
+				if d := p.parseClassifier(); d != nil {
+					return d
+				}
+				return nil
+			}
+		case "include", "import":
+			if p.isIncludeStart() {
+				if d := p.parseInclude(); d != nil {
+					return d
+				}
+				return nil
+			}
+		case "classDef", "style", "cssClass":
+			if p.isStyleStart() {
+				if d := p.parseStyleDecl(); d != nil {
+					return d
+				}
+				return nil
+			}
+		}
+		if next := p.peekAt(1); pkg == nil && metaKeys[t.Text] && next.Kind == TokenPunct && next.Text == ":" {
+			p.parseMetaLine()
+			return nil
+		}
+		if p.isRelationshipStart() {
+			if d := p.parseRelationship(); d != nil {
+				return d
+			}
+			return nil
+		}
+	}
+
+	if p.isMemberStart() {
+		text, span := p.restOfLine()
+		p.diags.Add(span, diagnostic.CodeMemberOutsideBlock, "member %q is outside of a class block", text)
+		return nil
+	}
+
+	p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "unexpected %s; expected a declaration", t.describe())
+	p.skipLine()
+	return nil
+}
+
+// isMemberStart reports whether the current line looks like a classifier
+// member or annotation, such as `+name: string`, `getId()` or `@url: ...`
+func (p *parser) isMemberStart() bool {
+	t := p.peek()
+	switch t.Kind {
+	case TokenAnnotation:
+		return true
+	case TokenPunct:
+		return strings.ContainsAny(t.Text, "+-#~") && p.peekAt(1).Kind == TokenIdent
+	case TokenIdent:
+		next := p.peekAt(1)
+		if next.Kind == TokenPunct && (next.Text == ":" || next.Text == "(") {
+			return true
+		}
+		// Mermaid-style `Type name`
+		return next.Kind == TokenIdent && p.peekAt(2).Kind == TokenNewline
+	}
+	return false
+}
+
+// isDeclStart reports whether the current line starts a new declaration,
+// used to recover from a classifier block missing its '}'
+func (p *parser) isDeclStart() bool {
+	t := p.peek()
+	if t.Kind == TokenIdent && (t.Text == "include" || t.Text == "import") {
+		return p.isIncludeStart()
+	}
+	if t.Kind == TokenIdent && (t.Text == "abstract" || t.Text == "final") {
+		return p.isModifiedClassStart()
+	}
+	if t.Kind == TokenIdent && (t.Text == "classDef" || t.Text == "cssClass") {
+		return p.isStyleStart()
+	}
+	if t.Kind != TokenIdent || p.peekAt(1).Kind != TokenIdent {
+		return false
+	}
+	switch t.Text {
+	case "class", "interface", "enum", "package":
+		return true
+	}
+	return false
+}
+
+// isModifiedClassStart reports whether the current line is a class
+// declaration preceded by modifiers, as in `abstract class Shape`
+func (p *parser) isModifiedClassStart() bool {
+	for i := 0; ; i++ {
+		t := p.peekAt(i)
+		if t.Kind != TokenIdent {
+			return false
+		}
+		switch t.Text {
+		case "abstract", "final":
+			continue
+		case "class":
+			return i > 0
+		}
+		return false
+	}
+}
+
+// isIncludeStart reports whether the current line is `include "path"`
+// rather than a relationship from a class named include
+func (p *parser) isIncludeStart() bool {
+	next := p.peekAt(2).Kind
+	return p.peekAt(1).Kind == TokenString && (next == TokenNewline || next == TokenEOF)
+}
+
+func (p *parser) isRelationshipStart() bool {
+	next := p.peekAt(1)
+	if next.Kind == TokenString {
+		next = p.peekAt(2)
+	}
+	return next.Kind == TokenArrow
+}
+
+func (p *parser) parseClassifier() *ClassifierDecl {
+	d := &ClassifierDecl{Span: p.peek().Span}
+	for t := p.peek(); t.Text == "abstract" || t.Text == "final"; t = p.peek() {
+		p.advance()
+		d.Modifiers = append(d.Modifiers, Ident{Span: t.Span, Name: t.Text})
+	}
+	kw := p.advance()
+	d.Kind = kw.Text
+
+	name, ok := p.expectName(kw.Text + " name")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Name = name
+	d.End = name.End
+
+	if t := p.peek(); t.Kind == TokenPunct && t.Text == "[" && t.Span.Start.Offset == name.End.Offset {
+		p.parseAlias(d)
+	}
+	if t := p.peek(); d.Kind != "enum" && t.Kind == TokenPunct && (t.Text == "~" || t.Text == "<") {
+		if params := p.parseTypeParams(); params != nil {
+			d.TypeParams = params
+			d.End = params.End
+		}
+	}
+	for {
+		if t := p.peek(); t.Kind == TokenIdent && t.Text == "as" && d.Alias == "" {
+			p.parseAlias(d)
+		} else if p.isClassShorthand() {
+			class := p.parseClassShorthand()
+			d.Classes = append(d.Classes, class)
+			d.End = class.End
+		} else {
+			break
+		}
+	}
+	for t := p.peek(); t.Kind == TokenStereotype; t = p.peek() {
+		p.advance()
+		d.Stereotypes = append(d.Stereotypes, newStereotype(t))
+		d.End = t.Span.End
+	}
+
+	if p.peek().Kind != TokenLBrace {
+		// Bodiless declaration such as `class Person`
+		p.expectLineEnd()
+		return d
+	}
+	p.advance()
+	p.expectLineEnd()
+
+	for {
+		p.skipBlank()
+		t := p.peek()
+		switch {
+		case t.Kind == TokenEOF || p.isDeclStart():
+			p.diags.Add(d.Span, diagnostic.CodeUnterminatedBlock, "%s %q is not closed (missing '}')", d.Kind, d.Name.Name)
+			return d
+		case t.Kind == TokenRBrace:
+			p.advance()
+			d.End = t.Span.End
+			p.expectLineEnd()
+			return d
+		case t.Kind == TokenStereotype:
+			p.advance()
+			d.Stereotypes = append(d.Stereotypes, newStereotype(t))
+			p.expectLineEnd()
+		case t.Kind == TokenAnnotation:
+			p.advance()
+			ann := &Annotation{Span: t.Span, Name: strings.TrimPrefix(t.Text, "@")}
+			if colon := p.peek(); colon.Kind == TokenPunct && colon.Text == ":" {
+				p.advance()
+			}
+			value, span := p.restOfLine()
+			ann.Value = value
+			if value != "" {
+				ann.End = span.End
+			}
+			d.Annotations = append(d.Annotations, ann)
+		default:
+			text, span := p.restOfLine()
+			d.Members = append(d.Members, &Member{Span: span, Text: text})
+		}
+	}
+}
+
+// parseAlias parses the display name of a classifier, written either
+// `["Display Name"]` right after the name or `as "Display Name"`
+func (p *parser) parseAlias(d *ClassifierDecl) {
+	bracket := p.advance().Text == "["
+	alias, ok := p.expectString("display name")
+	if !ok {
+		return
+	}
+	d.Alias = alias.Value
+	d.End = alias.Span.End
+	if !bracket {
+		return
+	}
+	if t := p.peek(); t.Kind != TokenPunct || t.Text != "]" {
+		p.diags.Add(t.Span, diagnostic.CodeExpected, "expected ']' after the display name, found %s", t.describe())
+		return
+	}
+	d.End = p.advance().Span.End
+}
+
+// parseTypeParams parses a `~T~` or `<K, V>` type parameter list. Mermaid
+// nests generics with `~` as well, so a `~` list runs to the last `~` before
+// the body or a stereotype.
+func (p *parser) parseTypeParams() *TypeParams {
+	open := p.peek()
+	lineEnd := open.Span.Start.Offset
+	for lineEnd < len(p.src) && p.src[lineEnd] != '\n' {
+		lineEnd++
+	}
+	rest := string(p.src[open.Span.Start.Offset:lineEnd])
+	if cut := strings.Index(rest, "{"); cut >= 0 {
+		rest = rest[:cut]
+	}
+	if cut := strings.Index(rest, "<<"); cut > 0 {
+		rest = rest[:cut]
+	}
+
+	closeAt := -1
+	if open.Text == "~" {
+		if i := strings.LastIndex(rest, "~"); i > 0 {
+			closeAt = i
+		}
+	} else {
+		depth := 0
+		for i := 0; i < len(rest) && closeAt < 0; i++ {
+			switch rest[i] {
+			case '<':
+				depth++
+			case '>':
+				depth--
+				if depth == 0 {
+					closeAt = i
+				}
+			}
+		}
+	}
+
+	if closeAt < 0 {
+		closing := ">"
+		if open.Text == "~" {
+			closing = "~"
+		}
+		p.diags.Add(open.Span, diagnostic.CodeExpected, "expected closing '%s' of the type parameter list", closing)
+		// Skip the list but keep the body
+		for t := p.peek(); t.Kind != TokenLBrace && t.Kind != TokenNewline && t.Kind != TokenEOF; t = p.peek() {
+			p.advance()
+		}
+		return nil
+	}
+
+	end := open.Span.Start.Offset + closeAt + 1
+	params := &TypeParams{Span: open.Span, Text: strings.TrimSpace(rest[1:closeAt])}
+	for p.peek().Kind != TokenEOF && p.peek().Span.Start.Offset < end {
+		params.End = p.advance().Span.End
+	}
+	return params
+}
+
+// newStereotype converts a stereotype token into its comma separated names
+func newStereotype(t Token) *Stereotype {
+	st := &Stereotype{Span: t.Span}
+	for _, name := range strings.Split(t.Value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			st.Names = append(st.Names, name)
+		}
+	}
+	return st
+}
+
+func (p *parser) parsePackage() *PackageDecl {
+	kw := p.advance()
+	d := &PackageDecl{Span: kw.Span}
+
+	name, ok := p.expectName("package name")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Name = name
+	d.End = name.End
+
+	if t := p.peek(); t.Kind != TokenLBrace {
+		p.diags.Add(t.Span, diagnostic.CodeExpected, "expected '{' after package name, found %s", t.describe())
+		p.skipLine()
+		return d
+	}
+	p.advance()
+	p.expectLineEnd()
+
+	d.Decls = p.parseDecls(d)
+	return d
+}
+
+func (p *parser) parseNote() *NoteDecl {
+	kw := p.advance()
+	d := &NoteDecl{Span: kw.Span}
+
+	if t := p.peek(); t.Kind == TokenIdent && t.Text == "for" {
+		p.advance()
+		anchor, ok := p.expectIdent("note anchor")
+		if !ok {
+			p.skipLine()
+			return nil
+		}
+		d.Anchor = &anchor
+	}
+
+	text, ok := p.expectString("note text")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Text = text.Value
+	d.End = text.Span.End
+	p.expectLineEnd()
+	return d
+}
+
+func (p *parser) parseInclude() *IncludeDecl {
+	kw := p.advance()
+	d := &IncludeDecl{Span: kw.Span}
+
+	path, ok := p.expectString(kw.Text + " path")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Path = path.Value
+	d.PathSpan = path.Span
+	d.End = path.Span.End
+	p.expectLineEnd()
+	return d
+}
+
+func (p *parser) parseRelationship() *RelationshipDecl {
+	left := p.advance()
+	d := &RelationshipDecl{Span: left.Span, Left: Ident{Span: left.Span, Name: left.Text}}
+
+	if t := p.peek(); t.Kind == TokenString {
+		p.advance()
+		p.checkTerminated(t, "multiplicity")
+		d.LeftMult = t.Value
+	}
+
+	op := p.advance()
+	d.Operator = op.Text
+	if _, ok := relationshipOperators[op.Text]; !ok {
+		p.diags.Add(op.Span, diagnostic.CodeUnexpectedToken, "message arrow %q can only be used in a sequence diagram", op.Text)
+		p.skipLine()
+		return nil
+	}
+
+	if t := p.peek(); t.Kind == TokenString {
+		p.advance()
+		p.checkTerminated(t, "multiplicity")
+		d.RightMult = t.Value
+	}
+
+	right, ok := p.expectIdent("relationship target")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Right = right
+	d.End = right.End
+
+	if t := p.peek(); t.Kind == TokenPunct && t.Text == ":" {
+		p.advance()
+		label, span := p.restOfLine()
+		d.Label = label
+		if label != "" {
+			d.End = span.End
+		}
+		return d
+	}
+	p.expectLineEnd()
+	return d
+}
@@ -0,0 +1,794 @@
+package parser
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// ParsePlantUML parses a PlantUML `@startuml ... @enduml` class diagram into
+// the same AST as Parse, so that it can be lowered like an .extuml file.
+// Layout and styling statements such as `skinparam` or `hide` are skipped,
+// statements that cannot be represented are reported as W210 and classes
+// that are only used in relationships are declared implicitly. Anything
+// after `@enduml` is ignored; see ExtractPlantUMLBlocks for files holding
+// several diagrams.
+func ParsePlantUML(filename string, src []byte) (*File, diagnostic.List) {
+	src = blankPlantUMLComments(src)
+	p := &parser{src: src, tokens: Lex(filename, src), notes: map[string]*NoteDecl{}}
+	p.file = &File{Name: filename}
+
+	p.skipBlank()
+	if t := p.peek(); t.Kind == TokenAnnotation && t.Text == "@startuml" {
+		// The diagram name after @startuml is not used
+		p.skipLine()
+		p.file.Header = &Header{Span: t.Span, DiagramType: "classDiagram"}
+	} else {
+		p.diags.Add(t.Span, diagnostic.CodeMissingHeader, "PlantUML header not found (expected '@startuml')")
+	}
+	p.file.Decls, _ = p.parsePlantUMLDecls(Span{}, "")
+	p.file.Span = Span{Start: Pos{File: filename, Line: 1, Column: 1}, End: p.peek().Span.End}
+
+	p.file.Decls = p.attachPlantUMLNotes(p.file.Decls)
+	normalizeImported(p.file, true)
+	p.diags.Sort()
+	return p.file, p.diags
+}
+
+// parsePlantUMLDecls parses declarations up to `@enduml` or, inside a block,
+// up to its closing '}'. what describes the block opened at open and is
+// empty at the top level; end is the end of the closing '}'.
+func (p *parser) parsePlantUMLDecls(open Span, what string) (decls []Decl, end Pos) {
+	for {
+		p.skipBlank()
+		t := p.peek()
+		switch {
+		case t.Kind == TokenEOF || (t.Kind == TokenAnnotation && t.Text == "@enduml"):
+			if what != "" {
+				p.diags.Add(open, diagnostic.CodeUnterminatedBlock, "%s is not closed (missing '}')", what)
+			}
+			return decls, t.Span.Start
+		case t.Kind == TokenRBrace:
+			if what != "" {
+				p.advance()
+				p.expectLineEnd()
+				return decls, t.Span.End
+			}
+			p.diags.Add(t.Span, diagnostic.CodeUnexpectedCloseBrace, "unexpected '}' without an open block")
+			p.skipLine()
+		default:
+			decls = append(decls, p.parsePlantUMLDecl()...)
+		}
+	}
+}
+
+// plantUMLClassKeywords are the keywords declaring a classifier. Those other
+// than class, abstract, interface and enum become a stereotype of a class.
+var plantUMLClassKeywords = map[string]bool{
+	"class": true, "abstract": true, "interface": true, "enum": true,
+	"annotation": true, "entity": true, "exception": true, "struct": true,
+	"record": true, "protocol": true, "metaclass": true, "dataclass": true,
+}
+
+// plantUMLIgnored are the layout and styling statements that have no
+// meaning in a 3D diagram
+var plantUMLIgnored = map[string]bool{
+	"skinparam": true, "hide": true, "show": true, "remove": true, "restore": true,
+	"scale": true, "set": true, "left": true, "top": true, "allowmixing": true,
+	"allow_mixing": true, "header": true, "footer": true, "caption": true,
+	"legend": true, "newpage": true,
+}
+
+// parsePlantUMLDecl parses one statement. A classifier with `extends` or
+// `implements` yields the relationships as well, and a `together` block
+// yields its contents.
+func (p *parser) parsePlantUMLDecl() []Decl {
+	t := p.peek()
+	next := p.peekAt(1)
+
+	if t.Kind == TokenPunct && (t.Text == "!" || t.Text == "(") {
+		what := "preprocessor directive"
+		if t.Text == "(" {
+			what = "association class"
+		}
+		text, span := p.restOfLine()
+		p.diags.Add(span, diagnostic.CodeUnsupportedStatement, "%s %q is not supported and is ignored", what, text)
+		return nil
+	}
+
+	if t.Kind == TokenIdent {
+		switch {
+		case plantUMLClassKeywords[t.Text] && (next.Kind == TokenIdent || next.Kind == TokenString):
+			return p.parsePlantUMLClassifier()
+		case (t.Text == "package" || t.Text == "namespace") && (next.Kind == TokenIdent || next.Kind == TokenString):
+			if d := p.parsePlantUMLPackage(); d != nil {
+				return []Decl{d}
+			}
+			return nil
+		case t.Text == "together" && next.Kind == TokenLBrace:
+			p.advance()
+			p.advance()
+			p.expectLineEnd()
+			decls, _ := p.parsePlantUMLDecls(t.Span, "together block")
+			return decls
+		case t.Text == "note" && next.Kind != TokenArrow:
+			if d := p.parsePlantUMLNote(); d != nil {
+				return []Decl{d}
+			}
+			return nil
+		case t.Text == "title" && next.Kind != TokenArrow:
+			p.advance()
+			value, span := p.restOfLine()
+			if value == "" {
+				// A multi-line title is not supported
+				p.skipPlantUMLBlock("title")
+				return nil
+			}
+			p.file.Meta = append(p.file.Meta, &MetaEntry{Span: Span{Start: t.Span.Start, End: span.End}, Key: "title", Value: unquote(value)})
+			return nil
+		case plantUMLIgnored[t.Text] && next.Kind != TokenArrow:
+			p.skipPlantUMLStatement()
+			return nil
+		}
+
+		if p.isPlantUMLRelationshipStart() {
+			if d := p.parsePlantUMLRelationship(); d != nil {
+				return []Decl{d}
+			}
+			return nil
+		}
+		if next.Kind == TokenPunct && next.Text == ":" && !p.isClassShorthandAt(1) {
+			if d := p.parseMemberDecl(); d != nil {
+				d.Member.Text = plantUMLMember(d.Member.Text)
+				return []Decl{d}
+			}
+			return nil
+		}
+	}
+
+	p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "unexpected %s; expected a declaration", t.describe())
+	p.skipLine()
+	return nil
+}
+
+// skipPlantUMLStatement skips an ignored statement, including the block of
+// `skinparam name {` and the lines of a multi-line legend, header or footer
+func (p *parser) skipPlantUMLStatement() {
+	kw := p.advance()
+	depth := 0
+	for t := p.peek(); t.Kind != TokenNewline && t.Kind != TokenEOF; t = p.peek() {
+		if t.Kind == TokenLBrace {
+			depth++
+		}
+		p.advance()
+	}
+	p.skipLine()
+	if depth > 0 {
+		for depth > 0 && p.peek().Kind != TokenEOF {
+			switch p.advance().Kind {
+			case TokenLBrace:
+				depth++
+			case TokenRBrace:
+				depth--
+			}
+		}
+		p.skipLine()
+		return
+	}
+	// A legend, and a header or footer alone on its line, run to `end<keyword>`
+	if alone := p.lastEnd == kw.Span.End; kw.Text == "legend" || (alone && (kw.Text == "header" || kw.Text == "footer")) {
+		p.skipPlantUMLBlock(kw.Text)
+	}
+}
+
+// skipPlantUMLBlock skips the lines up to `end<keyword>` or `end <keyword>`
+func (p *parser) skipPlantUMLBlock(keyword string) {
+	for p.peek().Kind != TokenEOF {
+		line, _ := p.restOfLine()
+		if line = strings.Join(strings.Fields(line), ""); line == "end"+keyword {
+			return
+		}
+	}
+}
+
+// parsePlantUMLName parses a classifier or package name, written `Name`,
+// `"Display Name" as Name`, `Name as "Display Name"` or `"Name"`
+func (p *parser) parsePlantUMLName(what string) (name Ident, alias string, ok bool) {
+	t := p.peek()
+	if t.Kind == TokenString {
+		p.advance()
+		p.checkTerminated(t, what)
+		name = Ident{Span: t.Span, Name: t.Value}
+		if as := p.peek(); as.Kind == TokenIdent && as.Text == "as" {
+			p.advance()
+			if name, ok = p.expectIdent(what); !ok {
+				return name, "", false
+			}
+			alias = t.Value
+		}
+		return name, alias, true
+	}
+
+	if name, ok = p.expectIdent(what); !ok {
+		return name, "", false
+	}
+	if as := p.peek(); as.Kind == TokenIdent && as.Text == "as" {
+		p.advance()
+		switch display := p.peek(); display.Kind {
+		case TokenString:
+			p.advance()
+			p.checkTerminated(display, "display name")
+			alias = display.Value
+		case TokenIdent:
+			// `Long as L` declares L displayed as Long
+			p.advance()
+			alias = name.Name
+			name = Ident{Span: display.Span, Name: display.Text}
+		default:
+			p.diags.Add(display.Span, diagnostic.CodeExpected, "expected a name after 'as', found %s", display.describe())
+		}
+	}
+	return name, alias, true
+}
+
+// parsePlantUMLClassifier parses a classifier declaration with its optional
+// generics, stereotypes, colour, `extends`/`implements` clauses and body
+func (p *parser) parsePlantUMLClassifier() []Decl {
+	kw := p.advance()
+	d := &ClassifierDecl{Span: kw.Span, Kind: "class"}
+	switch kw.Text {
+	case "abstract":
+		d.Modifiers = append(d.Modifiers, Ident{Span: kw.Span, Name: kw.Text})
+		if t := p.peek(); t.Kind == TokenIdent && t.Text == "class" {
+			p.advance()
+		}
+	case "interface", "enum":
+		d.Kind = kw.Text
+	case "class":
+	default:
+		d.Stereotypes = append(d.Stereotypes, &Stereotype{Span: kw.Span, Names: []string{kw.Text}})
+	}
+
+	name, alias, ok := p.parsePlantUMLName(d.Kind + " name")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Name, d.Alias = name, alias
+	d.End = p.lastEnd
+	decls := []Decl{d}
+
+	for t := p.peek(); t.Kind != TokenLBrace && t.Kind != TokenNewline && t.Kind != TokenEOF; t = p.peek() {
+		switch {
+		case t.Kind == TokenPunct && t.Text == "<" && d.TypeParams == nil && d.Kind != "enum":
+			if params := p.parseTypeParams(); params != nil {
+				d.TypeParams = params
+				d.End = params.End
+			}
+		case t.Kind == TokenStereotype:
+			p.advance()
+			d.Stereotypes = append(d.Stereotypes, plantUMLStereotype(t))
+			d.End = t.Span.End
+		case t.Kind == TokenPunct && t.Text == "#":
+			p.skipGlued()
+		case t.Kind == TokenIdent && (t.Text == "extends" || t.Text == "implements"):
+			p.advance()
+			decls = append(decls, p.parsePlantUMLSupertypes(d, t)...)
+		default:
+			p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "unexpected %s in %s declaration", t.describe(), d.Kind)
+			for t := p.peek(); t.Kind != TokenLBrace && t.Kind != TokenNewline && t.Kind != TokenEOF; t = p.peek() {
+				p.advance()
+			}
+		}
+	}
+
+	if p.peek().Kind != TokenLBrace {
+		p.expectLineEnd()
+		return decls
+	}
+	p.advance()
+	if t := p.peek(); t.Kind == TokenRBrace {
+		// Empty body on one line: `class Foo {}`
+		p.advance()
+		d.End = t.Span.End
+		p.expectLineEnd()
+		return decls
+	}
+	p.expectLineEnd()
+
+	for {
+		p.skipBlank()
+		t := p.peek()
+		switch {
+		case t.Kind == TokenEOF || (t.Kind == TokenAnnotation && t.Text == "@enduml"):
+			p.diags.Add(d.Span, diagnostic.CodeUnterminatedBlock, "%s %q is not closed (missing '}')", d.Kind, d.Name.Name)
+			return decls
+		case t.Kind == TokenRBrace:
+			p.advance()
+			d.End = t.Span.End
+			p.expectLineEnd()
+			return decls
+		default:
+			text, span := p.restOfLine()
+			if !isPlantUMLSeparator(text) {
+				d.Members = append(d.Members, &Member{Span: span, Text: plantUMLMember(text)})
+			}
+		}
+	}
+}
+
+// parsePlantUMLSupertypes parses the comma separated names after `extends`
+// or `implements` into inheritance or realization relationships
+func (p *parser) parsePlantUMLSupertypes(d *ClassifierDecl, kw Token) []Decl {
+	op := "--|>"
+	if kw.Text == "implements" && d.Kind != "interface" {
+		op = "..|>"
+	}
+	var decls []Decl
+	for {
+		super, ok := p.expectIdent(kw.Text + " target")
+		if !ok {
+			return decls
+		}
+		if t := p.peek(); t.Kind == TokenPunct && t.Text == "<" {
+			// Type arguments of the supertype are not kept
+			p.parseTypeParams()
+		}
+		decls = append(decls, &RelationshipDecl{Span: Span{Start: kw.Span.Start, End: p.lastEnd}, Left: d.Name, Operator: op, Right: super})
+		if t := p.peek(); t.Kind != TokenPunct || t.Text != "," {
+			return decls
+		}
+		p.advance()
+	}
+}
+
+// skipGlued skips a token and the tokens glued to it, such as a
+// `#back:pink;line:red` colour
+func (p *parser) skipGlued() {
+	end := p.advance().Span.End.Offset
+	for t := p.peek(); t.Kind != TokenNewline && t.Kind != TokenEOF && t.Kind != TokenLBrace && t.Span.Start.Offset == end; t = p.peek() {
+		end = p.advance().Span.End.Offset
+	}
+}
+
+// plantUMLStereotype converts a stereotype token, dropping the
+// `(S,#FF7700)` spot that PlantUML draws in front of the name
+func plantUMLStereotype(t Token) *Stereotype {
+	if strings.HasPrefix(t.Value, "(") {
+		if _, rest, ok := strings.Cut(t.Value, ")"); ok {
+			t.Value = rest
+		}
+	}
+	return newStereotype(t)
+}
+
+// isPlantUMLSeparator reports whether a body line is a `--`, `..`, `==` or
+// `__` separator, optionally titled as in `-- accessors --`
+func isPlantUMLSeparator(line string) bool {
+	for _, sep := range []string{"--", "..", "==", "__"} {
+		if strings.HasPrefix(line, sep) && strings.HasSuffix(line, sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// plantUMLModifiers maps the `{static}` style member modifiers to the
+// keywords of the member grammar; `{field}` and `{method}` are dropped
+var plantUMLModifiers = []struct{ marker, keyword string }{
+	{"{static}", "static"},
+	{"{classifier}", "static"},
+	{"{abstract}", "abstract"},
+	{"{field}", ""},
+	{"{method}", ""},
+}
+
+// plantUMLMember rewrites a PlantUML member into the member grammar: the
+// `{static}` and `{abstract}` modifiers become keywords after the visibility
+// and Java-style `Type name(params)` operations become `name(params) : Type`
+func plantUMLMember(text string) string {
+	var keywords []string
+	for _, m := range plantUMLModifiers {
+		if strings.Contains(text, m.marker) {
+			text = strings.Replace(text, m.marker, "", 1)
+			if m.keyword != "" {
+				keywords = append(keywords, m.keyword)
+			}
+		}
+	}
+	text = strings.TrimSpace(text)
+
+	visibility := ""
+	if text != "" && extuml.VisibilityFromSymbol(text[0]) != "" {
+		visibility, text = text[:1], strings.TrimSpace(text[1:])
+	}
+	text, static, abstract := parseModifierKeywords(text)
+	if static {
+		keywords = append(keywords, "static")
+	}
+	if abstract {
+		keywords = append(keywords, "abstract")
+	}
+
+	if open, closeIdx := strings.Index(text, "("), strings.LastIndex(text, ")"); open > 0 && closeIdx == len(text)-1 {
+		if fields := fieldsTopLevel(normalizeGenerics(text[:open])); len(fields) >= 2 {
+			last := len(fields) - 1
+			text = fields[last] + text[open:] + " : " + strings.Join(fields[:last], " ")
+		}
+	}
+	return visibility + strings.Join(append(keywords, text), " ")
+}
+
+// parsePlantUMLPackage parses a `package` or `namespace` block
+func (p *parser) parsePlantUMLPackage() *PackageDecl {
+	kw := p.advance()
+	d := &PackageDecl{Span: kw.Span}
+	// Packages have no display name, so `"Display" as id` keeps the id
+	name, _, ok := p.parsePlantUMLName("package name")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Name = name
+	d.End = p.lastEnd
+
+	for t := p.peek(); t.Kind == TokenStereotype || (t.Kind == TokenPunct && t.Text == "#"); t = p.peek() {
+		// Package styles such as <<Folder>> and colours are not kept
+		p.skipGlued()
+	}
+	if t := p.peek(); t.Kind != TokenLBrace {
+		// A package without a block is empty
+		p.expectLineEnd()
+		return d
+	}
+	p.advance()
+	p.expectLineEnd()
+	d.Decls, d.End = p.parsePlantUMLDecls(d.Span, "package "+`"`+name.Name+`"`)
+	return d
+}
+
+// parsePlantUMLNote parses the note forms
+//
+//	note "text" [as N1]
+//	note as N1 ... end note
+//	note left of A : text
+//	note left of A ... end note
+//
+// `note on link` is reported as unsupported. Notes named with `as` are
+// attached to the class they are linked to, as in `N1 .. A`.
+func (p *parser) parsePlantUMLNote() *NoteDecl {
+	kw := p.advance()
+	d := &NoteDecl{Span: kw.Span}
+
+	if t := p.peek(); t.Kind == TokenString {
+		p.advance()
+		p.checkTerminated(t, "note text")
+		d.Text = t.Value
+		d.End = t.Span.End
+		if as := p.peek(); as.Kind == TokenIdent && as.Text == "as" {
+			p.advance()
+			if alias, ok := p.expectIdent("note name"); ok {
+				p.notes[alias.Name] = d
+			}
+		}
+		p.skipNoteStyle()
+		p.expectLineEnd()
+		return d
+	}
+
+	if t := p.peek(); t.Kind == TokenIdent && t.Text == "as" {
+		p.advance()
+		alias, ok := p.expectIdent("note name")
+		if !ok {
+			p.skipLine()
+			return nil
+		}
+		p.notes[alias.Name] = d
+		p.skipNoteStyle()
+		p.expectLineEnd()
+		return p.parseNoteBody(d)
+	}
+
+	switch side := p.peek(); {
+	case side.Kind == TokenIdent && side.Text == "on":
+		text, span := p.restOfLine()
+		p.diags.Add(Span{Start: kw.Span.Start, End: span.End}, diagnostic.CodeUnsupportedStatement, "note on link is not supported and is ignored")
+		if !strings.Contains(text, ":") {
+			p.skipPlantUMLBlock("note")
+		}
+		return nil
+	case side.Kind == TokenIdent && (side.Text == "left" || side.Text == "right" || side.Text == "top" || side.Text == "bottom"):
+		p.advance()
+		if of := p.peek(); of.Kind != TokenIdent || of.Text != "of" {
+			p.diags.Add(of.Span, diagnostic.CodeExpected, "expected 'of' and the element the note is for, found %s", of.describe())
+			p.skipLine()
+			return nil
+		}
+		p.advance()
+		anchor, ok := p.expectIdent("note anchor")
+		if !ok {
+			p.skipLine()
+			return nil
+		}
+		d.Anchor = &anchor
+		d.End = anchor.End
+		// A note on `A::member` is shown on the class
+		for range p.memberRefLength(0) {
+			p.advance()
+		}
+		p.skipNoteStyle()
+		if t := p.peek(); t.Kind == TokenPunct && t.Text == ":" {
+			p.advance()
+			// The text after the colon is not a string; PlantUML reads `\n`
+			// in it as a line break
+			text, span := p.restOfLine()
+			d.Text = strings.ReplaceAll(text, `\n`, "\n")
+			d.End = span.End
+			return d
+		}
+		p.expectLineEnd()
+		return p.parseNoteBody(d)
+	default:
+		p.diags.Add(side.Span, diagnostic.CodeExpected, "expected note text, 'as', a side such as 'left of' or 'on link', found %s", side.describe())
+		p.skipLine()
+		return nil
+	}
+}
+
+// skipNoteStyle skips a `#colour` after a note's head
+func (p *parser) skipNoteStyle() {
+	if t := p.peek(); t.Kind == TokenPunct && t.Text == "#" {
+		p.skipGlued()
+	}
+}
+
+// parseNoteBody reads the lines of a note up to `end note`
+func (p *parser) parseNoteBody(d *NoteDecl) *NoteDecl {
+	var lines []string
+	for {
+		if p.peek().Kind == TokenEOF {
+			p.diags.Add(d.Span, diagnostic.CodeUnterminatedBlock, "note is not closed (missing 'end note')")
+			break
+		}
+		line, span := p.restOfLine()
+		if compact := strings.Join(strings.Fields(line), ""); compact == "endnote" {
+			d.End = span.End
+			break
+		}
+		lines = append(lines, line)
+	}
+	d.Text = strings.Join(lines, "\n")
+	return d
+}
+
+// attachPlantUMLNotes turns links between a named note and a class, such as
+// `N1 .. A`, into the note's anchor
+func (p *parser) attachPlantUMLNotes(decls []Decl) []Decl {
+	out := decls[:0]
+	for _, decl := range decls {
+		switch d := decl.(type) {
+		case *PackageDecl:
+			d.Decls = p.attachPlantUMLNotes(d.Decls)
+		case *RelationshipDecl:
+			note, target := p.notes[d.Left.Name], d.Right
+			if note == nil {
+				note, target = p.notes[d.Right.Name], d.Left
+			}
+			if note != nil {
+				if p.notes[target.Name] == nil && note.Anchor == nil {
+					note.Anchor = &target
+				}
+				continue
+			}
+		}
+		out = append(out, decl)
+	}
+	return out
+}
+
+// plantUMLArrow matches a relationship arrow such as `<|--`, `*-->`, `-up->`
+// or `.[#red].>`: an optional head, a line of dashes or dots that may carry
+// a direction and a style in brackets, and an optional head
+var plantUMLArrow = regexp.MustCompile(`^(<\||[<*o#x}+^])?[-.]+(?:\[[^\]]*\])?(?:up|down|left|right|u|d|l|r)?(?:\[[^\]]*\])?[-.]*(\|>|[>*o#x{+^])?`)
+
+// plantUMLArrowAt returns the operator of the arrow at offset, as one of the
+// DSL's relationship operators, and the arrow's length
+func (p *parser) plantUMLArrowAt(offset int) (op string, length int, ok bool) {
+	m := plantUMLArrow.FindSubmatchIndex(p.src[offset:])
+	if m == nil {
+		return "", 0, false
+	}
+	arrow := string(p.src[offset : offset+m[1]])
+	left, right := "", ""
+	if m[2] >= 0 {
+		left = arrow[m[2]:m[3]]
+	}
+	if m[4] >= 0 {
+		right = arrow[m[4]:m[5]]
+		// `--o` is aggregation only when the `o` does not start a name
+		if next := offset + m[1]; right == "o" && next < len(p.src) && isIdentPart(rune(p.src[next])) {
+			arrow, right = arrow[:m[4]], ""
+		}
+	}
+
+	// The line is dashed if drawn with dots or styled `[dashed]`/`[dotted]`
+	line := "--"
+	body := strings.Trim(arrow, "<|*o#x}+^>{")
+	if style := strings.Index(body, "["); style >= 0 {
+		if strings.Contains(body[style:], "dashed") || strings.Contains(body[style:], "dotted") {
+			line = ".."
+		}
+		body = body[:style]
+	}
+	if strings.Contains(body, ".") {
+		line = ".."
+	}
+	switch {
+	case left == "<|" || left == "^":
+		op = "<|" + line
+	case right == "|>" || right == "^":
+		op = line + "|>"
+	case left == "*" || left == "o":
+		op = left + "--"
+	case right == "*" || right == "o":
+		op = "--" + right
+	case left == "<":
+		op = "<" + line
+	case right == ">":
+		op = line + ">"
+	default:
+		op = line
+	}
+	return op, len(arrow), true
+}
+
+// isPlantUMLRelationshipStart reports whether the current line is a
+// relationship: a name, optionally followed by `::member` and a
+// multiplicity, and an arrow
+func (p *parser) isPlantUMLRelationshipStart() bool {
+	i := 1 + p.memberRefLength(1)
+	if p.peekAt(i).Kind == TokenString {
+		i++
+	}
+	t := p.peekAt(i)
+	if t.Kind == TokenNewline || t.Kind == TokenEOF {
+		return false
+	}
+	_, _, ok := p.plantUMLArrowAt(t.Span.Start.Offset)
+	return ok
+}
+
+// memberRefLength returns the number of tokens of a `::member` reference n
+// tokens ahead, or 0
+func (p *parser) memberRefLength(n int) int {
+	first, second, member := p.peekAt(n), p.peekAt(n+1), p.peekAt(n+2)
+	if first.Kind == TokenPunct && first.Text == ":" && second.Kind == TokenPunct && second.Text == ":" &&
+		second.Span.Start.Offset == first.Span.End.Offset &&
+		member.Kind == TokenIdent && member.Span.Start.Offset == second.Span.End.Offset {
+		return 3
+	}
+	return 0
+}
+
+// parsePlantUMLRelationship parses `A "1" *-- "many" B : label >`. Member
+// references such as `A::items` are shown on the class.
+func (p *parser) parsePlantUMLRelationship() *RelationshipDecl {
+	left := p.advance()
+	d := &RelationshipDecl{Span: left.Span, Left: Ident{Span: left.Span, Name: left.Text}}
+	for range p.memberRefLength(0) {
+		p.advance()
+	}
+
+	if t := p.peek(); t.Kind == TokenString {
+		p.advance()
+		p.checkTerminated(t, "multiplicity")
+		d.LeftMult = t.Value
+	}
+
+	start := p.peek().Span.Start.Offset
+	op, length, _ := p.plantUMLArrowAt(start)
+	d.Operator = op
+	for t := p.peek(); t.Kind != TokenEOF && t.Span.Start.Offset < start+length; t = p.peek() {
+		p.advance()
+	}
+
+	if t := p.peek(); t.Kind == TokenString {
+		p.advance()
+		p.checkTerminated(t, "multiplicity")
+		d.RightMult = t.Value
+	}
+
+	right, ok := p.expectIdent("relationship target")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Right = right
+	d.End = right.End
+	for range p.memberRefLength(0) {
+		p.advance()
+	}
+
+	if t := p.peek(); t.Kind == TokenPunct && t.Text == ":" {
+		p.advance()
+		label, span := p.restOfLine()
+		// `<` and `>` only show the reading direction of the label
+		label = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(label, ">"), "<"))
+		label = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(label, "<"), ">"))
+		d.Label = label
+		if label != "" {
+			d.End = span.End
+		}
+		return d
+	}
+	p.expectLineEnd()
+	return d
+}
+
+// blankPlantUMLComments replaces `'` line comments and `/' ... '/` block
+// comments with spaces, keeping line breaks so that positions are unchanged
+func blankPlantUMLComments(src []byte) []byte {
+	out := bytes.Clone(src)
+	inBlock := false
+	lineStart := true
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		switch {
+		case c == '\n':
+			lineStart = true
+			continue
+		case inBlock:
+			if c == '\'' && i+1 < len(out) && out[i+1] == '/' {
+				out[i], out[i+1] = ' ', ' '
+				i++
+				inBlock = false
+				continue
+			}
+			out[i] = ' '
+			continue
+		case c == '/' && i+1 < len(out) && out[i+1] == '\'':
+			out[i], out[i+1] = ' ', ' '
+			i++
+			inBlock = true
+			continue
+		case c == '\'' && lineStart:
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+			i--
+			continue
+		}
+		if c != ' ' && c != '\t' {
+			lineStart = false
+		}
+	}
+	return out
+}
+
+// ExtractPlantUMLBlocks returns the `@startuml ... @enduml` diagrams of a
+// file. Everything before a diagram is replaced by spaces, keeping line
+// breaks, so that positions in diagnostics refer to the file.
+func ExtractPlantUMLBlocks(src []byte) [][]byte {
+	var blocks [][]byte
+	offset := 0
+	start := -1
+	for _, line := range bytes.SplitAfter(src, []byte("\n")) {
+		trimmed := bytes.TrimSpace(line)
+		switch {
+		case start < 0 && bytes.HasPrefix(trimmed, []byte("@startuml")):
+			start = offset
+		case start >= 0 && bytes.HasPrefix(trimmed, []byte("@enduml")):
+			blocks = append(blocks, blankBefore(src[:offset+len(line)], start))
+			start = -1
+		}
+		offset += len(line)
+	}
+	if start >= 0 {
+		// The last diagram is missing @enduml
+		blocks = append(blocks, blankBefore(src, start))
+	}
+	return blocks
+}
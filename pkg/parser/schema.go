@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// schema is the subset of JSON Schema the Document schema is written in:
+// boolean schemas, $ref into $defs, type, enum, properties, required,
+// additionalProperties, items, minItems and maxItems
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Defs                 map[string]*schema `json:"$defs"`
+	Type                 string             `json:"type"`
+	Enum                 []string           `json:"enum"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+
+	never bool // the false schema, which no value matches
+}
+
+func (s *schema) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*s = schema{}
+		return nil
+	case "false":
+		*s = schema{never: true}
+		return nil
+	}
+	type plain schema
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+var documentSchema = mustLoadSchema(extuml.DocumentSchema)
+
+func mustLoadSchema(data []byte) *schema {
+	var s schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		panic(fmt.Sprintf("invalid document schema: %v", err))
+	}
+	return &s
+}
+
+// schemaValidator checks a document against the schema, reporting every
+// violation with the JSON path of the offending value
+type schemaValidator struct {
+	root  *schema
+	diags diagnostic.List
+}
+
+// validateDocument checks n against the Document schema
+func validateDocument(n *docNode) diagnostic.List {
+	v := &schemaValidator{root: documentSchema}
+	v.validate(v.root, n, "$")
+	return v.diags
+}
+
+// resolve follows $ref to the schema it names
+func (v *schemaValidator) resolve(s *schema) *schema {
+	for s.Ref != "" {
+		def, ok := v.root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if !ok {
+			panic(fmt.Sprintf("document schema: unresolved $ref %q", s.Ref))
+		}
+		s = def
+	}
+	return s
+}
+
+func (v *schemaValidator) report(n *docNode, path, format string, args ...any) {
+	v.diags.Add(n.span, diagnostic.CodeSchemaViolation, "%s: %s", path, fmt.Sprintf(format, args...))
+}
+
+func (v *schemaValidator) validate(s *schema, n *docNode, path string) {
+	s = v.resolve(s)
+	switch {
+	case s.never:
+		v.report(n, path, "no value is allowed here")
+		return
+	case s.Type != "" && !n.is(s.Type):
+		v.report(n, path, "expected %s, got %s", s.Type, n.kind)
+		return
+	case s.Enum != nil:
+		if value, ok := n.value.(string); !ok || !slices.Contains(s.Enum, value) {
+			v.report(n, path, "invalid value %s (expected %s)", n, oneOf(s.Enum))
+		}
+		return
+	}
+
+	switch n.kind {
+	case kindObject:
+		v.validateObject(s, n, path)
+	case kindArray:
+		if s.MinItems != nil && len(n.items) < *s.MinItems {
+			v.report(n, path, "expected at least %d items, got %d", *s.MinItems, len(n.items))
+		}
+		if s.MaxItems != nil && len(n.items) > *s.MaxItems {
+			v.report(n, path, "expected at most %d items, got %d", *s.MaxItems, len(n.items))
+		}
+		if s.Items != nil {
+			for i, item := range n.items {
+				v.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(s *schema, n *docNode, path string) {
+	seen := map[string]bool{}
+	for i, key := range n.keys {
+		name := key.value.(string)
+		keyPath := path + pathKey(name)
+		if seen[name] {
+			v.report(key, keyPath, "duplicate property")
+			continue
+		}
+		seen[name] = true
+
+		property, ok := s.Properties[name]
+		switch {
+		case ok:
+			v.validate(property, n.items[i], keyPath)
+		case s.AdditionalProperties == nil:
+			// Unlisted properties are allowed and unchecked
+		case v.resolve(s.AdditionalProperties).never:
+			v.report(key, keyPath, "unknown property")
+		default:
+			v.validate(s.AdditionalProperties, n.items[i], keyPath)
+		}
+	}
+	for _, name := range s.Required {
+		if !seen[name] {
+			v.report(n, path, "missing required property %q", name)
+		}
+	}
+}
+
+// pathKey returns the JSON path step selecting the property name
+func pathKey(name string) string {
+	for i, r := range name {
+		if !isIdentPart(r) || i == 0 && !isIdentStart(r) {
+			return fmt.Sprintf("[%q]", name)
+		}
+	}
+	if name == "" {
+		return `[""]`
+	}
+	return "." + name
+}
+
+// oneOf lists values as "a, b or c"
+func oneOf(values []string) string {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
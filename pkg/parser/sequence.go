@@ -0,0 +1,393 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+)
+
+// sequenceBlock is the kind of block a sequence statement appears in, which
+// decides the statements allowed there
+type sequenceBlock int
+
+const (
+	blockTop      sequenceBlock = iota // the top level of the file
+	blockZone                          // a zone, holding participants only
+	blockFragment                      // an operand of alt, loop or opt
+)
+
+// fragmentKeywords are the keywords opening a combined fragment
+var fragmentKeywords = map[string]bool{"alt": true, "loop": true, "opt": true}
+
+// parseSequenceDecls parses the statements of a sequence diagram up to the
+// end of file or, inside a block, up to its closing '}'. what describes the
+// block opened at open and is empty at the top level; end is the end of the
+// closing '}', which is consumed but not the rest of its line.
+func (p *parser) parseSequenceDecls(open Span, what string, block sequenceBlock) (decls []Decl, end Pos, closed bool) {
+	for {
+		p.skipBlank()
+		t := p.peek()
+		switch {
+		case t.Kind == TokenEOF:
+			if what != "" {
+				p.diags.Add(open, diagnostic.CodeUnterminatedBlock, "%s is not closed (missing '}')", what)
+			}
+			return decls, t.Span.Start, false
+		case t.Kind == TokenRBrace:
+			if what != "" {
+				p.advance()
+				return decls, t.Span.End, true
+			}
+			p.diags.Add(t.Span, diagnostic.CodeUnexpectedCloseBrace, "unexpected '}' without an open block")
+			p.skipLine()
+		default:
+			if d := p.parseSequenceDecl(block); d != nil {
+				decls = append(decls, d)
+			}
+		}
+	}
+}
+
+// parseSequenceDecl parses one statement of a sequence diagram
+func (p *parser) parseSequenceDecl(block sequenceBlock) Decl {
+	t := p.peek()
+	next := p.peekAt(1)
+	if t.Kind == TokenIdent && next.Kind == TokenArrow {
+		if block == blockZone {
+			return p.notInZone(t)
+		}
+		if d := p.parseMessage(); d != nil {
+			return d
+		}
+		return nil
+	}
+
+	if t.Kind == TokenIdent {
+		switch {
+		case (t.Text == "participant" || t.Text == "actor") && next.Kind == TokenIdent:
+			if block == blockFragment {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "%s must be declared at the top level or in a zone", t.Text)
+				p.skipLine()
+				return nil
+			}
+			if d := p.parseParticipant(); d != nil {
+				return d
+			}
+			return nil
+		case block == blockZone:
+			return p.notInZone(t)
+		case t.Text == "zone" && next.Kind == TokenIdent:
+			if block != blockTop {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "zones can only be declared at the top level")
+				p.skipLine()
+				return nil
+			}
+			if d := p.parseZone(); d != nil {
+				return d
+			}
+			return nil
+		case (t.Text == "activate" || t.Text == "deactivate") && next.Kind == TokenIdent:
+			return p.parseActivation()
+		case fragmentKeywords[t.Text]:
+			if d := p.parseFragment(); d != nil {
+				return d
+			}
+			return nil
+		case t.Text == "else":
+			p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "'else' must follow the '}' of an alt block")
+			// Parse the block anyway so that its '}' is not reported as well
+			p.parseOperand("else block")
+			return nil
+		case t.Text == "note":
+			if d := p.parseSequenceNote(); d != nil {
+				return d
+			}
+			return nil
+		case (t.Text == "include" || t.Text == "import") && p.isIncludeStart():
+			if block != blockTop {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "%s is only allowed at the top level", t.Text)
+				p.skipLine()
+				return nil
+			}
+			if d := p.parseInclude(); d != nil {
+				return d
+			}
+			return nil
+		case (t.Text == "classDef" || t.Text == "style" || t.Text == "cssClass") && p.isStyleStart():
+			if d := p.parseStyleDecl(); d != nil {
+				return d
+			}
+			return nil
+		case block == blockTop && metaKeys[t.Text] && next.Kind == TokenPunct && next.Text == ":":
+			p.parseMetaLine()
+			return nil
+		}
+	}
+
+	p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "unexpected %s; expected a participant, message or fragment", t.describe())
+	p.skipLine()
+	return nil
+}
+
+// notInZone reports a statement other than a participant inside a zone
+func (p *parser) notInZone(t Token) Decl {
+	p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "only participants and actors can be declared in a zone")
+	p.skipLine()
+	return nil
+}
+
+// parseParticipant parses `participant Name`, `actor Name` with an optional
+// `as "Display Name"` and `:::class`
+func (p *parser) parseParticipant() *ParticipantDecl {
+	kw := p.advance()
+	d := &ParticipantDecl{Span: kw.Span, Kind: kw.Text}
+	name, ok := p.expectName(kw.Text + " name")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Name = name
+	d.End = name.End
+
+	for {
+		if t := p.peek(); t.Kind == TokenIdent && t.Text == "as" && d.Alias == "" {
+			alias, end, ok := p.parseDisplayName()
+			if !ok {
+				p.skipLine()
+				return d
+			}
+			d.Alias, d.End = alias, end
+		} else if p.isClassShorthand() {
+			class := p.parseClassShorthand()
+			d.Classes = append(d.Classes, class)
+			d.End = class.End
+		} else {
+			break
+		}
+	}
+	p.expectLineEnd()
+	return d
+}
+
+// parseDisplayName parses `as "Display Name"`, or `as Name` as Mermaid
+// writes it
+func (p *parser) parseDisplayName() (name string, end Pos, ok bool) {
+	p.advance()
+	t := p.peek()
+	switch t.Kind {
+	case TokenString:
+		p.advance()
+		p.checkTerminated(t, "display name")
+		return t.Value, t.Span.End, true
+	case TokenIdent:
+		p.advance()
+		return t.Text, t.Span.End, true
+	}
+	p.diags.Add(t.Span, diagnostic.CodeExpected, "expected a display name after 'as', found %s", t.describe())
+	return "", Pos{}, false
+}
+
+// parseZone parses a `zone name { ... }` block of participants
+func (p *parser) parseZone() *ZoneDecl {
+	kw := p.advance()
+	d := &ZoneDecl{Span: kw.Span}
+	name, ok := p.expectName("zone name")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Name = name
+	d.End = name.End
+	if t := p.peek(); t.Kind == TokenIdent && t.Text == "as" {
+		alias, end, ok := p.parseDisplayName()
+		if !ok {
+			p.skipLine()
+			return nil
+		}
+		d.Alias, d.End = alias, end
+	}
+
+	if t := p.peek(); t.Kind != TokenLBrace {
+		p.diags.Add(t.Span, diagnostic.CodeExpected, "expected '{' after zone name, found %s", t.describe())
+		p.skipLine()
+		return d
+	}
+	p.advance()
+	p.expectLineEnd()
+
+	var closed bool
+	d.Decls, d.End, closed = p.parseSequenceDecls(d.Span, fmt.Sprintf("zone %q", name.Name), blockZone)
+	if closed {
+		p.expectLineEnd()
+	}
+	return d
+}
+
+// parseMessage parses `From ->> [+|-]To [: label]`
+func (p *parser) parseMessage() *MessageDecl {
+	from := p.advance()
+	d := &MessageDecl{Span: from.Span, From: Ident{Span: from.Span, Name: from.Text}}
+
+	op := p.advance()
+	d.Operator = op.Text
+	if _, ok := messageOperators[op.Text]; !ok {
+		p.diags.Add(op.Span, diagnostic.CodeUnexpectedToken,
+			"relationship operator %q cannot be used in a sequence diagram (expected ->>, ->, -), -->>, --> or --))", op.Text)
+		p.skipLine()
+		return nil
+	}
+
+	if t := p.peek(); t.Kind == TokenPunct && (t.Text == "+" || t.Text == "-") {
+		p.advance()
+		d.Activate = t.Text == "+"
+		d.Deactivate = t.Text == "-"
+	}
+	to, ok := p.expectIdent("message receiver")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.To = to
+	d.End = to.End
+
+	if t := p.peek(); t.Kind == TokenPunct && t.Text == ":" {
+		p.advance()
+		label, span := p.restOfLine()
+		d.Label = label
+		if label != "" {
+			d.End = span.End
+		}
+		return d
+	}
+	p.expectLineEnd()
+	return d
+}
+
+// parseActivation parses `activate Name` or `deactivate Name`
+func (p *parser) parseActivation() *ActivationDecl {
+	kw := p.advance()
+	target := p.advance()
+	d := &ActivationDecl{
+		Span:     Span{Start: kw.Span.Start, End: target.Span.End},
+		Activate: kw.Text == "activate",
+		Target:   Ident{Span: target.Span, Name: target.Text},
+	}
+	p.expectLineEnd()
+	return d
+}
+
+// parseFragment parses an `alt`, `loop` or `opt` block and, for alt, the
+// `else` blocks following it
+func (p *parser) parseFragment() *FragmentDecl {
+	kw := p.peek()
+	d := &FragmentDecl{Span: kw.Span, Kind: kw.Text}
+	for {
+		operand, closed := p.parseOperand(kw.Text + " block")
+		if operand == nil {
+			return nil
+		}
+		d.Operands = append(d.Operands, operand)
+		d.End = operand.End
+		if !closed {
+			return d
+		}
+
+		// `} else guard {` continues the block, as may an `else` on the
+		// next line
+		if t := p.peek(); t.Kind != TokenIdent || t.Text != "else" {
+			p.expectLineEnd()
+			p.skipBlank()
+			if t := p.peek(); t.Kind != TokenIdent || t.Text != "else" || p.peekAt(1).Kind == TokenArrow {
+				return d
+			}
+		}
+		if t := p.peek(); d.Kind != "alt" {
+			p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "'else' can only continue an alt block, not %s", d.Kind)
+		}
+	}
+}
+
+// parseOperand parses `keyword guard {`, the statements of the block and
+// its closing '}'. closed is false if the block is not terminated.
+func (p *parser) parseOperand(what string) (o *Operand, closed bool) {
+	kw := p.advance()
+	o = &Operand{Span: kw.Span}
+
+	// The guard is the text up to the '{' ending the line
+	var line []Token
+	for t := p.peek(); t.Kind != TokenNewline && t.Kind != TokenEOF; t = p.peek() {
+		line = append(line, p.advance())
+	}
+	if len(line) == 0 || line[len(line)-1].Kind != TokenLBrace {
+		p.diags.Add(p.peek().Span, diagnostic.CodeExpected, "expected '{' at the end of the %s line", kw.Text)
+		p.skipLine()
+		return nil, false
+	}
+	p.skipLine()
+	brace := line[len(line)-1]
+	o.End = brace.Span.End
+	switch guard := line[:len(line)-1]; {
+	case len(guard) == 1 && guard[0].Kind == TokenString:
+		// A quoted guard may hold braces
+		p.checkTerminated(guard[0], "guard")
+		o.Guard = guard[0].Value
+	case len(guard) > 0:
+		o.Guard = strings.TrimSpace(string(p.src[guard[0].Span.Start.Offset:brace.Span.Start.Offset]))
+	}
+
+	o.Decls, o.End, closed = p.parseSequenceDecls(kw.Span, what, blockFragment)
+	return o, closed
+}
+
+// parseSequenceNote parses `note over A[, B] "text"`, `note left of A
+// "text"` or `note right of A "text"`
+func (p *parser) parseSequenceNote() *SequenceNoteDecl {
+	kw := p.advance()
+	d := &SequenceNoteDecl{Span: kw.Span}
+
+	switch t := p.peek(); {
+	case t.Kind == TokenIdent && t.Text == "over":
+		p.advance()
+		d.Position = t.Text
+	case t.Kind == TokenIdent && (t.Text == "left" || t.Text == "right"):
+		p.advance()
+		d.Position = t.Text
+		if of := p.peek(); of.Kind != TokenIdent || of.Text != "of" {
+			p.diags.Add(of.Span, diagnostic.CodeExpected, "expected 'of' after '%s', found %s", t.Text, of.describe())
+			p.skipLine()
+			return nil
+		}
+		p.advance()
+	default:
+		p.diags.Add(t.Span, diagnostic.CodeExpected, "expected 'over', 'left of' or 'right of' after note, found %s", t.describe())
+		p.skipLine()
+		return nil
+	}
+
+	for {
+		target, ok := p.expectIdent("participant")
+		if !ok {
+			p.skipLine()
+			return nil
+		}
+		d.Targets = append(d.Targets, target)
+		if t := p.peek(); t.Kind != TokenPunct || t.Text != "," {
+			break
+		}
+		p.advance()
+	}
+	if len(d.Targets) > 1 && d.Position != "over" {
+		p.diags.Add(d.Targets[1].Span, diagnostic.CodeUnexpectedToken, "a note %s of a participant names only one participant", d.Position)
+	}
+
+	text, ok := p.expectString("note text")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Text = text.Value
+	d.End = text.Span.End
+	p.expectLineEnd()
+	return d
+}
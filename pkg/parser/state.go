@@ -0,0 +1,249 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+)
+
+// pseudoState is the name written for the initial and final pseudo-states
+const pseudoState = "[*]"
+
+// stateKinds are the stereotypes that turn a state into a pseudo-state
+var stateKinds = map[string]bool{"choice": true, "fork": true, "join": true}
+
+// parseStateDecls parses the statements of a state diagram up to the end of
+// file or, inside a composite state, up to its closing '}' or a `--` line
+// starting its next region. what describes the block opened at open and is
+// empty at the top level; end is the end of the closing '}', or the start of
+// the `--` line, which is consumed but not the rest of its line.
+func (p *parser) parseStateDecls(open Span, what string) (decls []Decl, end Pos, closed, separated bool) {
+	for {
+		p.skipBlank()
+		t := p.peek()
+		switch {
+		case t.Kind == TokenEOF:
+			if what != "" {
+				p.diags.Add(open, diagnostic.CodeUnterminatedBlock, "%s is not closed (missing '}')", what)
+			}
+			return decls, t.Span.Start, false, false
+		case t.Kind == TokenRBrace:
+			if what != "" {
+				p.advance()
+				return decls, t.Span.End, true, false
+			}
+			p.diags.Add(t.Span, diagnostic.CodeUnexpectedCloseBrace, "unexpected '}' without an open block")
+			p.skipLine()
+		case p.isRegionSeparator():
+			if what != "" {
+				p.skipLine()
+				return decls, t.Span.Start, false, true
+			}
+			p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "'--' separates the regions of a composite state and cannot be used at the top level")
+			p.skipLine()
+		default:
+			if d := p.parseStateDecl(what == ""); d != nil {
+				decls = append(decls, d)
+			}
+		}
+	}
+}
+
+// isRegionSeparator reports whether the line is a lone `--`
+func (p *parser) isRegionSeparator() bool {
+	t, next := p.peek(), p.peekAt(1)
+	return t.Kind == TokenArrow && t.Text == "--" && (next.Kind == TokenNewline || next.Kind == TokenEOF)
+}
+
+// parseStateDecl parses one statement of a state diagram; top reports
+// whether it is at the top level of the file
+func (p *parser) parseStateDecl(top bool) Decl {
+	t := p.peek()
+	if n := p.endpointLength(0); n > 0 && p.peekAt(n).Kind == TokenArrow {
+		if d := p.parseTransition(); d != nil {
+			return d
+		}
+		return nil
+	}
+
+	if t.Kind == TokenIdent {
+		next := p.peekAt(1)
+		switch {
+		case t.Text == "state" && (next.Kind == TokenIdent || next.Kind == TokenString):
+			if d := p.parseState(); d != nil {
+				return d
+			}
+			return nil
+		case t.Text == "note":
+			if d := p.parseNote(); d != nil {
+				return d
+			}
+			return nil
+		case (t.Text == "include" || t.Text == "import") && p.isIncludeStart():
+			if !top {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "%s is only allowed at the top level", t.Text)
+				p.skipLine()
+				return nil
+			}
+			if d := p.parseInclude(); d != nil {
+				return d
+			}
+			return nil
+		case (t.Text == "classDef" || t.Text == "style" || t.Text == "cssClass") && p.isStyleStart():
+			if d := p.parseStyleDecl(); d != nil {
+				return d
+			}
+			return nil
+		case top && metaKeys[t.Text] && next.Kind == TokenPunct && next.Text == ":":
+			p.parseMetaLine()
+			return nil
+		}
+	}
+
+	p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "unexpected %s; expected a state or transition", t.describe())
+	p.skipLine()
+	return nil
+}
+
+// endpointLength returns the number of tokens of the transition endpoint n
+// tokens ahead: one for a state name, three for `[*]` and zero if there is
+// none
+func (p *parser) endpointLength(n int) int {
+	t := p.peekAt(n)
+	if t.Kind == TokenIdent {
+		return 1
+	}
+	star, close := p.peekAt(n+1), p.peekAt(n+2)
+	if t.Kind == TokenPunct && t.Text == "[" &&
+		star.Kind == TokenPunct && star.Text == "*" && star.Span.Start.Offset == t.Span.End.Offset &&
+		close.Kind == TokenPunct && close.Text == "]" && close.Span.Start.Offset == star.Span.End.Offset {
+		return 3
+	}
+	return 0
+}
+
+// parseEndpoint parses a state name or `[*]` at one end of a transition
+func (p *parser) parseEndpoint(what string) (Ident, bool) {
+	switch p.endpointLength(0) {
+	case 1:
+		return p.expectIdent(what)
+	case 3:
+		open := p.advance()
+		p.advance()
+		close := p.advance()
+		return Ident{Span: Span{Start: open.Span.Start, End: close.Span.End}, Name: pseudoState}, true
+	}
+	t := p.peek()
+	p.diags.Add(t.Span, diagnostic.CodeExpected, "expected %s or [*], found %s", what, t.describe())
+	return Ident{}, false
+}
+
+// parseTransition parses `From --> To [: label]`
+func (p *parser) parseTransition() *TransitionDecl {
+	from, _ := p.parseEndpoint("source state")
+	d := &TransitionDecl{Span: from.Span, From: from}
+
+	if op := p.advance(); op.Text != "-->" {
+		p.diags.Add(op.Span, diagnostic.CodeUnexpectedToken,
+			"relationship operator %q cannot be used in a state diagram (expected -->)", op.Text)
+		p.skipLine()
+		return nil
+	}
+	to, ok := p.parseEndpoint("target state")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.To = to
+	d.End = to.End
+
+	if t := p.peek(); t.Kind == TokenPunct && t.Text == ":" {
+		p.advance()
+		label, span := p.restOfLine()
+		d.Label = label
+		if label != "" {
+			d.End = span.End
+		}
+		return d
+	}
+	p.expectLineEnd()
+	return d
+}
+
+// parseState parses `state Name [as "Display Name"]` or `state "Display
+// Name" as Name`, followed by an optional `<<choice>>`, `<<fork>>` or
+// `<<join>>`, `:::class` and a body of regions separated by `--` lines
+func (p *parser) parseState() *StateDecl {
+	kw := p.advance()
+	d := &StateDecl{Span: kw.Span}
+	if t := p.peek(); t.Kind == TokenString {
+		// Mermaid's `state "Display Name" as Name`
+		p.advance()
+		p.checkTerminated(t, "display name")
+		if as := p.peek(); as.Kind != TokenIdent || as.Text != "as" {
+			p.diags.Add(as.Span, diagnostic.CodeExpected, "expected 'as' after the display name, found %s", as.describe())
+			p.skipLine()
+			return nil
+		}
+		p.advance()
+		d.Alias = t.Value
+	}
+	name, ok := p.expectName("state name")
+	if !ok {
+		p.skipLine()
+		return nil
+	}
+	d.Name = name
+	d.End = name.End
+
+	for {
+		t := p.peek()
+		if t.Kind == TokenIdent && t.Text == "as" && d.Alias == "" {
+			alias, end, ok := p.parseDisplayName()
+			if !ok {
+				p.skipLine()
+				return d
+			}
+			d.Alias, d.End = alias, end
+		} else if t.Kind == TokenStereotype && d.Kind == "" {
+			p.advance()
+			if !stateKinds[t.Value] {
+				p.diags.Add(t.Span, diagnostic.CodeUnexpectedToken, "unknown state kind <<%s>> (expected choice, fork or join)", t.Value)
+			} else {
+				d.Kind = t.Value
+			}
+			d.End = t.Span.End
+		} else if p.isClassShorthand() {
+			class := p.parseClassShorthand()
+			d.Classes = append(d.Classes, class)
+			d.End = class.End
+		} else {
+			break
+		}
+	}
+
+	if t := p.peek(); t.Kind != TokenLBrace {
+		p.expectLineEnd()
+		return d
+	}
+	brace := p.advance()
+	if d.Kind != "" {
+		p.diags.Add(brace.Span, diagnostic.CodeUnexpectedToken, "a %s state cannot have a body", d.Kind)
+	}
+	p.expectLineEnd()
+
+	what := fmt.Sprintf("state %q", name.Name)
+	start := brace.Span.End
+	for {
+		decls, end, closed, separated := p.parseStateDecls(d.Span, what)
+		d.Regions = append(d.Regions, &Region{Span: Span{Start: start, End: end}, Decls: decls})
+		d.End = end
+		if closed {
+			p.expectLineEnd()
+		}
+		if !separated {
+			return d
+		}
+		start = end
+	}
+}
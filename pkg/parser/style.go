@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+)
+
+// isStyleStart reports whether the current line is a `classDef`, `style` or
+// `cssClass` statement rather than a relationship from a class of that name
+func (p *parser) isStyleStart() bool {
+	next := p.peekAt(1)
+	if p.peek().Text == "cssClass" {
+		return next.Kind == TokenString
+	}
+	return next.Kind == TokenIdent && p.peekAt(2).Kind != TokenArrow
+}
+
+// parseStyleDecl parses a `classDef`, `style` or `cssClass` statement
+func (p *parser) parseStyleDecl() Decl {
+	kw := p.advance()
+	switch kw.Text {
+	case "classDef":
+		d := &ClassDefDecl{Span: kw.Span}
+		for {
+			name, ok := p.expectIdent("classDef name")
+			if !ok {
+				p.skipLine()
+				return nil
+			}
+			d.Names = append(d.Names, name)
+			if t := p.peek(); t.Kind != TokenPunct || t.Text != "," {
+				break
+			}
+			p.advance()
+		}
+		d.Props, d.End = p.parseStyleProps(d.End)
+		return d
+	case "style":
+		target, ok := p.expectIdent("element to style")
+		if !ok {
+			p.skipLine()
+			return nil
+		}
+		d := &StyleDecl{Span: kw.Span, Target: target}
+		d.Props, d.End = p.parseStyleProps(target.End)
+		return d
+	default:
+		targets, ok := p.expectString("element list")
+		if !ok {
+			p.skipLine()
+			return nil
+		}
+		d := &CSSClassDecl{Span: kw.Span, Targets: splitIdents(targets)}
+		class, ok := p.expectIdent("classDef name")
+		if !ok {
+			p.skipLine()
+			return nil
+		}
+		d.Class = class
+		d.End = class.End
+		p.expectLineEnd()
+		return d
+	}
+}
+
+// parseStyleProps parses the rest of the line as comma separated
+// `name:value` pairs and returns them with the end of the statement
+func (p *parser) parseStyleProps(end Pos) ([]*StyleProp, Pos) {
+	text, span := p.restOfLine()
+	if text == "" {
+		p.diags.Add(span, diagnostic.CodeExpected, "expected style properties such as fill:#f66,stroke:#900")
+		return nil, end
+	}
+
+	var props []*StyleProp
+	offset := 0
+	for _, part := range splitTopLevel(text, ',') {
+		start := offset + len(part) - len(strings.TrimLeft(part, " \t"))
+		offset += len(part) + 1
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		propSpan := Span{Start: shiftPos(span.Start, start), End: shiftPos(span.Start, start+len(part))}
+		name, value, ok := strings.Cut(part, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || value == "" {
+			p.diags.Add(propSpan, diagnostic.CodeExpected, "expected name:value in style, found %q", part)
+			continue
+		}
+		props = append(props, &StyleProp{Span: propSpan, Name: name, Value: value})
+	}
+	return props, span.End
+}
+
+// isClassShorthand reports whether the next tokens are `:::name`
+func (p *parser) isClassShorthand() bool {
+	for i := 0; i < 3; i++ {
+		t := p.peekAt(i)
+		if t.Kind != TokenPunct || t.Text != ":" || (i > 0 && t.Span.Start.Offset != p.peekAt(i-1).Span.End.Offset) {
+			return false
+		}
+	}
+	return p.peekAt(3).Kind == TokenIdent
+}
+
+// parseClassShorthand parses `:::name` after a classifier or participant
+// name and returns the name of the classDef
+func (p *parser) parseClassShorthand() Ident {
+	for i := 0; i < 3; i++ {
+		p.advance()
+	}
+	name := p.advance()
+	return Ident{Span: name.Span, Name: name.Text}
+}
+
+// splitIdents splits the comma separated names in a string token
+func splitIdents(t Token) []Ident {
+	var idents []Ident
+	offset := 1 // opening quote
+	for _, part := range strings.Split(t.Value, ",") {
+		start := offset + len(part) - len(strings.TrimLeft(part, " \t"))
+		offset += len(part) + 1
+		if name := strings.TrimSpace(part); name != "" {
+			idents = append(idents, Ident{
+				Span: Span{Start: shiftPos(t.Span.Start, start), End: shiftPos(t.Span.Start, start+len(name))},
+				Name: name,
+			})
+		}
+	}
+	return idents
+}
+
+// shiftPos returns pos moved n bytes to the right on the same line
+func shiftPos(pos Pos, n int) Pos {
+	pos.Offset += n
+	pos.Column += n
+	return pos
+}
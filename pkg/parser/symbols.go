@@ -0,0 +1,39 @@
+package parser
+
+// Symbols lists the elements declared in a lowered file, including the
+// files it includes, and every use of their names. The elements are the
+// classifiers of class diagrams, and the participants, states, entities,
+// components, nodes, artifacts, ports and activity nodes of the others.
+type Symbols struct {
+	Declarations []Declaration
+	References   []Reference
+}
+
+// Declaration is an element declaration with the ID lowering gave it. Decl
+// is the declaration Name belongs to, such as a *ClassifierDecl, or nil if
+// the element is declared implicitly by its first use, which Name is then.
+type Declaration struct {
+	ID   string
+	Kind string // the keyword of the declaration, such as "class" or "participant"
+	Name Ident
+	Decl Decl
+}
+
+// Reference is an element name used by a relationship, message, transition,
+// connector, flow, note anchor, style statement or @near annotation. ID is
+// the element it resolves to, or empty if the name is undeclared or
+// ambiguous.
+type Reference struct {
+	Name Ident
+	ID   string
+}
+
+// Lookup returns the declaration with the given ID
+func (s *Symbols) Lookup(id string) (Declaration, bool) {
+	for _, d := range s.Declarations {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return Declaration{}, false
+}
@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+)
+
+// Pos is a position in a source file
+type Pos = diagnostic.Position
+
+// Span is the half-open source range [Start, End)
+type Span = diagnostic.Span
+
+// TokenKind identifies the lexical class of a token
+type TokenKind int
+
+const (
+	TokenEOF         TokenKind = iota
+	TokenNewline               // end of a line
+	TokenComment               // `%%` comment running to the end of the line
+	TokenIdent                 // identifier, possibly qualified (`billing.Invoice`)
+	TokenNumber                // decimal number
+	TokenString                // double-quoted string
+	TokenArrow                 // relationship operator such as `<|--` or `..>`, or message arrow such as `->>`
+	TokenLBrace                // {
+	TokenRBrace                // }
+	TokenAnnotation            // `@name`
+	TokenPunct                 // any other single character
+	TokenFrontMatter           // `---` delimited block at the start of the file
+	TokenDirective             // `%%{ ... }%%` block
+	TokenStereotype            // `<<name>>`
+)
+
+var tokenKindNames = map[TokenKind]string{
+	TokenEOF:         "end of file",
+	TokenNewline:     "end of line",
+	TokenComment:     "comment",
+	TokenIdent:       "identifier",
+	TokenNumber:      "number",
+	TokenString:      "string",
+	TokenArrow:       "relationship operator",
+	TokenLBrace:      "'{'",
+	TokenRBrace:      "'}'",
+	TokenAnnotation:  "annotation",
+	TokenPunct:       "punctuation",
+	TokenFrontMatter: "front matter",
+	TokenDirective:   "directive",
+	TokenStereotype:  "stereotype",
+}
+
+func (k TokenKind) String() string {
+	return tokenKindNames[k]
+}
+
+// Token is a lexical token. Text is the raw source text; for strings Value
+// holds the unquoted contents, and for front matter and directives the text
+// between the delimiters.
+type Token struct {
+	Kind         TokenKind
+	Text         string
+	Value        string
+	Span         Span
+	Unterminated bool // string, front matter or directive without its closing delimiter
+}
+
+// describe returns a short human readable description for error messages
+func (t Token) describe() string {
+	switch t.Kind {
+	case TokenEOF, TokenNewline:
+		return t.Kind.String()
+	default:
+		return fmt.Sprintf("%q", t.Text)
+	}
+}
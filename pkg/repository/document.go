@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// DocumentRepository defines interface for loading documents written as JSON
+// or YAML in the form of extuml.Document and validated against its schema.
+// A YAML stream yields one document per `---` separated document. As with
+// ExtumlRepository, the error is non-nil if the file could not be read or
+// has errors.
+type DocumentRepository interface {
+	LoadJSON(path string) ([]*extuml.Document, diagnostic.List, error)
+	LoadYAML(path string) ([]*extuml.Document, diagnostic.List, error)
+}
+
+type documentRepositoryImpl struct{}
+
+// NewDocumentRepository creates a new JSON/YAML document repository
+func NewDocumentRepository() DocumentRepository {
+	return &documentRepositoryImpl{}
+}
+
+func (r *documentRepositoryImpl) LoadJSON(path string) ([]*extuml.Document, diagnostic.List, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read json: %w", err)
+	}
+	doc, diags := parser.ParseJSONDocument(path, src)
+	return finishDocuments([]*extuml.Document{doc}, diags, path, src)
+}
+
+func (r *documentRepositoryImpl) LoadYAML(path string) ([]*extuml.Document, diagnostic.List, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read yaml: %w", err)
+	}
+	docs, diags := parser.ParseYAMLDocuments(path, src)
+	return finishDocuments(docs, diags, path, src)
+}
+
+// finishDocuments sorts the diagnostics of the documents read from path and
+// fails if there are errors
+func finishDocuments(docs []*extuml.Document, diags diagnostic.List, path string, src []byte) ([]*extuml.Document, diagnostic.List, error) {
+	diags.Sort()
+	diags.AttachSource(path, src)
+
+	if err := diags.Err(); err != nil {
+		return nil, diags, err
+	}
+	return docs, diags, nil
+}
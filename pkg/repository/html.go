@@ -6,14 +6,17 @@ import (
 	"html/template"
 	"os"
 	"path/filepath"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
 )
 
 //go:embed template/*.tmpl
 var templateFS embed.FS
 
-// HTMLRepository defines interface for writing HTML viewer output
+// HTMLRepository defines interface for writing HTML viewer output. The
+// diagram's meta, if any, is shown in the viewer header.
 type HTMLRepository interface {
-	Write(path string, gltfPath string) error
+	Write(path string, gltfPath string, meta *extuml.Meta) error
 }
 
 type htmlRepositoryImpl struct {
@@ -34,9 +37,12 @@ func NewHTMLRepository() (HTMLRepository, error) {
 
 type viewerData struct {
 	GLTFPath string
+	Title    string
+	Author   string
+	ID       string
 }
 
-func (r *htmlRepositoryImpl) Write(path string, gltfPath string) error {
+func (r *htmlRepositoryImpl) Write(path string, gltfPath string, meta *extuml.Meta) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return fmt.Errorf("create output dir: %w", err)
 	}
@@ -58,6 +64,11 @@ func (r *htmlRepositoryImpl) Write(path string, gltfPath string) error {
 	data := viewerData{
 		GLTFPath: relPath,
 	}
+	if meta != nil {
+		data.Title = meta.Title
+		data.Author = meta.Author
+		data.ID = meta.ID
+	}
 
 	if err := r.tmpl.Execute(f, data); err != nil {
 		return fmt.Errorf("execute template: %w", err)
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// MermaidRepository defines interface for importing Mermaid class diagrams.
+// Load returns one document per diagram: every ```mermaid classDiagram block
+// of a Markdown file, or the whole file otherwise. As with ExtumlRepository,
+// the error is non-nil if the file could not be read or has errors.
+type MermaidRepository interface {
+	Load(path string) ([]*extuml.Document, diagnostic.List, error)
+}
+
+type mermaidRepositoryImpl struct{}
+
+// NewMermaidRepository creates a new Mermaid repository
+func NewMermaidRepository() MermaidRepository {
+	return &mermaidRepositoryImpl{}
+}
+
+func (r *mermaidRepositoryImpl) Load(path string) ([]*extuml.Document, diagnostic.List, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read mermaid: %w", err)
+	}
+
+	sources := [][]byte{src}
+	if isMarkdown(path) {
+		sources = parser.ExtractMermaidBlocks(src)
+		if len(sources) == 0 {
+			return nil, nil, fmt.Errorf("no ```mermaid classDiagram blocks found in %s", path)
+		}
+	}
+
+	var (
+		docs  []*extuml.Document
+		diags diagnostic.List
+	)
+	for _, source := range sources {
+		file, parseDiags := parser.ParseMermaid(path, source)
+		doc, lowerDiags := parser.Lower(file)
+		diags = append(diags, parseDiags...)
+		diags = append(diags, lowerDiags...)
+		docs = append(docs, doc)
+	}
+	diags.Sort()
+	diags.AttachSource(path, src)
+
+	if err := diags.Err(); err != nil {
+		return nil, diags, err
+	}
+	return docs, diags, nil
+}
+
+// isMarkdown reports whether path names a Markdown document
+func isMarkdown(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+// PlantUMLRepository defines interface for importing PlantUML class
+// diagrams. Load returns one document per `@startuml ... @enduml` block of
+// the file. As with ExtumlRepository, the error is non-nil if the file could
+// not be read or has errors.
+type PlantUMLRepository interface {
+	Load(path string) ([]*extuml.Document, diagnostic.List, error)
+}
+
+type plantUMLRepositoryImpl struct{}
+
+// NewPlantUMLRepository creates a new PlantUML repository
+func NewPlantUMLRepository() PlantUMLRepository {
+	return &plantUMLRepositoryImpl{}
+}
+
+func (r *plantUMLRepositoryImpl) Load(path string) ([]*extuml.Document, diagnostic.List, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read plantuml: %w", err)
+	}
+
+	// Without any @startuml the whole file is parsed, reporting the header
+	sources := parser.ExtractPlantUMLBlocks(src)
+	if len(sources) == 0 {
+		sources = [][]byte{src}
+	}
+
+	var (
+		docs  []*extuml.Document
+		diags diagnostic.List
+	)
+	for _, source := range sources {
+		file, parseDiags := parser.ParsePlantUML(path, source)
+		doc, lowerDiags := parser.Lower(file)
+		diags = append(diags, parseDiags...)
+		diags = append(diags, lowerDiags...)
+		docs = append(docs, doc)
+	}
+	diags.Sort()
+	diags.AttachSource(path, src)
+
+	if err := diags.Err(); err != nil {
+		return nil, diags, err
+	}
+	return docs, diags, nil
+}
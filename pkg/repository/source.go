@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Stdout is the path that writes to standard output, or reads from
+// standard input
+const Stdout = "-"
+
+// SourceRepository defines interface for reading and writing diagram source.
+// List expands a directory into the .extuml files below it, skipping hidden
+// directories; any other path is returned as is.
+type SourceRepository interface {
+	Read(path string) ([]byte, error)
+	Write(path string, src []byte) error
+	List(path string) ([]string, error)
+}
+
+type sourceRepositoryImpl struct{}
+
+// NewSourceRepository creates a new diagram source repository
+func NewSourceRepository() SourceRepository {
+	return &sourceRepositoryImpl{}
+}
+
+func (r *sourceRepositoryImpl) Read(path string) ([]byte, error) {
+	var (
+		src []byte
+		err error
+	)
+	if path == Stdout {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read source: %w", err)
+	}
+	return src, nil
+}
+
+func (r *sourceRepositoryImpl) Write(path string, src []byte) error {
+	if path == Stdout {
+		if _, err := os.Stdout.Write(src); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+
+	if err := os.WriteFile(path, src, 0o644); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+
+	return nil
+}
+
+func (r *sourceRepositoryImpl) List(path string) ([]string, error) {
+	if path == Stdout {
+		return []string{path}, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat source: %w", err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var paths []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(p), ".extuml") {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list sources: %w", err)
+	}
+	return paths, nil
+}
@@ -0,0 +1,299 @@
+package usecase
+
+import (
+	"math"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+)
+
+// Activity diagram layout constants
+const (
+	activityRowGap    = 1.4 // Y distance between ranks
+	activityColumnGap = 1.2 // X distance between nodes of a rank
+	activityLaneGap   = 4.0 // Z distance between partition planes
+)
+
+// activityLayout places the nodes of an activity. Nodes are ranked
+// downwards by their distance from the start nodes, every partition is an
+// upright plane of its own, one behind the other, and the nodes of a rank
+// spread along X within their plane, so that flows between partitions cross
+// from plane to plane.
+type activityLayout struct {
+	plane    map[string]int // plane index of each partition, 0 for the nodes in none
+	position map[string][3]float64
+	size     map[string][3]float64
+
+	// Extent of the nodes in X and Y, shared by every plane
+	minB, maxB [2]float64
+}
+
+func newActivityLayout(act *extuml.Activity, geomGen *GeometryGenerator) *activityLayout {
+	l := &activityLayout{
+		plane:    make(map[string]int),
+		position: make(map[string][3]float64, len(act.Nodes)),
+		size:     make(map[string][3]float64, len(act.Nodes)),
+		minB:     [2]float64{math.Inf(1), math.Inf(1)},
+		maxB:     [2]float64{math.Inf(-1), math.Inf(-1)},
+	}
+	// The nodes in no partition, if any, come first
+	planes := 0
+	for _, n := range act.Nodes {
+		if n.Partition == "" {
+			planes = 1
+			break
+		}
+	}
+	for i, p := range act.Partitions {
+		l.plane[p.ID] = planes + i
+	}
+
+	rank := rankActivity(act)
+	type cell struct{ plane, rank int }
+	cells := make(map[cell][]string)
+	var order []cell
+	for _, n := range act.Nodes {
+		w, h, d := geomGen.ActivityNodeSize(n)
+		l.size[n.ID] = [3]float64{w, h, d}
+		c := cell{l.plane[n.Partition], rank[n.ID]}
+		if _, seen := cells[c]; !seen {
+			order = append(order, c)
+		}
+		cells[c] = append(cells[c], n.ID)
+	}
+
+	// The nodes of a cell spread along X around the axis of the diagram
+	for _, c := range order {
+		ids := cells[c]
+		width := float64(len(ids)-1) * activityColumnGap
+		for _, id := range ids {
+			width += l.size[id][0]
+		}
+		x := -width / 2
+		for _, id := range ids {
+			s := l.size[id]
+			p := [3]float64{x + s[0]/2, -float64(c.rank) * (stateHeight + activityRowGap), -float64(c.plane) * activityLaneGap}
+			l.position[id] = p
+			x += s[0] + activityColumnGap
+			for axis := 0; axis < 2; axis++ {
+				l.minB[axis] = math.Min(l.minB[axis], p[axis]-s[axis]/2)
+				l.maxB[axis] = math.Max(l.maxB[axis], p[axis]+s[axis]/2)
+			}
+		}
+	}
+	if len(act.Nodes) == 0 {
+		l.minB, l.maxB = [2]float64{-0.5, -0.5}, [2]float64{0.5, 0.5}
+	}
+	return l
+}
+
+// rankActivity ranks the nodes of an activity by their distance along flows
+// from its start nodes. Nodes not reached start a search of their own, in
+// declaration order.
+func rankActivity(act *extuml.Activity) map[string]int {
+	next := make(map[string][]string)
+	for _, f := range act.Flows {
+		next[f.From] = append(next[f.From], f.To)
+	}
+	var starts []string
+	for _, n := range act.Nodes {
+		if n.Type == extuml.ActivityStart {
+			starts = append(starts, n.ID)
+		}
+	}
+	for _, n := range act.Nodes {
+		starts = append(starts, n.ID)
+	}
+
+	rank := make(map[string]int, len(act.Nodes))
+	for _, start := range starts {
+		if _, ok := rank[start]; ok {
+			continue
+		}
+		rank[start] = 0
+		queue := []string{start}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			for _, to := range next[id] {
+				if _, ok := rank[to]; !ok {
+					rank[to] = rank[id] + 1
+					queue = append(queue, to)
+				}
+			}
+		}
+	}
+	return rank
+}
+
+// generateActivityGeometry lays out an activity: its nodes ranked downwards
+// from the start nodes, each partition as an upright translucent plane
+// behind the previous one, and flows crossing between the planes
+func (u *generateUsecaseImpl) generateActivityGeometry(doc *extuml.Document, asset *gltf.GLTFAsset) {
+	act := doc.Activity
+	styles := newStyleResolver(doc)
+	layout := newActivityLayout(act, u.geomGen)
+
+	placements := make(map[string]placement, len(act.Nodes)+len(act.Partitions))
+	memberNodes := make(map[string][]int, len(act.Nodes))
+	partitionOf := make(map[string]string, len(act.Nodes))
+	for _, n := range act.Nodes {
+		placements[n.ID] = placement{layout.position[n.ID], vecScale(layout.size[n.ID], 0.5)}
+		partitionOf[n.ID] = n.Partition
+		first := len(asset.Nodes)
+		u.addActivityNodeToScene(n, layout, styles, asset)
+		memberNodes[n.ID] = nodeRange(first, len(asset.Nodes))
+	}
+
+	for _, f := range act.Flows {
+		crossing := partitionOf[f.From] != partitionOf[f.To]
+		u.addFlowToScene(f, placements[f.From], placements[f.To], crossing, colorFactors(styles.theme.Relationship.Stroke), asset)
+	}
+
+	top := layout.maxB[1] + activityPlanePadding
+	for _, p := range act.Partitions {
+		u.addPartitionToScene(p, layout, colorFactors(styles.theme.Package.Fill), placements, memberNodes, asset)
+	}
+
+	u.placeNotes(act.Notes, placements, top, colorFactors(styles.theme.Note.Stroke), asset)
+
+	applyTextColor(asset, styles.theme.Text)
+	setSceneRoots(asset)
+}
+
+// addActivityNodeToScene adds the outline of an activity node and, if it
+// has a name, its label: inside an action or object node, beside the other
+// kinds
+func (u *generateUsecaseImpl) addActivityNodeToScene(n extuml.ActivityNode, layout *activityLayout, styles styleResolver, asset *gltf.GLTFAsset) {
+	base := styles.theme.Interface
+	switch n.Type {
+	case extuml.ActivityAction:
+		base = styles.theme.Class
+	case extuml.ActivityObject:
+		base = styles.theme.Enum
+	}
+	style := styles.resolve(base, n.StyleClasses, n.Style)
+	position := layout.position[n.ID]
+	size := layout.size[n.ID]
+
+	extras := map[string]any{
+		"type": n.Type,
+		"id":   n.ID,
+	}
+	if n.Name != "" {
+		extras["name"] = n.Name
+	}
+	if n.Partition != "" {
+		extras["partition"] = n.Partition
+	}
+	if len(n.StyleClasses) > 0 {
+		extras["styleClasses"] = n.StyleClasses
+	}
+
+	mesh, material, lines := u.geomGen.GenerateActivityNodeShape(n, size, colorFactors(style.Stroke))
+	u.addMeshNode(n.ID, mesh, material, lines.Vertices, lines.Indices, position, map[string]any{"extuml": extras}, asset)
+	if n.Name == "" {
+		return
+	}
+	labelPos := position
+	if n.Type != extuml.ActivityAction && n.Type != extuml.ActivityObject {
+		labelPos = vecAdd(position, [3]float64{size[0]/2 + float64(len(n.Name))*stateCharWidth/2 + 0.2, 0, 0})
+	}
+	textIdx := u.addTextLabel(n.Name, labelPos, true, "", asset)
+	u.addStyledExtras(n.ID, style, size, position, textIdx, asset)
+}
+
+// addFlowToScene adds the arrow of a flow between two nodes, labelled with
+// its guard and name at its midpoint. A flow to its source loops over the
+// top of it.
+func (u *generateUsecaseImpl) addFlowToScene(f extuml.Flow, from, to placement, crossing bool, color [4]float64, asset *gltf.GLTFAsset) {
+	path := connectionPath(from, to, f.From == f.To, selfTransitionUp)
+
+	extras := map[string]any{
+		"type": "flow",
+		"id":   f.ID,
+		"kind": f.Type,
+		"from": f.From,
+		"to":   f.To,
+	}
+	if f.Guard != "" {
+		extras["guard"] = f.Guard
+	}
+	if f.Label != "" {
+		extras["label"] = f.Label
+	}
+	if crossing {
+		extras["crossesPartitions"] = true
+	}
+	mesh, material, lines := u.geomGen.GenerateFlowArrow(f, path, color)
+	u.addMeshNode(f.ID, mesh, material, lines.Vertices, lines.Indices, path[0], map[string]any{"extuml": extras}, asset)
+
+	label := f.Label
+	if f.Guard != "" {
+		label = "[" + f.Guard + "] " + label
+	}
+	if label = strings.TrimSpace(label); label != "" {
+		u.addTextLabel(label, vecAdd(pathMidpoint(path), [3]float64{0, 0.3, 0}), true, "", asset)
+	}
+}
+
+// addPartitionToScene adds the plane of a partition as a parent node of its
+// nodes, as packages are: a translucent upright slab spanning every node of
+// the activity, its outline in the same colour and its name above its
+// top-left corner
+func (u *generateUsecaseImpl) addPartitionToScene(p extuml.Partition, layout *activityLayout, fill [4]float64, placements map[string]placement, memberNodes map[string][]int, asset *gltf.GLTFAsset) {
+	width := layout.maxB[0] - layout.minB[0] + 2*activityPlanePadding
+	height := layout.maxB[1] - layout.minB[1] + 2*activityPlanePadding
+	center := [3]float64{
+		(layout.minB[0] + layout.maxB[0]) / 2,
+		(layout.minB[1] + layout.maxB[1]) / 2,
+		-float64(layout.plane[p.ID]) * activityLaneGap,
+	}
+
+	var members []int
+	for _, id := range p.Nodes {
+		members = append(members, memberNodes[id]...)
+	}
+	// Member translations become relative to the partition node
+	for _, idx := range members {
+		if t := asset.Nodes[idx].Translation; len(t) == 3 {
+			asset.Nodes[idx].Translation = []float64{t[0] - center[0], t[1] - center[1], t[2] - center[2]}
+		}
+	}
+
+	mesh, material, lines := u.geomGen.GeneratePartitionPlane(p, width, height, fill)
+	children := []int{u.addMeshNode(p.ID+"_outline", mesh, material, lines.Vertices, lines.Indices, [3]float64{}, map[string]any{
+		"extuml": map[string]any{
+			"type": "partitionOutline",
+			"id":   p.ID,
+		},
+	}, asset)}
+	volMesh, volMaterial, vertices, indices := u.geomGen.generateVolume(p.ID, [3]float64{width, height, activityPlaneThickness}, fill, packageOpacity)
+	children = append(children, u.addMeshNode(p.ID+"_volume", volMesh, volMaterial, vertices, indices, [3]float64{}, map[string]any{
+		"extuml": map[string]any{
+			"type": "partitionVolume",
+			"id":   p.ID,
+		},
+	}, asset))
+	labelPos := [3]float64{-width/2 + float64(len(p.Name))*stateCharWidth/2 + 0.3, height/2 + 0.3, 0}
+	children = append(children, u.addTextLabel(p.Name, labelPos, true, "", asset))
+
+	idx := len(asset.Nodes)
+	asset.Nodes = append(asset.Nodes, gltf.Node{
+		Name:        p.ID,
+		Translation: []float64{center[0], center[1], center[2]},
+		Children:    append(children, members...),
+		Extras: map[string]any{
+			"extuml": map[string]any{
+				"type":     "partition",
+				"id":       p.ID,
+				"name":     p.Name,
+				"plane":    layout.plane[p.ID],
+				"children": p.Nodes,
+			},
+		},
+	})
+	placements[p.ID] = placement{center, [3]float64{width / 2, height / 2, activityPlaneThickness / 2}}
+	memberNodes[p.ID] = []int{idx}
+}
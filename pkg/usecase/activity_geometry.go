@@ -0,0 +1,66 @@
+package usecase
+
+import (
+	"math"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+)
+
+// Activity diagram dimensions
+const (
+	activityPlaneThickness = 0.05 // partition planes
+	activityPlanePadding   = 1.0  // margin of a partition plane around the nodes
+)
+
+// activityShapes maps the activity node kinds drawn like state diagram
+// nodes to the state kind they share a shape with
+var activityShapes = map[string]string{
+	extuml.ActivityAction:   extuml.StateSimple,
+	extuml.ActivityStart:    extuml.StateInitial,
+	extuml.ActivityEnd:      extuml.StateFinal,
+	extuml.ActivityDecision: extuml.StateChoice,
+	extuml.ActivityMerge:    extuml.StateChoice,
+	extuml.ActivityFork:     extuml.StateFork,
+	extuml.ActivityJoin:     extuml.StateJoin,
+}
+
+// ActivityNodeSize returns the width, height and depth of an activity
+// node. Fork and join bars lie across the flow, which runs downwards.
+func (g *GeometryGenerator) ActivityNodeSize(n extuml.ActivityNode) (width, height, depth float64) {
+	switch n.Type {
+	case extuml.ActivityFork, extuml.ActivityJoin:
+		return barHeight, barWidth, stateDepth
+	case extuml.ActivityAction, extuml.ActivityObject:
+		return math.Max(stateMinWidth, float64(len(n.Name))*stateCharWidth+0.8), stateHeight, stateDepth
+	}
+	return g.StateSize(extuml.State{Type: activityShapes[n.Type]})
+}
+
+// GenerateActivityNodeShape generates the outline of an activity node of
+// the given size centered on its origin: a plain box for an object node,
+// and otherwise the shape of the matching state diagram node, a box with
+// cut corners for an action
+func (g *GeometryGenerator) GenerateActivityNodeShape(n extuml.ActivityNode, size [3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	if n.Type != extuml.ActivityObject {
+		return g.GenerateStateShape(extuml.State{ID: n.ID, Type: activityShapes[n.Type]}, size, color)
+	}
+	lines = &LineSet{}
+	addBoxEdges(lines, size)
+	return lineMesh(n.ID + "_outline"), g.wireframeMaterial(n.ID+"_material", color), lines
+}
+
+// GeneratePartitionPlane generates the outline of a partition plane of the
+// given width and height, standing upright and centered on its origin
+func (g *GeometryGenerator) GeneratePartitionPlane(p extuml.Partition, width, height float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	w, h := width/2, height/2
+	lines.AddPolyline([3]float64{-w, -h, 0}, [3]float64{w, -h, 0}, [3]float64{w, h, 0}, [3]float64{-w, h, 0}, [3]float64{-w, -h, 0})
+	return lineMesh(p.ID + "_outline"), g.wireframeMaterial(p.ID+"_material", color), lines
+}
+
+// GenerateFlowArrow generates the arrow of a flow along path, with an open
+// head at its end. Vertices are relative to path[0].
+func (g *GeometryGenerator) GenerateFlowArrow(f extuml.Flow, path [][3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	return g.GenerateTransitionArrow(extuml.Transition{ID: f.ID}, path, color)
+}
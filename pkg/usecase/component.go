@@ -0,0 +1,500 @@
+package usecase
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+)
+
+// Component and deployment diagram layout constants
+const (
+	archColumnGap   = 1.6 // X distance between elements of a block
+	archRowGap      = 2.4 // Y distance, leaving room for interfaces
+	archPadding     = 1.0 // margin of a container around its elements
+	archTitleHeight = 0.6 // room for the name of a container
+)
+
+// archElement is a component, deployment node or artifact, which the
+// layout and the scene treat alike
+type archElement struct {
+	id, kind, name, parent string
+	stereotypes            []string
+	ports                  []extuml.Port
+	provides, requires     []string
+	classes                []string
+	style                  *extuml.Style
+}
+
+// archLayout places the elements of a component or deployment diagram.
+// The elements of every block are laid out in a grid around its center, and
+// a component or node holding elements grows around them, so that nested
+// deployment nodes become nested volumes.
+type archLayout struct {
+	geomGen  *GeometryGenerator
+	elements map[string]archElement
+	children map[string][]string // elements of each block, "" for the top level
+
+	size   map[string][3]float64 // size of each element
+	offset map[string][3]float64 // center relative to its parent's center
+}
+
+func newArchLayout(arch *extuml.Architecture, geomGen *GeometryGenerator) *archLayout {
+	l := &archLayout{
+		geomGen:  geomGen,
+		elements: make(map[string]archElement),
+		children: make(map[string][]string),
+		size:     make(map[string][3]float64),
+		offset:   make(map[string][3]float64),
+	}
+	add := func(e archElement) {
+		l.elements[e.id] = e
+		l.children[e.parent] = append(l.children[e.parent], e.id)
+	}
+	for _, n := range arch.Nodes {
+		add(archElement{id: n.ID, kind: n.Type, name: n.Name, parent: n.Parent, stereotypes: n.Stereotypes, ports: n.Ports, classes: n.StyleClasses, style: n.Style})
+	}
+	for _, c := range arch.Components {
+		add(archElement{id: c.ID, kind: c.Type, name: c.Name, parent: c.Parent, stereotypes: c.Stereotypes, ports: c.Ports,
+			provides: c.Provides, requires: c.Requires, classes: c.StyleClasses, style: c.Style})
+	}
+	for _, a := range arch.Artifacts {
+		add(archElement{id: a.ID, kind: a.Type, name: a.Name, parent: a.Parent, stereotypes: a.Stereotypes, classes: a.StyleClasses, style: a.Style})
+	}
+	l.placeBlock("")
+	return l
+}
+
+// placeBlock lays out the elements of a block in a grid around its origin,
+// sizing containers first, and returns the size of the block
+func (l *archLayout) placeBlock(parent string) [3]float64 {
+	members := l.children[parent]
+	if len(members) == 0 {
+		return [3]float64{}
+	}
+	for _, id := range members {
+		l.size[id] = l.sizeElement(l.elements[id])
+	}
+
+	columns := int(math.Ceil(math.Sqrt(float64(len(members)))))
+	rows := (len(members) + columns - 1) / columns
+	widths := make([]float64, columns)
+	heights := make([]float64, rows)
+	var size [3]float64
+	for i, id := range members {
+		s := l.size[id]
+		widths[i%columns] = max(widths[i%columns], s[0])
+		heights[i/columns] = max(heights[i/columns], s[1])
+		size[2] = max(size[2], s[2])
+	}
+	for i, w := range widths {
+		size[0] += w
+		if i > 0 {
+			size[0] += archColumnGap
+		}
+	}
+	for i, h := range heights {
+		size[1] += h
+		if i > 0 {
+			size[1] += archRowGap
+		}
+	}
+
+	y := size[1] / 2
+	for row := 0; row < rows; row++ {
+		x := -size[0] / 2
+		for col := 0; col < columns && row*columns+col < len(members); col++ {
+			l.offset[members[row*columns+col]] = [3]float64{x + widths[col]/2, y - heights[row]/2, 0}
+			x += widths[col] + archColumnGap
+		}
+		y -= heights[row] + archRowGap
+	}
+	return size
+}
+
+// sizeElement returns the size of an element, laying out the elements
+// nested in it
+func (l *archLayout) sizeElement(e archElement) [3]float64 {
+	w, h, d := l.geomGen.ArchitectureBoxSize(e.kind, e.name, len(e.ports))
+	if len(l.children[e.id]) == 0 {
+		return [3]float64{w, h, d}
+	}
+
+	inner := l.placeBlock(e.id)
+	size := [3]float64{
+		max(w, inner[0]+2*archPadding),
+		max(h, inner[1]+2*archPadding+archTitleHeight),
+		inner[2] + 2*archPadding,
+	}
+	// Nested elements below the title
+	for _, id := range l.children[e.id] {
+		l.offset[id] = vecAdd(l.offset[id], [3]float64{0, -archTitleHeight / 2, 0})
+	}
+	return size
+}
+
+// position returns the center of an element in the scene
+func (l *archLayout) position(id string) [3]float64 {
+	var p [3]float64
+	for ; id != ""; id = l.elements[id].parent {
+		p = vecAdd(p, l.offset[id])
+	}
+	return p
+}
+
+// portOffset returns the center of the i-th port of an element relative to
+// its center: ports straddle its right face, spread along Y
+func (l *archLayout) portOffset(id string, i int) [3]float64 {
+	n := len(l.elements[id].ports)
+	return [3]float64{l.size[id][0] / 2, (float64(n-1)/2 - float64(i)) * (portSize + portGap), 0}
+}
+
+// interfaceOffset returns where the i-th of n provided or required
+// interfaces of an element meets it relative to its center: provided
+// interfaces rise from its top face, required ones hang from its bottom
+// face
+func (l *archLayout) interfaceOffset(id string, i, n int, provided bool) [3]float64 {
+	size := l.size[id]
+	y := size[1] / 2
+	if !provided {
+		y = -y
+	}
+	return [3]float64{float64(i+1)*size[0]/float64(n+1) - size[0]/2, y, 0}
+}
+
+// interfaceID names the node of the i-th provided or required interface of
+// an element
+func interfaceID(id string, provided bool, i int) string {
+	kind := "requiredInterface"
+	if provided {
+		kind = "providedInterface"
+	}
+	return fmt.Sprintf("%s_%s_%d", id, kind, i+1)
+}
+
+// interfaceOf returns the node ID of the interface called name of an
+// element, preferring the interfaces it provides
+func (l *archLayout) interfaceOf(id, name string) string {
+	if i := slices.Index(l.elements[id].provides, name); i != -1 {
+		return interfaceID(id, true, i)
+	}
+	return interfaceID(id, false, slices.Index(l.elements[id].requires, name))
+}
+
+// generateComponentGeometry lays out a component or deployment diagram: the
+// top-level elements in a grid, deployment nodes and components as
+// containers around the elements nested in them, connectors between
+// elements or their ports, and assembly lines from every required
+// interface to the components providing it
+func (u *generateUsecaseImpl) generateComponentGeometry(doc *extuml.Document, asset *gltf.GLTFAsset) {
+	arch := doc.Architecture
+	styles := newStyleResolver(doc)
+	layout := newArchLayout(arch, u.geomGen)
+
+	placements := make(map[string]placement, len(layout.elements))
+	top := 0.0
+	for id, e := range layout.elements {
+		placements[id] = placement{layout.position(id), vecScale(layout.size[id], 0.5)}
+		for i, port := range e.ports {
+			placements[port.ID] = placement{vecAdd(placements[id].position, layout.portOffset(id, i)), [3]float64{portSize / 2, portSize / 2, portSize / 2}}
+		}
+		for _, provided := range []bool{true, false} {
+			names := e.provides
+			if !provided {
+				names = e.requires
+			}
+			for i := range names {
+				tip := vecAdd(vecAdd(placements[id].position, layout.interfaceOffset(id, i, len(names), provided)), interfaceTip(provided))
+				placements[interfaceID(id, provided, i)] = placement{tip, [3]float64{lollipopRadius, lollipopRadius, lollipopRadius}}
+			}
+		}
+		if e.parent == "" {
+			top = max(top, placements[id].position[1]+layout.size[id][1]/2)
+		}
+	}
+	for _, id := range layout.children[""] {
+		u.addArchTreeToScene(layout.elements[id], layout, placements, styles, asset)
+	}
+
+	for _, c := range arch.Connectors {
+		from, to := placements[c.From], placements[c.To]
+		if c.FromPort != "" {
+			from = placements[c.FromPort]
+		}
+		if c.ToPort != "" {
+			to = placements[c.ToPort]
+		}
+		if c.FromInterface != "" {
+			from = placements[layout.interfaceOf(c.From, c.FromInterface)]
+		}
+		if c.ToInterface != "" {
+			to = placements[layout.interfaceOf(c.To, c.ToInterface)]
+		}
+		u.addConnectorToScene(c, from, to, colorFactors(styles.theme.Relationship.Stroke), asset)
+	}
+	u.addAssembliesToScene(arch, layout, placements, colorFactors(styles.theme.Relationship.Stroke), asset)
+
+	u.placeNotes(arch.Notes, placements, top, colorFactors(styles.theme.Note.Stroke), asset)
+
+	applyTextColor(asset, styles.theme.Text)
+	setSceneRoots(asset)
+}
+
+// addArchTreeToScene adds an element with its ports and interfaces and, for
+// a container, the elements nested in it, and returns the indices of its
+// top-level nodes. Ports and interfaces are children of the node of their
+// element. A container becomes a parent node at its center, holding its
+// outline, its volume, its label and everything nested in it, as packages
+// do.
+func (u *generateUsecaseImpl) addArchTreeToScene(e archElement, layout *archLayout, placements map[string]placement, styles styleResolver, asset *gltf.GLTFAsset) []int {
+	base := styles.theme.Interface
+	switch e.kind {
+	case "component":
+		base = styles.theme.Class
+	case "artifact":
+		base = styles.theme.Enum
+	}
+	style := styles.resolve(base, e.classes, e.style)
+	p := placements[e.id]
+	size := layout.size[e.id]
+	color := colorFactors(style.Stroke)
+
+	extras := map[string]any{
+		"type": e.kind,
+		"id":   e.id,
+		"name": e.name,
+	}
+	if e.parent != "" {
+		extras["parent"] = e.parent
+	}
+	if len(e.stereotypes) > 0 {
+		extras["stereotypes"] = e.stereotypes
+	}
+	if len(e.ports) > 0 {
+		var ports []string
+		for _, port := range e.ports {
+			ports = append(ports, port.ID)
+		}
+		extras["ports"] = ports
+	}
+	if len(e.provides) > 0 {
+		extras["provides"] = e.provides
+	}
+	if len(e.requires) > 0 {
+		extras["requires"] = e.requires
+	}
+	if len(e.classes) > 0 {
+		extras["styleClasses"] = e.classes
+	}
+
+	var mesh gltf.Mesh
+	var material gltf.Material
+	var lines *LineSet
+	switch e.kind {
+	case "component":
+		mesh, material, lines = u.geomGen.GenerateComponentBox(e.id, size, color)
+	case "artifact":
+		mesh, material, lines = u.geomGen.GenerateArtifactBox(e.id, size, color)
+	default:
+		mesh, material, lines = u.geomGen.GenerateNodeBox(e.id, size, color)
+	}
+
+	// Ports and interfaces, placed in the scene
+	first := len(asset.Nodes)
+	for i, port := range e.ports {
+		portMesh, portMaterial, portLines := u.geomGen.GeneratePort(port, color)
+		position := placements[port.ID].position
+		u.addMeshNode(port.ID, portMesh, portMaterial, portLines.Vertices, portLines.Indices, position, map[string]any{
+			"extuml": map[string]any{
+				"type":  "port",
+				"id":    port.ID,
+				"name":  port.Name,
+				"owner": e.id,
+				"index": i,
+			},
+		}, asset)
+		u.addTextLabel(port.Name, vecAdd(position, [3]float64{portSize + 0.3, 0, 0}), true, "", asset)
+	}
+	for _, provided := range []bool{true, false} {
+		names, kind := e.provides, "providedInterface"
+		if !provided {
+			names, kind = e.requires, "requiredInterface"
+		}
+		for i, name := range names {
+			id := interfaceID(e.id, provided, i)
+			position := vecAdd(p.position, layout.interfaceOffset(e.id, i, len(names), provided))
+			ifaceMesh, ifaceMaterial, ifaceLines := u.geomGen.GenerateInterface(id, provided, color)
+			u.addMeshNode(id, ifaceMesh, ifaceMaterial, ifaceLines.Vertices, ifaceLines.Indices, position, map[string]any{
+				"extuml": map[string]any{
+					"type":      kind,
+					"component": e.id,
+					"interface": name,
+				},
+			}, asset)
+			tip := vecAdd(position, interfaceTip(provided))
+			u.addTextLabel(name, vecAdd(tip, [3]float64{lollipopRadius + 0.2, 0, 0}), true, "", asset)
+		}
+	}
+	attached := nodeRange(first, len(asset.Nodes))
+
+	label := archLabel(e)
+	if len(layout.children[e.id]) == 0 {
+		// The ports and interfaces of a leaf become children of its node,
+		// relative to it
+		first := len(asset.Nodes)
+		idx := u.addMeshNode(e.id, mesh, material, lines.Vertices, lines.Indices, p.position, map[string]any{"extuml": extras}, asset)
+		for _, child := range attached {
+			if t := asset.Nodes[child].Translation; len(t) == 3 {
+				asset.Nodes[child].Translation = []float64{t[0] - p.position[0], t[1] - p.position[1], t[2] - p.position[2]}
+			}
+		}
+		asset.Nodes[idx].Children = append(asset.Nodes[idx].Children, attached...)
+		textIdx := u.addTextLabel(label, p.position, true, "", asset)
+		u.addStyledExtras(e.id, style, size, p.position, textIdx, asset)
+		return nodeRange(first, len(asset.Nodes))
+	}
+
+	members := attached
+	for _, id := range layout.children[e.id] {
+		members = append(members, u.addArchTreeToScene(layout.elements[id], layout, placements, styles, asset)...)
+	}
+	// Nested translations become relative to the container
+	for _, idx := range members {
+		if t := asset.Nodes[idx].Translation; len(t) == 3 {
+			asset.Nodes[idx].Translation = []float64{t[0] - p.position[0], t[1] - p.position[1], t[2] - p.position[2]}
+		}
+	}
+
+	fill := colorFactors(styles.theme.Package.Fill)
+	if style.Fill != "" {
+		fill = colorFactors(style.Fill)
+	}
+	children := []int{u.addMeshNode(e.id+"_outline", mesh, material, lines.Vertices, lines.Indices, [3]float64{}, map[string]any{
+		"extuml": map[string]any{
+			"type": e.kind + "Outline",
+			"id":   e.id,
+		},
+	}, asset)}
+	volMesh, volMaterial, vertices, indices := u.geomGen.generateVolume(e.id, size, fill, packageOpacity)
+	children = append(children, u.addMeshNode(e.id+"_volume", volMesh, volMaterial, vertices, indices, [3]float64{}, map[string]any{
+		"extuml": map[string]any{
+			"type":     e.kind + "Volume",
+			"id":       e.id,
+			"children": layout.children[e.id],
+		},
+	}, asset))
+	labelIdx := u.addTextLabel(label, [3]float64{0, size[1]/2 - archTitleHeight/2, size[2] / 2}, true, "", asset)
+	if style.Color != "" {
+		setLabelStyle(asset, labelIdx, "color", style.Color)
+	}
+	children = append(children, labelIdx)
+
+	extras["children"] = layout.children[e.id]
+	idx := len(asset.Nodes)
+	asset.Nodes = append(asset.Nodes, gltf.Node{
+		Name:        e.id,
+		Translation: []float64{p.position[0], p.position[1], p.position[2]},
+		Children:    append(children, members...),
+		Extras:      map[string]any{"extuml": extras},
+	})
+	return []int{idx}
+}
+
+// archLabel returns the label of an element: its stereotypes, preceded by
+// its kind for a node or artifact, over its name
+func archLabel(e archElement) string {
+	var stereotypes []string
+	if e.kind != "component" && e.kind != extuml.NodeDefault {
+		stereotypes = append(stereotypes, "«"+e.kind+"»")
+	}
+	for _, s := range e.stereotypes {
+		stereotypes = append(stereotypes, "«"+s+"»")
+	}
+	if len(stereotypes) == 0 {
+		return e.name
+	}
+	return strings.Join(stereotypes, " ") + "\n" + e.name
+}
+
+// addConnectorToScene connects two elements, or ports or interfaces of
+// them, with the line of a connector and labels it at its midpoint. A
+// connector of an element with itself loops over the top of it.
+func (u *generateUsecaseImpl) addConnectorToScene(c extuml.Connector, from, to placement, color [4]float64, asset *gltf.GLTFAsset) {
+	self := c.From == c.To && c.FromPort == c.ToPort && c.FromInterface == c.ToInterface
+	path := connectionPath(from, to, self, selfLoopUp)
+
+	rel := extuml.Relationship{ID: c.ID, Type: c.Type, From: c.From, To: c.To, Label: c.Label}
+	mesh, material, lines := u.geomGen.GenerateRelationshipLines(rel, path, color)
+	extras := map[string]any{
+		"type": "connector",
+		"id":   c.ID,
+		"kind": c.Type,
+		"from": c.From,
+		"to":   c.To,
+	}
+	if c.FromPort != "" {
+		extras["fromPort"] = c.FromPort
+	}
+	if c.ToPort != "" {
+		extras["toPort"] = c.ToPort
+	}
+	if c.FromInterface != "" {
+		extras["fromInterface"] = c.FromInterface
+	}
+	if c.ToInterface != "" {
+		extras["toInterface"] = c.ToInterface
+	}
+	if c.Label != "" {
+		extras["label"] = c.Label
+	}
+	u.addMeshNode(c.ID, mesh, material, lines.Vertices, lines.Indices, path[0], map[string]any{"extuml": extras}, asset)
+
+	if c.Label != "" {
+		u.addTextLabel(c.Label, vecAdd(pathMidpoint(path), [3]float64{0, 0.3, 0}), true, "", asset)
+	}
+}
+
+// addAssembliesToScene joins the socket of every required interface to the
+// ball of each other component providing an interface of the same name
+// with a dashed assembly line
+func (u *generateUsecaseImpl) addAssembliesToScene(arch *extuml.Architecture, layout *archLayout, placements map[string]placement, color [4]float64, asset *gltf.GLTFAsset) {
+	type ball struct {
+		component string
+		tip       [3]float64
+	}
+	providers := make(map[string][]ball)
+	for _, c := range arch.Components {
+		for i, name := range c.Provides {
+			tip := vecAdd(vecAdd(placements[c.ID].position, layout.interfaceOffset(c.ID, i, len(c.Provides), true)), interfaceTip(true))
+			providers[name] = append(providers[name], ball{c.ID, tip})
+		}
+	}
+
+	n := 0
+	for _, c := range arch.Components {
+		for i, name := range c.Requires {
+			socket := vecAdd(vecAdd(placements[c.ID].position, layout.interfaceOffset(c.ID, i, len(c.Requires), false)), interfaceTip(false))
+			for _, b := range providers[name] {
+				if b.component == c.ID {
+					continue
+				}
+				n++
+				id := fmt.Sprintf("assembly_%d", n)
+				lines := &LineSet{}
+				lines.AddDashedSegment([3]float64{0, 0, 0}, vecSub(b.tip, socket), dashLength, dashGap)
+				u.addMeshNode(id, lineMesh(id+"_line"), u.geomGen.wireframeMaterial(id+"_material", color), lines.Vertices, lines.Indices, socket, map[string]any{
+					"extuml": map[string]any{
+						"type":      "assembly",
+						"id":        id,
+						"interface": name,
+						"from":      c.ID,
+						"to":        b.component,
+					},
+				}, asset)
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package usecase
+
+import (
+	"math"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+)
+
+// Component and deployment diagram dimensions
+const (
+	componentMinWidth  = 2.2 // component box
+	componentHeight    = 1.2
+	componentDepth     = 1.0
+	componentTabWidth  = 0.4 // the two tabs of the component icon
+	componentTabH      = 0.2
+	artifactWidth      = 1.8
+	artifactHeight     = 0.9
+	artifactDepth      = 0.5
+	artifactFold       = 0.25
+	nodeMinWidth       = 2.4 // deployment node without nested elements
+	nodeHeight         = 1.4
+	nodeDepth          = 1.4
+	portSize           = 0.3 // cube on the right face of its owner
+	portGap            = 0.2
+	lollipopStick      = 0.45 // stem of a provided or required interface
+	lollipopRadius     = 0.15 // ball of a provided interface
+	socketRadius       = 0.22 // cup of a required interface
+	componentCharWidth = 0.15 // width taken by each character of a name
+)
+
+// ArchitectureBoxSize returns the width, height and depth of a component,
+// artifact or deployment node that has no nested elements. Components and
+// nodes grow to fit their ports.
+func (g *GeometryGenerator) ArchitectureBoxSize(kind, name string, ports int) (width, height, depth float64) {
+	portsHeight := float64(ports)*(portSize+portGap) + portGap
+	switch kind {
+	case "component":
+		return math.Max(componentMinWidth, float64(len(name))*componentCharWidth+1.0), math.Max(componentHeight, portsHeight), componentDepth
+	case "artifact":
+		return math.Max(artifactWidth, float64(len(name))*componentCharWidth+0.6), artifactHeight, artifactDepth
+	}
+	return math.Max(nodeMinWidth, float64(len(name))*componentCharWidth+1.0), math.Max(nodeHeight, portsHeight), nodeDepth
+}
+
+// GenerateComponentBox generates the outline of a component of the given
+// size centered on its origin, with the two tabs of the component icon on
+// its left face
+func (g *GeometryGenerator) GenerateComponentBox(id string, size [3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	addBoxEdges(lines, size)
+	w, h, d := size[0]/2, size[1]/2, size[2]/2
+	tabW, tabH := componentTabWidth/2, componentTabH/2
+	for _, y := range []float64{h / 3, -h / 3} {
+		for _, z := range []float64{d, -d} {
+			lines.AddPolyline(
+				[3]float64{-w - tabW, y - tabH, z},
+				[3]float64{-w + tabW, y - tabH, z},
+				[3]float64{-w + tabW, y + tabH, z},
+				[3]float64{-w - tabW, y + tabH, z},
+				[3]float64{-w - tabW, y - tabH, z},
+			)
+		}
+	}
+	return lineMesh(id + "_outline"), g.wireframeMaterial(id+"_material", color), lines
+}
+
+// GenerateArtifactBox generates the outline of an artifact: a slab of the
+// given size with a folded top-right corner on its front face
+func (g *GeometryGenerator) GenerateArtifactBox(id string, size [3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	addBoxEdges(lines, size)
+	w, h, d := size[0]/2, size[1]/2, size[2]/2
+	lines.AddPolyline(
+		[3]float64{w - artifactFold, h, d},
+		[3]float64{w - artifactFold, h - artifactFold, d},
+		[3]float64{w, h - artifactFold, d},
+		[3]float64{w - artifactFold, h, d},
+	)
+	return lineMesh(id + "_outline"), g.wireframeMaterial(id+"_material", color), lines
+}
+
+// GenerateNodeBox generates the outline of a deployment node of the given
+// size centered on its origin
+func (g *GeometryGenerator) GenerateNodeBox(id string, size [3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	addBoxEdges(lines, size)
+	return lineMesh(id + "_outline"), g.wireframeMaterial(id+"_material", color), lines
+}
+
+// GeneratePort generates the cube of a port centered on its origin
+func (g *GeometryGenerator) GeneratePort(port extuml.Port, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	addBoxEdges(lines, [3]float64{portSize, portSize, portSize})
+	return lineMesh(port.ID + "_port"), g.wireframeMaterial(port.ID+"_material", color), lines
+}
+
+// GenerateInterface generates a provided interface, a stem rising from the
+// origin to a ball, or a required interface, a stem hanging from the origin
+// to a socket opening away from its component. The ball and socket are
+// drawn in two perpendicular planes so that they read from any side.
+func (g *GeometryGenerator) GenerateInterface(id string, provided bool, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	up := 1.0
+	if !provided {
+		up = -1
+	}
+	lines.AddSegment([3]float64{0, 0, 0}, [3]float64{0, up * lollipopStick, 0})
+
+	var xy, yz [][3]float64
+	if provided {
+		center := lollipopStick + lollipopRadius
+		for i := 0; i <= 16; i++ {
+			a := float64(i) * 2 * math.Pi / 16
+			xy = append(xy, [3]float64{lollipopRadius * math.Cos(a), center + lollipopRadius*math.Sin(a), 0})
+			yz = append(yz, [3]float64{0, center + lollipopRadius*math.Sin(a), lollipopRadius * math.Cos(a)})
+		}
+	} else {
+		center := -lollipopStick - socketRadius
+		for i := 0; i <= 8; i++ {
+			a := float64(i) * math.Pi / 8
+			xy = append(xy, [3]float64{socketRadius * math.Cos(a), center + socketRadius*math.Sin(a), 0})
+			yz = append(yz, [3]float64{0, center + socketRadius*math.Sin(a), socketRadius * math.Cos(a)})
+		}
+	}
+	lines.AddPolyline(xy...)
+	lines.AddPolyline(yz...)
+	return lineMesh(id), g.wireframeMaterial(id+"_material", color), lines
+}
+
+// interfaceTip returns the center of the ball or socket of an interface
+// relative to its origin
+func interfaceTip(provided bool) [3]float64 {
+	if provided {
+		return [3]float64{0, lollipopStick + lollipopRadius, 0}
+	}
+	return [3]float64{0, -lollipopStick - socketRadius, 0}
+}
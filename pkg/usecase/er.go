@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+)
+
+// entitySpacing is the X distance between entities of a row
+const entitySpacing = 3.5
+
+// generateERGeometry builds the scene of an ER diagram. Entities are laid
+// out like classifiers, and each schema or tablespace gets a depth layer of
+// its own with a translucent volume around its entities, as packages do.
+func (u *generateUsecaseImpl) generateERGeometry(doc *extuml.Document, asset *gltf.GLTFAsset) {
+	er := doc.ER
+	styles := newStyleResolver(doc)
+	memberNodes := make(map[string][]int)
+
+	owner := make(map[string]string)
+	layers := make(map[string]int)
+	for i, schema := range er.Schemas {
+		layers[schema.ID] = i + 1
+		for _, id := range schema.Entities {
+			owner[id] = schema.ID
+		}
+	}
+	layerOf := func(id string) float64 {
+		return -float64(layers[owner[id]]) * packageLayerDepth
+	}
+
+	var items []layoutItem
+	for _, entity := range er.Entities {
+		w, h, d := u.geomGen.EntityBoxSize(entity)
+		items = append(items, layoutItem{entity.ID, 0, [3]float64{w / 2, h / 2, d / 2}, nil})
+	}
+	placements := u.layoutClassifiers(items, entitySpacing, owner, layerOf, nil, LayoutOptions{Algorithm: LayoutGrid})
+
+	top := 0.0
+	for _, entity := range er.Entities {
+		first := len(asset.Nodes)
+		style := styles.resolve(styles.theme.Class, entity.StyleClasses, entity.Style)
+		u.addEntityToScene(entity, placements[entity.ID].position, style, asset)
+		memberNodes[entity.ID] = nodeRange(first, len(asset.Nodes))
+		top = max(top, placements[entity.ID].position[1]+placements[entity.ID].halfExtents[1])
+	}
+
+	u.placeNotes(er.Notes, placements, top, colorFactors(styles.theme.Note.Stroke), asset)
+
+	for _, rel := range er.Relationships {
+		u.addERRelationshipToScene(rel, placements[rel.From], placements[rel.To], colorFactors(styles.theme.Relationship.Stroke), asset)
+	}
+
+	for _, schema := range er.Schemas {
+		pkg := extuml.Package{ID: schema.ID, Type: schema.Type, Name: schema.Name, Children: schema.Entities}
+		u.addPackageToScene(pkg, -float64(layers[schema.ID])*packageLayerDepth, schema.Type, colorFactors(styles.theme.Package.Fill), placements, memberNodes, asset)
+	}
+
+	applyTextColor(asset, styles.theme.Text)
+	setSceneRoots(asset)
+}
+
+// addEntityToScene adds the wireframe of an entity and its label: the name,
+// then a line per column with its type and keys
+func (u *generateUsecaseImpl) addEntityToScene(entity extuml.Entity, position [3]float64, style extuml.Style, asset *gltf.GLTFAsset) {
+	extras := map[string]any{
+		"type":    "entity",
+		"id":      entity.ID,
+		"name":    entity.Name,
+		"columns": len(entity.Columns),
+	}
+	if entity.Schema != "" {
+		extras["schema"] = entity.Schema
+	}
+	var keys []string
+	for _, c := range entity.Columns {
+		for _, key := range c.Keys {
+			if key == extuml.KeyPrimary {
+				keys = append(keys, c.Name)
+			}
+		}
+	}
+	if len(keys) > 0 {
+		extras["primaryKey"] = keys
+	}
+
+	mesh, material, vertices, indices := u.geomGen.GenerateEntityWireframe(entity, colorFactors(style.Stroke))
+	u.addMeshNode(entity.ID, mesh, material, vertices, indices, position, map[string]any{"extuml": extras}, asset)
+
+	text := entity.Name + "\n---"
+	for _, c := range entity.Columns {
+		text += "\n" + formatColumn(c)
+	}
+	textIdx := u.addTextLabel(text, position, true, "", asset)
+	w, h, d := u.geomGen.EntityBoxSize(entity)
+	u.addStyledExtras(entity.ID, style, [3]float64{w, h, d}, position, textIdx, asset)
+}
+
+// formatColumn renders a column as `name: type PK, FK`
+func formatColumn(c extuml.Column) string {
+	s := c.Name + ": " + c.Type
+	if len(c.Keys) > 0 {
+		s += " " + strings.Join(c.Keys, ", ")
+	}
+	return s
+}
+
+// addERRelationshipToScene connects the surfaces of two entities with the
+// crow's foot line of a relationship, labelled at its midpoint. A
+// relationship of an entity with itself loops over the top of it.
+func (u *generateUsecaseImpl) addERRelationshipToScene(rel extuml.ERRelationship, from, to placement, color [4]float64, asset *gltf.GLTFAsset) {
+	path := connectionPath(from, to, rel.From == rel.To, erSelfLoopUp)
+
+	mesh, material, lines := u.geomGen.GenerateERRelationshipLines(rel, path, color)
+	kind := "nonIdentifying"
+	if rel.Identifying {
+		kind = "identifying"
+	}
+	extras := map[string]any{
+		"type":            "relationship",
+		"id":              rel.ID,
+		"kind":            kind,
+		"from":            rel.From,
+		"to":              rel.To,
+		"fromCardinality": rel.FromCardinality,
+		"toCardinality":   rel.ToCardinality,
+	}
+	if rel.Label != "" {
+		extras["label"] = rel.Label
+	}
+	u.addMeshNode(rel.ID, mesh, material, lines.Vertices, lines.Indices, path[0], map[string]any{"extuml": extras}, asset)
+
+	if rel.Label != "" {
+		u.addTextLabel(rel.Label, vecAdd(pathMidpoint(path), [3]float64{0, 0.3, 0}), true, "", asset)
+	}
+}
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"math"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+)
+
+// ER diagram dimensions
+const (
+	entityWidth      = 2.5
+	entityDepth      = 1.0
+	entityNameHeight = 0.4  // name compartment
+	columnHeight     = 0.22 // height taken by each column
+	crowsFootLength  = 0.35 // from the entity to where the prongs meet
+	crowsFootWidth   = 0.2
+	cardinalityBar   = 0.2  // half length of a bar across the line
+	cardinalityRing  = 0.1  // radius of the circle for an optional end
+	cardinalityStep  = 0.15 // distance between marks along the line
+	erSelfLoopUp     = 1.0  // height of the loop of a relationship of an entity with itself
+)
+
+// EntityBoxSize returns the width, height and depth of an entity wireframe
+func (g *GeometryGenerator) EntityBoxSize(entity extuml.Entity) (width, height, depth float64) {
+	columns := math.Max(float64(len(entity.Columns)), 1) * columnHeight
+	return entityWidth, entityNameHeight + columns, entityDepth
+}
+
+// GenerateEntityWireframe generates the wireframe of an entity: a box with
+// its name compartment divided from its columns
+func (g *GeometryGenerator) GenerateEntityWireframe(entity extuml.Entity, color [4]float64) (mesh gltf.Mesh, material gltf.Material, vertices []float32, indices []uint32) {
+	width, height, depth := g.EntityBoxSize(entity)
+	vertices, indices = g.createWireframeBox(float32(width), float32(height), float32(depth), 2, []float32{entityNameHeight})
+	return lineMesh(entity.ID + "_wireframe"), g.wireframeMaterial(entity.ID+"_material", color), vertices, indices
+}
+
+// GenerateERRelationshipLines generates the line of a relationship along
+// path, with the crow's foot marks of its cardinality at both ends: solid
+// for an identifying relationship, dashed otherwise. Vertices are relative
+// to the first point of path.
+func (g *GeometryGenerator) GenerateERRelationshipLines(rel extuml.ERRelationship, path [][3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	relative := make([][3]float64, len(path))
+	for i, p := range path {
+		relative[i] = vecSub(p, path[0])
+	}
+	if len(relative) == 2 && vecLen(relative[1]) == 0 {
+		relative[1] = [3]float64{0.001, 0, 0}
+	}
+	for i := 1; i < len(relative); i++ {
+		if rel.Identifying {
+			lines.AddSegment(relative[i-1], relative[i])
+		} else {
+			lines.AddDashedSegment(relative[i-1], relative[i], dashLength, dashGap)
+		}
+	}
+
+	last := len(relative) - 1
+	addCardinalityMarks(lines, relative[0], vecNormalize(vecSub(relative[1], relative[0])), rel.FromCardinality)
+	addCardinalityMarks(lines, relative[last], vecNormalize(vecSub(relative[last-1], relative[last])), rel.ToCardinality)
+
+	return lineMesh(rel.ID + "_line"), g.wireframeMaterial(rel.ID+"_material", color), lines
+}
+
+// addCardinalityMarks appends the crow's foot marks of cardinality at the
+// end of a line touching an entity at point, with back pointing away from
+// the entity along the line. The mark nearest the entity gives the maximum
+// (a crow's foot for many, a bar for one) and the next the minimum (a circle
+// for zero, a bar for one). Marks are drawn in two perpendicular planes so
+// that they read from any side.
+func addCardinalityMarks(lines *LineSet, point, back [3]float64, cardinality string) {
+	side := perpendicular(back)
+	normal := vecCross(back, side)
+	along := func(d float64) [3]float64 { return vecAdd(point, vecScale(back, d)) }
+	bar := func(d float64) {
+		for _, axis := range [][3]float64{side, normal} {
+			c := along(d)
+			lines.AddSegment(vecAdd(c, vecScale(axis, cardinalityBar)), vecSub(c, vecScale(axis, cardinalityBar)))
+		}
+	}
+
+	var next float64
+	switch cardinality {
+	case extuml.CardinalityZeroOrMore, extuml.CardinalityOneOrMore:
+		joint := along(crowsFootLength)
+		for _, axis := range [][3]float64{side, normal} {
+			lines.AddSegment(joint, vecAdd(point, vecScale(axis, crowsFootWidth)))
+			lines.AddSegment(joint, vecSub(point, vecScale(axis, crowsFootWidth)))
+		}
+		next = crowsFootLength + cardinalityStep
+	default:
+		bar(cardinalityStep)
+		next = 2 * cardinalityStep
+	}
+
+	switch cardinality {
+	case extuml.CardinalityZeroOrOne, extuml.CardinalityZeroOrMore:
+		center := along(next + cardinalityRing)
+		var ring [][3]float64
+		for i := 0; i <= 12; i++ {
+			a := float64(i) * 2 * math.Pi / 12
+			ring = append(ring, vecAdd(center, vecAdd(vecScale(back, cardinalityRing*math.Cos(a)), vecScale(side, cardinalityRing*math.Sin(a)))))
+		}
+		lines.AddPolyline(ring...)
+	default:
+		bar(next)
+	}
+}
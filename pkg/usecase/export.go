@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/exporter"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/repository"
+)
+
+// ExportUsecase defines interface for export business logic: diagrams in any
+// input format are written back as .extuml, Mermaid or PlantUML source.
+// Execute returns the paths of the files it wrote, numbered as in
+// GenerateUsecase when the input holds several diagrams. The output path
+// repository.Stdout writes every diagram to standard output.
+type ExportUsecase interface {
+	Execute(inputPath, outputPath, inputFormat, outputFormat string) ([]string, diagnostic.List, error)
+}
+
+type exportUsecaseImpl struct {
+	documentLoader
+	sourceRepo repository.SourceRepository
+}
+
+// NewExportUsecase creates a new export usecase
+func NewExportUsecase(extumlRepo repository.ExtumlRepository, mermaidRepo repository.MermaidRepository, plantUMLRepo repository.PlantUMLRepository, documentRepo repository.DocumentRepository, sourceRepo repository.SourceRepository) ExportUsecase {
+	return &exportUsecaseImpl{
+		documentLoader: documentLoader{
+			extumlRepo:   extumlRepo,
+			mermaidRepo:  mermaidRepo,
+			plantUMLRepo: plantUMLRepo,
+			documentRepo: documentRepo,
+		},
+		sourceRepo: sourceRepo,
+	}
+}
+
+func (u *exportUsecaseImpl) Execute(inputPath, outputPath, inputFormat, outputFormat string) ([]string, diagnostic.List, error) {
+	if outputFormat == FormatAuto {
+		outputFormat = DetectFormat(outputPath)
+	}
+	export, err := exporterFor(outputFormat)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	docs, diags, err := u.load(inputPath, inputFormat)
+	if err != nil {
+		return nil, diags, err
+	}
+
+	var written []string
+	for i, doc := range docs {
+		// The exporters write class diagrams only
+		if doc.Elements == nil {
+			return written, diags, fmt.Errorf("cannot export %s: only class diagrams can be exported, not %s diagrams", inputPath, doc.Diagram)
+		}
+		path := outputPath
+		if len(docs) > 1 && path != repository.Stdout {
+			path = numberedPath(path, i+1)
+		}
+		src := export(doc)
+		if outputFormat == FormatMermaid && isMarkdown(path) {
+			src = []byte("```mermaid\n" + string(src) + "```\n")
+		}
+		if err := u.sourceRepo.Write(path, src); err != nil {
+			return written, diags, err
+		}
+		written = append(written, path)
+	}
+	return written, diags, nil
+}
+
+// exporterFor returns the exporter writing the given format
+func exporterFor(format string) (func(*extuml.Document) []byte, error) {
+	switch format {
+	case FormatExtuml:
+		return exporter.DSL, nil
+	case FormatMermaid:
+		return exporter.Mermaid, nil
+	case FormatPlantUML:
+		return exporter.PlantUML, nil
+	}
+	return nil, fmt.Errorf("cannot export to %q (expected extuml, mermaid or plantuml)", format)
+}
+
+// isMarkdown reports whether path is a Markdown file, into which Mermaid is
+// written as a ```mermaid block
+func isMarkdown(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/formatter"
+	"github.com/extuml/extuml/pkg/repository"
+)
+
+// FmtUsecase defines interface for formatting .extuml source in the
+// canonical layout. Directories are searched for .extuml files and the path
+// repository.Stdout reads standard input. With write set, files that are not
+// formatted are rewritten; standard input is always written to standard
+// output. Files with syntax errors are reported in the diagnostics and left
+// alone, and the error is non-nil if there were any.
+type FmtUsecase interface {
+	Execute(paths []string, write bool) ([]FormattedFile, diagnostic.List, error)
+}
+
+// FormattedFile is one file checked by FmtUsecase
+type FormattedFile struct {
+	Path      string
+	Source    []byte // the source as read
+	Formatted []byte // the canonical source
+}
+
+// Changed reports whether formatting changes the file
+func (f FormattedFile) Changed() bool {
+	return string(f.Source) != string(f.Formatted)
+}
+
+// Diff returns the changes formatting makes as a unified diff
+func (f FormattedFile) Diff() []byte {
+	return formatter.Diff(f.Path+".orig", f.Path, f.Source, f.Formatted)
+}
+
+type fmtUsecaseImpl struct {
+	sourceRepo repository.SourceRepository
+}
+
+// NewFmtUsecase creates a new fmt usecase
+func NewFmtUsecase(sourceRepo repository.SourceRepository) FmtUsecase {
+	return &fmtUsecaseImpl{
+		sourceRepo: sourceRepo,
+	}
+}
+
+func (u *fmtUsecaseImpl) Execute(paths []string, write bool) ([]FormattedFile, diagnostic.List, error) {
+	var files []FormattedFile
+	var diags diagnostic.List
+	for _, arg := range paths {
+		listed, err := u.sourceRepo.List(arg)
+		if err != nil {
+			return files, diags, err
+		}
+		for _, path := range listed {
+			src, err := u.sourceRepo.Read(path)
+			if err != nil {
+				return files, diags, err
+			}
+
+			formatted, fileDiags := formatter.Source(path, src)
+			fileDiags.AttachSource(path, src)
+			diags = append(diags, fileDiags...)
+			if formatted == nil {
+				continue
+			}
+
+			file := FormattedFile{Path: path, Source: src, Formatted: formatted}
+			if write && (path == repository.Stdout || file.Changed()) {
+				if err := u.sourceRepo.Write(path, formatted); err != nil {
+					return files, diags, err
+				}
+			}
+			files = append(files, file)
+		}
+	}
+	return files, diags, diags.Err()
+}
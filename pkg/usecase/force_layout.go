@@ -0,0 +1,321 @@
+package usecase
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// Layout algorithms for the classifiers of class diagrams
+const (
+	LayoutForce = "force" // force-directed placement, the default
+	LayoutGrid  = "grid"  // one row per package, kind and layer
+)
+
+// Default limits of the force-directed layout
+const (
+	DefaultLayoutIterations = 500
+	DefaultLayoutEnergy     = 1e-3
+)
+
+// LayoutOptions configure how the classifiers of a class diagram are placed.
+// Every field is used as given; DefaultLayoutOptions returns the defaults.
+type LayoutOptions struct {
+	Algorithm  string  // LayoutForce or LayoutGrid
+	Seed       int64   // seed of the initial positions
+	Iterations int     // maximum number of steps, at least 1
+	Energy     float64 // the layout stops once the mean squared move of a step is below it; 0 runs every step
+}
+
+// DefaultLayoutOptions returns the force-directed layout with seed 0 and the
+// default limits
+func DefaultLayoutOptions() LayoutOptions {
+	return LayoutOptions{
+		Algorithm:  LayoutForce,
+		Iterations: DefaultLayoutIterations,
+		Energy:     DefaultLayoutEnergy,
+	}
+}
+
+// Validate reports options that cannot be used
+func (o LayoutOptions) Validate() error {
+	switch o.Algorithm {
+	case LayoutForce, LayoutGrid:
+	default:
+		return fmt.Errorf("unknown layout %q (expected force or grid)", o.Algorithm)
+	}
+	if o.Iterations < 1 {
+		return fmt.Errorf("layout iterations must be at least 1, got %d", o.Iterations)
+	}
+	if o.Energy < 0 || math.IsNaN(o.Energy) {
+		return fmt.Errorf("layout energy must not be negative, got %g", o.Energy)
+	}
+	return nil
+}
+
+// Force layout constants
+const (
+	forceCohesion    = 0.3  // pull of a package on its members, per unit of distance
+	forceGravity     = 0.05 // pull of the origin on every classifier, per unit of distance
+	forceRange       = 2.0  // classifiers further apart than this many rest distances do not repel
+	forceCooling     = 0.98 // factor by which the largest step shrinks every iteration
+	forceMinDistance = 0.01 // distance below which two classifiers count as coincident
+	forceSeparations = 50   // passes spent pushing overlapping boxes apart
+	forceClearance   = 0.01 // extra distance kept by separated boxes, so that rounding cannot make them touch
+)
+
+// forceNode is a classifier in the force simulation
+type forceNode struct {
+	item   layoutItem
+	pos    [3]float64
+	radius float64 // half the larger of its width and height
+	layerZ float64 // z of its depth layer
+	slack  float64 // how far it may leave its layer in z
+	group  int     // index of its innermost package, -1 for none
+	fixed  bool    // placed with @pos
+}
+
+// forceLayout places items with a force-directed simulation: relationships
+// pull their ends together like springs, all classifiers push each other
+// away, every package pulls its members towards their centre and a weak
+// gravity keeps unrelated parts of the diagram close. Each classifier stays
+// within its depth layer so that package volumes keep their own depth.
+// pinned boxes take part in the simulation but do not move. Initial
+// positions come from opts.Seed, so equal options give equal layouts.
+func forceLayout(items, pinned []layoutItem, spacing float64, owner map[string]string, layerZ func(layoutItem) float64, rels []extuml.Relationship, opts LayoutOptions) map[string][3]float64 {
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	// Initial positions are scattered in a cube that grows with the number
+	// of classifiers, interfaces starting above classes and enums below
+	extent := spacing * math.Cbrt(float64(len(items)+len(pinned)))
+	var nodes []forceNode
+	index := make(map[string]int, len(items)+len(pinned))
+	groups := make(map[string]int)
+	add := func(item layoutItem, fixed bool) {
+		n := forceNode{
+			item:   item,
+			radius: math.Max(item.halfExtents[0], item.halfExtents[1]),
+			layerZ: layerZ(item),
+			slack:  math.Max(0, packageLayerDepth/2-item.halfExtents[2]-packagePadding-layoutGap),
+			group:  -1,
+			fixed:  fixed,
+		}
+		if fixed {
+			n.pos = *item.hint.Position
+		} else {
+			n.pos = [3]float64{
+				(rng.Float64() - 0.5) * extent,
+				item.row + (rng.Float64()-0.5)*extent,
+				n.layerZ + (rng.Float64()-0.5)*2*n.slack,
+			}
+		}
+		if pkg := owner[item.id]; pkg != "" {
+			g, ok := groups[pkg]
+			if !ok {
+				g = len(groups)
+				groups[pkg] = g
+			}
+			n.group = g
+		}
+		index[item.id] = len(nodes)
+		nodes = append(nodes, n)
+	}
+	for _, item := range pinned {
+		add(item, true)
+	}
+	for _, item := range items {
+		add(item, false)
+	}
+
+	type spring struct{ a, b int }
+	var springs []spring
+	for _, rel := range rels {
+		a, okA := index[rel.From]
+		b, okB := index[rel.To]
+		if okA && okB && a != b {
+			springs = append(springs, spring{a, b})
+		}
+	}
+	// rest is the preferred distance between the centres of two classifiers
+	rest := func(a, b int) float64 {
+		return nodes[a].radius + nodes[b].radius + spacing
+	}
+
+	step := extent + spacing
+	force := make([][3]float64, len(nodes))
+	centers := make([][3]float64, len(groups))
+	counts := make([]int, len(groups))
+	for it := 0; it < opts.Iterations && len(items) > 0; it++ {
+		for i := range force {
+			force[i] = [3]float64{}
+		}
+
+		// Repulsion between every pair of neighbours
+		for a := range nodes {
+			for b := a + 1; b < len(nodes); b++ {
+				delta := vecSub(nodes[a].pos, nodes[b].pos)
+				d := vecLen(delta)
+				if d < forceMinDistance {
+					// Coincident boxes part in a seeded direction
+					delta = [3]float64{rng.Float64() - 0.5, rng.Float64() - 0.5, 0}
+					d = math.Max(vecLen(delta), forceMinDistance)
+				}
+				k := rest(a, b)
+				if d > forceRange*k {
+					continue
+				}
+				f := vecScale(delta, k*k/(d*d))
+				force[a] = vecAdd(force[a], f)
+				force[b] = vecSub(force[b], f)
+			}
+		}
+
+		// Springs along relationships
+		for _, s := range springs {
+			delta := vecSub(nodes[s.b].pos, nodes[s.a].pos)
+			f := vecScale(delta, vecLen(delta)/rest(s.a, s.b))
+			force[s.a] = vecAdd(force[s.a], f)
+			force[s.b] = vecSub(force[s.b], f)
+		}
+
+		// Package cohesion and gravity
+		for g := range centers {
+			centers[g], counts[g] = [3]float64{}, 0
+		}
+		for _, n := range nodes {
+			if n.group >= 0 {
+				centers[n.group] = vecAdd(centers[n.group], n.pos)
+				counts[n.group]++
+			}
+		}
+		for i, n := range nodes {
+			if n.group >= 0 {
+				center := vecScale(centers[n.group], 1/float64(counts[n.group]))
+				force[i] = vecAdd(force[i], vecScale(vecSub(center, n.pos), forceCohesion))
+			}
+			force[i] = vecSub(force[i], vecScale(n.pos, forceGravity))
+		}
+
+		// Move every free classifier by at most the current step
+		energy := 0.0
+		for i := range nodes {
+			n := &nodes[i]
+			if n.fixed {
+				continue
+			}
+			move := force[i]
+			if d := vecLen(move); d > step {
+				move = vecScale(move, step/d)
+			}
+			before := n.pos
+			n.pos = vecAdd(n.pos, move)
+			n.pos[2] = math.Max(n.layerZ-n.slack, math.Min(n.layerZ+n.slack, n.pos[2]))
+			moved := vecSub(n.pos, before)
+			energy += vecLen(moved) * vecLen(moved)
+		}
+		step *= forceCooling
+		if energy/float64(len(items)) < opts.Energy {
+			break
+		}
+	}
+
+	// Without pinned boxes the diagram is centred on the origin
+	if len(pinned) == 0 && len(nodes) > 0 {
+		var sum [3]float64
+		for _, n := range nodes {
+			sum = vecAdd(sum, n.pos)
+		}
+		shift := vecScale(sum, 1/float64(len(nodes)))
+		shift[2] = 0
+		for i := range nodes {
+			nodes[i].pos = vecSub(nodes[i].pos, shift)
+		}
+	}
+
+	separateBoxes(nodes)
+
+	positions := make(map[string][3]float64, len(items))
+	for _, n := range nodes {
+		if !n.fixed {
+			positions[n.item.id] = [3]float64{roundLayout(n.pos[0]), roundLayout(n.pos[1]), roundLayout(n.pos[2])}
+		}
+	}
+	return positions
+}
+
+// separateBoxes pushes overlapping boxes apart along X or Y, whichever needs
+// the shorter move, and finally moves any box still overlapping another to
+// the right until it is free
+func separateBoxes(nodes []forceNode) {
+	overlap := func(a, b *forceNode) bool {
+		return boxesOverlap(a.pos, a.item.halfExtents, b.pos, b.item.halfExtents)
+	}
+	for pass := 0; pass < forceSeparations; pass++ {
+		moved := false
+		for a := range nodes {
+			for b := a + 1; b < len(nodes); b++ {
+				na, nb := &nodes[a], &nodes[b]
+				if (na.fixed && nb.fixed) || !overlap(na, nb) {
+					continue
+				}
+				axis, depth := 0, math.Inf(1)
+				for ax := 0; ax < 2; ax++ {
+					need := na.item.halfExtents[ax] + nb.item.halfExtents[ax] + layoutGap + forceClearance - math.Abs(na.pos[ax]-nb.pos[ax])
+					if need < depth {
+						axis, depth = ax, need
+					}
+				}
+				dir := 1.0
+				if na.pos[axis] < nb.pos[axis] {
+					dir = -1
+				}
+				switch {
+				case na.fixed:
+					nb.pos[axis] -= dir * depth
+				case nb.fixed:
+					na.pos[axis] += dir * depth
+				default:
+					na.pos[axis] += dir * depth / 2
+					nb.pos[axis] -= dir * depth / 2
+				}
+				moved = true
+			}
+		}
+		if !moved {
+			return
+		}
+	}
+
+	// Fixed boxes first, then the others in order, each moving right past
+	// the boxes settled before it
+	var settled []*forceNode
+	for i := range nodes {
+		if nodes[i].fixed {
+			settled = append(settled, &nodes[i])
+		}
+	}
+	for i := range nodes {
+		n := &nodes[i]
+		if n.fixed {
+			continue
+		}
+		for collided := true; collided; {
+			collided = false
+			for _, s := range settled {
+				if overlap(n, s) {
+					n.pos[0] = s.pos[0] + s.item.halfExtents[0] + n.item.halfExtents[0] + layoutGap + forceClearance
+					collided = true
+				}
+			}
+		}
+		settled = append(settled, n)
+	}
+}
+
+// roundLayout rounds a coordinate to a thousandth, which keeps the output
+// readable and free of floating-point noise
+func roundLayout(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
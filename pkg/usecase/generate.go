@@ -2,42 +2,144 @@ package usecase
 
 import (
 	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/extuml/extuml/pkg/diagnostic"
 	"github.com/extuml/extuml/pkg/model/extuml"
 	"github.com/extuml/extuml/pkg/model/gltf"
 	"github.com/extuml/extuml/pkg/repository"
 )
 
-// GenerateUsecase defines interface for generate business logic
+// Input formats accepted by Execute
+const (
+	FormatAuto     = ""         // chosen from the file extension
+	FormatExtuml   = "extuml"   // the .extuml DSL
+	FormatMermaid  = "mermaid"  // a Mermaid classDiagram, or Markdown with ```mermaid blocks
+	FormatPlantUML = "plantuml" // PlantUML @startuml ... @enduml class diagrams
+	FormatJSON     = "json"     // an extuml.Document as JSON
+	FormatYAML     = "yaml"     // one or more extuml.Documents as YAML
+)
+
+// GenerateUsecase defines interface for generate business logic. Execute
+// returns the paths of the files it wrote: an input holding several diagrams,
+// such as a Markdown file with several Mermaid blocks, a PlantUML file with
+// several @startuml blocks or a YAML stream of several documents, yields
+// numbered outputs `name-1.gl`, `name-2.gl` and so on.
+//
+// layout configures how the classifiers of class diagrams are placed.
 type GenerateUsecase interface {
-	Execute(extumlPath, outputPath, htmlOutput string) error
+	Execute(inputPath, outputPath, htmlOutput, format string, layout LayoutOptions) ([]string, diagnostic.List, error)
 }
 
 type generateUsecaseImpl struct {
-	extumlRepo repository.ExtumlRepository
-	gltfRepo   repository.GLTFRepository
-	htmlRepo   repository.HTMLRepository
-	geomGen    *GeometryGenerator
-	textGen    *TextGeometryGenerator
+	documentLoader
+	gltfRepo repository.GLTFRepository
+	htmlRepo repository.HTMLRepository
+	geomGen  *GeometryGenerator
+	textGen  *TextGeometryGenerator
 }
 
 // NewGenerateUsecase creates a new generate usecase
-func NewGenerateUsecase(extumlRepo repository.ExtumlRepository, gltfRepo repository.GLTFRepository, htmlRepo repository.HTMLRepository) GenerateUsecase {
+func NewGenerateUsecase(extumlRepo repository.ExtumlRepository, mermaidRepo repository.MermaidRepository, plantUMLRepo repository.PlantUMLRepository, documentRepo repository.DocumentRepository, gltfRepo repository.GLTFRepository, htmlRepo repository.HTMLRepository) GenerateUsecase {
 	return &generateUsecaseImpl{
-		extumlRepo: extumlRepo,
-		gltfRepo:   gltfRepo,
-		htmlRepo:   htmlRepo,
-		geomGen:    NewGeometryGenerator(),
-		textGen:    NewTextGeometryGenerator(),
+		documentLoader: documentLoader{
+			extumlRepo:   extumlRepo,
+			mermaidRepo:  mermaidRepo,
+			plantUMLRepo: plantUMLRepo,
+			documentRepo: documentRepo,
+		},
+		gltfRepo: gltfRepo,
+		htmlRepo: htmlRepo,
+		geomGen:  NewGeometryGenerator(),
+		textGen:  NewTextGeometryGenerator(),
 	}
 }
 
-func (u *generateUsecaseImpl) Execute(extumlPath, outputPath, htmlOutput string) error {
-	// Load extuml DSL
-	doc, err := u.extumlRepo.Load(extumlPath)
+func (u *generateUsecaseImpl) Execute(inputPath, outputPath, htmlOutput, format string, layout LayoutOptions) ([]string, diagnostic.List, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	docs, diags, err := u.load(inputPath, format)
 	if err != nil {
-		return fmt.Errorf("load extuml: %w", err)
+		return nil, diags, err
+	}
+
+	var written []string
+	for i, doc := range docs {
+		gltfPath, htmlPath := outputPath, htmlOutput
+		if len(docs) > 1 {
+			gltfPath = numberedPath(gltfPath, i+1)
+			if htmlPath != "" {
+				htmlPath = numberedPath(htmlPath, i+1)
+			}
+		}
+		if err := u.render(doc, gltfPath, htmlPath, layout); err != nil {
+			return written, diags, err
+		}
+		written = append(written, gltfPath)
+		if htmlPath != "" {
+			written = append(written, htmlPath)
+		}
+	}
+	return written, diags, nil
+}
+
+// DetectFormat returns the input format implied by the extension of path;
+// anything that is not Mermaid, Markdown, PlantUML, JSON or YAML is read as
+// the .extuml DSL
+func DetectFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mmd", ".mermaid", ".md", ".markdown":
+		return FormatMermaid
+	case ".puml", ".plantuml", ".pu", ".iuml", ".wsd":
+		return FormatPlantUML
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	return FormatExtuml
+}
+
+// numberedPath inserts -n before the extension of path
+func numberedPath(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// render builds the glTF asset of one diagram and writes it, along with the
+// HTML viewer if htmlOutput is set
+func (u *generateUsecaseImpl) render(doc *extuml.Document, outputPath, htmlOutput string, layout LayoutOptions) error {
+	// Diagram metadata and settings travel with the asset
+	extumlExtras := map[string]any{
+		"version":     doc.Version,
+		"generatedAt": time.Now().UTC().Format(time.RFC3339),
+	}
+	sceneName := "Scene"
+	if doc.Meta != nil {
+		if doc.Meta.Title != "" {
+			extumlExtras["title"] = doc.Meta.Title
+			sceneName = doc.Meta.Title
+		}
+		if doc.Meta.Author != "" {
+			extumlExtras["author"] = doc.Meta.Author
+		}
+		if doc.Meta.ID != "" {
+			extumlExtras["id"] = doc.Meta.ID
+		}
+	}
+	if len(doc.Config) > 0 {
+		extumlExtras["config"] = doc.Config
+	}
+	if doc.Diagram != extuml.DiagramClass {
+		extumlExtras["diagram"] = doc.Diagram
+	}
+	if background := newStyleResolver(doc).theme.Background; background != "" {
+		extumlExtras["background"] = background
 	}
 
 	// Create glTF asset with geometry
@@ -46,14 +148,11 @@ func (u *generateUsecaseImpl) Execute(extumlPath, outputPath, htmlOutput string)
 			Version:   "2.0",
 			Generator: "extuml-cli v0.1",
 			Extras: map[string]any{
-				"extuml": map[string]any{
-					"version":     doc.Version,
-					"generatedAt": time.Now().UTC().Format(time.RFC3339),
-				},
+				"extuml": extumlExtras,
 			},
 		},
 		Scenes: []gltf.Scene{
-			{Nodes: []int{}, Name: "Scene"},
+			{Nodes: []int{}, Name: sceneName},
 		},
 		Scene:       0,
 		Nodes:       []gltf.Node{},
@@ -67,20 +166,34 @@ func (u *generateUsecaseImpl) Execute(extumlPath, outputPath, htmlOutput string)
 		Accessors:   []gltf.Accessor{},
 	}
 
-	// Generate geometry if elements exist
-	if doc.Elements != nil {
-		u.generateGeometry(doc, gltfAsset)
-
-		// Calculate scene bounds and add camera hint to extras
-		bounds := u.calculateSceneBounds(gltfAsset)
-		if gltfAsset.Asset.Extras == nil {
-			gltfAsset.Asset.Extras = make(map[string]any)
-		}
-		if extrasMap, ok := gltfAsset.Asset.Extras.(map[string]any); ok {
-			extrasMap["camera"] = bounds
+	// Generate geometry for the kind of diagram; a class document may leave
+	// out its elements
+	switch doc.Diagram {
+	case extuml.DiagramSequence:
+		u.generateSequenceGeometry(doc, gltfAsset)
+	case extuml.DiagramState:
+		u.generateStateGeometry(doc, gltfAsset)
+	case extuml.DiagramER:
+		u.generateERGeometry(doc, gltfAsset)
+	case extuml.DiagramComponent, extuml.DiagramDeployment:
+		u.generateComponentGeometry(doc, gltfAsset)
+	case extuml.DiagramActivity:
+		u.generateActivityGeometry(doc, gltfAsset)
+	default:
+		if doc.Elements != nil {
+			u.generateGeometry(doc, gltfAsset, layout)
 		}
 	}
 
+	// Calculate scene bounds and add camera hint to extras
+	bounds := u.calculateSceneBounds(gltfAsset)
+	if gltfAsset.Asset.Extras == nil {
+		gltfAsset.Asset.Extras = make(map[string]any)
+	}
+	if extrasMap, ok := gltfAsset.Asset.Extras.(map[string]any); ok {
+		extrasMap["camera"] = bounds
+	}
+
 	// Write glTF output
 	if err := u.gltfRepo.Write(outputPath, gltfAsset); err != nil {
 		return fmt.Errorf("write glTF: %w", err)
@@ -88,7 +201,7 @@ func (u *generateUsecaseImpl) Execute(extumlPath, outputPath, htmlOutput string)
 
 	// Write HTML viewer if requested
 	if htmlOutput != "" {
-		if err := u.htmlRepo.Write(htmlOutput, outputPath); err != nil {
+		if err := u.htmlRepo.Write(htmlOutput, outputPath, doc.Meta); err != nil {
 			return fmt.Errorf("write HTML: %w", err)
 		}
 	}
@@ -96,40 +209,275 @@ func (u *generateUsecaseImpl) Execute(extumlPath, outputPath, htmlOutput string)
 	return nil
 }
 
-func (u *generateUsecaseImpl) generateGeometry(doc *extuml.Document, asset *gltf.GLTFAsset) {
+// placement records where a classifier was placed, for connecting relationships
+type placement struct {
+	position    [3]float64
+	halfExtents [3]float64
+}
+
+// Package layout constants
+const (
+	packageLayerDepth = 5.0 // Z distance between package layers
+	packagePadding    = 0.5 // Space between a package volume and its members
+)
+
+func (u *generateUsecaseImpl) generateGeometry(doc *extuml.Document, asset *gltf.GLTFAsset, layout LayoutOptions) {
 	nodeIndex := 0
 	spacing := 3.0 // Space between elements
+	placements := make(map[string]placement)
+	memberNodes := make(map[string][]int)
+	styles := newStyleResolver(doc)
+
+	// Each package gets its own depth layer so that sibling volumes never
+	// overlap; top-level elements stay on layer 0
+	packageOrder, owner := u.orderPackages(doc.Elements.Packages)
+	layers := make(map[string]int)
+	for i, pkg := range packageOrder {
+		layers[pkg.ID] = i + 1
+	}
+	layerOf := func(id string) float64 {
+		return -float64(layers[owner[id]]) * packageLayerDepth
+	}
+
+	// Place classifiers: classes on y=0, interfaces above and enums below,
+	// unless their annotations say otherwise
+	var items []layoutItem
+	for _, class := range doc.Elements.Classes {
+		w, h, d := u.geomGen.ClassBoxSize(class)
+		items = append(items, layoutItem{class.ID, 0, [3]float64{w / 2, h / 2, d / 2}, class.Placement})
+	}
+	for _, iface := range doc.Elements.Interfaces {
+		w, h, d := u.geomGen.InterfaceBoxSize(iface)
+		items = append(items, layoutItem{iface.ID, spacing, [3]float64{w / 2, h / 2, d / 2}, iface.Placement})
+	}
+	for _, enum := range doc.Elements.Enums {
+		w, h, d := u.geomGen.EnumBoxSize(enum)
+		items = append(items, layoutItem{enum.ID, -spacing, [3]float64{w / 2, h / 2, d / 2}, enum.Placement})
+	}
+	for id, p := range u.layoutClassifiers(items, spacing, owner, layerOf, doc.Elements.Relationships, layout) {
+		placements[id] = p
+	}
 
 	// Generate classes
-	for i, class := range doc.Elements.Classes {
-		position := [3]float64{float64(i) * spacing, 0, 0}
-		u.addClassToScene(class, position, asset, &nodeIndex)
+	for _, class := range doc.Elements.Classes {
+		first := len(asset.Nodes)
+		style := styles.resolve(styles.theme.Class, class.StyleClasses, class.Style)
+		u.addClassToScene(class, placements[class.ID].position, style, asset, &nodeIndex)
+		memberNodes[class.ID] = nodeRange(first, len(asset.Nodes))
 	}
 
 	// Generate interfaces
-	for i, iface := range doc.Elements.Interfaces {
-		position := [3]float64{float64(i) * spacing, spacing, 0}
-		u.addInterfaceToScene(iface, position, asset, &nodeIndex)
+	for _, iface := range doc.Elements.Interfaces {
+		first := len(asset.Nodes)
+		style := styles.resolve(styles.theme.Interface, iface.StyleClasses, iface.Style)
+		u.addInterfaceToScene(iface, placements[iface.ID].position, style, asset, &nodeIndex)
+		memberNodes[iface.ID] = nodeRange(first, len(asset.Nodes))
 	}
 
 	// Generate enums
-	for i, enum := range doc.Elements.Enums {
-		position := [3]float64{float64(i) * spacing, -spacing, 0}
-		u.addEnumToScene(enum, position, asset, &nodeIndex)
+	for _, enum := range doc.Elements.Enums {
+		first := len(asset.Nodes)
+		style := styles.resolve(styles.theme.Enum, enum.StyleClasses, enum.Style)
+		u.addEnumToScene(enum, placements[enum.ID].position, style, asset, &nodeIndex)
+		memberNodes[enum.ID] = nodeRange(first, len(asset.Nodes))
+	}
+
+	// Generate notes: anchored notes hover in front of their anchor, free
+	// notes get a row of their own above the interfaces, or above every
+	// classifier when the force layout spread them out
+	noteRow := 2 * spacing
+	if layout.Algorithm == LayoutForce {
+		for _, p := range placements {
+			noteRow = math.Max(noteRow, p.position[1]+p.halfExtents[1]+spacing)
+		}
+	}
+	noteSlots := make(map[string]int)
+	anchorSlots := make(map[string]int)
+	for _, note := range doc.Elements.Notes {
+		w, h := u.geomGen.NoteSize(note)
+		anchor, anchored := placements[note.Anchor]
+		var position [3]float64
+		if anchored {
+			k := anchorSlots[note.Anchor]
+			anchorSlots[note.Anchor]++
+			position = vecAdd(anchor.position, [3]float64{float64(k) * (w + 0.5), anchor.halfExtents[1], anchor.halfExtents[2] + 1.0})
+		} else {
+			i := noteSlots[owner[note.ID]]
+			noteSlots[owner[note.ID]]++
+			position = [3]float64{float64(i) * spacing, noteRow, layerOf(note.ID)}
+		}
+		first := len(asset.Nodes)
+		u.addNoteToScene(note, position, colorFactors(styles.theme.Note.Stroke), asset)
+		memberNodes[note.ID] = nodeRange(first, len(asset.Nodes))
+		placements[note.ID] = placement{position, [3]float64{w / 2, h / 2, 0}}
+		if anchored {
+			u.addNoteConnectorToScene(note, placements[note.ID], anchor, colorFactors(styles.theme.Note.Stroke), asset)
+		}
+	}
+
+	// Generate relationships between placed classifiers
+	for _, rel := range doc.Elements.Relationships {
+		from, okFrom := placements[rel.From]
+		to, okTo := placements[rel.To]
+		if !okFrom || !okTo {
+			continue
+		}
+		u.addRelationshipToScene(rel, from, to, colorFactors(styles.theme.Relationship.Stroke), asset)
+	}
+
+	// Wrap package members into parent nodes, innermost packages first so that
+	// enclosing volumes can grow to contain nested ones
+	for i := len(packageOrder) - 1; i >= 0; i-- {
+		pkg := packageOrder[i]
+		layerZ := -float64(layers[pkg.ID]) * packageLayerDepth
+		u.addPackageToScene(pkg, layerZ, "package", colorFactors(styles.theme.Package.Fill), placements, memberNodes, asset)
+	}
+
+	// Labels without a colour of their own follow the theme
+	applyTextColor(asset, styles.theme.Text)
+
+	setSceneRoots(asset)
+}
+
+// setSceneRoots makes every node that is not a child of another a root of
+// the scene
+func setSceneRoots(asset *gltf.GLTFAsset) {
+	if len(asset.Nodes) == 0 {
+		return
+	}
+	isChild := make([]bool, len(asset.Nodes))
+	for _, node := range asset.Nodes {
+		for _, child := range node.Children {
+			isChild[child] = true
+		}
+	}
+	nodeIndices := []int{}
+	for i := range asset.Nodes {
+		if !isChild[i] {
+			nodeIndices = append(nodeIndices, i)
+		}
 	}
+	asset.Scenes[0].Nodes = nodeIndices
+}
 
-	// Update scene nodes
-	if len(asset.Nodes) > 0 {
-		nodeIndices := make([]int, len(asset.Nodes))
-		for i := range asset.Nodes {
-			nodeIndices[i] = i
+// orderPackages returns packages in depth-first declaration order together
+// with a map from each member ID to the ID of its innermost enclosing package
+func (u *generateUsecaseImpl) orderPackages(packages []extuml.Package) ([]extuml.Package, map[string]string) {
+	byID := make(map[string]extuml.Package, len(packages))
+	owner := make(map[string]string)
+	for _, pkg := range packages {
+		byID[pkg.ID] = pkg
+		for _, child := range pkg.Children {
+			owner[child] = pkg.ID
+		}
+	}
+
+	var ordered []extuml.Package
+	visited := make(map[string]bool)
+	var visit func(pkg extuml.Package)
+	visit = func(pkg extuml.Package) {
+		if visited[pkg.ID] {
+			return
+		}
+		visited[pkg.ID] = true
+		ordered = append(ordered, pkg)
+		for _, child := range pkg.Children {
+			if nested, ok := byID[child]; ok {
+				visit(nested)
+			}
 		}
-		asset.Scenes[0].Nodes = nodeIndices
 	}
+	for _, pkg := range packages {
+		if _, nested := owner[pkg.ID]; !nested {
+			visit(pkg)
+		}
+	}
+
+	return ordered, owner
 }
 
-func (u *generateUsecaseImpl) addClassToScene(class extuml.Class, position [3]float64, asset *gltf.GLTFAsset, nodeIndex *int) {
-	mesh, material, bufferData := u.geomGen.GenerateClassWireframe(class, position)
+// addPackageToScene creates the parent node for a package, re-parenting the
+// nodes of its members and adding a translucent bounding volume and a label.
+// The package's own placement is recorded so that enclosing packages can
+// include it in their bounds. kind is the extras type of the node, such as
+// "package" or "schema", and its volume's type is kind + "Volume".
+func (u *generateUsecaseImpl) addPackageToScene(pkg extuml.Package, layerZ float64, kind string, fill [4]float64, placements map[string]placement, memberNodes map[string][]int, asset *gltf.GLTFAsset) {
+	// Bounds of all members (classifiers and nested package volumes)
+	minB := [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	maxB := [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	var members []int
+	for _, child := range pkg.Children {
+		p, ok := placements[child]
+		if !ok {
+			continue
+		}
+		members = append(members, memberNodes[child]...)
+		for axis := 0; axis < 3; axis++ {
+			minB[axis] = math.Min(minB[axis], p.position[axis]-p.halfExtents[axis])
+			maxB[axis] = math.Max(maxB[axis], p.position[axis]+p.halfExtents[axis])
+		}
+	}
+	if len(members) == 0 {
+		// Empty package: a unit volume at the origin of its layer
+		minB = [3]float64{-0.5, -0.5, layerZ - 0.5}
+		maxB = [3]float64{0.5, 0.5, layerZ + 0.5}
+	}
+
+	var center, size [3]float64
+	for axis := 0; axis < 3; axis++ {
+		center[axis] = (minB[axis] + maxB[axis]) / 2
+		size[axis] = maxB[axis] - minB[axis] + 2*packagePadding
+	}
+
+	// Member translations become relative to the package node
+	for _, idx := range members {
+		if t := asset.Nodes[idx].Translation; len(t) == 3 {
+			asset.Nodes[idx].Translation = []float64{t[0] - center[0], t[1] - center[1], t[2] - center[2]}
+		}
+	}
+
+	mesh, material, vertices, indices := u.geomGen.GeneratePackageVolume(pkg, size, fill)
+	volumeIdx := u.addMeshNode(pkg.ID+"_volume", mesh, material, vertices, indices, [3]float64{0, 0, 0}, map[string]any{
+		"extuml": map[string]any{
+			"type": kind + "Volume",
+			"id":   pkg.ID,
+		},
+	}, asset)
+
+	// Package label sits above the front-top edge of the volume
+	labelPos := [3]float64{0, size[1]/2 + 0.3, size[2] / 2}
+	labelIdx := u.addTextLabel(pkg.Name, labelPos, true, "", asset)
+
+	packageIdx := len(asset.Nodes)
+	asset.Nodes = append(asset.Nodes, gltf.Node{
+		Name:        pkg.ID,
+		Translation: []float64{center[0], center[1], center[2]},
+		Children:    append([]int{volumeIdx, labelIdx}, members...),
+		Extras: map[string]any{
+			"extuml": map[string]any{
+				"type":     kind,
+				"id":       pkg.ID,
+				"name":     pkg.Name,
+				"children": len(pkg.Children),
+			},
+		},
+	})
+
+	placements[pkg.ID] = placement{center, [3]float64{size[0] / 2, size[1] / 2, size[2] / 2}}
+	memberNodes[pkg.ID] = []int{packageIdx}
+}
+
+// nodeRange returns the node indices in [first, end)
+func nodeRange(first, end int) []int {
+	indices := make([]int, 0, end-first)
+	for i := first; i < end; i++ {
+		indices = append(indices, i)
+	}
+	return indices
+}
+
+func (u *generateUsecaseImpl) addClassToScene(class extuml.Class, position [3]float64, style extuml.Style, asset *gltf.GLTFAsset, nodeIndex *int) {
+	mesh, material, bufferData := u.geomGen.GenerateClassWireframe(class, position, colorFactors(style.Stroke))
 
 	meshIdx := len(asset.Meshes)
 	materialIdx := len(asset.Materials)
@@ -199,9 +547,13 @@ func (u *generateUsecaseImpl) addClassToScene(class extuml.Class, position [3]fl
 	// Text and cube positions are kept identical for proper alignment.
 	textZ := 0.0
 
-	// Build combined text: class name, attributes, and operations
-	var combinedText string
-	combinedText = class.Name
+	// Build combined text: stereotypes, class name, attributes, and operations
+	name := formatClassifierName(class.Name, class.TypeParameters)
+	if class.Final {
+		name += " {final}"
+	}
+	header, nameLine := labelHeader(formatStereotypes(class.Stereotypes...), name)
+	combinedText := strings.Join(header, "\n")
 
 	// Add URL if present
 	if class.URL != "" {
@@ -214,7 +566,7 @@ func (u *generateUsecaseImpl) addClassToScene(class extuml.Class, position [3]fl
 	// Add attributes
 	if len(class.Attributes) > 0 {
 		for _, attr := range class.Attributes {
-			combinedText += "\n" + attr.Name + ": " + attr.Type
+			combinedText += "\n" + formatAttribute(attr)
 		}
 	}
 
@@ -224,7 +576,7 @@ func (u *generateUsecaseImpl) addClassToScene(class extuml.Class, position [3]fl
 	// Add operations
 	if len(class.Operations) > 0 {
 		for _, op := range class.Operations {
-			combinedText += "\n" + op.Name + "(): " + op.ReturnType
+			combinedText += "\n" + formatOperation(op)
 		}
 	}
 
@@ -235,25 +587,34 @@ func (u *generateUsecaseImpl) addClassToScene(class extuml.Class, position [3]fl
 		Mesh:        &meshIdx,
 		Translation: []float64{position[0], position[1], position[2]},
 		Extras: map[string]any{
-			"extuml": map[string]any{
+			"extuml": classifierExtras(map[string]any{
 				"type":       "class",
 				"id":         class.ID,
+				"name":       class.Name,
 				"attributes": len(class.Attributes),
 				"operations": len(class.Operations),
-			},
+				"abstract":   class.Abstract,
+				"final":      class.Final,
+			}, class.Stereotypes, class.TypeParameters),
 		},
 	})
 
 	// Add text label as independent node (not a child) with world coordinates
 	// Text position = class position + Z offset
 	textWorldPos := [3]float64{position[0], position[1], position[2] + textZ}
-	u.addTextLabel(combinedText, textWorldPos, true, class.URL, asset)
+	textIdx := u.addTextLabel(combinedText, textWorldPos, true, class.URL, asset)
+	if class.Abstract {
+		// Abstract class names are set in italics
+		setLabelStyle(asset, textIdx, "italicLines", []int{nameLine})
+	}
+	w, h, d := u.geomGen.ClassBoxSize(class)
+	u.addStyledExtras(class.ID, style, [3]float64{w, h, d}, position, textIdx, asset)
 
 	*nodeIndex = classNodeIdx + 2 // Class node + text node
 }
 
-func (u *generateUsecaseImpl) addInterfaceToScene(iface extuml.Interface, position [3]float64, asset *gltf.GLTFAsset, nodeIndex *int) {
-	mesh, material, bufferData := u.geomGen.GenerateInterfaceWireframe(iface, position)
+func (u *generateUsecaseImpl) addInterfaceToScene(iface extuml.Interface, position [3]float64, style extuml.Style, asset *gltf.GLTFAsset, nodeIndex *int) {
+	mesh, material, bufferData := u.geomGen.GenerateInterfaceWireframe(iface, position, colorFactors(style.Stroke))
 
 	meshIdx := len(asset.Meshes)
 	materialIdx := len(asset.Materials)
@@ -310,24 +671,41 @@ func (u *generateUsecaseImpl) addInterfaceToScene(iface extuml.Interface, positi
 	asset.Meshes = append(asset.Meshes, mesh)
 	asset.Materials = append(asset.Materials, material)
 
+	ifaceNodeIdx := len(asset.Nodes)
 	asset.Nodes = append(asset.Nodes, gltf.Node{
 		Name:        iface.Name,
 		Mesh:        &meshIdx,
 		Translation: []float64{position[0], position[1], position[2]},
 		Extras: map[string]any{
-			"extuml": map[string]any{
+			"extuml": classifierExtras(map[string]any{
 				"type":       "interface",
 				"id":         iface.ID,
+				"name":       iface.Name,
 				"operations": len(iface.Operations),
-			},
+			}, iface.Stereotypes, iface.TypeParameters),
 		},
 	})
 
-	*nodeIndex++
+	// Label: «interface» line, name and operations
+	stereotypes := append([]string{"interface"}, iface.Stereotypes...)
+	header, _ := labelHeader(formatStereotypes(stereotypes...), formatClassifierName(iface.Name, iface.TypeParameters))
+	text := strings.Join(header, "\n")
+	if iface.URL != "" {
+		text += "\n" + iface.URL
+	}
+	text += "\n---"
+	for _, op := range iface.Operations {
+		text += "\n" + formatOperation(op)
+	}
+	textIdx := u.addTextLabel(text, position, true, iface.URL, asset)
+	w, h, d := u.geomGen.InterfaceBoxSize(iface)
+	u.addStyledExtras(iface.ID, style, [3]float64{w, h, d}, position, textIdx, asset)
+
+	*nodeIndex = ifaceNodeIdx + 2 // Interface node + text node
 }
 
-func (u *generateUsecaseImpl) addEnumToScene(enum extuml.Enum, position [3]float64, asset *gltf.GLTFAsset, nodeIndex *int) {
-	mesh, material, bufferData := u.geomGen.GenerateEnumWireframe(enum, position)
+func (u *generateUsecaseImpl) addEnumToScene(enum extuml.Enum, position [3]float64, style extuml.Style, asset *gltf.GLTFAsset, nodeIndex *int) {
+	mesh, material, bufferData := u.geomGen.GenerateEnumWireframe(enum, position, colorFactors(style.Stroke))
 
 	meshIdx := len(asset.Meshes)
 	materialIdx := len(asset.Materials)
@@ -384,20 +762,252 @@ func (u *generateUsecaseImpl) addEnumToScene(enum extuml.Enum, position [3]float
 	asset.Meshes = append(asset.Meshes, mesh)
 	asset.Materials = append(asset.Materials, material)
 
+	enumNodeIdx := len(asset.Nodes)
 	asset.Nodes = append(asset.Nodes, gltf.Node{
 		Name:        enum.Name,
 		Mesh:        &meshIdx,
 		Translation: []float64{position[0], position[1], position[2]},
 		Extras: map[string]any{
-			"extuml": map[string]any{
+			"extuml": classifierExtras(map[string]any{
 				"type":     "enum",
 				"id":       enum.ID,
+				"name":     enum.Name,
 				"literals": len(enum.Literals),
-			},
+			}, enum.Stereotypes, nil),
 		},
 	})
 
-	*nodeIndex++
+	// Label: «enumeration» line, name and literals
+	stereotypes := append([]string{"enumeration"}, enum.Stereotypes...)
+	header, _ := labelHeader(formatStereotypes(stereotypes...), enum.Name)
+	text := strings.Join(header, "\n")
+	if enum.URL != "" {
+		text += "\n" + enum.URL
+	}
+	text += "\n---"
+	for _, literal := range enum.Literals {
+		text += "\n" + literal
+	}
+	textIdx := u.addTextLabel(text, position, true, enum.URL, asset)
+	w, h, d := u.geomGen.EnumBoxSize(enum)
+	u.addStyledExtras(enum.ID, style, [3]float64{w, h, d}, position, textIdx, asset)
+
+	*nodeIndex = enumNodeIdx + 2 // Enum node + text node
+}
+
+// classifierExtras adds stereotypes and type parameters to a classifier's
+// node extras when present
+func classifierExtras(extras map[string]any, stereotypes, typeParams []string) map[string]any {
+	if len(stereotypes) > 0 {
+		extras["stereotypes"] = stereotypes
+	}
+	if len(typeParams) > 0 {
+		extras["typeParameters"] = typeParams
+	}
+	return extras
+}
+
+// addStyledExtras applies the parts of a classifier's style beyond its
+// wireframe colour: a label colour and a translucent fill volume of the
+// given size
+func (u *generateUsecaseImpl) addStyledExtras(id string, style extuml.Style, size, position [3]float64, textIdx int, asset *gltf.GLTFAsset) {
+	if style.Color != "" {
+		setLabelStyle(asset, textIdx, "color", style.Color)
+	}
+	if style.Fill == "" {
+		return
+	}
+	mesh, material, vertices, indices := u.geomGen.GenerateFillVolume(id, size, colorFactors(style.Fill))
+	u.addMeshNode(id+"_fill", mesh, material, vertices, indices, position, map[string]any{
+		"extuml": map[string]any{
+			"type": "fill",
+			"id":   id,
+		},
+	}, asset)
+}
+
+// setLabelStyle records a rendering hint for the viewer on a text node
+func setLabelStyle(asset *gltf.GLTFAsset, textIdx int, key string, value any) {
+	if extras, ok := asset.Nodes[textIdx].Extras.(map[string]any); ok {
+		if ext, ok := extras["extuml"].(map[string]any); ok {
+			ext[key] = value
+		}
+	}
+}
+
+func (u *generateUsecaseImpl) addNoteToScene(note extuml.Note, position [3]float64, color [4]float64, asset *gltf.GLTFAsset) {
+	mesh, material, lines := u.geomGen.GenerateNotePanel(note, color)
+
+	extumlExtras := map[string]any{
+		"type": "note",
+		"id":   note.ID,
+		"text": note.Text,
+	}
+	if note.Anchor != "" {
+		extumlExtras["anchor"] = note.Anchor
+	}
+	u.addMeshNode(note.ID, mesh, material, lines.Vertices, lines.Indices, position, map[string]any{"extuml": extumlExtras}, asset)
+
+	u.addTextLabel(note.Text, position, true, "", asset)
+}
+
+// placeNotes adds the notes of a diagram other than a class diagram:
+// anchored notes hover in front of their element, others in a row above top
+func (u *generateUsecaseImpl) placeNotes(notes []extuml.Note, placements map[string]placement, top float64, color [4]float64, asset *gltf.GLTFAsset) {
+	anchorSlots := make(map[string]int)
+	free := 0
+	for _, note := range notes {
+		w, h := u.geomGen.NoteSize(note)
+		anchor, anchored := placements[note.Anchor]
+		var position [3]float64
+		if anchored {
+			k := anchorSlots[note.Anchor]
+			anchorSlots[note.Anchor]++
+			position = vecAdd(anchor.position, [3]float64{float64(k) * (w + 0.5), anchor.halfExtents[1], anchor.halfExtents[2] + 1.0})
+		} else {
+			position = [3]float64{float64(free) * (w + 0.5), top + h/2 + 1.0, 0}
+			free++
+		}
+		u.addNoteToScene(note, position, color, asset)
+		if anchored {
+			u.addNoteConnectorToScene(note, placement{position, [3]float64{w / 2, h / 2, 0}}, anchor, color, asset)
+		}
+	}
+}
+
+// addNoteConnectorToScene links the bottom edge of a note panel to the surface
+// of its anchor with a dashed line
+func (u *generateUsecaseImpl) addNoteConnectorToScene(note extuml.Note, panel, anchor placement, color [4]float64, asset *gltf.GLTFAsset) {
+	start := vecSub(panel.position, [3]float64{0, panel.halfExtents[1], 0})
+	dir := vecNormalize(vecSub(anchor.position, start))
+	end := vecSub(anchor.position, vecScale(dir, clipToBox(dir, anchor.halfExtents)))
+
+	mesh, material, lines := u.geomGen.GenerateNoteConnector(note, start, end, color)
+	u.addMeshNode(note.ID+"_connector", mesh, material, lines.Vertices, lines.Indices, start, map[string]any{
+		"extuml": map[string]any{
+			"type":   "noteConnector",
+			"id":     note.ID,
+			"anchor": note.Anchor,
+		},
+	}, asset)
+}
+
+func (u *generateUsecaseImpl) addRelationshipToScene(rel extuml.Relationship, from, to placement, color [4]float64, asset *gltf.GLTFAsset) {
+	// Clip the connector to the surfaces of both boxes; a relationship of a
+	// classifier with itself loops over the top of it
+	path := connectionPath(from, to, rel.From == rel.To, selfLoopUp)
+	mesh, material, lines := u.geomGen.GenerateRelationshipLines(rel, path, color)
+
+	extumlExtras := map[string]any{
+		"type": "relationship",
+		"id":   rel.ID,
+		"kind": rel.Type,
+		"from": rel.From,
+		"to":   rel.To,
+	}
+	if rel.Label != "" {
+		extumlExtras["label"] = rel.Label
+	}
+	if rel.FromMultiplicity != "" {
+		extumlExtras["fromMultiplicity"] = rel.FromMultiplicity
+	}
+	if rel.ToMultiplicity != "" {
+		extumlExtras["toMultiplicity"] = rel.ToMultiplicity
+	}
+
+	u.addMeshNode(rel.ID, mesh, material, lines.Vertices, lines.Indices, path[0], map[string]any{"extuml": extumlExtras}, asset)
+
+	// Labels: relationship name at the midpoint, multiplicities near each end
+	start, end := path[0], path[len(path)-1]
+	if rel.Label != "" {
+		u.addTextLabel(rel.Label, vecAdd(pathMidpoint(path), [3]float64{0, 0.3, 0}), true, "", asset)
+	}
+	if rel.FromMultiplicity != "" {
+		dir := vecNormalize(vecSub(path[1], start))
+		u.addTextLabel(rel.FromMultiplicity, vecAdd(vecAdd(start, vecScale(dir, 0.5)), vecScale(perpendicular(dir), 0.3)), true, "", asset)
+	}
+	if rel.ToMultiplicity != "" {
+		dir := vecNormalize(vecSub(end, path[len(path)-2]))
+		u.addTextLabel(rel.ToMultiplicity, vecAdd(vecSub(end, vecScale(dir, 0.5)), vecScale(perpendicular(dir), 0.3)), true, "", asset)
+	}
+}
+
+// addMeshNode adds a node for a single-primitive mesh with packed XYZ vertices
+// and indices at translation, and returns its index
+func (u *generateUsecaseImpl) addMeshNode(name string, mesh gltf.Mesh, material gltf.Material, vertices []float32, indices []uint32, translation [3]float64, extras map[string]any, asset *gltf.GLTFAsset) int {
+	bufferData := u.geomGen.createBufferData(vertices, indices)
+
+	meshIdx := len(asset.Meshes)
+	materialIdx := len(asset.Materials)
+	bufferIdx := len(asset.Buffers)
+
+	asset.Buffers = append(asset.Buffers, gltf.Buffer{
+		ByteLength: len(bufferData),
+		URI:        u.geomGen.CreateBufferURI(bufferData),
+	})
+
+	vertexCount := len(vertices) / 3
+	indexCount := len(indices)
+	indexType, indexSize := indexComponent(vertexCount)
+	vertexBytes := vertexCount * 3 * 4
+	indexOffset := vertexBytes
+	if indexOffset%4 != 0 {
+		indexOffset += 4 - (indexOffset % 4)
+	}
+
+	positionBufferView := len(asset.BufferViews)
+	asset.BufferViews = append(asset.BufferViews, gltf.BufferView{
+		Buffer:     bufferIdx,
+		ByteOffset: 0,
+		ByteLength: vertexBytes,
+		Target:     intPtr(34962), // ARRAY_BUFFER
+	})
+
+	indicesBufferView := len(asset.BufferViews)
+	asset.BufferViews = append(asset.BufferViews, gltf.BufferView{
+		Buffer:     bufferIdx,
+		ByteOffset: indexOffset,
+		ByteLength: indexCount * indexSize,
+		Target:     intPtr(34963), // ELEMENT_ARRAY_BUFFER
+	})
+
+	positionAccessor := len(asset.Accessors)
+	minBounds, maxBounds := vertexBounds(vertices)
+	asset.Accessors = append(asset.Accessors, gltf.Accessor{
+		BufferView:    &positionBufferView,
+		ByteOffset:    0,
+		ComponentType: 5126, // FLOAT
+		Count:         vertexCount,
+		Type:          "VEC3",
+		Min:           minBounds,
+		Max:           maxBounds,
+	})
+
+	indicesAccessor := len(asset.Accessors)
+	asset.Accessors = append(asset.Accessors, gltf.Accessor{
+		BufferView:    &indicesBufferView,
+		ByteOffset:    0,
+		ComponentType: indexType,
+		Count:         indexCount,
+		Type:          "SCALAR",
+	})
+
+	mesh.Primitives[0].Attributes["POSITION"] = positionAccessor
+	mesh.Primitives[0].Indices = &indicesAccessor
+	mesh.Primitives[0].Material = &materialIdx
+
+	asset.Meshes = append(asset.Meshes, mesh)
+	asset.Materials = append(asset.Materials, material)
+
+	nodeIdx := len(asset.Nodes)
+	asset.Nodes = append(asset.Nodes, gltf.Node{
+		Name:        name,
+		Mesh:        &meshIdx,
+		Translation: []float64{translation[0], translation[1], translation[2]},
+		Extras:      extras,
+	})
+
+	return nodeIdx
 }
 
 // addTextLabel creates a billboard text label node and returns its index
@@ -601,10 +1211,13 @@ func (u *generateUsecaseImpl) calculateSceneBounds(asset *gltf.GLTFAsset) map[st
 	minX, minY, minZ := 1e10, 1e10, 1e10
 	maxX, maxY, maxZ := -1e10, -1e10, -1e10
 
-	// Iterate through all nodes to find bounds
-	for _, node := range asset.Nodes {
+	// Walk the scene graph from the roots, accumulating parent translations
+	var visit func(idx int, offset [3]float64)
+	visit = func(idx int, offset [3]float64) {
+		node := asset.Nodes[idx]
 		if len(node.Translation) == 3 {
-			x, y, z := node.Translation[0], node.Translation[1], node.Translation[2]
+			offset = vecAdd(offset, [3]float64{node.Translation[0], node.Translation[1], node.Translation[2]})
+			x, y, z := offset[0], offset[1], offset[2]
 
 			// Estimate node size (box is 2x2x0.5)
 			nodeWidth, nodeHeight, nodeDepth := 2.0, 2.0, 0.5
@@ -628,6 +1241,12 @@ func (u *generateUsecaseImpl) calculateSceneBounds(asset *gltf.GLTFAsset) map[st
 				maxZ = z + nodeDepth
 			}
 		}
+		for _, child := range node.Children {
+			visit(child, offset)
+		}
+	}
+	for _, root := range asset.Scenes[0].Nodes {
+		visit(root, [3]float64{0, 0, 0})
 	}
 
 	// Calculate center and size
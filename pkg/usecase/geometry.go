@@ -4,11 +4,18 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"math"
+	"strings"
 
 	"github.com/extuml/extuml/pkg/model/extuml"
 	"github.com/extuml/extuml/pkg/model/gltf"
 )
 
+// Opacity of translucent volumes, multiplied with the alpha of their colour
+const (
+	packageOpacity = 0.12
+	fillOpacity    = 0.25
+)
+
 // GeometryGenerator generates 3D geometry for extuml elements
 type GeometryGenerator struct{}
 
@@ -18,12 +25,8 @@ func NewGeometryGenerator() *GeometryGenerator {
 }
 
 // GenerateClassWireframe generates wireframe lines for a class with compartments
-func (g *GeometryGenerator) GenerateClassWireframe(class extuml.Class, position [3]float64) (mesh gltf.Mesh, material gltf.Material, buffers []byte) {
-	// Fixed cube dimensions for all classes
-	size := 2.5
-	width := size
-	height := size
-	depth := size
+func (g *GeometryGenerator) GenerateClassWireframe(class extuml.Class, position [3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, buffers []byte) {
+	width, height, depth := g.ClassBoxSize(class)
 
 	// Generate simple wireframe cube (no compartment dividers)
 	vertices, indices := g.createSimpleWireframeBox(float32(width), float32(height), float32(depth))
@@ -45,32 +48,16 @@ func (g *GeometryGenerator) GenerateClassWireframe(class extuml.Class, position
 		},
 	}
 
-	// Create emissive material for wireframe
-	material = gltf.Material{
-		Name: class.Name + "_material",
-		PbrMetallicRoughness: &gltf.PbrMetallicRoughness{
-			BaseColorFactor: []float64{0.2, 0.6, 0.8, 1.0}, // Blue color for classes
-			MetallicFactor:  0.0,
-			RoughnessFactor: 1.0,
-		},
-		EmissiveFactor: []float64{0.2, 0.6, 0.8}, // Make wireframe glow
-		DoubleSided:    true,
-	}
+	material = g.wireframeMaterial(class.Name+"_material", color)
 
 	return
 }
 
 // GenerateInterfaceWireframe generates wireframe lines for an interface with compartments
-func (g *GeometryGenerator) GenerateInterfaceWireframe(iface extuml.Interface, position [3]float64) (mesh gltf.Mesh, material gltf.Material, buffers []byte) {
-	width := 2.0
+func (g *GeometryGenerator) GenerateInterfaceWireframe(iface extuml.Interface, position [3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, buffers []byte) {
 	// 2 compartments: name, operations
 	nameHeight := float64(0.4)
-	opHeight := float64(len(iface.Operations)) * 0.2
-	if opHeight == 0 {
-		opHeight = 0.2
-	}
-	height := nameHeight + opHeight
-	depth := 0.5
+	width, height, depth := g.InterfaceBoxSize(iface)
 
 	vertices, indices := g.createWireframeBox(float32(width), float32(height), float32(depth), 2, []float32{float32(nameHeight)})
 	buffers = g.createBufferData(vertices, indices)
@@ -88,31 +75,16 @@ func (g *GeometryGenerator) GenerateInterfaceWireframe(iface extuml.Interface, p
 		},
 	}
 
-	material = gltf.Material{
-		Name: iface.Name + "_material",
-		PbrMetallicRoughness: &gltf.PbrMetallicRoughness{
-			BaseColorFactor: []float64{0.8, 0.6, 0.2, 1.0}, // Orange color for interfaces
-			MetallicFactor:  0.0,
-			RoughnessFactor: 1.0,
-		},
-		EmissiveFactor: []float64{0.8, 0.6, 0.2},
-		DoubleSided:    true,
-	}
+	material = g.wireframeMaterial(iface.Name+"_material", color)
 
 	return
 }
 
 // GenerateEnumWireframe generates wireframe lines for an enum
-func (g *GeometryGenerator) GenerateEnumWireframe(enum extuml.Enum, position [3]float64) (mesh gltf.Mesh, material gltf.Material, buffers []byte) {
-	width := 1.5
+func (g *GeometryGenerator) GenerateEnumWireframe(enum extuml.Enum, position [3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, buffers []byte) {
 	// 2 compartments: name, literals
 	nameHeight := float64(0.3)
-	litHeight := float64(len(enum.Literals)) * 0.15
-	if litHeight == 0 {
-		litHeight = 0.15
-	}
-	height := nameHeight + litHeight
-	depth := 0.4
+	width, height, depth := g.EnumBoxSize(enum)
 
 	vertices, indices := g.createWireframeBox(float32(width), float32(height), float32(depth), 2, []float32{float32(nameHeight)})
 	buffers = g.createBufferData(vertices, indices)
@@ -130,20 +102,171 @@ func (g *GeometryGenerator) GenerateEnumWireframe(enum extuml.Enum, position [3]
 		},
 	}
 
+	material = g.wireframeMaterial(enum.Name+"_material", color)
+
+	return
+}
+
+// ClassBoxSize returns the width, height and depth of a class wireframe
+func (g *GeometryGenerator) ClassBoxSize(class extuml.Class) (width, height, depth float64) {
+	// Fixed cube dimensions for all classes
+	size := 2.5
+	return size, size, size
+}
+
+// InterfaceBoxSize returns the width, height and depth of an interface wireframe
+func (g *GeometryGenerator) InterfaceBoxSize(iface extuml.Interface) (width, height, depth float64) {
+	nameHeight := 0.4
+	opHeight := float64(len(iface.Operations)) * 0.2
+	if opHeight == 0 {
+		opHeight = 0.2
+	}
+	return 2.0, nameHeight + opHeight, 0.5
+}
+
+// EnumBoxSize returns the width, height and depth of an enum wireframe
+func (g *GeometryGenerator) EnumBoxSize(enum extuml.Enum) (width, height, depth float64) {
+	nameHeight := 0.3
+	litHeight := float64(len(enum.Literals)) * 0.15
+	if litHeight == 0 {
+		litHeight = 0.15
+	}
+	return 1.5, nameHeight + litHeight, 0.4
+}
+
+// GeneratePackageVolume generates a translucent box enclosing a package's members
+func (g *GeometryGenerator) GeneratePackageVolume(pkg extuml.Package, size [3]float64, fill [4]float64) (mesh gltf.Mesh, material gltf.Material, vertices []float32, indices []uint32) {
+	return g.generateVolume(pkg.ID, size, fill, packageOpacity)
+}
+
+// GenerateFillVolume generates the translucent box drawn inside the
+// wireframe of a classifier styled with a fill colour
+func (g *GeometryGenerator) GenerateFillVolume(id string, size [3]float64, fill [4]float64) (mesh gltf.Mesh, material gltf.Material, vertices []float32, indices []uint32) {
+	return g.generateVolume(id+"_fill", size, fill, fillOpacity)
+}
+
+// generateVolume generates a translucent box of the given size, naming its
+// mesh and material after prefix. The alpha of fill is scaled by opacity.
+func (g *GeometryGenerator) generateVolume(prefix string, size [3]float64, fill [4]float64, opacity float64) (mesh gltf.Mesh, material gltf.Material, vertices []float32, indices []uint32) {
+	vertices, indices = g.createBoxGeometry(float32(size[0]), float32(size[1]), float32(size[2]))
+
+	mesh = gltf.Mesh{
+		Name: prefix + "_volume",
+		Primitives: []gltf.Primitive{
+			{
+				Attributes: map[string]int{
+					"POSITION": 0,
+				},
+				Indices: intPtr(1),
+				Mode:    intPtr(4), // TRIANGLES
+			},
+		},
+	}
+
 	material = gltf.Material{
-		Name: enum.Name + "_material",
+		Name: prefix + "_material",
 		PbrMetallicRoughness: &gltf.PbrMetallicRoughness{
-			BaseColorFactor: []float64{0.6, 0.8, 0.6, 1.0}, // Green color for enums
+			BaseColorFactor: []float64{fill[0], fill[1], fill[2], fill[3] * opacity},
 			MetallicFactor:  0.0,
 			RoughnessFactor: 1.0,
 		},
-		EmissiveFactor: []float64{0.6, 0.8, 0.6},
-		DoubleSided:    true,
+		AlphaMode:   "BLEND",
+		DoubleSided: true,
+	}
+
+	return
+}
+
+// NoteSize returns the width and height of a note panel
+func (g *GeometryGenerator) NoteSize(note extuml.Note) (width, height float64) {
+	lines := float64(strings.Count(note.Text, "\n") + 1)
+	height = 0.4 + lines*0.2
+	if height < 1.0 {
+		height = 1.0
+	}
+	return 2.0, height
+}
+
+// GenerateNotePanel generates a flat folded-corner panel outline for a note
+func (g *GeometryGenerator) GenerateNotePanel(note extuml.Note, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	width, height := g.NoteSize(note)
+	w := width / 2
+	h := height / 2
+	fold := 0.3
+
+	// Outline with the top-right corner cut off, then the folded flap
+	lines = &LineSet{}
+	lines.AddPolyline(
+		[3]float64{-w, -h, 0},
+		[3]float64{w, -h, 0},
+		[3]float64{w, h - fold, 0},
+		[3]float64{w - fold, h, 0},
+		[3]float64{-w, h, 0},
+		[3]float64{-w, -h, 0},
+	)
+	lines.AddPolyline(
+		[3]float64{w - fold, h, 0},
+		[3]float64{w - fold, h - fold, 0},
+		[3]float64{w, h - fold, 0},
+	)
+
+	mesh = gltf.Mesh{
+		Name: note.ID + "_panel",
+		Primitives: []gltf.Primitive{
+			{
+				Attributes: map[string]int{
+					"POSITION": 0,
+				},
+				Indices: intPtr(1),
+				Mode:    intPtr(1), // LINES mode
+			},
+		},
+	}
+
+	material = g.wireframeMaterial(note.ID+"_material", color)
+
+	return
+}
+
+// GenerateNoteConnector generates a dashed line from a note to its anchor.
+// Vertices are relative to start.
+func (g *GeometryGenerator) GenerateNoteConnector(note extuml.Note, start, end [3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	lines.AddDashedSegment([3]float64{0, 0, 0}, vecSub(end, start), dashLength, dashGap)
+
+	mesh = gltf.Mesh{
+		Name: note.ID + "_connector",
+		Primitives: []gltf.Primitive{
+			{
+				Attributes: map[string]int{
+					"POSITION": 0,
+				},
+				Indices: intPtr(1),
+				Mode:    intPtr(1), // LINES mode
+			},
+		},
 	}
 
+	material = g.wireframeMaterial(note.ID+"_connector_material", color)
+
 	return
 }
 
+// wireframeMaterial returns an emissive material so that lines glow in
+// their colour regardless of lighting
+func (g *GeometryGenerator) wireframeMaterial(name string, color [4]float64) gltf.Material {
+	return gltf.Material{
+		Name: name,
+		PbrMetallicRoughness: &gltf.PbrMetallicRoughness{
+			BaseColorFactor: []float64{color[0], color[1], color[2], 1.0},
+			MetallicFactor:  0.0,
+			RoughnessFactor: 1.0,
+		},
+		EmissiveFactor: []float64{color[0], color[1], color[2]},
+		DoubleSided:    true,
+	}
+}
+
 // GenerateInterfaceBox generates a box mesh for an interface (deprecated, use wireframe)
 func (g *GeometryGenerator) GenerateInterfaceBox(iface extuml.Interface, position [3]float64) (mesh gltf.Mesh, material gltf.Material, buffers []byte) {
 	width := 2.0
@@ -213,7 +336,7 @@ func (g *GeometryGenerator) GenerateEnumBox(enum extuml.Enum, position [3]float6
 }
 
 // createBoxGeometry creates vertices and indices for a box
-func (g *GeometryGenerator) createBoxGeometry(width, height, depth float32) ([]float32, []uint16) {
+func (g *GeometryGenerator) createBoxGeometry(width, height, depth float32) ([]float32, []uint32) {
 	w := width / 2
 	h := height / 2
 	d := depth / 2
@@ -235,7 +358,7 @@ func (g *GeometryGenerator) createBoxGeometry(width, height, depth float32) ([]f
 	}
 
 	// 36 indices (2 triangles per face * 6 faces)
-	indices := []uint16{
+	indices := []uint32{
 		0, 1, 2, 0, 2, 3, // Front
 		4, 5, 6, 4, 6, 7, // Back
 		8, 9, 10, 8, 10, 11, // Top
@@ -250,7 +373,7 @@ func (g *GeometryGenerator) createBoxGeometry(width, height, depth float32) ([]f
 // createWireframeBox creates wireframe edges for a box with horizontal compartment dividers
 // compartments: number of compartments (e.g., 3 for class: name, attrs, ops)
 // dividerHeights: heights of each compartment from top (length = compartments-1)
-func (g *GeometryGenerator) createWireframeBox(width, height, depth float32, compartments int, dividerHeights []float32) ([]float32, []uint16) {
+func (g *GeometryGenerator) createWireframeBox(width, height, depth float32, compartments int, dividerHeights []float32) ([]float32, []uint32) {
 	w := width / 2
 	h := height / 2
 	d := depth / 2
@@ -285,7 +408,7 @@ func (g *GeometryGenerator) createWireframeBox(width, height, depth float32, com
 	vertices = append(vertices, dividerVertices...)
 
 	// Create line indices
-	indices := []uint16{
+	indices := []uint32{
 		// 12 edges of the box
 		// Bottom face edges
 		0, 1, 1, 2, 2, 3, 3, 0,
@@ -296,9 +419,9 @@ func (g *GeometryGenerator) createWireframeBox(width, height, depth float32, com
 	}
 
 	// Add divider line indices
-	baseIndex := uint16(8) // 8 corner vertices
+	baseIndex := uint32(8) // 8 corner vertices
 	for i := 0; i < len(dividerHeights); i++ {
-		offset := baseIndex + uint16(i*4)
+		offset := baseIndex + uint32(i*4)
 		// Front edge
 		indices = append(indices, offset, offset+1)
 		// Right edge
@@ -313,7 +436,7 @@ func (g *GeometryGenerator) createWireframeBox(width, height, depth float32, com
 }
 
 // createSimpleWireframeBox creates wireframe edges for a simple box without compartment dividers
-func (g *GeometryGenerator) createSimpleWireframeBox(width, height, depth float32) ([]float32, []uint16) {
+func (g *GeometryGenerator) createSimpleWireframeBox(width, height, depth float32) ([]float32, []uint32) {
 	w := width / 2
 	h := height / 2
 	d := depth / 2
@@ -333,7 +456,7 @@ func (g *GeometryGenerator) createSimpleWireframeBox(width, height, depth float3
 	}
 
 	// Create line indices (12 edges of the box)
-	indices := []uint16{
+	indices := []uint32{
 		// Bottom face edges
 		0, 1, 1, 2, 2, 3, 3, 0,
 		// Top face edges
@@ -345,11 +468,22 @@ func (g *GeometryGenerator) createSimpleWireframeBox(width, height, depth float3
 	return vertices, indices
 }
 
+// indexComponent returns the glTF component type and byte size of the
+// indices of a mesh with vertexCount vertices: unsigned shorts while they can
+// address every vertex, unsigned ints beyond that
+func indexComponent(vertexCount int) (componentType, size int) {
+	if vertexCount <= math.MaxUint16+1 {
+		return 5123, 2 // UNSIGNED_SHORT
+	}
+	return 5125, 4 // UNSIGNED_INT
+}
+
 // createBufferData creates binary buffer data for vertices and indices
-func (g *GeometryGenerator) createBufferData(vertices []float32, indices []uint16) []byte {
+func (g *GeometryGenerator) createBufferData(vertices []float32, indices []uint32) []byte {
 	// Calculate buffer size
+	_, indexSize := indexComponent(len(vertices) / 3)
 	vertexBytes := len(vertices) * 4 // float32 = 4 bytes
-	indexBytes := len(indices) * 2   // uint16 = 2 bytes
+	indexBytes := len(indices) * indexSize
 
 	// Align index offset to 4-byte boundary
 	indexOffset := vertexBytes
@@ -367,7 +501,11 @@ func (g *GeometryGenerator) createBufferData(vertices []float32, indices []uint1
 
 	// Write indices
 	for i, idx := range indices {
-		binary.LittleEndian.PutUint16(buffer[indexOffset+i*2:], idx)
+		if indexSize == 2 {
+			binary.LittleEndian.PutUint16(buffer[indexOffset+i*2:], uint16(idx))
+		} else {
+			binary.LittleEndian.PutUint32(buffer[indexOffset+i*4:], idx)
+		}
 	}
 
 	return buffer
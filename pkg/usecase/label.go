@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// formatAttribute renders an attribute as `+ {static} name: Type [mult] = default`
+func formatAttribute(attr extuml.Attribute) string {
+	var b strings.Builder
+	writeMemberPrefix(&b, attr.Visibility, attr.Static, false)
+	b.WriteString(attr.Name)
+	if attr.Type != "" {
+		b.WriteString(": " + attr.Type)
+	}
+	if attr.Multiplicity != "" {
+		b.WriteString(" [" + attr.Multiplicity + "]")
+	}
+	if attr.Default != "" {
+		b.WriteString(" = " + attr.Default)
+	}
+	return b.String()
+}
+
+// formatOperation renders an operation as `+ {abstract} name(p: T): Return`
+func formatOperation(op extuml.Operation) string {
+	var b strings.Builder
+	writeMemberPrefix(&b, op.Visibility, op.Static, op.Abstract)
+	b.WriteString(op.Name + "(")
+	for i, param := range op.Parameters {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(formatParameter(param))
+	}
+	b.WriteString(")")
+	if op.ReturnType != "" {
+		b.WriteString(": " + op.ReturnType)
+	}
+	return b.String()
+}
+
+// formatParameter renders a parameter as `out name: Type = default`
+func formatParameter(param extuml.Parameter) string {
+	s := param.Name
+	if param.Direction != "" {
+		s = param.Direction + " " + s
+	}
+	if param.Type != "" {
+		s += ": " + param.Type
+	}
+	if param.Default != "" {
+		s += " = " + param.Default
+	}
+	return s
+}
+
+// writeMemberPrefix writes the visibility marker and {static}/{abstract} modifiers
+func writeMemberPrefix(b *strings.Builder, visibility string, static, abstract bool) {
+	if symbol := extuml.VisibilitySymbol(visibility); symbol != "" {
+		b.WriteString(symbol + " ")
+	}
+	if static {
+		b.WriteString("{static} ")
+	}
+	if abstract {
+		b.WriteString("{abstract} ")
+	}
+}
+
+// formatStereotypes renders stereotypes as a single `«a, b»` line, or "" if
+// there are none
+func formatStereotypes(stereotypes ...string) string {
+	if len(stereotypes) == 0 {
+		return ""
+	}
+	return "«" + strings.Join(stereotypes, ", ") + "»"
+}
+
+// formatClassifierName renders a name with its type parameters, as in
+// `Repository<T>`
+func formatClassifierName(name string, typeParams []string) string {
+	if len(typeParams) == 0 {
+		return name
+	}
+	return name + "<" + strings.Join(typeParams, ", ") + ">"
+}
+
+// labelHeader returns the header lines of a classifier label: the stereotype
+// line, if any, followed by the name. nameLine is the index of the name.
+func labelHeader(stereotypes, name string) (lines []string, nameLine int) {
+	if stereotypes != "" {
+		lines = append(lines, stereotypes)
+	}
+	return append(lines, name), len(lines)
+}
@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"math"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+)
+
+// layoutGap is the free space kept between boxes that are placed next to
+// each other or moved aside to avoid an explicitly placed box
+const layoutGap = 0.5
+
+// layoutItem is a classifier waiting to be placed
+type layoutItem struct {
+	id          string
+	row         float64 // y of the automatic row for its kind
+	halfExtents [3]float64
+	hint        *extuml.Placement
+}
+
+// slotKey identifies an automatic row: one per package, kind and layer
+type slotKey struct {
+	owner string
+	row   float64
+	z     float64
+}
+
+// layoutClassifiers positions items in three passes: explicit `@pos` boxes
+// first, then the automatic ones, and finally `@near` boxes beside their
+// target. The grid layout puts automatic boxes in slots along each row of
+// the owning package's layer, skipping slots that would collide with boxes
+// already placed; the force layout places them all at once with forceLayout,
+// pulled together by rels. Items whose `@near` target cannot be placed fall
+// back to a grid slot.
+func (u *generateUsecaseImpl) layoutClassifiers(items []layoutItem, spacing float64, owner map[string]string, layerOf func(id string) float64, rels []extuml.Relationship, opts LayoutOptions) map[string]placement {
+	placements := make(map[string]placement, len(items))
+	var placed []placement
+
+	put := func(item layoutItem, position [3]float64) {
+		p := placement{position, item.halfExtents}
+		placements[item.id] = p
+		placed = append(placed, p)
+	}
+	collides := func(item layoutItem, position [3]float64) bool {
+		for _, p := range placed {
+			if boxesOverlap(position, item.halfExtents, p.position, p.halfExtents) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var pinned, auto, near []layoutItem
+	for _, item := range items {
+		switch {
+		case item.hint != nil && item.hint.Position != nil:
+			pinned = append(pinned, item)
+			put(item, *item.hint.Position)
+		case item.hint != nil && item.hint.Near != "":
+			near = append(near, item)
+		default:
+			auto = append(auto, item)
+		}
+	}
+
+	layerZ := func(item layoutItem) float64 {
+		if item.hint != nil && item.hint.Layer != nil {
+			return -float64(*item.hint.Layer) * packageLayerDepth
+		}
+		return layerOf(item.id)
+	}
+	slots := make(map[slotKey]int)
+	placeAuto := func(item layoutItem) {
+		z := layerZ(item)
+		key := slotKey{owner[item.id], item.row, z}
+		i := slots[key]
+		position := [3]float64{float64(i) * spacing, item.row, z}
+		for collides(item, position) {
+			i++
+			position[0] = float64(i) * spacing
+		}
+		slots[key] = i + 1
+		put(item, position)
+	}
+	if opts.Algorithm == LayoutForce {
+		positions := forceLayout(auto, pinned, spacing, owner, layerZ, rels, opts)
+		for _, item := range auto {
+			put(item, positions[item.id])
+		}
+	} else {
+		for _, item := range auto {
+			placeAuto(item)
+		}
+	}
+
+	// Near targets may themselves be near other items, so keep going while
+	// progress is made
+	for len(near) > 0 {
+		var pending []layoutItem
+		for _, item := range near {
+			target, ok := placements[item.hint.Near]
+			if !ok {
+				pending = append(pending, item)
+				continue
+			}
+			put(item, u.besideTarget(item, target, collides))
+		}
+		if len(pending) == len(near) {
+			// Unknown targets or a cycle of @near hints
+			for _, item := range pending {
+				placeAuto(item)
+			}
+			break
+		}
+		near = pending
+	}
+
+	return placements
+}
+
+// besideTarget returns the first free position next to target, trying right,
+// left, above, below, in front and behind, and moving further out to the
+// right if all of them are taken
+func (u *generateUsecaseImpl) besideTarget(item layoutItem, target placement, collides func(layoutItem, [3]float64) bool) [3]float64 {
+	var offsets [3]float64
+	for axis := 0; axis < 3; axis++ {
+		offsets[axis] = target.halfExtents[axis] + item.halfExtents[axis] + layoutGap
+	}
+	directions := [][3]float64{
+		{1, 0, 0}, {-1, 0, 0},
+		{0, 1, 0}, {0, -1, 0},
+		{0, 0, 1}, {0, 0, -1},
+	}
+	for _, dir := range directions {
+		position := target.position
+		for axis := 0; axis < 3; axis++ {
+			position[axis] += dir[axis] * offsets[axis]
+		}
+		if !collides(item, position) {
+			return position
+		}
+	}
+
+	position := target.position
+	position[0] += offsets[0]
+	for collides(item, position) {
+		position[0] += 2*item.halfExtents[0] + layoutGap
+	}
+	return position
+}
+
+// boxesOverlap reports whether two axis-aligned boxes, grown by half the
+// layout gap each, intersect
+func boxesOverlap(a, aHalf, b, bHalf [3]float64) bool {
+	for axis := 0; axis < 3; axis++ {
+		if math.Abs(a[axis]-b[axis]) >= aHalf[axis]+bHalf[axis]+layoutGap {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,240 @@
+package usecase
+
+import (
+	"math"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+)
+
+// LineSet holds LINES-mode geometry in node-local coordinates
+type LineSet struct {
+	Vertices []float32
+	Indices  []uint32
+}
+
+// AddSegment appends a single line segment from a to b
+func (s *LineSet) AddSegment(a, b [3]float64) {
+	base := uint32(len(s.Vertices) / 3)
+	s.Vertices = append(s.Vertices,
+		float32(a[0]), float32(a[1]), float32(a[2]),
+		float32(b[0]), float32(b[1]), float32(b[2]),
+	)
+	s.Indices = append(s.Indices, base, base+1)
+}
+
+// AddPolyline appends connected segments through the given points
+func (s *LineSet) AddPolyline(points ...[3]float64) {
+	for i := 1; i < len(points); i++ {
+		s.AddSegment(points[i-1], points[i])
+	}
+}
+
+// AddDashedSegment appends a segment broken into dashes
+func (s *LineSet) AddDashedSegment(a, b [3]float64, dash, gap float64) {
+	length := vecLen(vecSub(b, a))
+	if length == 0 {
+		return
+	}
+	dir := vecScale(vecSub(b, a), 1/length)
+	for t := 0.0; t < length; t += dash + gap {
+		end := math.Min(t+dash, length)
+		s.AddSegment(vecAdd(a, vecScale(dir, t)), vecAdd(a, vecScale(dir, end)))
+	}
+}
+
+// Relationship decoration dimensions
+const (
+	arrowLength   = 0.3
+	arrowWidth    = 0.18
+	diamondLength = 0.5
+	diamondWidth  = 0.2
+	dashLength    = 0.25
+	dashGap       = 0.15
+	selfLoopUp    = 1.0 // height of the loop of a line from a box to itself
+)
+
+// GenerateRelationshipLines generates the connector line along path and
+// the end decoration for a relationship. Vertices are relative to the first
+// point; the decoration sits at the last one.
+func (g *GeometryGenerator) GenerateRelationshipLines(rel extuml.Relationship, path [][3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	relative := make([][3]float64, len(path))
+	for i, p := range path {
+		relative[i] = vecSub(p, path[0])
+	}
+	last := len(relative) - 1
+	if last == 1 && vecLen(relative[1]) == 0 {
+		relative[1] = [3]float64{0.001, 0, 0}
+	}
+	tip := relative[last]
+	dir := vecNormalize(vecSub(tip, relative[last-1]))
+	side := perpendicular(dir)
+	normal := vecCross(dir, side)
+
+	// The connector stops where the decoration begins
+	lineEnd := tip
+	switch rel.Type {
+	case extuml.RelInheritance, extuml.RelRealization:
+		base := vecSub(tip, vecScale(dir, arrowLength))
+		c1 := vecAdd(base, vecScale(side, arrowWidth))
+		c2 := vecSub(base, vecScale(side, arrowWidth))
+		lines.AddPolyline(tip, c1, c2, tip)
+		lineEnd = base
+	case extuml.RelAssociation, extuml.RelDependency:
+		base := vecSub(tip, vecScale(dir, arrowLength))
+		lines.AddSegment(tip, vecAdd(base, vecScale(side, arrowWidth)))
+		lines.AddSegment(tip, vecSub(base, vecScale(side, arrowWidth)))
+	case extuml.RelAggregation, extuml.RelComposition:
+		far := vecSub(tip, vecScale(dir, diamondLength))
+		mid := vecSub(tip, vecScale(dir, diamondLength/2))
+		c1 := vecAdd(mid, vecScale(side, diamondWidth))
+		c2 := vecSub(mid, vecScale(side, diamondWidth))
+		lines.AddPolyline(tip, c1, far, c2, tip)
+		if rel.Type == extuml.RelComposition {
+			// Composition is drawn as a solid-looking diamond: a second
+			// diamond in the perpendicular plane plus both diagonals.
+			c3 := vecAdd(mid, vecScale(normal, diamondWidth))
+			c4 := vecSub(mid, vecScale(normal, diamondWidth))
+			lines.AddPolyline(tip, c3, far, c4, tip)
+			lines.AddSegment(c1, c2)
+			lines.AddSegment(c3, c4)
+		}
+		lineEnd = far
+	}
+
+	for i := 1; i <= last; i++ {
+		a, b := relative[i-1], relative[i]
+		if i == last {
+			b = lineEnd
+		}
+		if isDashedRelationship(rel.Type) {
+			lines.AddDashedSegment(a, b, dashLength, dashGap)
+		} else {
+			lines.AddSegment(a, b)
+		}
+	}
+
+	mesh = gltf.Mesh{
+		Name: rel.ID + "_line",
+		Primitives: []gltf.Primitive{
+			{
+				Attributes: map[string]int{
+					"POSITION": 0,
+				},
+				Indices: intPtr(1),
+				Mode:    intPtr(1), // LINES mode
+			},
+		},
+	}
+
+	material = g.wireframeMaterial(rel.ID+"_material", color)
+
+	return
+}
+
+// isDashedRelationship reports whether a relationship kind uses a dashed line
+func isDashedRelationship(kind string) bool {
+	return kind == extuml.RelRealization || kind == extuml.RelDependency || kind == extuml.RelDashedLink
+}
+
+// vertexBounds returns the per-axis min and max of packed XYZ vertices
+func vertexBounds(vertices []float32) (min, max []float64) {
+	min = []float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	max = []float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for i := 0; i+2 < len(vertices); i += 3 {
+		for axis := 0; axis < 3; axis++ {
+			v := float64(vertices[i+axis])
+			min[axis] = math.Min(min[axis], v)
+			max[axis] = math.Max(max[axis], v)
+		}
+	}
+	return min, max
+}
+
+// connectionPath returns the path of a line between two boxes: from surface
+// to surface, or between the centers of nested or overlapping boxes. With
+// self set, the line goes from the box to itself, looping up by up from the
+// left half of its top and back down onto the right half.
+func connectionPath(from, to placement, self bool, up float64) [][3]float64 {
+	if self {
+		h := from.halfExtents
+		q := h[0] / 2
+		return [][3]float64{
+			vecAdd(from.position, [3]float64{-q, h[1], 0}),
+			vecAdd(from.position, [3]float64{-q, h[1] + up, 0}),
+			vecAdd(from.position, [3]float64{q, h[1] + up, 0}),
+			vecAdd(from.position, [3]float64{q, h[1], 0}),
+		}
+	}
+	dir := vecNormalize(vecSub(to.position, from.position))
+	start := vecAdd(from.position, vecScale(dir, clipToBox(dir, from.halfExtents)))
+	end := vecSub(to.position, vecScale(dir, clipToBox(dir, to.halfExtents)))
+	if vecLen(vecSub(end, start)) == 0 || vecLen(vecSub(to.position, from.position)) < vecLen(vecSub(start, from.position))+vecLen(vecSub(to.position, end)) {
+		// Nested or overlapping boxes: connect the centers
+		start, end = from.position, to.position
+	}
+	return [][3]float64{start, end}
+}
+
+// pathMidpoint returns the middle of a straight path, or of the top of a
+// loop
+func pathMidpoint(path [][3]float64) [3]float64 {
+	return vecScale(vecAdd(path[1], path[len(path)-2]), 0.5)
+}
+
+// clipToBox returns the distance from a box center along dir to its surface
+func clipToBox(dir [3]float64, halfExtents [3]float64) float64 {
+	t := math.Inf(1)
+	for axis := 0; axis < 3; axis++ {
+		if d := math.Abs(dir[axis]); d > 1e-9 {
+			t = math.Min(t, halfExtents[axis]/d)
+		}
+	}
+	if math.IsInf(t, 1) {
+		return 0
+	}
+	return t
+}
+
+// perpendicular returns a unit vector perpendicular to dir, preferring the
+// horizontal plane so that arrowheads lie flat for typical layouts
+func perpendicular(dir [3]float64) [3]float64 {
+	side := vecCross(dir, [3]float64{0, 1, 0})
+	if vecLen(side) < 1e-6 {
+		side = vecCross(dir, [3]float64{0, 0, 1})
+	}
+	return vecNormalize(side)
+}
+
+func vecAdd(a, b [3]float64) [3]float64 {
+	return [3]float64{a[0] + b[0], a[1] + b[1], a[2] + b[2]}
+}
+
+func vecSub(a, b [3]float64) [3]float64 {
+	return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func vecScale(a [3]float64, s float64) [3]float64 {
+	return [3]float64{a[0] * s, a[1] * s, a[2] * s}
+}
+
+func vecCross(a, b [3]float64) [3]float64 {
+	return [3]float64{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
+
+func vecLen(a [3]float64) float64 {
+	return math.Sqrt(a[0]*a[0] + a[1]*a[1] + a[2]*a[2])
+}
+
+func vecNormalize(a [3]float64) [3]float64 {
+	l := vecLen(a)
+	if l == 0 {
+		return a
+	}
+	return vecScale(a, 1/l)
+}
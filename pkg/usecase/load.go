@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/repository"
+)
+
+// documentLoader reads diagrams in any of the input formats
+type documentLoader struct {
+	extumlRepo   repository.ExtumlRepository
+	mermaidRepo  repository.MermaidRepository
+	plantUMLRepo repository.PlantUMLRepository
+	documentRepo repository.DocumentRepository
+}
+
+// load reads the diagrams of the input in the given format
+func (l *documentLoader) load(inputPath, format string) ([]*extuml.Document, diagnostic.List, error) {
+	if format == FormatAuto {
+		format = DetectFormat(inputPath)
+	}
+	switch format {
+	case FormatExtuml:
+		doc, diags, err := l.extumlRepo.Load(inputPath)
+		if err != nil {
+			return nil, diags, fmt.Errorf("load extuml: %w", err)
+		}
+		return []*extuml.Document{doc}, diags, nil
+	case FormatMermaid:
+		docs, diags, err := l.mermaidRepo.Load(inputPath)
+		if err != nil {
+			return nil, diags, fmt.Errorf("load mermaid: %w", err)
+		}
+		return docs, diags, nil
+	case FormatPlantUML:
+		docs, diags, err := l.plantUMLRepo.Load(inputPath)
+		if err != nil {
+			return nil, diags, fmt.Errorf("load plantuml: %w", err)
+		}
+		return docs, diags, nil
+	case FormatJSON:
+		docs, diags, err := l.documentRepo.LoadJSON(inputPath)
+		if err != nil {
+			return nil, diags, fmt.Errorf("load json: %w", err)
+		}
+		return docs, diags, nil
+	case FormatYAML:
+		docs, diags, err := l.documentRepo.LoadYAML(inputPath)
+		if err != nil {
+			return nil, diags, fmt.Errorf("load yaml: %w", err)
+		}
+		return docs, diags, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown input format %q", format)
+	}
+}
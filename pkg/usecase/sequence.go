@@ -0,0 +1,297 @@
+package usecase
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+)
+
+// Sequence diagram layout constants
+const (
+	participantSpacing = 4.0 // X distance between lifelines
+	zoneDepth          = 4.0 // Z distance between zones
+	stepHeight         = 0.8 // Y distance between steps
+	fragmentPadding    = 1.4 // X margin of a fragment around its lifelines
+	zonePadding        = 0.5 // margin of a zone volume around its participants
+)
+
+// stepY returns the height of a step: time runs down from below the
+// participant heads
+func stepY(step int) float64 {
+	return -headHeight/2 - float64(step+1)*stepHeight
+}
+
+// generateSequenceGeometry lays out a sequence diagram: participants in
+// declaration order along X, each zone at a depth of its own along Z and
+// time running down Y
+func (u *generateUsecaseImpl) generateSequenceGeometry(doc *extuml.Document, asset *gltf.GLTFAsset) {
+	seq := doc.Sequence
+	styles := newStyleResolver(doc)
+
+	layers := make(map[string]int, len(seq.Zones))
+	for i, zone := range seq.Zones {
+		layers[zone.ID] = i + 1
+	}
+	positions := make(map[string][3]float64, len(seq.Participants))
+	for i, p := range seq.Participants {
+		positions[p.ID] = [3]float64{float64(i) * participantSpacing, 0, -float64(layers[p.Zone]) * zoneDepth}
+	}
+	bottom := stepY(seq.Steps)
+
+	// Zones behind everything else, so that their translucent volumes do
+	// not hide what they contain
+	for _, zone := range seq.Zones {
+		u.addZoneToScene(zone, positions, bottom, colorFactors(styles.theme.Package.Fill), asset)
+	}
+
+	// Participants and their lifelines
+	strokes := make(map[string][4]float64, len(seq.Participants))
+	for i, p := range seq.Participants {
+		base := styles.theme.Class
+		if p.Type == extuml.ParticipantActor {
+			base = styles.theme.Interface
+		}
+		style := styles.resolve(base, p.StyleClasses, p.Style)
+		strokes[p.ID] = colorFactors(style.Stroke)
+		u.addParticipantToScene(p, i, positions[p.ID], bottom, style, asset)
+	}
+
+	// Activation bars, shifted right for every activation they are nested in
+	for i, a := range seq.Activations {
+		top := stepY(a.Start) + stepHeight/4
+		end := stepY(a.End) - stepHeight/4
+		id := fmt.Sprintf("activation_%d", i+1)
+		position := vecAdd(positions[a.Participant], [3]float64{float64(a.Level) * activationOffset, (top + end) / 2, 0})
+		mesh, material, lines := u.geomGen.GenerateActivationBar(id, top-end, strokes[a.Participant])
+		u.addMeshNode(id, mesh, material, lines.Vertices, lines.Indices, position, map[string]any{
+			"extuml": map[string]any{
+				"type":        "activation",
+				"id":          id,
+				"participant": a.Participant,
+				"start":       a.Start,
+				"end":         a.End,
+				"level":       a.Level,
+			},
+		}, asset)
+	}
+
+	for _, msg := range seq.Messages {
+		u.addMessageToScene(msg, positions, colorFactors(styles.theme.Relationship.Stroke), asset)
+	}
+	for _, f := range seq.Fragments {
+		u.addFragmentToScene(f, positions, colorFactors(styles.theme.Enum.Stroke), asset)
+	}
+	for _, note := range seq.Notes {
+		u.addSequenceNoteToScene(note, positions, colorFactors(styles.theme.Note.Stroke), asset)
+	}
+
+	applyTextColor(asset, styles.theme.Text)
+	setSceneRoots(asset)
+}
+
+// addParticipantToScene adds the head, label and lifeline of a participant
+// at the given position, index being its place in the diagram
+func (u *generateUsecaseImpl) addParticipantToScene(p extuml.Participant, index int, position [3]float64, bottom float64, style extuml.Style, asset *gltf.GLTFAsset) {
+	color := colorFactors(style.Stroke)
+	extras := map[string]any{
+		"type":  p.Type,
+		"id":    p.ID,
+		"name":  p.Name,
+		"index": index,
+	}
+	if p.Zone != "" {
+		extras["zone"] = p.Zone
+	}
+	if len(p.StyleClasses) > 0 {
+		extras["styleClasses"] = p.StyleClasses
+	}
+	mesh, material, lines := u.geomGen.GenerateParticipantHead(p, color)
+	u.addMeshNode(p.ID, mesh, material, lines.Vertices, lines.Indices, position, map[string]any{"extuml": extras}, asset)
+
+	// An actor's name sits above the figure, a participant's in its box
+	labelPos := position
+	if p.Type == extuml.ParticipantActor {
+		labelPos = vecAdd(position, [3]float64{0, actorHeight/2 + 0.3, 0})
+	}
+	textIdx := u.addTextLabel(p.Name, labelPos, true, "", asset)
+	if p.Type != extuml.ParticipantActor {
+		u.addStyledExtras(p.ID, style, [3]float64{headWidth, headHeight, headDepth}, position, textIdx, asset)
+	} else if style.Color != "" {
+		setLabelStyle(asset, textIdx, "color", style.Color)
+	}
+
+	start := vecAdd(position, [3]float64{0, -headHeight / 2, 0})
+	if p.Type == extuml.ParticipantActor {
+		start = vecAdd(position, [3]float64{0, -actorHeight / 2, 0})
+	}
+	mesh, material, lines = u.geomGen.GenerateLifeline(p, start[1]-bottom, color)
+	u.addMeshNode(p.ID+"_lifeline", mesh, material, lines.Vertices, lines.Indices, start, map[string]any{
+		"extuml": map[string]any{
+			"type":        "lifeline",
+			"participant": p.ID,
+		},
+	}, asset)
+}
+
+// addMessageToScene adds the arrow of a message between two lifelines and
+// its label
+func (u *generateUsecaseImpl) addMessageToScene(msg extuml.Message, positions map[string][3]float64, color [4]float64, asset *gltf.GLTFAsset) {
+	y := [3]float64{0, stepY(msg.Step), 0}
+	start := vecAdd(positions[msg.From], y)
+	end := vecAdd(positions[msg.To], y)
+
+	extras := map[string]any{
+		"type": "message",
+		"id":   msg.ID,
+		"kind": msg.Kind,
+		"from": msg.From,
+		"to":   msg.To,
+		"step": msg.Step,
+	}
+	if msg.Label != "" {
+		extras["label"] = msg.Label
+	}
+	mesh, material, lines := u.geomGen.GenerateMessageArrow(msg, start, end, color)
+	u.addMeshNode(msg.ID, mesh, material, lines.Vertices, lines.Indices, start, map[string]any{"extuml": extras}, asset)
+
+	if msg.Label != "" {
+		mid := vecScale(vecAdd(start, end), 0.5)
+		if msg.From == msg.To {
+			mid = vecAdd(start, [3]float64{selfMessageWidth / 2, 0, 0})
+		}
+		u.addTextLabel(msg.Label, vecAdd(mid, [3]float64{0, 0.25, 0}), true, "", asset)
+	}
+}
+
+// addFragmentToScene frames the lifelines taking part in a fragment from
+// its header to its end. Nested fragments get smaller margins so that they
+// stay inside the fragments enclosing them.
+func (u *generateUsecaseImpl) addFragmentToScene(f extuml.Fragment, positions map[string][3]float64, color [4]float64, asset *gltf.GLTFAsset) {
+	minB := [3]float64{math.Inf(1), 0, math.Inf(1)}
+	maxB := [3]float64{math.Inf(-1), 0, math.Inf(-1)}
+	for _, id := range f.Participants {
+		p := positions[id]
+		minB[0], maxB[0] = math.Min(minB[0], p[0]), math.Max(maxB[0], p[0])
+		minB[2], maxB[2] = math.Min(minB[2], p[2]), math.Max(maxB[2], p[2])
+	}
+	if len(f.Participants) == 0 {
+		minB, maxB = [3]float64{}, [3]float64{}
+	}
+	padX := math.Max(fragmentPadding-float64(f.Level)*0.25, 0.4)
+	padZ := math.Max(0.6-float64(f.Level)*0.1, 0.2)
+	top := stepY(f.Start) + stepHeight/3
+	end := stepY(f.End)
+	size := [3]float64{maxB[0] - minB[0] + 2*padX, top - end, maxB[2] - minB[2] + 2*padZ}
+	center := [3]float64{(minB[0] + maxB[0]) / 2, (top + end) / 2, (minB[2] + maxB[2]) / 2}
+
+	var separators []float64
+	var operands []map[string]any
+	for i, o := range f.Operands {
+		operand := map[string]any{"start": o.Start, "end": o.End}
+		if o.Guard != "" {
+			operand["guard"] = o.Guard
+		}
+		operands = append(operands, operand)
+		if i > 0 {
+			separators = append(separators, stepY(o.Start)+stepHeight/3-center[1])
+		}
+	}
+
+	mesh, material, lines := u.geomGen.GenerateFragmentFrame(f, size, separators, color)
+	u.addMeshNode(f.ID, mesh, material, lines.Vertices, lines.Indices, center, map[string]any{
+		"extuml": map[string]any{
+			"type":         "fragment",
+			"id":           f.ID,
+			"kind":         f.Kind,
+			"start":        f.Start,
+			"end":          f.End,
+			"level":        f.Level,
+			"operands":     operands,
+			"participants": f.Participants,
+		},
+	}, asset)
+
+	// The kind and first guard label the tab; later guards their separator
+	front := center[2] + size[2]/2
+	left := center[0] - size[0]/2
+	for i, o := range f.Operands {
+		var text string
+		if o.Guard != "" {
+			text = "[" + o.Guard + "]"
+		}
+		y := stepY(o.Start) + stepHeight/3 - 0.2
+		if i == 0 {
+			text = strings.TrimSpace(f.Kind + " " + text)
+			y = top - fragmentTabDepth/2
+		}
+		if text == "" {
+			continue
+		}
+		u.addTextLabel(text, [3]float64{left + fragmentTabWidth/2 + 0.1, y, front}, true, "", asset)
+	}
+}
+
+// addSequenceNoteToScene adds a note panel over, left of or right of its
+// participants, in front of their lifelines
+func (u *generateUsecaseImpl) addSequenceNoteToScene(n extuml.SequenceNote, positions map[string][3]float64, color [4]float64, asset *gltf.GLTFAsset) {
+	note := extuml.Note{ID: n.ID, Type: n.Type, Text: n.Text}
+	w, _ := u.geomGen.NoteSize(note)
+
+	minX, maxX, front := math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, id := range n.Participants {
+		p := positions[id]
+		minX, maxX, front = math.Min(minX, p[0]), math.Max(maxX, p[0]), math.Max(front, p[2])
+	}
+	x := (minX + maxX) / 2
+	switch n.Position {
+	case extuml.NoteLeft:
+		x = minX - w/2 - activationWidth
+	case extuml.NoteRight:
+		x = maxX + w/2 + activationWidth
+	}
+	position := [3]float64{x, stepY(n.Step), front + 0.2}
+
+	mesh, material, lines := u.geomGen.GenerateNotePanel(note, color)
+	u.addMeshNode(n.ID, mesh, material, lines.Vertices, lines.Indices, position, map[string]any{
+		"extuml": map[string]any{
+			"type":         "note",
+			"id":           n.ID,
+			"text":         n.Text,
+			"position":     n.Position,
+			"participants": n.Participants,
+			"step":         n.Step,
+		},
+	}, asset)
+	u.addTextLabel(n.Text, position, true, "", asset)
+}
+
+// addZoneToScene adds a translucent volume around the lifelines of a zone
+// and its label. A zone without participants is not drawn.
+func (u *generateUsecaseImpl) addZoneToScene(zone extuml.Zone, positions map[string][3]float64, bottom float64, fill [4]float64, asset *gltf.GLTFAsset) {
+	if len(zone.Participants) == 0 {
+		return
+	}
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	z := 0.0
+	for _, id := range zone.Participants {
+		p := positions[id]
+		minX, maxX, z = math.Min(minX, p[0]), math.Max(maxX, p[0]), p[2]
+	}
+	top := headHeight/2 + zonePadding
+	size := [3]float64{maxX - minX + headWidth + 2*zonePadding, top - bottom + zonePadding, headDepth + 2*zonePadding}
+	center := [3]float64{(minX + maxX) / 2, (top + bottom - zonePadding) / 2, z}
+
+	mesh, material, vertices, indices := u.geomGen.generateVolume(zone.ID, size, fill, packageOpacity)
+	u.addMeshNode(zone.ID, mesh, material, vertices, indices, center, map[string]any{
+		"extuml": map[string]any{
+			"type":         "zone",
+			"id":           zone.ID,
+			"name":         zone.Name,
+			"participants": zone.Participants,
+		},
+	}, asset)
+	u.addTextLabel(zone.Name, [3]float64{center[0], top + 0.3, z + size[2]/2}, true, "", asset)
+}
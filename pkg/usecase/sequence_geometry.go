@@ -0,0 +1,171 @@
+package usecase
+
+import (
+	"math"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+)
+
+// Sequence diagram dimensions
+const (
+	headWidth        = 2.0 // participant box
+	headHeight       = 0.8
+	headDepth        = 0.8
+	actorHeight      = 1.0  // stick figure
+	activationWidth  = 0.3  // activation bar
+	activationOffset = 0.15 // X shift of each nested activation
+	selfMessageWidth = 1.0  // loop of a message to its sender
+	selfMessageDrop  = 0.4
+	fragmentTabWidth = 1.2 // label tab in the corner of a fragment frame
+	fragmentTabDepth = 0.35
+)
+
+// GenerateParticipantHead generates the head of a lifeline: a box for a
+// participant, a stick figure for an actor
+func (g *GeometryGenerator) GenerateParticipantHead(p extuml.Participant, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	if p.Type == extuml.ParticipantActor {
+		// Head, body, arms and legs within actorHeight
+		h := actorHeight / 2
+		radius := 0.15
+		center := [3]float64{0, h - radius, 0}
+		var circle [][3]float64
+		for i := 0; i <= 12; i++ {
+			a := float64(i) * 2 * math.Pi / 12
+			circle = append(circle, vecAdd(center, [3]float64{radius * math.Cos(a), radius * math.Sin(a), 0}))
+		}
+		lines.AddPolyline(circle...)
+		hip := [3]float64{0, -h / 3, 0}
+		lines.AddSegment([3]float64{0, h - 2*radius, 0}, hip)
+		lines.AddSegment([3]float64{-0.3, h / 3, 0}, [3]float64{0.3, h / 3, 0})
+		lines.AddSegment(hip, [3]float64{-0.25, -h, 0})
+		lines.AddSegment(hip, [3]float64{0.25, -h, 0})
+	} else {
+		addBoxEdges(lines, [3]float64{headWidth, headHeight, headDepth})
+	}
+	return lineMesh(p.ID + "_head"), g.wireframeMaterial(p.ID+"_material", color), lines
+}
+
+// GenerateLifeline generates the dashed lifeline of a participant, running
+// down from its origin
+func (g *GeometryGenerator) GenerateLifeline(p extuml.Participant, length float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	lines.AddDashedSegment([3]float64{0, 0, 0}, [3]float64{0, -length, 0}, dashLength, dashGap)
+	return lineMesh(p.ID + "_lifeline"), g.wireframeMaterial(p.ID+"_lifeline_material", color), lines
+}
+
+// GenerateActivationBar generates an activation bar of the given height,
+// centered on its origin
+func (g *GeometryGenerator) GenerateActivationBar(id string, height float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	addBoxEdges(lines, [3]float64{activationWidth, height, activationWidth})
+	return lineMesh(id), g.wireframeMaterial(id+"_material", color), lines
+}
+
+// GenerateMessageArrow generates the arrow of a message: solid with a closed
+// head for a call, solid with an open head for an asynchronous message and
+// dashed with an open head for a reply. A message to its sender loops out
+// to the right. Vertices are relative to start.
+func (g *GeometryGenerator) GenerateMessageArrow(msg extuml.Message, start, end [3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	origin := [3]float64{0, 0, 0}
+	path := [][3]float64{origin, vecSub(end, start)}
+	if msg.From == msg.To {
+		path = [][3]float64{
+			origin,
+			{selfMessageWidth, 0, 0},
+			{selfMessageWidth, -selfMessageDrop, 0},
+			{0, -selfMessageDrop, 0},
+		}
+	}
+
+	for i := 1; i < len(path); i++ {
+		if msg.Kind == extuml.MessageReturn {
+			lines.AddDashedSegment(path[i-1], path[i], dashLength, dashGap)
+		} else {
+			lines.AddSegment(path[i-1], path[i])
+		}
+	}
+
+	addArrowHead(lines, path[len(path)-2], path[len(path)-1], msg.Kind == extuml.MessageSynchronous)
+	return lineMesh(msg.ID + "_line"), g.wireframeMaterial(msg.ID+"_material", color), lines
+}
+
+// addArrowHead appends the head of an arrow running from from to tip,
+// closed or open. Heads lie in the plane of the diagram, facing the viewer.
+func addArrowHead(lines *LineSet, from, tip [3]float64, closed bool) {
+	dir := vecNormalize(vecSub(tip, from))
+	side := vecNormalize(vecCross(dir, [3]float64{0, 0, 1}))
+	if vecLen(side) == 0 {
+		side = perpendicular(dir)
+	}
+	base := vecSub(tip, vecScale(dir, arrowLength))
+	c1 := vecAdd(base, vecScale(side, arrowWidth))
+	c2 := vecSub(base, vecScale(side, arrowWidth))
+	if closed {
+		lines.AddPolyline(tip, c1, c2, tip)
+		lines.AddSegment(tip, base)
+	} else {
+		lines.AddSegment(tip, c1)
+		lines.AddSegment(tip, c2)
+	}
+}
+
+// GenerateFragmentFrame generates the frame of a combined fragment: a box of
+// the given size centered on its origin with a label tab in its front
+// top-left corner and a dashed line on its front and back faces at each
+// height in separators, where an `else` operand begins
+func (g *GeometryGenerator) GenerateFragmentFrame(f extuml.Fragment, size [3]float64, separators []float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	addBoxEdges(lines, size)
+	w, h, d := size[0]/2, size[1]/2, size[2]/2
+	tab := math.Min(fragmentTabWidth, size[0])
+	lines.AddPolyline(
+		[3]float64{-w + tab, h, d},
+		[3]float64{-w + tab, h - fragmentTabDepth*0.6, d},
+		[3]float64{-w + tab - 0.15, h - fragmentTabDepth, d},
+		[3]float64{-w, h - fragmentTabDepth, d},
+	)
+	for _, y := range separators {
+		lines.AddDashedSegment([3]float64{-w, y, d}, [3]float64{w, y, d}, dashLength, dashGap)
+		lines.AddDashedSegment([3]float64{-w, y, -d}, [3]float64{w, y, -d}, dashLength, dashGap)
+	}
+	return lineMesh(f.ID + "_frame"), g.wireframeMaterial(f.ID+"_material", color), lines
+}
+
+// addBoxEdges appends the twelve edges of a box of the given size centered
+// on the origin
+func addBoxEdges(lines *LineSet, size [3]float64) {
+	w, h, d := size[0]/2, size[1]/2, size[2]/2
+	for _, z := range []float64{d, -d} {
+		lines.AddPolyline(
+			[3]float64{-w, -h, z},
+			[3]float64{w, -h, z},
+			[3]float64{w, h, z},
+			[3]float64{-w, h, z},
+			[3]float64{-w, -h, z},
+		)
+	}
+	for _, x := range []float64{-w, w} {
+		for _, y := range []float64{-h, h} {
+			lines.AddSegment([3]float64{x, y, d}, [3]float64{x, y, -d})
+		}
+	}
+}
+
+// lineMesh returns a LINES-mode mesh whose accessors addMeshNode fills in
+func lineMesh(name string) gltf.Mesh {
+	return gltf.Mesh{
+		Name: name,
+		Primitives: []gltf.Primitive{
+			{
+				Attributes: map[string]int{
+					"POSITION": 0,
+				},
+				Indices: intPtr(1),
+				Mode:    intPtr(1), // LINES mode
+			},
+		},
+	}
+}
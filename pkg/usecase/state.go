@@ -0,0 +1,370 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+)
+
+// State diagram layout constants
+const (
+	stateColumnGap   = 2.0 // X distance between columns of a region
+	stateRowGap      = 1.0 // Y distance between states of a column
+	statePadding     = 0.6 // margin of a composite state around its regions
+	stateTitleHeight = 0.6 // room for the name of a composite state
+)
+
+// stateRegion identifies a region: the ID of its composite state, "" at
+// the top level, and its index
+type stateRegion struct {
+	parent string
+	index  int
+}
+
+// stateLayout places the states of a state machine. Every region is laid
+// out in columns along X, ranked by their distance from the region's
+// initial state, and the regions of a composite state are stacked as slabs
+// along Z, front to back, so that nested regions become nested volumes.
+type stateLayout struct {
+	geomGen     *GeometryGenerator
+	states      map[string]extuml.State
+	members     map[stateRegion][]string // states of each region, in order
+	transitions []extuml.Transition
+
+	size    map[string][3]float64  // size of each state
+	offset  map[string][3]float64  // center relative to its parent's center
+	regions map[string][]placement // slabs of each composite state, relative to it
+}
+
+func newStateLayout(sm *extuml.StateMachine, geomGen *GeometryGenerator) *stateLayout {
+	l := &stateLayout{
+		geomGen:     geomGen,
+		states:      make(map[string]extuml.State, len(sm.States)),
+		members:     make(map[stateRegion][]string),
+		transitions: sm.Transitions,
+		size:        make(map[string][3]float64, len(sm.States)),
+		offset:      make(map[string][3]float64, len(sm.States)),
+		regions:     make(map[string][]placement),
+	}
+	for _, s := range sm.States {
+		l.states[s.ID] = s
+		key := stateRegion{s.Parent, s.Region}
+		l.members[key] = append(l.members[key], s.ID)
+	}
+	l.placeRegion(stateRegion{})
+	return l
+}
+
+// placeRegion lays out the states of a region around its origin, sizing
+// composite states first, and returns the size of the region
+func (l *stateLayout) placeRegion(region stateRegion) [3]float64 {
+	members := l.members[region]
+	for _, id := range members {
+		l.size[id] = l.sizeState(l.states[id])
+	}
+
+	columns := l.rankColumns(region)
+	var size [3]float64
+	widths := make([]float64, len(columns))
+	heights := make([]float64, len(columns))
+	for i, column := range columns {
+		for j, id := range column {
+			s := l.size[id]
+			widths[i] = max(widths[i], s[0])
+			heights[i] += s[1]
+			if j > 0 {
+				heights[i] += stateRowGap
+			}
+			size[2] = max(size[2], s[2])
+		}
+		size[0] += widths[i]
+		if i > 0 {
+			size[0] += stateColumnGap
+		}
+		size[1] = max(size[1], heights[i])
+	}
+
+	x := -size[0] / 2
+	for i, column := range columns {
+		y := heights[i] / 2
+		for _, id := range column {
+			s := l.size[id]
+			l.offset[id] = [3]float64{x + widths[i]/2, y - s[1]/2, 0}
+			y -= s[1] + stateRowGap
+		}
+		x += widths[i] + stateColumnGap
+	}
+	return size
+}
+
+// sizeState returns the size of a state, laying out the regions of a
+// composite state
+func (l *stateLayout) sizeState(s extuml.State) [3]float64 {
+	if s.Regions == 0 {
+		w, h, d := l.geomGen.StateSize(s)
+		return [3]float64{w, h, d}
+	}
+
+	sizes := make([][3]float64, s.Regions)
+	var inner [3]float64
+	for i := range sizes {
+		sizes[i] = l.placeRegion(stateRegion{s.ID, i})
+		inner[0] = max(inner[0], sizes[i][0])
+		inner[1] = max(inner[1], sizes[i][1])
+		inner[2] += sizes[i][2] + 2*statePadding
+	}
+	inner[0] = max(inner[0], float64(len(s.Name))*stateCharWidth)
+	size := [3]float64{inner[0] + 2*statePadding, inner[1] + 2*statePadding + stateTitleHeight, inner[2]}
+
+	// Regions below the title, the first in front
+	z := size[2] / 2
+	for i, r := range sizes {
+		depth := r[2] + 2*statePadding
+		center := [3]float64{0, -stateTitleHeight / 2, z - depth/2}
+		l.regions[s.ID] = append(l.regions[s.ID], placement{center, [3]float64{size[0] / 2, size[1]/2 - stateTitleHeight/2, depth / 2}})
+		for _, id := range l.members[stateRegion{s.ID, i}] {
+			l.offset[id] = vecAdd(l.offset[id], center)
+		}
+		z -= depth
+	}
+	return size
+}
+
+// rankColumns groups the states of a region into columns by their distance
+// from its initial state, following transitions between them and between
+// the states nested in them. States not reached start a search of their
+// own, in declaration order.
+func (l *stateLayout) rankColumns(region stateRegion) [][]string {
+	members := l.members[region]
+	isMember := make(map[string]bool, len(members))
+	for _, id := range members {
+		isMember[id] = true
+	}
+	// memberOf returns the state of the region that id is or is nested in
+	memberOf := func(id string) string {
+		for id != "" && !isMember[id] {
+			id = l.states[id].Parent
+		}
+		return id
+	}
+	next := make(map[string][]string)
+	for _, t := range l.transitions {
+		from, to := memberOf(t.From), memberOf(t.To)
+		if from != "" && to != "" && from != to {
+			next[from] = append(next[from], to)
+		}
+	}
+
+	var starts []string
+	for _, id := range members {
+		if l.states[id].Type == extuml.StateInitial {
+			starts = append(starts, id)
+		}
+	}
+	starts = append(starts, members...)
+
+	rank := make(map[string]int, len(members))
+	var columns [][]string
+	for _, start := range starts {
+		if _, ok := rank[start]; ok {
+			continue
+		}
+		rank[start] = 0
+		queue := []string{start}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			for _, to := range next[id] {
+				if _, ok := rank[to]; !ok {
+					rank[to] = rank[id] + 1
+					queue = append(queue, to)
+				}
+			}
+		}
+	}
+	for _, id := range members {
+		for len(columns) <= rank[id] {
+			columns = append(columns, nil)
+		}
+		columns[rank[id]] = append(columns[rank[id]], id)
+	}
+	return columns
+}
+
+// position returns the center of a state in the scene
+func (l *stateLayout) position(id string) [3]float64 {
+	var p [3]float64
+	for ; id != ""; id = l.states[id].Parent {
+		p = vecAdd(p, l.offset[id])
+	}
+	return p
+}
+
+// generateStateGeometry lays out a state machine: the top-level states in
+// columns from the initial state, and composite states as stacked volumes
+// holding their regions
+func (u *generateUsecaseImpl) generateStateGeometry(doc *extuml.Document, asset *gltf.GLTFAsset) {
+	sm := doc.StateMachine
+	styles := newStyleResolver(doc)
+	layout := newStateLayout(sm, u.geomGen)
+
+	placements := make(map[string]placement, len(sm.States))
+	top := 0.0
+	for _, s := range sm.States {
+		size := layout.size[s.ID]
+		placements[s.ID] = placement{layout.position(s.ID), vecScale(size, 0.5)}
+		if s.Parent == "" {
+			top = max(top, placements[s.ID].position[1]+size[1]/2)
+		}
+	}
+	for _, id := range layout.members[stateRegion{}] {
+		u.addStateTreeToScene(layout.states[id], layout, placements, styles, asset)
+	}
+
+	for _, t := range sm.Transitions {
+		u.addTransitionToScene(t, placements[t.From], placements[t.To], colorFactors(styles.theme.Relationship.Stroke), asset)
+	}
+
+	u.placeNotes(sm.Notes, placements, top, colorFactors(styles.theme.Note.Stroke), asset)
+
+	applyTextColor(asset, styles.theme.Text)
+	setSceneRoots(asset)
+}
+
+// addStateTreeToScene adds a state and, for a composite state, the states
+// nested in it, and returns the indices of its top-level nodes. A composite state
+// becomes a parent node at its center, holding its outline, the volumes of
+// its regions, its label and its nested states, as packages do.
+func (u *generateUsecaseImpl) addStateTreeToScene(s extuml.State, layout *stateLayout, placements map[string]placement, styles styleResolver, asset *gltf.GLTFAsset) []int {
+	base := styles.theme.Class
+	if s.Type != extuml.StateSimple {
+		base = styles.theme.Interface
+	}
+	style := styles.resolve(base, s.StyleClasses, s.Style)
+	p := placements[s.ID]
+	size := layout.size[s.ID]
+
+	extras := map[string]any{
+		"type": s.Type,
+		"id":   s.ID,
+	}
+	if s.Name != "" {
+		extras["name"] = s.Name
+	}
+	if s.Parent != "" {
+		extras["parent"] = s.Parent
+		extras["region"] = s.Region
+	}
+	if len(s.StyleClasses) > 0 {
+		extras["styleClasses"] = s.StyleClasses
+	}
+
+	mesh, material, lines := u.geomGen.GenerateStateShape(s, size, colorFactors(style.Stroke))
+	if s.Regions == 0 {
+		first := len(asset.Nodes)
+		u.addMeshNode(s.ID, mesh, material, lines.Vertices, lines.Indices, p.position, map[string]any{"extuml": extras}, asset)
+		if s.Type == extuml.StateSimple {
+			textIdx := u.addTextLabel(s.Name, p.position, true, "", asset)
+			u.addStyledExtras(s.ID, style, size, p.position, textIdx, asset)
+		}
+		return nodeRange(first, len(asset.Nodes))
+	}
+
+	var members []int
+	for i := 0; i < s.Regions; i++ {
+		for _, id := range layout.members[stateRegion{s.ID, i}] {
+			members = append(members, u.addStateTreeToScene(layout.states[id], layout, placements, styles, asset)...)
+		}
+	}
+	// Nested translations become relative to the composite state
+	for _, idx := range members {
+		if t := asset.Nodes[idx].Translation; len(t) == 3 {
+			asset.Nodes[idx].Translation = []float64{t[0] - p.position[0], t[1] - p.position[1], t[2] - p.position[2]}
+		}
+	}
+
+	fill := colorFactors(styles.theme.Package.Fill)
+	if style.Fill != "" {
+		fill = colorFactors(style.Fill)
+	}
+	children := []int{u.addMeshNode(s.ID+"_outline", mesh, material, lines.Vertices, lines.Indices, [3]float64{}, map[string]any{
+		"extuml": map[string]any{
+			"type": "stateOutline",
+			"id":   s.ID,
+		},
+	}, asset)}
+	for i, r := range layout.regions[s.ID] {
+		mesh, material, vertices, indices := u.geomGen.generateVolume(regionID(s.ID, i), vecScale(r.halfExtents, 2), fill, packageOpacity)
+		children = append(children, u.addMeshNode(regionID(s.ID, i), mesh, material, vertices, indices, r.position, map[string]any{
+			"extuml": map[string]any{
+				"type":   "region",
+				"state":  s.ID,
+				"index":  i,
+				"states": layout.members[stateRegion{s.ID, i}],
+			},
+		}, asset))
+	}
+	labelIdx := u.addTextLabel(s.Name, [3]float64{0, size[1]/2 - stateTitleHeight/2, size[2] / 2}, true, "", asset)
+	if style.Color != "" {
+		setLabelStyle(asset, labelIdx, "color", style.Color)
+	}
+	children = append(children, labelIdx)
+
+	extras["regions"] = s.Regions
+	stateIdx := len(asset.Nodes)
+	asset.Nodes = append(asset.Nodes, gltf.Node{
+		Name:        s.ID,
+		Translation: []float64{p.position[0], p.position[1], p.position[2]},
+		Children:    append(children, members...),
+		Extras:      map[string]any{"extuml": extras},
+	})
+	return []int{stateIdx}
+}
+
+// regionID names the node of a region of a composite state
+func regionID(state string, index int) string {
+	return fmt.Sprintf("%s_region_%d", state, index+1)
+}
+
+// addTransitionToScene adds the arrow of a transition between two states
+// and its label. A transition to its source loops over the top of it.
+func (u *generateUsecaseImpl) addTransitionToScene(t extuml.Transition, from, to placement, color [4]float64, asset *gltf.GLTFAsset) {
+	path := connectionPath(from, to, t.From == t.To, selfTransitionUp)
+
+	extras := map[string]any{
+		"type": "transition",
+		"id":   t.ID,
+		"from": t.From,
+		"to":   t.To,
+	}
+	if t.Event != "" {
+		extras["event"] = t.Event
+	}
+	if t.Guard != "" {
+		extras["guard"] = t.Guard
+	}
+	if t.Action != "" {
+		extras["action"] = t.Action
+	}
+	mesh, material, lines := u.geomGen.GenerateTransitionArrow(t, path, color)
+	u.addMeshNode(t.ID, mesh, material, lines.Vertices, lines.Indices, path[0], map[string]any{"extuml": extras}, asset)
+
+	if label := transitionLabel(t); label != "" {
+		u.addTextLabel(label, vecAdd(pathMidpoint(path), [3]float64{0, 0.3, 0}), true, "", asset)
+	}
+}
+
+// transitionLabel formats the label of a transition as `event [guard] /
+// action`, leaving out the parts it does not have
+func transitionLabel(t extuml.Transition) string {
+	label := t.Event
+	if t.Guard != "" {
+		label += " [" + t.Guard + "]"
+	}
+	if t.Action != "" {
+		label += " / " + t.Action
+	}
+	return strings.TrimSpace(label)
+}
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"math"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+)
+
+// State diagram dimensions
+const (
+	stateMinWidth    = 2.0 // simple state box
+	stateHeight      = 0.9
+	stateDepth       = 0.9
+	stateCharWidth   = 0.15 // width taken by each character of a state's name
+	stateChamfer     = 0.2  // cut corners standing in for rounded ones
+	pseudoStateSize  = 0.5  // initial and final pseudo-states
+	choiceSize       = 0.8
+	barWidth         = 0.2 // fork and join bars
+	barHeight        = 1.6
+	selfTransitionUp = 0.6 // height of the loop of a transition to its source
+)
+
+// StateSize returns the width, height and depth of a state that is not
+// composite
+func (g *GeometryGenerator) StateSize(s extuml.State) (width, height, depth float64) {
+	switch s.Type {
+	case extuml.StateInitial, extuml.StateFinal:
+		return pseudoStateSize, pseudoStateSize, pseudoStateSize
+	case extuml.StateChoice:
+		return choiceSize, choiceSize, choiceSize
+	case extuml.StateFork, extuml.StateJoin:
+		return barWidth, barHeight, stateDepth
+	}
+	return math.Max(stateMinWidth, float64(len(s.Name))*stateCharWidth+0.8), stateHeight, stateDepth
+}
+
+// GenerateStateShape generates the outline of a state of the given size
+// centered on its origin: a box with cut corners for a simple or composite
+// state, an octahedron with its axes for an initial pseudo-state, the same
+// in a ring for a final one, an octahedron for a choice and a bar for a fork
+// or join
+func (g *GeometryGenerator) GenerateStateShape(s extuml.State, size [3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	w, h, d := size[0]/2, size[1]/2, size[2]/2
+	switch s.Type {
+	case extuml.StateInitial:
+		addOctahedron(lines, [3]float64{w, h, d}, true)
+	case extuml.StateFinal:
+		addOctahedron(lines, [3]float64{w * 0.6, h * 0.6, d * 0.6}, true)
+		var xy, yz [][3]float64
+		for i := 0; i <= 16; i++ {
+			a := float64(i) * 2 * math.Pi / 16
+			xy = append(xy, [3]float64{w * math.Cos(a), h * math.Sin(a), 0})
+			yz = append(yz, [3]float64{0, h * math.Sin(a), d * math.Cos(a)})
+		}
+		lines.AddPolyline(xy...)
+		lines.AddPolyline(yz...)
+	case extuml.StateChoice:
+		addOctahedron(lines, [3]float64{w, h, d}, false)
+	case extuml.StateFork, extuml.StateJoin:
+		addBoxEdges(lines, size)
+	default:
+		c := math.Min(stateChamfer, math.Min(w, h)/2)
+		outline := func(z float64) [][3]float64 {
+			return [][3]float64{
+				{-w + c, -h, z}, {w - c, -h, z}, {w, -h + c, z}, {w, h - c, z},
+				{w - c, h, z}, {-w + c, h, z}, {-w, h - c, z}, {-w, -h + c, z},
+			}
+		}
+		front, back := outline(d), outline(-d)
+		lines.AddPolyline(append(front, front[0])...)
+		lines.AddPolyline(append(back, back[0])...)
+		for i := range front {
+			lines.AddSegment(front[i], back[i])
+		}
+	}
+	return lineMesh(s.ID + "_outline"), g.wireframeMaterial(s.ID+"_material", color), lines
+}
+
+// GenerateTransitionArrow generates the arrow of a transition along path,
+// with an open head at its end. Vertices are relative to path[0].
+func (g *GeometryGenerator) GenerateTransitionArrow(t extuml.Transition, path [][3]float64, color [4]float64) (mesh gltf.Mesh, material gltf.Material, lines *LineSet) {
+	lines = &LineSet{}
+	relative := make([][3]float64, len(path))
+	for i, p := range path {
+		relative[i] = vecSub(p, path[0])
+	}
+	lines.AddPolyline(relative...)
+	addArrowHead(lines, relative[len(relative)-2], relative[len(relative)-1], false)
+	return lineMesh(t.ID + "_line"), g.wireframeMaterial(t.ID+"_material", color), lines
+}
+
+// addOctahedron appends the edges of an octahedron with the given half
+// extents centered on the origin, and its three axes if solid is set
+func addOctahedron(lines *LineSet, half [3]float64, solid bool) {
+	x, y, z := half[0], half[1], half[2]
+	ring := [][3]float64{{x, 0, 0}, {0, 0, z}, {-x, 0, 0}, {0, 0, -z}}
+	lines.AddPolyline(append(ring, ring[0])...)
+	for _, p := range ring {
+		lines.AddSegment([3]float64{0, y, 0}, p)
+		lines.AddSegment([3]float64{0, -y, 0}, p)
+	}
+	if solid {
+		lines.AddSegment([3]float64{-x, 0, 0}, [3]float64{x, 0, 0})
+		lines.AddSegment([3]float64{0, -y, 0}, [3]float64{0, y, 0})
+		lines.AddSegment([3]float64{0, 0, -z}, [3]float64{0, 0, z})
+	}
+}
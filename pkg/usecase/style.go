@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"strings"
+
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+)
+
+// styleResolver computes the colours of elements from the selected theme,
+// the `default` classDef, the classDefs applied to them and their `style`
+// statements, in increasing precedence
+type styleResolver struct {
+	theme     extuml.Theme
+	classDefs map[string]extuml.Style
+}
+
+// newStyleResolver selects the theme named in the document's config,
+// falling back to the default theme
+func newStyleResolver(doc *extuml.Document) styleResolver {
+	theme := extuml.Themes[extuml.DefaultTheme]
+	if name, ok := doc.Config["theme"].(string); ok {
+		if selected, ok := extuml.Themes[name]; ok {
+			theme = selected
+		}
+	}
+	return styleResolver{theme: theme, classDefs: doc.ClassDefs}
+}
+
+// resolve returns the style of an element whose theme default is base
+func (r styleResolver) resolve(base extuml.Style, classes []string, inline *extuml.Style) extuml.Style {
+	style := base.Merge(r.classDefs["default"])
+	for _, name := range classes {
+		style = style.Merge(r.classDefs[name])
+	}
+	if inline != nil {
+		style = style.Merge(*inline)
+	}
+	return style
+}
+
+// colorFactors converts a colour validated during lowering to RGBA factors
+func colorFactors(color string) [4]float64 {
+	rgba, _ := extuml.ParseColor(color)
+	return rgba
+}
+
+// applyTextColor sets color on every text label that has no colour of its
+// own yet
+func applyTextColor(asset *gltf.GLTFAsset, color string) {
+	if color == "" {
+		return
+	}
+	for i, node := range asset.Nodes {
+		if !strings.HasPrefix(node.Name, "text_node_") {
+			continue
+		}
+		if extras, ok := node.Extras.(map[string]any); ok {
+			if ext, ok := extras["extuml"].(map[string]any); ok && ext["color"] == nil {
+				setLabelStyle(asset, i, "color", color)
+			}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/formatter"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+const activitySource = `extuml activityDiagram3D
+title: Order handling
+
+classDef urgent stroke:#f00
+
+partition Customer {
+  start
+  action Browse as "Browse catalogue"
+  action Order as "Place order"
+  end Done
+}
+
+partition Shop as "Online shop" {
+  object Cart:::urgent
+  decision InStock
+  fork Split
+  action Ship
+  action Invoice
+  join Joined
+  merge Closed
+}
+
+start --> Browse
+Browse --> Order
+Order --> Cart
+Cart --> InStock
+InStock --> Split : [yes]
+InStock --> Closed : [no] out of stock
+Split --> Ship
+Split --> Invoice
+Ship --> Joined
+Invoice --> Joined
+Joined --> Closed
+Closed --> Done
+Done --> Archive
+note for InStock "checked per warehouse"
+`
+
+func TestActivityLowering(t *testing.T) {
+	doc := lowerSource(t, "order.extuml", []byte(activitySource))
+	if doc.Diagram != extuml.DiagramActivity || doc.Elements != nil || doc.Activity == nil {
+		t.Fatalf("expected an activity document, got %+v", doc)
+	}
+	act := doc.Activity
+
+	nodes := map[string]extuml.ActivityNode{}
+	for _, n := range act.Nodes {
+		nodes[n.ID] = n
+	}
+	for id, want := range map[string]extuml.ActivityNode{
+		"start":  {ID: "start", Type: extuml.ActivityStart, Partition: "Customer"},
+		"Browse": {ID: "Browse", Type: extuml.ActivityAction, Name: "Browse catalogue", Partition: "Customer"},
+		"Done":   {ID: "Done", Type: extuml.ActivityEnd, Name: "Done", Partition: "Customer"},
+		"Cart":   {ID: "Cart", Type: extuml.ActivityObject, Name: "Cart", Partition: "Shop", StyleClasses: []string{"urgent"}},
+		"Split":  {ID: "Split", Type: extuml.ActivityFork, Name: "Split", Partition: "Shop"},
+		"Closed": {ID: "Closed", Type: extuml.ActivityMerge, Name: "Closed", Partition: "Shop"},
+		// Archive is only used by a flow at the top level
+		"Archive": {ID: "Archive", Type: extuml.ActivityAction, Name: "Archive"},
+	} {
+		if !reflect.DeepEqual(nodes[id], want) {
+			t.Errorf("unexpected node %s\n got %+v\nwant %+v", id, nodes[id], want)
+		}
+	}
+
+	wantPartitions := []extuml.Partition{
+		{ID: "Customer", Name: "Customer", Nodes: []string{"start", "Browse", "Order", "Done"}},
+		{ID: "Shop", Name: "Online shop", Nodes: []string{"Cart", "InStock", "Split", "Ship", "Invoice", "Joined", "Closed"}},
+	}
+	if !reflect.DeepEqual(act.Partitions, wantPartitions) {
+		t.Errorf("unexpected partitions %+v", act.Partitions)
+	}
+
+	if len(act.Flows) != 13 {
+		t.Fatalf("expected 13 flows, got %+v", act.Flows)
+	}
+	for i, want := range map[int]extuml.Flow{
+		2: {ID: "flow_3", Type: extuml.FlowObject, From: "Order", To: "Cart"},
+		4: {ID: "flow_5", Type: extuml.FlowControl, From: "InStock", To: "Split", Guard: "yes"},
+		5: {ID: "flow_6", Type: extuml.FlowControl, From: "InStock", To: "Closed", Guard: "no", Label: "out of stock"},
+	} {
+		if !reflect.DeepEqual(act.Flows[i], want) {
+			t.Errorf("unexpected flow\n got %+v\nwant %+v", act.Flows[i], want)
+		}
+	}
+	if len(act.Notes) != 1 || act.Notes[0].Anchor != "InStock" {
+		t.Errorf("unexpected notes %+v", act.Notes)
+	}
+}
+
+func TestActivityDiagnostics(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, code string
+	}{
+		{"dependency arrow", "extuml activityDiagram3D\nA ..> B\n", "E103"},
+		{"nested partition", "extuml activityDiagram3D\npartition a {\n  partition b {\n  }\n}\n", "E103"},
+		{"action without a name", "extuml activityDiagram3D\naction\n", "E103"},
+		{"partition without a body", "extuml activityDiagram3D\npartition a\n", "E105"},
+		{"unclosed partition", "extuml activityDiagram3D\npartition a {\n  action A\n", "E102"},
+		{"duplicate node", "extuml activityDiagram3D\naction A\ndecision A\n", "E200"},
+		{"node in two partitions", "extuml activityDiagram3D\npartition a {\n  action A\n}\npartition b {\n  action A\n}\n", "E200"},
+		{"flow to a partition", "extuml activityDiagram3D\npartition a {\n}\nstart --> a\n", "W200"},
+		{"unknown note anchor", "extuml activityDiagram3D\nnote for A \"n\"\n", "W201"},
+		{"unknown style class", "extuml activityDiagram3D\naction A:::missing\n", "W208"},
+	} {
+		diags := parseAndLower("order.extuml", tc.src)
+		found := false
+		for _, d := range diags {
+			found = found || d.Code == tc.code
+		}
+		if !found {
+			t.Errorf("%s: expected %s, got %v", tc.name, tc.code, diags)
+		}
+	}
+
+	if diags := parseAndLower("order.extuml", "extuml activityDiagram3D\nstart\nstart --> A\nA --> end\nend\npartition p {\n  A --> B\n}\nstyle B fill:#0f0\nnote for p \"lane\"\n"); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
+
+func TestGenerateActivity(t *testing.T) {
+	asset, byType, nodes := generateScene(t, t.TempDir(), "order.extuml", activitySource)
+	if ext := asset.Asset.Extras.(map[string]any)["extuml"].(map[string]any); ext["diagram"] != "activity" {
+		t.Errorf("unexpected asset extras %v", ext)
+	}
+	for kind, count := range map[string]int{
+		"action": 5, "object": 1, "decision": 1, "merge": 1, "fork": 1, "join": 1, "start": 1, "end": 1,
+		"flow": 13, "partition": 2, "partitionVolume": 2, "note": 1,
+	} {
+		if len(byType[kind]) != count {
+			t.Errorf("expected %d %s nodes, got %d", count, kind, len(byType[kind]))
+		}
+	}
+
+	// Partitions are planes one behind the other, holding their nodes
+	customer, shop := nodes["Customer"], nodes["Shop"]
+	if !containsNode(asset, customer, "Browse") || !containsNode(asset, shop, "InStock") {
+		t.Errorf("expected partitions to be parent nodes of their nodes")
+	}
+	if archive := nodes["Archive"]; customer.Translation[2] >= archive.Translation[2] || shop.Translation[2] >= customer.Translation[2] {
+		t.Errorf("expected partitions behind the nodes in none, got %v, %v and %v", archive.Translation, customer.Translation, shop.Translation)
+	}
+
+	crossing := map[any]bool{}
+	for _, e := range byType["flow"] {
+		crossing[e["id"]] = e["crossesPartitions"] == true
+		if e["id"] == "flow_6" && (e["kind"] != "control" || e["guard"] != "no" || e["label"] != "out of stock") {
+			t.Errorf("unexpected flow extras %v", e)
+		}
+	}
+	if !crossing["flow_3"] || crossing["flow_2"] {
+		t.Errorf("expected only flows between partitions to cross them, got %v", crossing)
+	}
+	for _, e := range byType["object"] {
+		if e["id"] != "Cart" || e["partition"] != "Shop" || !reflect.DeepEqual(e["styleClasses"], []any{"urgent"}) {
+			t.Errorf("unexpected object extras %v", e)
+		}
+	}
+}
+
+func TestFormatActivity(t *testing.T) {
+	src := `extuml activityDiagram3D
+partition   Shop as "Online shop"{
+  start
+action   Pay:::hot
+   decision Paid
+}
+start-->Pay
+  Pay --> Paid :[ok] done
+`
+	want := `extuml activityDiagram3D
+
+partition Shop as "Online shop" {
+  start
+  action Pay:::hot
+  decision Paid
+}
+
+start --> Pay
+Pay --> Paid : [ok] done
+`
+	out, diags := formatter.Source("order.extuml", []byte(src))
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if string(out) != want {
+		t.Errorf("unexpected formatting:\n%s\nwant:\n%s", out, want)
+	}
+
+	for name, src := range map[string][]byte{"shop.extuml": []byte(src), "order.extuml": []byte(activitySource)} {
+		file, _ := parser.Parse(name, src)
+		before, _ := parser.Lower(file)
+		out, _ := formatter.Source(name, src)
+		file, _ = parser.Parse(name, out)
+		after, _ := parser.Lower(file)
+		if !reflect.DeepEqual(before, after) {
+			t.Errorf("%s: document changed by formatting\n got %+v\nwant %+v", name, after, before)
+		}
+	}
+}
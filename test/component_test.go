@@ -0,0 +1,269 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/formatter"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+const deploymentSource = `extuml deploymentDiagram3D
+title: Platform
+
+classDef edge stroke:#f90
+
+device Browser
+
+cluster prod as "Production" {
+  namespace shop {
+    pod api <<deployment>> {
+      port http
+      container app {
+        component OrderService:::edge {
+          port rest
+          provides IOrders
+          requires IPayments, IStock
+        }
+        artifact orders_jar as "orders.jar"
+      }
+    }
+    pod db {
+      port sql
+    }
+  }
+}
+
+component Billing {
+  provides IPayments
+}
+
+Browser --> prod.shop.api.http : HTTPS
+api.app.OrderService.rest -- db.sql
+orders_jar ..> OrderService : manifests
+Billing .. Ledger
+note for OrderService "handles checkout"
+`
+
+func TestComponentLowering(t *testing.T) {
+	doc := lowerSource(t, "platform.extuml", []byte(deploymentSource))
+	if doc.Diagram != extuml.DiagramDeployment || doc.Elements != nil || doc.Architecture == nil {
+		t.Fatalf("expected a deployment document, got %+v", doc)
+	}
+	arch := doc.Architecture
+
+	wantNodes := []extuml.DeploymentNode{
+		{ID: "Browser", Type: "device", Name: "Browser"},
+		{ID: "prod", Type: "cluster", Name: "Production"},
+		{ID: "prod.shop", Type: "namespace", Name: "shop", Parent: "prod"},
+		{ID: "prod.shop.api", Type: "pod", Name: "api", Parent: "prod.shop", Stereotypes: []string{"deployment"}, Ports: []extuml.Port{{ID: "prod.shop.api.http", Name: "http"}}},
+		{ID: "prod.shop.api.app", Type: "container", Name: "app", Parent: "prod.shop.api"},
+		{ID: "prod.shop.db", Type: "pod", Name: "db", Parent: "prod.shop", Ports: []extuml.Port{{ID: "prod.shop.db.sql", Name: "sql"}}},
+	}
+	if !reflect.DeepEqual(arch.Nodes, wantNodes) {
+		t.Errorf("unexpected nodes\n got %+v\nwant %+v", arch.Nodes, wantNodes)
+	}
+
+	components := map[string]extuml.Component{}
+	for _, c := range arch.Components {
+		components[c.ID] = c
+	}
+	order := components["prod.shop.api.app.OrderService"]
+	if order.Parent != "prod.shop.api.app" || !reflect.DeepEqual(order.Provides, []string{"IOrders"}) ||
+		!reflect.DeepEqual(order.Requires, []string{"IPayments", "IStock"}) || !reflect.DeepEqual(order.StyleClasses, []string{"edge"}) {
+		t.Errorf("unexpected component %+v", order)
+	}
+	// Ledger is only used by a connector at the top level
+	if ledger, ok := components["Ledger"]; !ok || ledger.Parent != "" {
+		t.Errorf("expected an implicit Ledger component, got %+v", ledger)
+	}
+	if len(arch.Artifacts) != 1 || arch.Artifacts[0].ID != "prod.shop.api.app.orders_jar" || arch.Artifacts[0].Name != "orders.jar" {
+		t.Errorf("unexpected artifacts %+v", arch.Artifacts)
+	}
+
+	want := []extuml.Connector{
+		{ID: "connector_1", Type: extuml.RelAssociation, From: "Browser", To: "prod.shop.api", ToPort: "prod.shop.api.http", Label: "HTTPS"},
+		{ID: "connector_2", Type: extuml.RelLink, From: "prod.shop.api.app.OrderService", FromPort: "prod.shop.api.app.OrderService.rest", To: "prod.shop.db", ToPort: "prod.shop.db.sql"},
+		{ID: "connector_3", Type: extuml.RelDependency, From: "prod.shop.api.app.orders_jar", To: "prod.shop.api.app.OrderService", Label: "manifests"},
+		{ID: "connector_4", Type: extuml.RelDashedLink, From: "Billing", To: "Ledger"},
+	}
+	if !reflect.DeepEqual(arch.Connectors, want) {
+		t.Errorf("unexpected connectors\n got %+v\nwant %+v", arch.Connectors, want)
+	}
+	if len(arch.Notes) != 1 || arch.Notes[0].Anchor != "prod.shop.api.app.OrderService" {
+		t.Errorf("unexpected notes %+v", arch.Notes)
+	}
+
+	// Interface names resolve to the component providing, or else requiring,
+	// them rather than to new components
+	doc = lowerSource(t, "web.extuml", []byte("extuml componentDiagram3D\ncomponent Orders {\n  provides IOrders\n  requires IStock\n}\ncomponent Web {\n  requires IOrders\n}\nWeb ..> IOrders\nIStock -- Orders\n"))
+	if len(doc.Architecture.Components) != 2 {
+		t.Errorf("expected no implicit components, got %+v", doc.Architecture.Components)
+	}
+	want = []extuml.Connector{
+		{ID: "connector_1", Type: extuml.RelDependency, From: "Web", To: "Orders", ToInterface: "IOrders"},
+		{ID: "connector_2", Type: extuml.RelLink, From: "Orders", FromInterface: "IStock", To: "Orders"},
+	}
+	if !reflect.DeepEqual(doc.Architecture.Connectors, want) {
+		t.Errorf("unexpected interface connectors\n got %+v\nwant %+v", doc.Architecture.Connectors, want)
+	}
+}
+
+func TestComponentDiagnostics(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, code string
+	}{
+		{"node in a component diagram", "extuml componentDiagram3D\nnode A\n", "E103"},
+		{"artifact in a component diagram", "extuml componentDiagram3D\nartifact a\n", "E103"},
+		{"node in a component", "extuml deploymentDiagram3D\ncomponent A {\n  node B\n}\n", "E103"},
+		{"namespace in a pod", "extuml deploymentDiagram3D\npod p {\n  namespace n\n}\n", "E103"},
+		{"cluster in a container", "extuml deploymentDiagram3D\ncontainer c {\n  node n {\n    cluster k\n  }\n}\n", "E103"},
+		{"top-level port", "extuml componentDiagram3D\nport p\n", "E103"},
+		{"provides outside a component", "extuml deploymentDiagram3D\nnode n {\n  provides I\n}\n", "E103"},
+		{"artifact with a body", "extuml deploymentDiagram3D\nartifact a {\n}\n", "E103"},
+		{"class relationship arrow", "extuml componentDiagram3D\nA --|> B\n", "E103"},
+		{"unclosed component", "extuml componentDiagram3D\ncomponent A {\n  port p\n", "E102"},
+		{"duplicate component", "extuml componentDiagram3D\ncomponent A\ncomponent A\n", "E200"},
+		{"duplicate port", "extuml componentDiagram3D\ncomponent A {\n  port p\n  port p\n}\n", "E200"},
+		{"unknown port", "extuml componentDiagram3D\ncomponent A\nA.p -- B\n", "W200"},
+		{"ambiguous interface", "extuml componentDiagram3D\ncomponent A {\n  provides I\n}\ncomponent B {\n  provides I\n}\nW -- I\n", "W206"},
+		{"ambiguous component", "extuml componentDiagram3D\ncomponent a {\n  component X\n}\ncomponent b {\n  component X\n}\nX -- Y\n", "W206"},
+		{"unknown note anchor", "extuml componentDiagram3D\nnote for A \"n\"\n", "W201"},
+		{"unknown style class", "extuml componentDiagram3D\ncomponent A:::missing\n", "W208"},
+	} {
+		diags := parseAndLower("platform.extuml", tc.src)
+		found := false
+		for _, d := range diags {
+			found = found || d.Code == tc.code
+		}
+		if !found {
+			t.Errorf("%s: expected %s, got %v", tc.name, tc.code, diags)
+		}
+	}
+
+	if diags := parseAndLower("platform.extuml", "extuml deploymentDiagram3D\nnode n {\n  device d {\n    environment jvm {\n      artifact a\n    }\n  }\n}\npod p {\n  container c\n}\nA -- B\nstyle n fill:#0f0\n"); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
+
+func TestGenerateDeployment(t *testing.T) {
+	tmpDir := t.TempDir()
+	asset, byType, nodes := generateScene(t, tmpDir, "platform.extuml", deploymentSource)
+	if ext := asset.Asset.Extras.(map[string]any)["extuml"].(map[string]any); ext["diagram"] != "deployment" {
+		t.Errorf("unexpected asset extras %v", ext)
+	}
+	for kind, count := range map[string]int{
+		"component": 3, "artifact": 1, "device": 1, "cluster": 1, "namespace": 1, "pod": 2, "container": 1,
+		"clusterVolume": 1, "namespaceVolume": 1, "podVolume": 1, "containerVolume": 1,
+		"port": 3, "providedInterface": 2, "requiredInterface": 2, "connector": 4, "assembly": 1, "note": 1,
+	} {
+		if len(byType[kind]) != count {
+			t.Errorf("expected %d %s nodes, got %d", count, kind, len(byType[kind]))
+		}
+	}
+
+	// Deployment nodes nest in the scene graph
+	prod, shop, api, app := nodes["prod"], nodes["prod.shop"], nodes["prod.shop.api"], nodes["prod.shop.api.app"]
+	if !containsNode(asset, prod, "prod.shop") || !containsNode(asset, shop, "prod.shop.api") ||
+		!containsNode(asset, api, "prod.shop.api.app") || !containsNode(asset, app, "prod.shop.api.app.OrderService") ||
+		!containsNode(asset, api, "prod.shop.api.http") {
+		t.Errorf("expected deployment nodes to be parent nodes of what is nested in them")
+	}
+
+	// Ports and interfaces are children of the node of their element
+	service := nodes["prod.shop.api.app.OrderService"]
+	if !containsNode(asset, nodes["prod.shop.db"], "prod.shop.db.sql") || !containsNode(asset, service, "prod.shop.api.app.OrderService.rest") ||
+		!containsNode(asset, service, "prod.shop.api.app.OrderService_providedInterface_1") ||
+		!containsNode(asset, service, "prod.shop.api.app.OrderService_requiredInterface_2") ||
+		!containsNode(asset, nodes["Billing"], "Billing_providedInterface_1") {
+		t.Errorf("expected ports and interfaces to be children of their element")
+	}
+
+	for _, e := range byType["connector"] {
+		if e["id"] == "connector_2" && (e["kind"] != "link" || e["fromPort"] != "prod.shop.api.app.OrderService.rest" || e["toPort"] != "prod.shop.db.sql") {
+			t.Errorf("unexpected connector extras %v", e)
+		}
+	}
+	if a := byType["assembly"][0]; a["interface"] != "IPayments" || a["from"] != "prod.shop.api.app.OrderService" || a["to"] != "Billing" {
+		t.Errorf("unexpected assembly extras %v", a)
+	}
+
+	// A connector of a component with itself loops over the top of it, and
+	// one to an interface ends at its ball
+	asset, _, _ = generateScene(t, tmpDir, "self.extuml", "extuml componentDiagram3D\ncomponent A {\n  provides I\n}\nA --> A : self\nB ..> I\n")
+	var component, line, label []float64
+	for i, node := range asset.Nodes {
+		switch node.Name {
+		case "A":
+			component = node.Translation
+		case "connector_1":
+			line = node.Translation
+			if i+1 < len(asset.Nodes) {
+				label = asset.Nodes[i+1].Translation
+			}
+		case "connector_2":
+			if e := node.Extras.(map[string]any)["extuml"].(map[string]any); e["to"] != "A" || e["toInterface"] != "I" {
+				t.Errorf("unexpected interface connector extras %v", e)
+			}
+		case "I":
+			t.Errorf("expected no component for the interface I")
+		}
+	}
+	if len(component) != 3 || len(line) != 3 || len(label) != 3 {
+		t.Fatalf("expected a component, a connector and its label, got %v %v %v", component, line, label)
+	}
+	if line[1] <= component[1] || label[1] <= line[1]+0.5 {
+		t.Errorf("expected the loop on top of the component at %v, got line at %v and label at %v", component, line, label)
+	}
+}
+
+func TestFormatComponent(t *testing.T) {
+	src := `extuml componentDiagram3D
+component   Shop   <<subsystem>>{
+port    api
+  provides IOrders,IStock
+component Cart:::hot
+}
+  Shop.api-->Gateway:REST
+`
+	want := `extuml componentDiagram3D
+
+component Shop <<subsystem>> {
+  port api
+  provides IOrders, IStock
+  component Cart:::hot
+}
+
+Shop.api --> Gateway : REST
+`
+	out, diags := formatter.Source("shop.extuml", []byte(src))
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if string(out) != want {
+		t.Errorf("unexpected formatting:\n%s\nwant:\n%s", out, want)
+	}
+
+	for name, src := range map[string][]byte{"shop.extuml": []byte(src), "platform.extuml": []byte(deploymentSource)} {
+		file, _ := parser.Parse(name, src)
+		before, _ := parser.Lower(file)
+		out, _ := formatter.Source(name, src)
+		file, _ = parser.Parse(name, out)
+		after, _ := parser.Lower(file)
+		if !reflect.DeepEqual(before, after) {
+			t.Errorf("%s: document changed by formatting\n got %+v\nwant %+v", name, after, before)
+		}
+	}
+}
+
+func TestGenerateEmptyArchitecture(t *testing.T) {
+	// A diagram with only its header generates an empty scene
+	tmpDir := t.TempDir()
+	for _, kind := range []string{"component", "deployment"} {
+		asset, _, _ := generateScene(t, tmpDir, kind+".extuml", "extuml "+kind+"Diagram3D\n")
+		if ext := asset.Asset.Extras.(map[string]any)["extuml"].(map[string]any); ext["diagram"] != kind {
+			t.Errorf("unexpected asset extras %v", ext)
+		}
+	}
+}
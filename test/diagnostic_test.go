@@ -0,0 +1,181 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/parser"
+	"github.com/extuml/extuml/pkg/repository"
+)
+
+// parseAndLower runs both compiler passes and returns every diagnostic
+func parseAndLower(name, src string) diagnostic.List {
+	file, diags := parser.Parse(name, []byte(src))
+	_, lowerDiags := parser.Lower(file)
+	diags = append(diags, lowerDiags...)
+	diags.Sort()
+	return diags
+}
+
+func TestDiagnosticCatalogue(t *testing.T) {
+	src := `extuml classDiagram3D
+class Foo {
+  +id: int
+  +id: int
+  +save(a: int)
+  +save(a: string)
+  @colour: red
+}
+class Foo
+class 123Bar {
+}
+class Foo-Baz
++name: string
+Foo --> Missing
+note for Nope "hi"
+`
+	diags := parseAndLower("catalogue.extuml", src)
+
+	expected := []struct {
+		code     string
+		severity diagnostic.Severity
+		line     int
+	}{
+		{diagnostic.CodeDuplicateMember, diagnostic.SeverityWarning, 4},
+		{diagnostic.CodeUnknownAnnotation, diagnostic.SeverityWarning, 7},
+		{diagnostic.CodeDuplicateElement, diagnostic.SeverityError, 9},
+		{diagnostic.CodeInvalidIdentifier, diagnostic.SeverityError, 10},
+		{diagnostic.CodeInvalidIdentifier, diagnostic.SeverityError, 12},
+		{diagnostic.CodeMemberOutsideBlock, diagnostic.SeverityError, 13},
+		{diagnostic.CodeUnknownRelationshipTarget, diagnostic.SeverityWarning, 14},
+		{diagnostic.CodeUnknownNoteAnchor, diagnostic.SeverityWarning, 15},
+	}
+	if len(diags) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %d:\n%v", len(expected), len(diags), diags)
+	}
+	for i, want := range expected {
+		d := diags[i]
+		if d.Code != want.code || d.Severity != want.severity || d.Span.Start.Line != want.line {
+			t.Errorf("diagnostic %d: expected %s %s on line %d, got %v", i, want.severity, want.code, want.line, d)
+		}
+	}
+}
+
+func TestDiagnosticMalformedMembers(t *testing.T) {
+	tests := []struct {
+		member string
+		codes  []string
+	}{
+		{"+foo(: int", []string{diagnostic.CodeExpected, diagnostic.CodeExpected}},
+		{"bogus line here (", []string{diagnostic.CodeExpected, diagnostic.CodeInvalidIdentifier}},
+		{"123abc", []string{diagnostic.CodeInvalidIdentifier}},
+		{"+save(in 1st: int)", []string{diagnostic.CodeInvalidIdentifier}},
+		{"+total() : Money extra", []string{diagnostic.CodeExpected}},
+		{"+id:", []string{diagnostic.CodeExpected}},
+	}
+	for _, tt := range tests {
+		diags := parseAndLower("members.extuml", "extuml classDiagram3D\nclass A {\n  "+tt.member+"\n}\n")
+		var codes []string
+		for _, d := range diags {
+			codes = append(codes, d.Code)
+			if d.Span.Start.Line != 3 {
+				t.Errorf("%q: expected the diagnostic on the member, got %v", tt.member, d)
+			}
+		}
+		if !reflect.DeepEqual(codes, tt.codes) {
+			t.Errorf("%q: expected %v, got %v", tt.member, tt.codes, diags)
+		}
+	}
+
+	// A trailing comment is not part of the member
+	file, diags := parser.Parse("comment.extuml", []byte("extuml classDiagram3D\nclass A {\n  +x: int %% the count\n  +y() : int %% none\n}\n"))
+	doc, lowerDiags := parser.Lower(file)
+	if diags = append(diags, lowerDiags...); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if a := doc.Elements.Classes[0]; a.Attributes[0].Type != "int" || a.Operations[0].ReturnType != "int" {
+		t.Errorf("expected the comments stripped from the types, got %+v", a)
+	}
+}
+
+func TestDiagnosticTextRendering(t *testing.T) {
+	src := "extuml classDiagram3D\n\tclass 9Lives {\n}\n"
+	diags := parseAndLower("caret.extuml", src)
+	diags.AttachSource("caret.extuml", []byte(src))
+
+	var buf bytes.Buffer
+	if err := diagnostic.WriteText(&buf, diags); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	want := `caret.extuml:2:8: error[E107]: invalid class name "9Lives": names must start with a letter and contain only letters, digits, '_' and '.'
+  2 | 	class 9Lives {
+    | 	      ^^^^^^
+1 error, 0 warnings
+`
+	if buf.String() != want {
+		t.Errorf("unexpected text output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestDiagnosticJSON(t *testing.T) {
+	src := "extuml classDiagram3D\nclass A {\n}\nA --> B\n"
+	diags := parseAndLower("ci.extuml", src)
+	diags.AttachSource("ci.extuml", []byte(src))
+
+	var buf bytes.Buffer
+	if err := diagnostic.WriteJSON(&buf, diags); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	var report struct {
+		Diagnostics []struct {
+			Code      string `json:"code"`
+			Severity  string `json:"severity"`
+			File      string `json:"file"`
+			Line      int    `json:"line"`
+			Column    int    `json:"column"`
+			EndColumn int    `json:"endColumn"`
+			Source    string `json:"source"`
+		} `json:"diagnostics"`
+		Errors   int `json:"errors"`
+		Warnings int `json:"warnings"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+
+	if report.Errors != 0 || report.Warnings != 1 || len(report.Diagnostics) != 1 {
+		t.Fatalf("unexpected report: %s", buf.String())
+	}
+	d := report.Diagnostics[0]
+	if d.Code != "W200" || d.Severity != "warning" || d.File != "ci.extuml" ||
+		d.Line != 4 || d.Column != 7 || d.EndColumn != 8 || d.Source != "A --> B" {
+		t.Errorf("unexpected diagnostic: %+v", d)
+	}
+}
+
+func TestLoadWarningsDoNotFail(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "warn.extuml")
+	input := "extuml classDiagram3D\nclass A {\n  @colour: red\n}\n"
+	if err := os.WriteFile(inputPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write test input: %v", err)
+	}
+
+	doc, diags, err := repository.NewExtumlRepository().Load(inputPath)
+	if err != nil {
+		t.Fatalf("warnings must not fail the load: %v", err)
+	}
+	if doc == nil || len(doc.Elements.Classes) != 1 {
+		t.Fatalf("expected the document to be loaded, got %+v", doc)
+	}
+	if len(diags) != 1 || diags[0].Code != diagnostic.CodeUnknownAnnotation || diags[0].SourceLine != "  @colour: red" {
+		t.Errorf("expected one W202 with its source line, got %v", diags)
+	}
+}
@@ -0,0 +1,253 @@
+package test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/config"
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/repository"
+	"github.com/extuml/extuml/pkg/usecase"
+)
+
+func TestJSONDocumentImport(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"shop.json": `{
+  "version": "0.1",
+  "meta": {"title": "Shop \/ orders"},
+  "config": {"theme": "dark"},
+  "classDefs": {"hot": {"fill": "#f00"}},
+  "elements": {
+    "classes": [
+      {"id": "orders.Order", "abstract": true, "styleClasses": ["hot"],
+       "attributes": [{"name": "id", "type": "Long", "visibility": "private"}],
+       "placement": {"position": [0, 1.5, -2], "layer": 1}},
+      {"id": "Customer", "name": "Customer Account"}
+    ],
+    "packages": [{"id": "orders", "children": ["orders.Order"]}],
+    "relationships": [{"type": "association", "from": "Customer", "to": "orders.Order", "label": "places"}],
+    "notes": [{"text": "VIP", "anchor": "Customer"}]
+  }
+}
+`,
+	})
+
+	docs, diags, err := repository.NewDocumentRepository().LoadJSON(filepath.Join(tmpDir, "shop.json"))
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+	doc := docs[0]
+	if doc.Meta.Title != "Shop / orders" || doc.Config["theme"] != "dark" || doc.ClassDefs["hot"].Fill != "#f00" {
+		t.Errorf("unexpected header: %+v %v %v", doc.Meta, doc.Config, doc.ClassDefs)
+	}
+
+	classes := doc.Elements.Classes
+	if len(classes) != 2 {
+		t.Fatalf("expected 2 classes, got %+v", classes)
+	}
+	// Types and names default as in the DSL
+	order := classes[0]
+	if order.Type != "class" || order.Name != "Order" || !order.Abstract || order.Attributes[0].Visibility != "private" {
+		t.Errorf("unexpected Order: %+v", order)
+	}
+	if p := order.Placement; p == nil || *p.Position != [3]float64{0, 1.5, -2} || *p.Layer != 1 {
+		t.Errorf("unexpected placement: %+v", p)
+	}
+	if classes[1].Name != "Customer Account" {
+		t.Errorf("expected display name, got %q", classes[1].Name)
+	}
+	if doc.Elements.Packages[0].Type != "package" || doc.Elements.Packages[0].Name != "orders" {
+		t.Errorf("unexpected package: %+v", doc.Elements.Packages[0])
+	}
+	if rel := doc.Elements.Relationships[0]; rel.ID != "rel_1" || rel.From != "Customer" || rel.To != "orders.Order" {
+		t.Errorf("unexpected relationship: %+v", rel)
+	}
+	if note := doc.Elements.Notes[0]; note.ID != "note_1" || note.Type != "note" {
+		t.Errorf("unexpected note: %+v", note)
+	}
+}
+
+func TestJSONDocumentSchemaErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"bad.json": `{
+  "version": 1,
+  "elements": {
+    "classes": [
+      {"id": "A", "nmae": "x", "attributes": [{"visibility": "open"}]},
+      {"id": "A", "placement": {"position": [1, 2]}}
+    ],
+    "relationships": [{"type": "link", "from": "A", "to": "B"}]
+  }
+}
+`,
+		"dup.json": `{"version": "0.1", "elements": {"classes": [{"id": "A"}, {"id": "A"}], "notes": [{"text": "x", "anchor": "B"}]}}`,
+		"packages.json": `{"version": "0.1", "elements": {
+  "classes": [{"id": "A"}, {"id": "B"}],
+  "packages": [
+    {"id": "p", "children": ["A", "q"]},
+    {"id": "q", "children": ["Missing", "A", "p"]},
+    {"id": "r", "children": ["B", "r"]}
+  ]
+}}`,
+		"syntax.json":       "{\n  \"version\": \"0.1\",\n}\n",
+		"sequence.json":     `{"version": "0.1", "diagram": "sequence", "sequence": {"participants": []}}`,
+		"stateMachine.json": `{"version": "0.1", "stateMachine": {}}`,
+		"er.json":           `{"version": "0.1", "er": {}}`,
+		"architecture.json": `{"version": "0.1", "architecture": {}}`,
+		"activity.json":     `{"version": "0.1", "activity": {}}`,
+	})
+
+	type diag struct {
+		code      string
+		line, col int
+		message   string
+	}
+	collect := func(diags diagnostic.List) []diag {
+		var got []diag
+		for _, d := range diags {
+			got = append(got, diag{d.Code, d.Span.Start.Line, d.Span.Start.Column, d.Message})
+		}
+		return got
+	}
+
+	// Every schema violation is reported at the offending value
+	_, diags, err := repository.NewDocumentRepository().LoadJSON(filepath.Join(tmpDir, "bad.json"))
+	if err == nil {
+		t.Fatal("expected schema errors to fail the load")
+	}
+	want := []diag{
+		{"E205", 2, 14, "$.version: expected string, got integer"},
+		{"E205", 5, 19, "$.elements.classes[0].nmae: unknown property"},
+		{"E205", 5, 47, `$.elements.classes[0].attributes[0]: missing required property "name"`},
+		{"E205", 5, 62, `$.elements.classes[0].attributes[0].visibility: invalid value "open" (expected public, private, protected or package)`},
+		{"E205", 6, 45, "$.elements.classes[1].placement.position: expected at least 3 items, got 2"},
+	}
+	if got := collect(diags); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected diagnostics:\n got %v\nwant %v", got, want)
+	}
+
+	// Checks beyond the schema run once it is satisfied
+	_, diags, _ = repository.NewDocumentRepository().LoadJSON(filepath.Join(tmpDir, "dup.json"))
+	want = []diag{
+		{"E200", 1, 65, `$.elements.classes[1].id: ID "A" is already declared at ` + filepath.Join(tmpDir, "dup.json") + ":1:52"},
+		{"W201", 1, 106, `$.elements.notes[0].anchor: note anchor "B" is not declared`},
+	}
+	if got := collect(diags); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected diagnostics:\n got %v\nwant %v", got, want)
+	}
+
+	// Packages form a tree of declared elements
+	_, diags, err = repository.NewDocumentRepository().LoadJSON(filepath.Join(tmpDir, "packages.json"))
+	if err == nil {
+		t.Error("expected the package errors to fail the load")
+	}
+	want = []diag{
+		{"E207", 5, 30, `$.elements.packages[1].children[0]: package child "Missing" is not declared`},
+		{"E208", 5, 41, `$.elements.packages[1].children[1]: "A" is already a child of package "p" at ` + filepath.Join(tmpDir, "packages.json") + ":4:30"},
+		{"E209", 5, 46, `$.elements.packages[1].children[2]: package "p" contains itself: p > q > p`},
+		{"E209", 6, 35, `$.elements.packages[2].children[1]: package "r" contains itself: r > r`},
+	}
+	if got := collect(diags); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected diagnostics:\n got %v\nwant %v", got, want)
+	}
+
+	// Documents of other diagram types are rejected before the schema runs
+	_, diags, err = repository.NewDocumentRepository().LoadJSON(filepath.Join(tmpDir, "sequence.json"))
+	if err == nil {
+		t.Error("expected a sequence diagram document to fail the load")
+	}
+	want = []diag{
+		{"E205", 1, 20, "$.diagram: only class diagrams can be read from JSON and YAML documents, not sequence diagrams"},
+		{"E205", 1, 43, "$.sequence: only class diagrams can be read from JSON and YAML documents, not sequence diagrams"},
+	}
+	if got := collect(diags); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected diagnostics:\n got %v\nwant %v", got, want)
+	}
+
+	_, diags, _ = repository.NewDocumentRepository().LoadJSON(filepath.Join(tmpDir, "stateMachine.json"))
+	if got := collect(diags); len(got) != 1 || got[0].message != "$.stateMachine: only class diagrams can be read from JSON and YAML documents, not state diagrams" {
+		t.Errorf("expected the stateMachine document to be rejected, got %v", got)
+	}
+
+	_, diags, _ = repository.NewDocumentRepository().LoadJSON(filepath.Join(tmpDir, "er.json"))
+	if got := collect(diags); len(got) != 1 || got[0].message != "$.er: only class diagrams can be read from JSON and YAML documents, not ER diagrams" {
+		t.Errorf("expected the er document to be rejected, got %v", got)
+	}
+
+	_, diags, _ = repository.NewDocumentRepository().LoadJSON(filepath.Join(tmpDir, "architecture.json"))
+	if got := collect(diags); len(got) != 1 || got[0].message != "$.architecture: only class diagrams can be read from JSON and YAML documents, not component or deployment diagrams" {
+		t.Errorf("expected the architecture document to be rejected, got %v", got)
+	}
+
+	_, diags, _ = repository.NewDocumentRepository().LoadJSON(filepath.Join(tmpDir, "activity.json"))
+	if got := collect(diags); len(got) != 1 || got[0].message != "$.activity: only class diagrams can be read from JSON and YAML documents, not activity diagrams" {
+		t.Errorf("expected the activity document to be rejected, got %v", got)
+	}
+
+	_, diags, _ = repository.NewDocumentRepository().LoadJSON(filepath.Join(tmpDir, "syntax.json"))
+	if len(diags) != 1 || diags[0].Code != diagnostic.CodeMalformedDocument || diags[0].Span.Start.Line != 3 {
+		t.Errorf("expected E109 on line 3, got %v", diags)
+	}
+}
+
+func TestGenerateYAMLDocuments(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"model.yaml": `version: "0.1"
+elements:
+  classes:
+    - id: Animal
+    - id: Duck
+  relationships:
+    - {type: inheritance, from: Duck, to: Animal}
+---
+version: "0.1"
+elements:
+  classes:
+    - id: Keeper
+      stereotypes: [Person]
+  notes:
+    - text: |
+        Feeds the
+        animals
+      anchor: Keepr
+`,
+		"bad.yml":   "version: 0.1\nelements:\n  classes:\n    - id: A\n      attributes:\n        - name: x\n          visibility: public\n          static: yes\n",
+		"notes.txt": "version: \"0.1\"\n",
+	})
+	outputPath := filepath.Join(tmpDir, "model.gl")
+
+	cfg := config.NewConfig()
+	written, diags, err := cfg.GenerateCtrl.Generate(filepath.Join(tmpDir, "model.yaml"), outputPath, "", "", usecase.DefaultLayoutOptions())
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	// A YAML stream yields one output per document
+	want := []string{filepath.Join(tmpDir, "model-1.gl"), filepath.Join(tmpDir, "model-2.gl")}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("expected outputs %v, got %v", want, written)
+	}
+	if len(diags) != 1 || diags[0].Code != diagnostic.CodeUnknownNoteAnchor ||
+		diags[0].Span.Start.Line != 18 || diags[0].Span.Start.Column != 15 {
+		t.Errorf("expected W201 at 18:15, got %v", diags)
+	}
+
+	// YAML scalars keep their YAML types
+	_, diags, err = cfg.GenerateCtrl.Generate(filepath.Join(tmpDir, "bad.yml"), outputPath, "", "", usecase.DefaultLayoutOptions())
+	if err == nil || len(diags) != 2 ||
+		diags[0].Message != "$.version: expected string, got number" || diags[0].Span.Start.Line != 1 ||
+		diags[1].Message != "$.elements.classes[0].attributes[0].static: expected boolean, got string" || diags[1].Span.Start.Line != 8 {
+		t.Errorf("expected type errors on lines 1 and 8, got %v, %v", err, diags)
+	}
+
+	// Other extensions are read as YAML when the format is given
+	if _, _, err := cfg.GenerateCtrl.Generate(filepath.Join(tmpDir, "notes.txt"), outputPath, "", "yaml", usecase.DefaultLayoutOptions()); err != nil {
+		t.Errorf("expected --input-format yaml to load notes.txt: %v", err)
+	}
+}
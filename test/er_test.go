@@ -0,0 +1,223 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/formatter"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+const erSource = `extuml erDiagram3D
+title: Shop
+
+classDef hot fill:#f66
+
+schema sales as "Sales" {
+  entity Customer as "Customer account" {
+    int id PK
+    string email UK "login name"
+    decimal(10, 2) credit
+  }
+  Order:::hot {
+    int id PK
+    int customer_id FK
+  }
+  Customer ||--o{ Order : places
+}
+
+tablespace archive {
+  entity OldOrder {
+    int id PK, FK
+  }
+}
+
+Order |o..|| OldOrder : "archived as"
+LineItem }|--|| Order
+note for Customer "key account"
+`
+
+func TestERLowering(t *testing.T) {
+	doc := lowerSource(t, "shop.extuml", []byte(erSource))
+	if doc.Diagram != extuml.DiagramER || doc.Elements != nil || doc.ER == nil {
+		t.Fatalf("expected an ER document, got %+v", doc)
+	}
+	er := doc.ER
+
+	entities := map[string]extuml.Entity{}
+	for _, e := range er.Entities {
+		entities[e.ID] = e
+	}
+	customer := entities["sales.Customer"]
+	wantColumns := []extuml.Column{
+		{Name: "id", Type: "int", Keys: []string{extuml.KeyPrimary}},
+		{Name: "email", Type: "string", Keys: []string{extuml.KeyUnique}, Comment: "login name"},
+		{Name: "credit", Type: "decimal(10, 2)"},
+	}
+	if customer.Name != "Customer account" || customer.Schema != "sales" || !reflect.DeepEqual(customer.Columns, wantColumns) {
+		t.Errorf("unexpected entity %+v", customer)
+	}
+	if order := entities["sales.Order"]; !reflect.DeepEqual(order.StyleClasses, []string{"hot"}) {
+		t.Errorf("unexpected entity %+v", order)
+	}
+	if old := entities["archive.OldOrder"]; !reflect.DeepEqual(old.Columns[0].Keys, []string{"PK", "FK"}) {
+		t.Errorf("unexpected entity %+v", old)
+	}
+	// LineItem is only used by a relationship at the top level
+	if item, ok := entities["LineItem"]; !ok || item.Schema != "" || len(item.Columns) != 0 {
+		t.Errorf("expected an implicit LineItem entity, got %+v", item)
+	}
+
+	wantSchemas := []extuml.Schema{
+		{ID: "sales", Type: "schema", Name: "Sales", Entities: []string{"sales.Customer", "sales.Order"}},
+		{ID: "archive", Type: "tablespace", Name: "archive", Entities: []string{"archive.OldOrder"}},
+	}
+	if !reflect.DeepEqual(er.Schemas, wantSchemas) {
+		t.Errorf("unexpected schemas %+v", er.Schemas)
+	}
+
+	want := []extuml.ERRelationship{
+		{ID: "rel_1", From: "sales.Customer", To: "sales.Order", FromCardinality: extuml.CardinalityExactlyOne, ToCardinality: extuml.CardinalityZeroOrMore, Identifying: true, Label: "places"},
+		{ID: "rel_2", From: "sales.Order", To: "archive.OldOrder", FromCardinality: extuml.CardinalityZeroOrOne, ToCardinality: extuml.CardinalityExactlyOne, Label: "archived as"},
+		{ID: "rel_3", From: "LineItem", To: "sales.Order", FromCardinality: extuml.CardinalityOneOrMore, ToCardinality: extuml.CardinalityExactlyOne, Identifying: true},
+	}
+	if !reflect.DeepEqual(er.Relationships, want) {
+		t.Errorf("unexpected relationships\n got %+v\nwant %+v", er.Relationships, want)
+	}
+	if len(er.Notes) != 1 || er.Notes[0].Anchor != "sales.Customer" {
+		t.Errorf("unexpected notes %+v", er.Notes)
+	}
+}
+
+func TestERDiagnostics(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, code string
+	}{
+		{"class relationship arrow", "extuml erDiagram3D\nA --> B\n", "E103"},
+		{"unknown column key", "extuml erDiagram3D\nentity A {\n  int id PRIMARY\n}\n", "E103"},
+		{"column without a name", "extuml erDiagram3D\nentity A {\n  int\n}\n", "E105"},
+		{"nested schema", "extuml erDiagram3D\nschema a {\n  schema b {\n  }\n}\n", "E103"},
+		{"unclosed entity", "extuml erDiagram3D\nentity A {\n  int id\n", "E102"},
+		{"unclosed schema", "extuml erDiagram3D\nschema a {\n  entity A\n", "E102"},
+		{"duplicate entity", "extuml erDiagram3D\nentity A\nentity A\n", "E200"},
+		{"entity named like a schema", "extuml erDiagram3D\nschema a {\n}\nentity a\n", "E200"},
+		{"duplicate column", "extuml erDiagram3D\nentity A {\n  int id\n  string id\n}\n", "W203"},
+		{"ambiguous entity", "extuml erDiagram3D\nschema a {\n  entity X\n}\nschema b {\n  entity X\n}\nX ||--|| Y\n", "W206"},
+		{"unknown note anchor", "extuml erDiagram3D\nnote for A \"n\"\n", "W201"},
+		{"unknown style class", "extuml erDiagram3D\nentity A:::missing\n", "W208"},
+	} {
+		diags := parseAndLower("shop.extuml", tc.src)
+		found := false
+		for _, d := range diags {
+			found = found || d.Code == tc.code
+		}
+		if !found {
+			t.Errorf("%s: expected %s, got %v", tc.name, tc.code, diags)
+		}
+	}
+
+	if diags := parseAndLower("shop.extuml", "extuml erDiagram3D\nA ||--o{ B\nschema s {\n}\nschema s {\n  C }o..o| A\n}\nstyle B fill:#0f0\n"); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
+
+func TestGenerateER(t *testing.T) {
+	tmpDir := t.TempDir()
+	asset, byType, nodes := generateScene(t, tmpDir, "shop.extuml", erSource)
+	if ext := asset.Asset.Extras.(map[string]any)["extuml"].(map[string]any); ext["diagram"] != "er" {
+		t.Errorf("unexpected asset extras %v", ext)
+	}
+	for kind, count := range map[string]int{"entity": 4, "relationship": 3, "schema": 1, "schemaVolume": 1, "tablespace": 1, "tablespaceVolume": 1, "fill": 1} {
+		if len(byType[kind]) != count {
+			t.Errorf("expected %d %s nodes, got %d", count, kind, len(byType[kind]))
+		}
+	}
+
+	// Schemas hold their entities on layers of their own
+	sales, archive := nodes["sales"], nodes["archive"]
+	if !containsNode(asset, sales, "sales.Customer") || !containsNode(asset, archive, "archive.OldOrder") {
+		t.Errorf("expected schemas to be parent nodes of their entities")
+	}
+	if sales.Translation[2] >= nodes["LineItem"].Translation[2] || archive.Translation[2] >= sales.Translation[2] {
+		t.Errorf("expected schemas behind the top level, got %v, %v and %v", nodes["LineItem"].Translation, sales.Translation, archive.Translation)
+	}
+
+	for _, e := range byType["relationship"] {
+		if e["id"] == "rel_2" && (e["kind"] != "nonIdentifying" || e["fromCardinality"] != "zeroOrOne" || e["toCardinality"] != "exactlyOne" || e["label"] != "archived as") {
+			t.Errorf("unexpected relationship extras %v", e)
+		}
+	}
+	for _, e := range byType["entity"] {
+		if e["id"] == "sales.Customer" && (e["schema"] != "sales" || e["columns"] != float64(3) || !reflect.DeepEqual(e["primaryKey"], []any{"id"})) {
+			t.Errorf("unexpected entity extras %v", e)
+		}
+	}
+
+	// A relationship of an entity with itself loops over its top, with the
+	// label above the loop
+	asset, _, _ = generateScene(t, tmpDir, "self.extuml", "extuml erDiagram3D\nentity Employee {\n  int id PK\n  int manager_id FK\n}\nEmployee }o--o| Employee : reports to\n")
+	var entity, line, label []float64
+	for i, node := range asset.Nodes {
+		switch node.Name {
+		case "Employee":
+			entity = node.Translation
+		case "rel_1":
+			line = node.Translation
+			if i+1 < len(asset.Nodes) {
+				label = asset.Nodes[i+1].Translation
+			}
+		}
+	}
+	if len(entity) != 3 || len(line) != 3 || len(label) != 3 {
+		t.Fatalf("expected an entity, a relationship and its label, got %v %v %v", entity, line, label)
+	}
+	if line[1] <= entity[1] || label[1] <= line[1]+0.5 {
+		t.Errorf("expected the loop on top of the entity at %v, got line at %v and label at %v", entity, line, label)
+	}
+}
+
+func TestFormatER(t *testing.T) {
+	src := `extuml erDiagram3D
+schema   sales{
+Customer {
+    %% keys first
+  int   id    PK,FK
+     string email UK   "login name"
+}
+  Customer||--o{Order:places
+}
+entity Order as "Order":::hot
+`
+	want := `extuml erDiagram3D
+
+schema sales {
+  entity Customer {
+    %% keys first
+    int id PK, FK
+    string email UK "login name"
+  }
+  Customer ||--o{ Order : places
+}
+
+entity Order as "Order":::hot
+`
+	out, diags := formatter.Source("shop.extuml", []byte(src))
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if string(out) != want {
+		t.Errorf("unexpected formatting:\n%s\nwant:\n%s", out, want)
+	}
+
+	for name, src := range map[string][]byte{"shop.extuml": []byte(src), "er.extuml": []byte(erSource)} {
+		file, _ := parser.Parse(name, src)
+		before, _ := parser.Lower(file)
+		out, _ := formatter.Source(name, src)
+		file, _ = parser.Parse(name, out)
+		after, _ := parser.Lower(file)
+		if !reflect.DeepEqual(before, after) {
+			t.Errorf("%s: document changed by formatting\n got %+v\nwant %+v", name, after, before)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/config"
+	"github.com/extuml/extuml/pkg/exporter"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/parser"
+	"github.com/extuml/extuml/pkg/repository"
+)
+
+const exportSource = `---
+title: Shop
+config:
+  theme: dark
+  layout:
+    spacing: 4
+---
+extuml classDiagram3D
+
+classDef hot fill:#f66,stroke:#900
+
+abstract class Order<T>:::hot <<Entity, Aggregate>> {
+  -id: Long
+  +items: LineItem [0..*]
+  +count: int$ = 0
+  +total(in currency: string = "EUR")*: Money
+  +create()$: Order
+  @url: https://example.com/order
+  @pos: 0, 1.5, -2
+  @layer: 1
+}
+
+interface Payable {
+  +pay(amount: Money): bool
+  +reference
+}
+
+enum Status {
+  NEW
+  PAID
+}
+
+package billing {
+  class Invoice as "Tax Invoice"
+  note for Invoice "Billed\nmonthly"
+  package tax {
+    final class Rule {
+      @near: Invoice
+    }
+  }
+}
+
+note "Prices include VAT"
+
+package billing {
+  class Ledger
+}
+
+Order "1" *-- "many" Ledger : records
+Order ..|> Payable
+Invoice --> Order : bills
+Status .. Order
+style Payable fill:#0f0
+`
+
+// lowerSource parses and lowers DSL source, failing on any diagnostic
+func lowerSource(t *testing.T, name string, src []byte) *extuml.Document {
+	t.Helper()
+	file, diags := parser.Parse(name, src)
+	doc, lowerDiags := parser.Lower(file)
+	if diags = append(diags, lowerDiags...); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics in %s: %v\n%s", name, diags, src)
+	}
+	return doc
+}
+
+func TestExportDSLRoundTrip(t *testing.T) {
+	sample, err := os.ReadFile(filepath.Join("..", "etc", "sample.extuml"))
+	if err != nil {
+		t.Fatalf("read sample: %v", err)
+	}
+
+	for name, src := range map[string][]byte{"shop.extuml": []byte(exportSource), "sample.extuml": sample} {
+		doc := lowerSource(t, name, src)
+		out := exporter.DSL(doc)
+		again := lowerSource(t, "exported "+name, out)
+		if !reflect.DeepEqual(doc, again) {
+			t.Errorf("%s: document changed by the round trip\n got %+v\nwant %+v\nsource:\n%s", name, again.Elements, doc.Elements, out)
+		}
+		// The canonical form is a fixed point
+		if twice := exporter.DSL(again); string(twice) != string(out) {
+			t.Errorf("%s: exporting again changed the source:\n%s\nthen:\n%s", name, out, twice)
+		}
+	}
+}
+
+// quotesSource escapes quotes and backslashes in its strings
+const quotesSource = `extuml classDiagram3D
+
+class A as "The \"A\" class"
+class B
+note for A "say \"hi\" to C:\\"
+note for B "back\\nslash\nin C:\\new"
+A "1 \"or\" 2" --> "*" B
+`
+
+func TestExportDSLQuotes(t *testing.T) {
+	doc := lowerSource(t, "quotes.extuml", []byte(quotesSource))
+	a := doc.Elements.Classes[0]
+	if a.Name != `The "A" class` || doc.Elements.Notes[0].Text != `say "hi" to C:\` || doc.Elements.Relationships[0].FromMultiplicity != `1 "or" 2` {
+		t.Fatalf("escapes not read back: %q, %q, %q", a.Name, doc.Elements.Notes[0].Text, doc.Elements.Relationships[0].FromMultiplicity)
+	}
+	// An escaped backslash followed by n is not a line break
+	if text := doc.Elements.Notes[1].Text; text != "back\\nslash\nin C:\\new" {
+		t.Fatalf("unexpected note text %q", text)
+	}
+	out := exporter.DSL(doc)
+	if again := lowerSource(t, "exported quotes.extuml", out); !reflect.DeepEqual(doc, again) {
+		t.Errorf("document changed by the round trip\n got %+v\nwant %+v\nsource:\n%s", again.Elements, doc.Elements, out)
+	}
+
+	// A PlantUML note may hold quotes, which the DSL export escapes
+	puml := "@startuml\nclass A\nnote left of A : say \"hi\"\n@enduml\n"
+	file, diags := parser.ParsePlantUML("quotes.puml", []byte(puml))
+	doc, lowerDiags := parser.Lower(file)
+	if diags = append(diags, lowerDiags...); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	out = exporter.DSL(doc)
+	if note := lowerSource(t, "exported quotes.puml", out).Elements.Notes[0]; note.Text != `say "hi"` {
+		t.Errorf("unexpected note text %q in\n%s", note.Text, out)
+	}
+	// Mermaid has no escape for a quote, only the #quot; entity
+	if out := string(exporter.Mermaid(doc)); !strings.Contains(out, `note for A "say #quot;hi#quot;"`) {
+		t.Errorf("expected the quotes of the note as #quot; in\n%s", out)
+	}
+}
+
+func TestExportDSLSizedArrays(t *testing.T) {
+	src := "classDiagram\nclass Cache {\n  -cache[0..*] state\n  -cache[2] slots [1]\n  +int[] ids\n}\n"
+	file, diags := parser.ParseMermaid("cache.mmd", []byte(src))
+	doc, lowerDiags := parser.Lower(file)
+	if diags = append(diags, lowerDiags...); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if state := doc.Elements.Classes[0].Attributes[0]; state.Type != "cache[0..*]" || state.Multiplicity != "" {
+		t.Fatalf("expected the sized array as the type of state, got %+v", state)
+	}
+
+	// The sized array stays on the type rather than reading back as a multiplicity
+	out := exporter.DSL(doc)
+	if again := lowerSource(t, "exported cache.mmd", out); !reflect.DeepEqual(again.Elements.Classes, doc.Elements.Classes) {
+		t.Errorf("classes changed by the round trip\n got %+v\nwant %+v\nsource:\n%s", again.Elements.Classes, doc.Elements.Classes, out)
+	}
+	if !strings.Contains(string(out), "  -cache[0..*] state\n  -slots: cache[2] [1]\n  +ids: int[]\n") {
+		t.Errorf("unexpected members in\n%s", out)
+	}
+}
+
+func TestExportDSLFromJSON(t *testing.T) {
+	src := `{"version": "0.1", "elements": {"classes": [{"id": "User", "attributes": [
+  {"name": "created", "type": "Date", "default": "now()"},
+  {"name": "tags", "type": "List<String>", "default": "List.of(\"a\")"}
+]}]}}`
+	doc, diags := parser.ParseJSONDocument("user.json", []byte(src))
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	// Defaults with parentheses read back as attributes, not operations
+	out := exporter.DSL(doc)
+	user := lowerSource(t, "exported user.json", out).Elements.Classes[0]
+	if !reflect.DeepEqual(user.Attributes, doc.Elements.Classes[0].Attributes) || len(user.Operations) != 0 {
+		t.Errorf("members changed by the round trip\n got %+v\nwant %+v\nsource:\n%s", user, doc.Elements.Classes[0], out)
+	}
+}
+
+func TestExportDSLCanonicalForm(t *testing.T) {
+	doc := lowerSource(t, "shop.extuml", []byte(exportSource))
+	out := string(exporter.DSL(doc))
+
+	for _, want := range []string{
+		"abstract class Order<T>:::hot <<Entity, Aggregate>> {\n  -id: Long\n  +items: LineItem [0..*]\n  +count: int$ = 0\n",
+		"  +total(in currency: string = \"EUR\")*: Money\n  +create()$: Order\n  @url: https://example.com/order\n  @pos: 0, 1.5, -2\n  @layer: 1\n}",
+		// The reopened package is merged into one block
+		"package billing {\n  class Invoice as \"Tax Invoice\"\n  note for billing.Invoice \"Billed\\nmonthly\"\n  package tax {\n    final class Rule {\n      @near: billing.Invoice\n    }\n  }\n  class Ledger\n}",
+		// Relationships are written with the decorated end as in the README
+		"Order \"1\" *-- \"many\" billing.Ledger : records\n",
+		"Order ..|> Payable\nbilling.Invoice --> Order : bills\nStatus .. Order\n",
+		"style Payable fill:#0f0\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected the export to contain\n%s\ngot:\n%s", want, out)
+		}
+	}
+}
+
+func TestExportMermaidAndPlantUML(t *testing.T) {
+	doc := lowerSource(t, "shop.extuml", []byte(exportSource))
+
+	mermaid := exporter.Mermaid(doc)
+	file, diags := parser.ParseMermaid("shop.mmd", mermaid)
+	fromMermaid, lowerDiags := parser.Lower(file)
+	if len(diags)+len(lowerDiags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v %v\n%s", diags, lowerDiags, mermaid)
+	}
+
+	file, diags = parser.ParsePlantUML("shop.puml", exporter.PlantUML(doc))
+	fromPlantUML, lowerDiags := parser.Lower(file)
+	if len(diags)+len(lowerDiags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v %v\n%s", diags, lowerDiags, exporter.PlantUML(doc))
+	}
+
+	for name, got := range map[string]*extuml.Document{"mermaid": fromMermaid, "plantuml": fromPlantUML} {
+		// Members and relationships survive both notations
+		if !reflect.DeepEqual(got.Elements.Classes[0].Attributes, doc.Elements.Classes[0].Attributes) ||
+			!reflect.DeepEqual(got.Elements.Classes[0].Operations, doc.Elements.Classes[0].Operations) {
+			t.Errorf("%s: Order members changed: %+v", name, got.Elements.Classes[0])
+		}
+		if order := got.Elements.Classes[0]; !order.Abstract || !reflect.DeepEqual(order.Stereotypes, []string{"Entity", "Aggregate"}) {
+			t.Errorf("%s: expected abstract Order with its stereotypes, got %+v", name, order)
+		}
+		if !reflect.DeepEqual(got.Elements.Relationships, doc.Elements.Relationships) {
+			t.Errorf("%s: relationships changed:\n got %+v\nwant %+v", name, got.Elements.Relationships, doc.Elements.Relationships)
+		}
+		if !reflect.DeepEqual(got.Elements.Interfaces[0].Operations, doc.Elements.Interfaces[0].Operations) ||
+			!reflect.DeepEqual(got.Elements.Enums[0].Literals, []string{"NEW", "PAID"}) {
+			t.Errorf("%s: interface or enum changed: %+v %+v", name, got.Elements.Interfaces, got.Elements.Enums)
+		}
+		if invoice := got.Elements.Classes[1]; invoice.ID != "billing.Invoice" || invoice.Name != "Tax Invoice" {
+			t.Errorf("%s: expected billing.Invoice displayed as Tax Invoice, got %+v", name, invoice)
+		}
+		if !reflect.DeepEqual(got.Elements.Notes, doc.Elements.Notes) {
+			t.Errorf("%s: notes changed: %+v", name, got.Elements.Notes)
+		}
+	}
+
+	// Mermaid keeps the header, links and styles; its namespaces do not nest
+	if fromMermaid.Meta.Title != "Shop" || !reflect.DeepEqual(fromMermaid.Config, doc.Config) ||
+		!reflect.DeepEqual(fromMermaid.ClassDefs, doc.ClassDefs) || fromMermaid.Elements.Classes[0].URL != doc.Elements.Classes[0].URL {
+		t.Errorf("mermaid: header, links or styles changed: %+v %v %v", fromMermaid.Meta, fromMermaid.Config, fromMermaid.ClassDefs)
+	}
+	// Grouped by namespace, Rule follows Ledger
+	if rule := fromMermaid.Elements.Classes[3]; rule.ID != "billing_tax.Rule" || !rule.Final {
+		t.Errorf("mermaid: expected final billing_tax.Rule, got %+v", rule)
+	}
+	if rule := fromPlantUML.Elements.Classes[2]; rule.ID != "billing.tax.Rule" || !rule.Final {
+		t.Errorf("plantuml: expected final billing.tax.Rule, got %+v", rule)
+	}
+}
+
+func TestExportCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"model.yaml": `version: "0.1"
+elements:
+  classes:
+    - id: Animal
+    - id: Duck
+  relationships:
+    - {type: inheritance, from: Duck, to: Animal}
+---
+version: "0.1"
+elements:
+  classes:
+    - id: Keeper
+`,
+	})
+	cfg := config.NewConfig()
+
+	// Mermaid written to Markdown is fenced, one file per document
+	written, _, err := cfg.ExportCtrl.Export(filepath.Join(tmpDir, "model.yaml"), filepath.Join(tmpDir, "model.md"), "", "")
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	want := []string{filepath.Join(tmpDir, "model-1.md"), filepath.Join(tmpDir, "model-2.md")}
+	if !reflect.DeepEqual(written, want) {
+		t.Fatalf("expected outputs %v, got %v", want, written)
+	}
+	data, err := os.ReadFile(want[0])
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if got, expected := string(data), "```mermaid\nclassDiagram\nclass Animal\nclass Duck\nAnimal <|-- Duck\n```\n"; got != expected {
+		t.Errorf("unexpected Markdown:\n%s\nwant:\n%s", got, expected)
+	}
+
+	// The output can be loaded like any other input
+	docs, _, err := repository.NewMermaidRepository().Load(want[0])
+	if err != nil || len(docs) != 1 || docs[0].Elements.Relationships[0].To != "Animal" {
+		t.Errorf("expected the Markdown to import, got %v %v", docs, err)
+	}
+
+	// --to overrides the extension, and only source formats are written
+	if _, _, err := cfg.ExportCtrl.Export(filepath.Join(tmpDir, "model.yaml"), filepath.Join(tmpDir, "out.txt"), "", "plantuml"); err != nil {
+		t.Errorf("expected --to plantuml to export: %v", err)
+	}
+	if _, _, err := cfg.ExportCtrl.Export(filepath.Join(tmpDir, "model.yaml"), filepath.Join(tmpDir, "out.json"), "", ""); err == nil {
+		t.Error("expected exporting to JSON to fail")
+	}
+}
@@ -0,0 +1,530 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/parser"
+	"github.com/extuml/extuml/pkg/repository"
+)
+
+func TestRelationshipParsing(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "rel.extuml")
+
+	input := `extuml classDiagram3D
+
+class Animal {
+}
+
+class Dog {
+}
+
+Animal <|-- Dog
+Dog ..|> Pet
+Car *-- Wheel
+Team o-- Player
+Customer "1" --> "*" Ticket : places
+Service ..> Logger
+A -- B
+C .. D
+`
+	if err := os.WriteFile(inputPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write test input: %v", err)
+	}
+
+	doc, _, err := repository.NewExtumlRepository().Load(inputPath)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	expected := []extuml.Relationship{
+		{ID: "rel_1", Type: extuml.RelInheritance, From: "Dog", To: "Animal"},
+		{ID: "rel_2", Type: extuml.RelRealization, From: "Dog", To: "Pet"},
+		{ID: "rel_3", Type: extuml.RelComposition, From: "Wheel", To: "Car"},
+		{ID: "rel_4", Type: extuml.RelAggregation, From: "Player", To: "Team"},
+		{ID: "rel_5", Type: extuml.RelAssociation, From: "Customer", To: "Ticket", Label: "places", FromMultiplicity: "1", ToMultiplicity: "*"},
+		{ID: "rel_6", Type: extuml.RelDependency, From: "Service", To: "Logger"},
+		{ID: "rel_7", Type: extuml.RelLink, From: "A", To: "B"},
+		{ID: "rel_8", Type: extuml.RelDashedLink, From: "C", To: "D"},
+	}
+
+	rels := doc.Elements.Relationships
+	if len(rels) != len(expected) {
+		t.Fatalf("expected %d relationships, got %d", len(expected), len(rels))
+	}
+	for i, want := range expected {
+		if rels[i] != want {
+			t.Errorf("relationship %d: expected %+v, got %+v", i, want, rels[i])
+		}
+	}
+}
+
+func TestPackageParsing(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "pkg.extuml")
+
+	input := `extuml classDiagram3D
+
+class Customer {
+}
+
+package billing {
+  class Invoice {
+  }
+  package tax {
+    enum Rate {
+      STANDARD
+    }
+  }
+  interface Payable {
+  }
+}
+`
+	if err := os.WriteFile(inputPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write test input: %v", err)
+	}
+
+	doc, _, err := repository.NewExtumlRepository().Load(inputPath)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	pkgs := doc.Elements.Packages
+	if len(pkgs) != 2 {
+		t.Fatalf("expected 2 packages, got %d", len(pkgs))
+	}
+	if pkgs[0].ID != "billing" || !reflect.DeepEqual(pkgs[0].Children, []string{"billing.Invoice", "billing.tax", "billing.Payable"}) {
+		t.Errorf("unexpected billing package: %+v", pkgs[0])
+	}
+	if pkgs[1].ID != "billing.tax" || pkgs[1].Name != "tax" || !reflect.DeepEqual(pkgs[1].Children, []string{"billing.tax.Rate"}) {
+		t.Errorf("unexpected tax package: %+v", pkgs[1])
+	}
+	if len(doc.Elements.Classes) != 2 || len(doc.Elements.Enums) != 1 || len(doc.Elements.Interfaces) != 1 {
+		t.Errorf("package members not collected: %+v", doc.Elements)
+	}
+}
+
+func TestNoteParsing(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "note.extuml")
+
+	input := `extuml classDiagram3D
+
+class Person {
+}
+
+note for Person "Aggregate root\nowned by CRM"
+note "Free note
+spanning
+lines"
+`
+	if err := os.WriteFile(inputPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write test input: %v", err)
+	}
+
+	doc, _, err := repository.NewExtumlRepository().Load(inputPath)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	expected := []extuml.Note{
+		{ID: "note_1", Type: "note", Text: "Aggregate root\nowned by CRM", Anchor: "Person"},
+		{ID: "note_2", Type: "note", Text: "Free note\nspanning\nlines"},
+	}
+	if !reflect.DeepEqual(doc.Elements.Notes, expected) {
+		t.Errorf("expected notes %+v, got %+v", expected, doc.Elements.Notes)
+	}
+}
+
+func TestOperationSignatureParsing(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "ops.extuml")
+
+	input := `extuml classDiagram3D
+
+class Shape {
+  +setEmail(email: string): void
+  #area()* double
+  +create(String name, inout opts: Map<K, V>, retries: int = 3)$ : Shape
+  -String cache[0..*] = empty
+  +count$
+  static +x: int
+  abstract #draw(): void
+}
+`
+	if err := os.WriteFile(inputPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write test input: %v", err)
+	}
+
+	doc, _, err := repository.NewExtumlRepository().Load(inputPath)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	class := doc.Elements.Classes[0]
+	expectedOps := []extuml.Operation{
+		{Name: "setEmail", ReturnType: "void", Visibility: extuml.VisibilityPublic, Parameters: []extuml.Parameter{
+			{Name: "email", Type: "string"},
+		}},
+		{Name: "area", ReturnType: "double", Visibility: extuml.VisibilityProtected, Abstract: true, Parameters: []extuml.Parameter{}},
+		{Name: "create", ReturnType: "Shape", Visibility: extuml.VisibilityPublic, Static: true, Parameters: []extuml.Parameter{
+			{Name: "name", Type: "String"},
+			{Name: "opts", Type: "Map<K, V>", Direction: extuml.DirectionInOut},
+			{Name: "retries", Type: "int", Default: "3"},
+		}},
+		{Name: "draw", ReturnType: "void", Visibility: extuml.VisibilityProtected, Abstract: true, Parameters: []extuml.Parameter{}},
+	}
+	if !reflect.DeepEqual(class.Operations, expectedOps) {
+		t.Errorf("expected operations %+v, got %+v", expectedOps, class.Operations)
+	}
+
+	expectedAttrs := []extuml.Attribute{
+		{Name: "cache", Type: "String", Visibility: extuml.VisibilityPrivate, Multiplicity: "0..*", Default: "empty"},
+		{Name: "count", Visibility: extuml.VisibilityPublic, Static: true},
+		{Name: "x", Type: "int", Visibility: extuml.VisibilityPublic, Static: true},
+	}
+	if !reflect.DeepEqual(class.Attributes, expectedAttrs) {
+		t.Errorf("expected attributes %+v, got %+v", expectedAttrs, class.Attributes)
+	}
+}
+
+func TestSampleMemberParsing(t *testing.T) {
+	doc, _, err := repository.NewExtumlRepository().Load(filepath.Join("..", "etc", "sample.extuml"))
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	classes := make(map[string]extuml.Class)
+	for _, class := range doc.Elements.Classes {
+		classes[class.Name] = class
+	}
+
+	// UML `name: Type` form
+	person := classes["Person"]
+	expectedPerson := []extuml.Attribute{
+		{Name: "id", Type: "int", Visibility: extuml.VisibilityPublic},
+		{Name: "name", Type: "string", Visibility: extuml.VisibilityPublic},
+		{Name: "email", Type: "string", Visibility: extuml.VisibilityPublic},
+	}
+	if !reflect.DeepEqual(person.Attributes, expectedPerson) {
+		t.Errorf("expected Person attributes %+v, got %+v", expectedPerson, person.Attributes)
+	}
+
+	// Mermaid generics, `Type name` form, arrays and defaults
+	company := classes["Company"]
+	expectedCompany := []extuml.Attribute{
+		{Name: "name", Type: "string", Visibility: extuml.VisibilityPublic},
+		{Name: "founded", Type: "int", Visibility: extuml.VisibilityPublic},
+		{Name: "employees", Type: "List<Person>", Visibility: extuml.VisibilityPublic},
+		{Name: "tags", Type: "string[]", Visibility: extuml.VisibilityPublic},
+		{Name: "offices", Type: "Map<string, Address>", Visibility: extuml.VisibilityPrivate},
+		{Name: "active", Type: "bool", Visibility: extuml.VisibilityPublic, Default: "true"},
+	}
+	if !reflect.DeepEqual(company.Attributes, expectedCompany) {
+		t.Errorf("expected Company attributes %+v, got %+v", expectedCompany, company.Attributes)
+	}
+}
+
+func TestMemberGrammarForms(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "members.extuml")
+
+	input := `extuml classDiagram3D
+
+class Forms {
+  +String name
+  +name2: String
+  -List~List~int~~ matrix
+  #Map<K, V> cache
+  ~names[]: String
+  +String aliases[]
+  +url: string = "http://example.com/a:b"
+  +List~int~ position = empty
+  +created: Date = now()
+  +tags: List~String~ = List.of()
+  +String label = "a(b"
+  +run()
+}
+`
+	if err := os.WriteFile(inputPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write test input: %v", err)
+	}
+
+	doc, _, err := repository.NewExtumlRepository().Load(inputPath)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	expected := []extuml.Attribute{
+		{Name: "name", Type: "String", Visibility: extuml.VisibilityPublic},
+		{Name: "name2", Type: "String", Visibility: extuml.VisibilityPublic},
+		{Name: "matrix", Type: "List<List<int>>", Visibility: extuml.VisibilityPrivate},
+		{Name: "cache", Type: "Map<K, V>", Visibility: extuml.VisibilityProtected},
+		{Name: "names", Type: "String[]", Visibility: extuml.VisibilityPackage},
+		{Name: "aliases", Type: "String[]", Visibility: extuml.VisibilityPublic},
+		{Name: "url", Type: "string", Visibility: extuml.VisibilityPublic, Default: `"http://example.com/a:b"`},
+		{Name: "position", Type: "List<int>", Visibility: extuml.VisibilityPublic, Default: "empty"},
+		// Defaults holding parentheses leave the member an attribute
+		{Name: "created", Type: "Date", Visibility: extuml.VisibilityPublic, Default: "now()"},
+		{Name: "tags", Type: "List<String>", Visibility: extuml.VisibilityPublic, Default: "List.of()"},
+		{Name: "label", Type: "String", Visibility: extuml.VisibilityPublic, Default: `"a(b"`},
+	}
+	if !reflect.DeepEqual(doc.Elements.Classes[0].Attributes, expected) {
+		t.Errorf("expected attributes\n%+v\ngot\n%+v", expected, doc.Elements.Classes[0].Attributes)
+	}
+	if ops := doc.Elements.Classes[0].Operations; len(ops) != 1 || ops[0].Name != "run" {
+		t.Errorf("expected only the operation run, got %+v", ops)
+	}
+}
+
+func TestPlacementAnnotations(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "placement.extuml")
+
+	input := `extuml classDiagram3D
+
+class Person {
+  @pos: 3, 0, -6
+}
+
+interface Named {
+  @layer: 2
+}
+
+enum Status {
+  ACTIVE
+  @near: Person
+}
+`
+	if err := os.WriteFile(inputPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write test input: %v", err)
+	}
+
+	doc, _, err := repository.NewExtumlRepository().Load(inputPath)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	person := doc.Elements.Classes[0].Placement
+	if person == nil || person.Position == nil || *person.Position != [3]float64{3, 0, -6} {
+		t.Errorf("unexpected Person placement: %+v", person)
+	}
+	named := doc.Elements.Interfaces[0].Placement
+	if named == nil || named.Layer == nil || *named.Layer != 2 {
+		t.Errorf("unexpected Named placement: %+v", named)
+	}
+	status := doc.Elements.Enums[0]
+	if status.Placement == nil || status.Placement.Near != "Person" {
+		t.Errorf("unexpected Status placement: %+v", status.Placement)
+	}
+	if len(status.Literals) != 1 {
+		t.Errorf("annotations must not become enum literals: %v", status.Literals)
+	}
+}
+
+func TestStereotypesAndGenerics(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "stereotypes.extuml")
+
+	input := `extuml classDiagram3D
+
+abstract class Shape <<entity>> {
+  <<service, entity>>
+  +area(): double
+}
+
+class Repository~T~ {
+  <<final>>
+}
+
+class Cache<K, V> {
+}
+
+interface Store~List~T~~ <<repository>> {
+}
+
+enum Color {
+  <<flags>>
+  RED
+}
+`
+	if err := os.WriteFile(inputPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write test input: %v", err)
+	}
+
+	doc, _, err := repository.NewExtumlRepository().Load(inputPath)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	shape := doc.Elements.Classes[0]
+	if !shape.Abstract || shape.Final || !reflect.DeepEqual(shape.Stereotypes, []string{"entity", "service"}) {
+		t.Errorf("unexpected Shape: %+v", shape)
+	}
+	repo := doc.Elements.Classes[1]
+	if !repo.Final || len(repo.Stereotypes) != 0 || !reflect.DeepEqual(repo.TypeParameters, []string{"T"}) {
+		t.Errorf("unexpected Repository: %+v", repo)
+	}
+	if cache := doc.Elements.Classes[2]; !reflect.DeepEqual(cache.TypeParameters, []string{"K", "V"}) {
+		t.Errorf("unexpected Cache type parameters: %v", cache.TypeParameters)
+	}
+	store := doc.Elements.Interfaces[0]
+	if !reflect.DeepEqual(store.TypeParameters, []string{"List<T>"}) || !reflect.DeepEqual(store.Stereotypes, []string{"repository"}) {
+		t.Errorf("unexpected Store: %+v", store)
+	}
+	color := doc.Elements.Enums[0]
+	if !reflect.DeepEqual(color.Stereotypes, []string{"flags"}) || !reflect.DeepEqual(color.Literals, []string{"RED"}) {
+		t.Errorf("unexpected Color: %+v", color)
+	}
+}
+
+func TestAliasesAndQualifiedIDs(t *testing.T) {
+	src := `extuml classDiagram3D
+
+class billing.Config as "Billing Config" {
+}
+class Cfg["Shop Config"] {
+  @near: Config
+}
+package crm {
+  class Config {
+  }
+  class Customer~T~ as "Customer Record" {
+  }
+  Customer --> Config
+}
+package billing {
+  enum Currency {
+    EUR
+  }
+}
+Cfg --> crm.Customer
+note for Currency "ISO 4217"
+Cfg ..> Config
+`
+	file, diags := parser.Parse("alias.extuml", []byte(src))
+	doc, lowerDiags := parser.Lower(file)
+	diags = append(diags, lowerDiags...)
+
+	type classifier struct{ id, name string }
+	var got []classifier
+	for _, c := range doc.Elements.Classes {
+		got = append(got, classifier{c.ID, c.Name})
+	}
+	for _, e := range doc.Elements.Enums {
+		got = append(got, classifier{e.ID, e.Name})
+	}
+	expected := []classifier{
+		{"billing.Config", "Billing Config"},
+		{"Cfg", "Shop Config"},
+		{"crm.Config", "Config"},
+		{"crm.Customer", "Customer Record"},
+		{"billing.Currency", "Currency"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected classifiers:\n got %v\nwant %v", got, expected)
+	}
+
+	// References resolve in the enclosing package first, then by unique
+	// short name; `Config` alone is ambiguous at the top level
+	rels := doc.Elements.Relationships
+	if len(rels) != 3 || rels[0].From != "crm.Customer" || rels[0].To != "crm.Config" ||
+		rels[1].From != "Cfg" || rels[1].To != "crm.Customer" || rels[2].To != "Config" {
+		t.Errorf("unexpected relationships: %+v", rels)
+	}
+	if doc.Elements.Notes[0].Anchor != "billing.Currency" {
+		t.Errorf("expected note anchored to billing.Currency, got %q", doc.Elements.Notes[0].Anchor)
+	}
+
+	var codes []string
+	for _, d := range diags {
+		codes = append(codes, d.Code)
+	}
+	want := []string{diagnostic.CodeAmbiguousReference, diagnostic.CodeAmbiguousReference}
+	if !reflect.DeepEqual(codes, want) {
+		t.Errorf("expected %v, got %v", want, diags)
+	}
+
+	// Qualified IDs must be unique, also across packages and classifiers
+	diags = parseAndLower("dup.extuml", `extuml classDiagram3D
+package crm {
+  class Config
+}
+class crm.Config
+class crm
+`)
+	if len(diags) != 2 || diags[0].Code != diagnostic.CodeDuplicateElement || diags[0].Span.Start.Line != 5 ||
+		diags[1].Code != diagnostic.CodeDuplicateElement || diags[1].Span.Start.Line != 6 {
+		t.Errorf("expected duplicate ID errors on lines 5 and 6, got %v", diags)
+	}
+}
+
+func TestStyleDirectives(t *testing.T) {
+	src := `extuml classDiagram3D
+%%{init: {"theme": "midnight"}}%%
+classDef critical fill:#f66,stroke:#900
+classDef critical, external color:white
+class Person:::critical {
+}
+package billing {
+  interface Payable {
+  }
+  style Payable stroke:#333, stroke-width:4px
+}
+enum Level:::external
+cssClass "Person, billing.Payable" external
+style Level fill:#0f08
+style Level stroke:#12
+class Other:::unknown
+style Missing fill:red
+`
+	file, diags := parser.Parse("style.extuml", []byte(src))
+	doc, lowerDiags := parser.Lower(file)
+	diags = append(diags, lowerDiags...)
+
+	expectedDefs := map[string]extuml.Style{
+		"critical": {Fill: "#f66", Stroke: "#900", Color: "white"},
+		"external": {Color: "white"},
+	}
+	if !reflect.DeepEqual(doc.ClassDefs, expectedDefs) {
+		t.Errorf("unexpected classDefs: %+v", doc.ClassDefs)
+	}
+
+	person := doc.Elements.Classes[0]
+	if !reflect.DeepEqual(person.StyleClasses, []string{"critical", "external"}) || person.Style != nil {
+		t.Errorf("unexpected Person style: %+v", person)
+	}
+	payable := doc.Elements.Interfaces[0]
+	if !reflect.DeepEqual(payable.StyleClasses, []string{"external"}) || payable.Style == nil || *payable.Style != (extuml.Style{Stroke: "#333"}) {
+		t.Errorf("unexpected Payable style: %+v", payable)
+	}
+	level := doc.Elements.Enums[0]
+	if !reflect.DeepEqual(level.StyleClasses, []string{"external"}) || level.Style == nil || *level.Style != (extuml.Style{Fill: "#0f08"}) {
+		t.Errorf("unexpected Level style: %+v", level)
+	}
+
+	expected := []struct {
+		code string
+		line int
+	}{
+		{diagnostic.CodeUnknownSetting, 2},
+		{diagnostic.CodeUnknownStyleProperty, 10},
+		{diagnostic.CodeInvalidColor, 15},
+		{diagnostic.CodeUnknownStyleClass, 16},
+		{diagnostic.CodeUnknownStyleTarget, 17},
+	}
+	diags.Sort()
+	if len(diags) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %d:\n%v", len(expected), len(diags), diags)
+	}
+	for i, want := range expected {
+		if diags[i].Code != want.code || diags[i].Span.Start.Line != want.line {
+			t.Errorf("diagnostic %d: expected %s on line %d, got %v", i, want.code, want.line, diags[i])
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/config"
+	"github.com/extuml/extuml/pkg/formatter"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+const unformattedSource = `---
+title: Shop
+---
+%%{ init: { "theme": "dark" } }%%
+extuml   classDiagram3D
+author:   "Me"
+%% the order
+abstract   class Order<T>:::hot as "Big Order"   <<Entity>> {
+        +total(): Money
+    %% identifier
+    -id: Long
+
+
+  @url   https://example.com
+  <<Aggregate>>
+  +items: int
+}
+class Empty {
+}
+   Order "1"   *--   "many" Line:   has
+package billing {
+
+    %% invoices
+    class Invoice {
+      +pay()
+    }
+        note for Invoice "Billed
+            every
+              month"
+  package tax {
+  class Rule
+  }
+
+
+}
+classDef hot   fill:#f66 , stroke:#900
+cssClass "Order, Line" hot
+include "line.extuml"
+%% trailing
+`
+
+const formattedSource = `---
+title: Shop
+---
+%%{ init: { "theme": "dark" } }%%
+extuml classDiagram3D
+
+author: "Me"
+
+%% the order
+abstract class Order<T>:::hot as "Big Order" <<Entity>> {
+  <<Aggregate>>
+  %% identifier
+  -id: Long
+  +items: int
+  +total(): Money
+  @url: https://example.com
+}
+
+class Empty
+Order "1" *-- "many" Line : has
+
+package billing {
+  %% invoices
+  class Invoice {
+    +pay()
+  }
+  note for Invoice "Billed
+    every
+    month"
+  package tax {
+    class Rule
+  }
+}
+
+classDef hot fill:#f66,stroke:#900
+cssClass "Order,Line" hot
+include "line.extuml"
+%% trailing
+`
+
+func TestFormatCanonicalLayout(t *testing.T) {
+	out, diags := formatter.Source("shop.extuml", []byte(unformattedSource))
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if string(out) != formattedSource {
+		t.Errorf("unexpected formatting:\n%s\nwant:\n%s", out, formattedSource)
+	}
+}
+
+func TestFormatMemberOrder(t *testing.T) {
+	src := "extuml classDiagram3D\n\nclass User {\n  +login(): bool\n  +name: string %% (deprecated)\n  +created: Date = now()\n}\n"
+	want := "extuml classDiagram3D\n\nclass User {\n  +name: string %% (deprecated)\n  +created: Date = now()\n  +login(): bool\n}\n"
+
+	// Attributes rank before operations whatever their comments and defaults
+	out, diags := formatter.Source("user.extuml", []byte(src))
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if string(out) != want {
+		t.Errorf("unexpected formatting:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestFormatKeepsModel(t *testing.T) {
+	sample, err := os.ReadFile(filepath.Join("..", "etc", "sample.extuml"))
+	if err != nil {
+		t.Fatalf("read sample: %v", err)
+	}
+
+	for name, src := range map[string][]byte{
+		"unformatted.extuml": []byte(unformattedSource),
+		"shop.extuml":        []byte(exportSource),
+		"quotes.extuml":      []byte(quotesSource),
+		"sample.extuml":      sample,
+	} {
+		out, diags := formatter.Source(name, src)
+		if diags.HasErrors() {
+			t.Fatalf("%s: unexpected diagnostics: %v", name, diags)
+		}
+
+		// Formatting changes the layout only
+		file, _ := parser.Parse(name, src)
+		want, _ := parser.Lower(file)
+		file, _ = parser.Parse(name, out)
+		got, _ := parser.Lower(file)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: document changed by formatting\n got %+v\nwant %+v\nsource:\n%s", name, got, want, out)
+		}
+
+		// and the canonical layout is a fixed point
+		if again, _ := formatter.Source(name, out); string(again) != string(out) {
+			t.Errorf("%s: formatting again changed the source:\n%s\nthen:\n%s", name, out, again)
+		}
+	}
+
+	// The sample is already formatted
+	if out, _ := formatter.Source("sample.extuml", sample); string(out) != string(sample) {
+		t.Errorf("expected the sample to be formatted, got:\n%s", out)
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk"
+	want := `--- x.orig
++++ x
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -8,3 +8,4 @@
+ h
+ i
+ j
++k
+\ No newline at end of file
+`
+	if got := string(formatter.Diff("x.orig", "x", []byte(before), []byte(after))); got != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	if diff := formatter.Diff("x.orig", "x", []byte(before), []byte(before)); diff != nil {
+		t.Errorf("expected no diff for equal sources, got:\n%s", diff)
+	}
+}
+
+func TestFmtCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"model.extuml":           unformattedSource,
+		"sub/done.extuml":        formattedSource,
+		"sub/notes.txt":          "not a diagram",
+		"broken/broken.extuml":   "extuml classDiagram3D\nclass {\n",
+		".hidden/skipped.extuml": "extuml classDiagram3D\n  class A\n",
+	})
+	cfg := config.NewConfig()
+
+	// Checking lists every file without rewriting any
+	files, _, err := cfg.FmtCtrl.Format([]string{filepath.Join(tmpDir, "model.extuml"), filepath.Join(tmpDir, "sub")}, false)
+	if err != nil {
+		t.Fatalf("fmt failed: %v", err)
+	}
+	if len(files) != 2 || !files[0].Changed() || files[1].Changed() {
+		t.Fatalf("expected model.extuml to need formatting and done.extuml not, got %+v", files)
+	}
+	if len(files[0].Diff()) == 0 {
+		t.Error("expected a diff for model.extuml")
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "model.extuml")); string(data) != unformattedSource {
+		t.Error("expected checking to leave the file alone")
+	}
+
+	// Writing rewrites the file; broken files are reported and left alone
+	_, diags, err := cfg.FmtCtrl.Format([]string{tmpDir}, true)
+	if err == nil || len(diags) == 0 || diags[0].Span.Start.File != filepath.Join(tmpDir, "broken", "broken.extuml") {
+		t.Errorf("expected the syntax error in broken.extuml to fail, got %v %v", diags, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "model.extuml")); string(data) != formattedSource {
+		t.Errorf("expected model.extuml to be rewritten, got:\n%s", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, ".hidden", "skipped.extuml")); string(data) != "extuml classDiagram3D\n  class A\n" {
+		t.Error("expected hidden directories to be skipped")
+	}
+}
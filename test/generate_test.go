@@ -1,13 +1,19 @@
 package test
 
 import (
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/extuml/extuml/pkg/config"
+	"github.com/extuml/extuml/pkg/model/extuml"
 	"github.com/extuml/extuml/pkg/model/gltf"
+	"github.com/extuml/extuml/pkg/usecase"
 )
 
 func TestGenerateCommand(t *testing.T) {
@@ -29,7 +35,7 @@ class Test {
 
 	// Use dependency injection to test
 	cfg := config.NewConfig()
-	err := cfg.GenerateCtrl.Generate(inputPath, outputPath, "") // No HTML output in test
+	_, _, err := cfg.GenerateCtrl.Generate(inputPath, outputPath, "", "", usecase.DefaultLayoutOptions()) // No HTML output in test
 	if err != nil {
 		t.Fatalf("generate failed: %v", err)
 	}
@@ -69,3 +75,353 @@ class Test {
 		t.Errorf("expected version=0.1, got %v", version)
 	}
 }
+
+func TestGeneratePackageHierarchy(t *testing.T) {
+	input := `extuml classDiagram3D
+
+package billing {
+  class Invoice {
+  }
+}
+`
+	gltfAsset, _, _ := generateScene(t, t.TempDir(), "test.extuml", input)
+
+	// The package node must be a scene root and own the class node
+	var packageNode *gltf.Node
+	for _, root := range gltfAsset.Scenes[0].Nodes {
+		if gltfAsset.Nodes[root].Name == "billing" {
+			packageNode = &gltfAsset.Nodes[root]
+		}
+	}
+	if packageNode == nil {
+		t.Fatalf("expected package node 'billing' among scene roots")
+	}
+
+	found := false
+	for _, child := range packageNode.Children {
+		if gltfAsset.Nodes[child].Name == "Invoice" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected class 'Invoice' to be a child of package 'billing'")
+	}
+}
+
+func TestGenerateMetaInExtrasAndViewer(t *testing.T) {
+	tmpDir := t.TempDir()
+	htmlPath := filepath.Join(tmpDir, "viewer.html")
+
+	input := `---
+title: Billing Overview
+author: Jane
+---
+%%{ init: { 'theme': 'dark' } }%%
+extuml classDiagram3D
+id: billing-overview
+
+class Invoice {
+}
+`
+	gltfAsset, _, _ := generateSceneWith(t, tmpDir, "test.extuml", input, htmlPath, usecase.DefaultLayoutOptions())
+
+	extras, _ := gltfAsset.Asset.Extras.(map[string]any)
+	meta, _ := extras["extuml"].(map[string]any)
+	if meta["title"] != "Billing Overview" || meta["author"] != "Jane" || meta["id"] != "billing-overview" {
+		t.Errorf("expected meta in asset extras, got %v", meta)
+	}
+	if cfgMap, _ := meta["config"].(map[string]any); cfgMap["theme"] != "dark" {
+		t.Errorf("expected init directive config in asset extras, got %v", meta["config"])
+	}
+
+	html, err := os.ReadFile(htmlPath)
+	if err != nil {
+		t.Fatalf("failed to read viewer: %v", err)
+	}
+	if !strings.Contains(string(html), "<h1>Billing Overview</h1>") || !strings.Contains(string(html), "by Jane") {
+		t.Errorf("expected the viewer header to show the diagram title and author")
+	}
+}
+
+func TestGeneratePlacementAnnotations(t *testing.T) {
+	input := `extuml classDiagram3D
+
+class Pinned {
+  @pos: 3, 0, 0
+}
+class Auto {
+}
+class Beside {
+  @near: Pinned
+}
+class Deep {
+  @layer: 2
+}
+class Next {
+}
+`
+	// The automatic rows are those of the grid layout
+	layout := usecase.DefaultLayoutOptions()
+	layout.Algorithm = usecase.LayoutGrid
+	_, _, nodes := generateSceneWith(t, t.TempDir(), "test.extuml", input, "", layout)
+
+	translations := map[string][]float64{}
+	for name, node := range nodes {
+		translations[name] = node.Translation
+	}
+
+	expected := map[string][]float64{
+		"Pinned": {3, 0, 0},
+		"Auto":   {0, 0, 0},
+		"Next":   {6, 0, 0}, // the slot at x=3 is taken by Pinned
+		"Deep":   {0, 0, -10},
+	}
+	for name, want := range expected {
+		if got := translations[name]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected translation %v, got %v", name, want, got)
+		}
+	}
+
+	// Beside sits next to Pinned without overlapping it or Next
+	beside := translations["Beside"]
+	if len(beside) != 3 || beside[2] != 0 || (beside[0] == 3 && beside[1] == 0) || (beside[0] == 6 && beside[1] == 0) {
+		t.Errorf("unexpected Beside translation %v", beside)
+	}
+}
+
+func TestGenerateStereotypeLabels(t *testing.T) {
+	input := `extuml classDiagram3D
+
+abstract class Repository~T~ <<service>> {
+  +find(id: int): T
+}
+
+interface Named {
+  +getName(): string
+}
+`
+	_, byType, _ := generateScene(t, t.TempDir(), "test.extuml", input)
+
+	if len(byType["class"]) != 1 {
+		t.Fatalf("expected one class, got %v", byType["class"])
+	}
+	classExtras := byType["class"][0]
+	labels := map[string]map[string]any{}
+	for _, ext := range byType["text"] {
+		labels[strings.SplitN(ext["text"].(string), "\n---", 2)[0]] = ext
+	}
+
+	if classExtras["abstract"] != true || !reflect.DeepEqual(classExtras["stereotypes"], []any{"service"}) ||
+		!reflect.DeepEqual(classExtras["typeParameters"], []any{"T"}) {
+		t.Errorf("unexpected class extras: %v", classExtras)
+	}
+
+	// The stereotype line sits above the name, which is italic for abstract classes
+	classLabel, ok := labels["«service»\nRepository<T>"]
+	if !ok {
+		t.Fatalf("expected a class label with a stereotype line, got %v", labels)
+	}
+	if !reflect.DeepEqual(classLabel["italicLines"], []any{float64(1)}) {
+		t.Errorf("expected the name line in italics, got %v", classLabel["italicLines"])
+	}
+	if _, ok := labels["«interface»\nNamed"]; !ok {
+		t.Errorf("expected an interface label, got %v", labels)
+	}
+}
+
+func TestGenerateUsesIDsInExtras(t *testing.T) {
+	input := `extuml classDiagram3D
+
+package billing {
+  class Config as "Billing Config" {
+  }
+}
+package shop {
+  class Config["Shop Config"] {
+  }
+  Config --> billing.Config
+}
+`
+	_, byType, _ := generateScene(t, t.TempDir(), "test.extuml", input)
+
+	classes := map[string]string{}
+	for _, ext := range byType["class"] {
+		classes[ext["id"].(string)] = ext["name"].(string)
+	}
+	var rel map[string]any
+	if rels := byType["relationship"]; len(rels) == 1 {
+		rel = rels[0]
+	}
+
+	expected := map[string]string{"billing.Config": "Billing Config", "shop.Config": "Shop Config"}
+	if !reflect.DeepEqual(classes, expected) {
+		t.Errorf("expected class extras %v, got %v", expected, classes)
+	}
+	if rel == nil || rel["from"] != "shop.Config" || rel["to"] != "billing.Config" {
+		t.Errorf("expected a relationship between the qualified IDs, got %v", rel)
+	}
+}
+
+func TestGenerateStyles(t *testing.T) {
+	input := `---
+config:
+  theme: dark
+---
+extuml classDiagram3D
+classDef critical fill:#ff0000,stroke:#990000,color:#ffffff
+class Person:::critical {
+}
+class Plain {
+}
+`
+	gltfAsset, byType, _ := generateScene(t, t.TempDir(), "test.extuml", input)
+
+	materials := map[string][]float64{}
+	for _, m := range gltfAsset.Materials {
+		materials[m.Name] = m.PbrMetallicRoughness.BaseColorFactor
+	}
+	if got := materials["Person_material"]; !reflect.DeepEqual(got, []float64{0.6, 0, 0, 1}) {
+		t.Errorf("expected the classDef stroke on Person, got %v", got)
+	}
+	if got := materials["Person_fill_material"]; !reflect.DeepEqual(got, []float64{1, 0, 0, 0.25}) {
+		t.Errorf("expected a translucent red fill for Person, got %v", got)
+	}
+	dark, _ := extuml.ParseColor(extuml.Themes["dark"].Class.Stroke)
+	if got := materials["Plain_material"]; !reflect.DeepEqual(got, []float64{dark[0], dark[1], dark[2], 1}) {
+		t.Errorf("expected the dark theme stroke on Plain, got %v", got)
+	}
+
+	// Label colours: the classDef wins over the theme's text colour
+	labels := map[string]any{}
+	for _, ext := range byType["text"] {
+		labels[strings.SplitN(ext["text"].(string), "\n", 2)[0]] = ext["color"]
+	}
+	if labels["Person"] != "#ffffff" || labels["Plain"] != extuml.Themes["dark"].Text {
+		t.Errorf("unexpected label colours: %v", labels)
+	}
+
+	extras := gltfAsset.Asset.Extras.(map[string]any)["extuml"].(map[string]any)
+	if extras["background"] != extuml.Themes["dark"].Background {
+		t.Errorf("expected the theme background in the asset extras, got %v", extras["background"])
+	}
+}
+
+func TestGenerateLongDashedRelationship(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "test.extuml")
+	outputPath := filepath.Join(tmpDir, "output.gl")
+
+	// The dashes of a line this long need more than 65,536 vertices
+	input := `extuml classDiagram3D
+
+class A {
+  @pos: 30000, 0, 0
+}
+class B {
+  @pos: 0, 0, 0
+}
+A ..> B
+`
+	if err := os.WriteFile(inputPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write test input: %v", err)
+	}
+
+	cfg := config.NewConfig()
+	if _, _, err := cfg.GenerateCtrl.Generate(inputPath, outputPath, "", "", usecase.DefaultLayoutOptions()); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	var asset gltf.GLTFAsset
+	if err := json.Unmarshal(data, &asset); err != nil {
+		t.Fatalf("invalid glTF JSON: %v", err)
+	}
+
+	for _, node := range asset.Nodes {
+		extras, _ := node.Extras.(map[string]any)
+		if ext, _ := extras["extuml"].(map[string]any); ext["type"] != "relationship" {
+			continue
+		}
+		primitive := asset.Meshes[*node.Mesh].Primitives[0]
+		vertices := asset.Accessors[primitive.Attributes["POSITION"]].Count
+		indices := asset.Accessors[*primitive.Indices]
+		view := asset.BufferViews[*indices.BufferView]
+		if vertices <= 65536 || indices.ComponentType != 5125 || view.ByteLength != indices.Count*4 {
+			t.Fatalf("expected unsigned int indices for %d vertices, got type %d and %d bytes for %d indices",
+				vertices, indices.ComponentType, view.ByteLength, indices.Count)
+		}
+
+		uri := asset.Buffers[view.Buffer].URI
+		buffer, err := base64.StdEncoding.DecodeString(uri[strings.Index(uri, ",")+1:])
+		if err != nil {
+			t.Fatalf("invalid buffer URI: %v", err)
+		}
+		last := uint32(0)
+		for i := 0; i < indices.Count; i++ {
+			last = binary.LittleEndian.Uint32(buffer[view.ByteOffset+i*4:])
+			if int(last) >= vertices {
+				t.Fatalf("index %d points at vertex %d of %d", i, last, vertices)
+			}
+		}
+		if int(last) != vertices-1 {
+			t.Errorf("expected the last index to address the last vertex %d, got %d", vertices-1, last)
+		}
+		return
+	}
+	t.Fatal("expected a relationship node")
+}
+
+func TestGenerateSelfRelationship(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "test.extuml")
+	outputPath := filepath.Join(tmpDir, "output.gl")
+
+	input := "extuml classDiagram3D\n\nclass Employee {\n}\nEmployee \"1\" --> \"*\" Employee : manages\n"
+	if err := os.WriteFile(inputPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write test input: %v", err)
+	}
+
+	cfg := config.NewConfig()
+	if _, _, err := cfg.GenerateCtrl.Generate(inputPath, outputPath, "", "", usecase.DefaultLayoutOptions()); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	var asset gltf.GLTFAsset
+	if err := json.Unmarshal(data, &asset); err != nil {
+		t.Fatalf("invalid glTF JSON: %v", err)
+	}
+
+	// The relationship is followed by its label and both multiplicities
+	var class, line []float64
+	var labels [][]float64
+	for i, node := range asset.Nodes {
+		extras, _ := node.Extras.(map[string]any)
+		ext, _ := extras["extuml"].(map[string]any)
+		switch ext["type"] {
+		case "class":
+			class = node.Translation
+		case "relationship":
+			line = node.Translation
+			for _, label := range asset.Nodes[i+1 : i+4] {
+				labels = append(labels, label.Translation)
+			}
+		}
+	}
+	if len(class) != 3 || len(line) != 3 || len(labels) != 3 {
+		t.Fatalf("expected a class, a relationship and three labels, got %v %v %v", class, line, labels)
+	}
+	if line[1] <= class[1] {
+		t.Errorf("expected the loop to start on top of the class at %v, got %v", class, line)
+	}
+	if name := labels[0]; name[1] <= line[1]+0.5 {
+		t.Errorf("expected the label over the loop starting at %v, got %v", line, name)
+	}
+	if from, to := labels[1], labels[2]; from[1] <= class[1] || to[1] <= class[1] || from[0] >= to[0] {
+		t.Errorf("expected the multiplicities on either side of the loop over %v, got %v and %v", class, from, to)
+	}
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/config"
+	"github.com/extuml/extuml/pkg/model/gltf"
+	"github.com/extuml/extuml/pkg/usecase"
+)
+
+// writeFiles writes name -> content pairs below dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+}
+
+// generateScene writes src below dir as name and generates it. It returns
+// the glTF asset, the extuml extras of its nodes grouped by their type and
+// the nodes by name.
+func generateScene(t *testing.T, dir, name, src string) (asset gltf.GLTFAsset, byType map[string][]map[string]any, nodes map[string]gltf.Node) {
+	t.Helper()
+	return generateSceneWith(t, dir, name, src, "", usecase.DefaultLayoutOptions())
+}
+
+// generateSceneWith is generateScene with a viewer written to htmlPath,
+// unless it is empty, and the given layout
+func generateSceneWith(t *testing.T, dir, name, src, htmlPath string, layout usecase.LayoutOptions) (asset gltf.GLTFAsset, byType map[string][]map[string]any, nodes map[string]gltf.Node) {
+	t.Helper()
+	writeFiles(t, dir, map[string]string{name: src})
+	inputPath := filepath.Join(dir, name)
+	outputPath := strings.TrimSuffix(inputPath, filepath.Ext(inputPath)) + ".gltf"
+
+	cfg := config.NewConfig()
+	if _, _, err := cfg.GenerateCtrl.Generate(inputPath, outputPath, htmlPath, "", layout); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if err := json.Unmarshal(data, &asset); err != nil {
+		t.Fatalf("invalid glTF JSON: %v", err)
+	}
+
+	byType = map[string][]map[string]any{}
+	nodes = map[string]gltf.Node{}
+	for _, node := range asset.Nodes {
+		extras, _ := node.Extras.(map[string]any)
+		e, _ := extras["extuml"].(map[string]any)
+		if e == nil {
+			t.Errorf("node %s has no extuml extras", node.Name)
+			continue
+		}
+		kind, ok := e["type"].(string)
+		if !ok {
+			t.Fatalf("node %s has no type in its extras %v", node.Name, e)
+		}
+		byType[kind] = append(byType[kind], e)
+		nodes[node.Name] = node
+	}
+	return asset, byType, nodes
+}
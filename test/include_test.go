@@ -0,0 +1,100 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/repository"
+)
+
+func TestIncludeAssemblesDocument(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"main.extuml": `extuml classDiagram3D
+include "billing/billing.extuml"
+include "shared.extuml"
+package crm {
+  import "crm.extuml"
+}
+Customer --> Invoice : pays
+`,
+		"billing/billing.extuml": `extuml classDiagram3D
+include "../shared.extuml"
+class Invoice {
+}
+Invoice --> Money
+`,
+		"shared.extuml": `extuml classDiagram3D
+class Money {
+}
+`,
+		"crm.extuml": `extuml classDiagram3D
+class Customer {
+}
+`,
+	})
+
+	doc, diags, err := repository.NewExtumlRepository().Load(filepath.Join(tmpDir, "main.extuml"))
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+
+	// shared.extuml is included twice but lowered once
+	var names []string
+	for _, c := range doc.Elements.Classes {
+		names = append(names, c.Name)
+	}
+	if len(names) != 3 || names[0] != "Money" || names[1] != "Invoice" || names[2] != "Customer" {
+		t.Errorf("unexpected classes: %v", names)
+	}
+
+	// Included declarations belong to the including package
+	if len(doc.Elements.Packages) != 1 || len(doc.Elements.Packages[0].Children) != 1 ||
+		doc.Elements.Packages[0].Children[0] != "crm.Customer" {
+		t.Errorf("expected Customer inside package crm, got %+v", doc.Elements.Packages)
+	}
+
+	// Relationships resolve across files, by short name where it is unique
+	if rels := doc.Elements.Relationships; len(rels) != 2 || rels[1].From != "crm.Customer" || rels[1].To != "Invoice" {
+		t.Errorf("expected 2 relationships, got %+v", rels)
+	}
+}
+
+func TestIncludeDiagnostics(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"a.extuml": "extuml classDiagram3D\ninclude \"b.extuml\"\n",
+		"b.extuml": "extuml classDiagram3D\nclass B {\n}\ninclude \"a.extuml\"\ninclude \"missing.extuml\"\nclass B {\n}\n",
+	})
+
+	_, diags, err := repository.NewExtumlRepository().Load(filepath.Join(tmpDir, "a.extuml"))
+	if err == nil {
+		t.Fatalf("expected load to fail")
+	}
+
+	bPath := filepath.Join(tmpDir, "b.extuml")
+	expected := []struct {
+		code string
+		line int
+	}{
+		{diagnostic.CodeIncludeCycle, 4},
+		{diagnostic.CodeIncludeNotFound, 5},
+		{diagnostic.CodeDuplicateElement, 6},
+	}
+	if len(diags) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %d:\n%v", len(expected), len(diags), diags)
+	}
+	for i, want := range expected {
+		d := diags[i]
+		if d.Code != want.code || d.Span.Start.File != bPath || d.Span.Start.Line != want.line {
+			t.Errorf("diagnostic %d: expected %s at %s:%d, got %v", i, want.code, bPath, want.line, d)
+		}
+		if d.SourceLine == "" {
+			t.Errorf("diagnostic %d: expected the source line of the included file", i)
+		}
+	}
+}
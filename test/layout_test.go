@@ -0,0 +1,211 @@
+package test
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/config"
+	"github.com/extuml/extuml/pkg/model/gltf"
+	"github.com/extuml/extuml/pkg/usecase"
+)
+
+const forceLayoutInput = `extuml classDiagram3D
+
+package shop {
+  class Order {
+  }
+  class Item {
+  }
+  class Cart {
+  }
+}
+package billing {
+  class Invoice {
+  }
+  class Payment {
+  }
+}
+class User {
+}
+class Audit {
+}
+class Pinned {
+  @pos: 20, 0, 0
+}
+interface Repository {
+}
+enum Status {
+}
+
+Order --> Item
+Cart --> Item
+User --> Cart
+User --> Order
+Invoice --> Order
+Payment --> Invoice
+Order ..|> Repository
+Order --> Status
+`
+
+// layoutPositions generates input with the given layout and returns the
+// scene position of every node by name
+func layoutPositions(t *testing.T, input string, layout usecase.LayoutOptions) map[string][3]float64 {
+	t.Helper()
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "test.extuml")
+	outputPath := filepath.Join(tmpDir, "output.gl")
+	if err := os.WriteFile(inputPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write test input: %v", err)
+	}
+
+	cfg := config.NewConfig()
+	if _, _, err := cfg.GenerateCtrl.Generate(inputPath, outputPath, "", "", layout); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	var asset gltf.GLTFAsset
+	if err := json.Unmarshal(data, &asset); err != nil {
+		t.Fatalf("invalid glTF JSON: %v", err)
+	}
+
+	// Package members are placed relative to their package node
+	positions := map[string][3]float64{}
+	var walk func(idx int, offset [3]float64)
+	walk = func(idx int, offset [3]float64) {
+		node := asset.Nodes[idx]
+		p := offset
+		if len(node.Translation) == 3 {
+			for axis := 0; axis < 3; axis++ {
+				p[axis] += node.Translation[axis]
+			}
+		}
+		positions[node.Name] = p
+		for _, child := range node.Children {
+			walk(child, p)
+		}
+	}
+	for _, root := range asset.Scenes[0].Nodes {
+		walk(root, [3]float64{})
+	}
+	return positions
+}
+
+// forceOptions returns the default force layout with the given seed and
+// iteration limit
+func forceOptions(seed int64, iterations int) usecase.LayoutOptions {
+	layout := usecase.DefaultLayoutOptions()
+	layout.Seed, layout.Iterations = seed, iterations
+	return layout
+}
+
+func distance(a, b [3]float64) float64 {
+	return math.Sqrt((a[0]-b[0])*(a[0]-b[0]) + (a[1]-b[1])*(a[1]-b[1]) + (a[2]-b[2])*(a[2]-b[2]))
+}
+
+func TestForceLayoutDeterministic(t *testing.T) {
+	first := layoutPositions(t, forceLayoutInput, forceOptions(42, usecase.DefaultLayoutIterations))
+	second := layoutPositions(t, forceLayoutInput, forceOptions(42, usecase.DefaultLayoutIterations))
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("equal seeds gave different layouts:\n%v\n%v", first, second)
+	}
+
+	other := layoutPositions(t, forceLayoutInput, forceOptions(7, usecase.DefaultLayoutIterations))
+	if reflect.DeepEqual(first, other) {
+		t.Errorf("different seeds gave the same layout")
+	}
+
+	// The iteration limit is honoured
+	short := layoutPositions(t, forceLayoutInput, forceOptions(42, 1))
+	if reflect.DeepEqual(first, short) {
+		t.Errorf("a single iteration gave the same layout as the default limit")
+	}
+}
+
+func TestForceLayoutPlacement(t *testing.T) {
+	positions := layoutPositions(t, forceLayoutInput, usecase.DefaultLayoutOptions())
+
+	if got := positions["Pinned"]; got != [3]float64{20, 0, 0} {
+		t.Errorf("Pinned: expected @pos [20 0 0], got %v", got)
+	}
+
+	// Not a line: the classifiers spread over both X and Y
+	classifiers := []string{"Order", "Item", "Cart", "Invoice", "Payment", "User", "Audit", "Repository", "Status"}
+	var minB, maxB [2]float64
+	for i, id := range classifiers {
+		p := positions[id]
+		for axis := 0; axis < 2; axis++ {
+			if i == 0 || p[axis] < minB[axis] {
+				minB[axis] = p[axis]
+			}
+			if i == 0 || p[axis] > maxB[axis] {
+				maxB[axis] = p[axis]
+			}
+		}
+	}
+	if maxB[0]-minB[0] < 3 || maxB[1]-minB[1] < 3 {
+		t.Errorf("expected the layout to spread over X and Y, got extent %v..%v", minB, maxB)
+	}
+
+	// Classes are cubes of 2.5, which must not intersect
+	classes := classifiers[:7]
+	for i, a := range classes {
+		for _, b := range classes[i+1:] {
+			pa, pb := positions[a], positions[b]
+			if math.Abs(pa[0]-pb[0]) < 2.5 && math.Abs(pa[1]-pb[1]) < 2.5 && math.Abs(pa[2]-pb[2]) < 2.5 {
+				t.Errorf("%s at %v overlaps %s at %v", a, pa, b, pb)
+			}
+		}
+	}
+
+	// Package members stay on their package's depth layer
+	for id, z := range map[string]float64{"Order": -5, "Item": -5, "Cart": -5, "Invoice": -10, "Payment": -10, "User": 0} {
+		if got := positions[id][2]; math.Abs(got-z) > 2.5 {
+			t.Errorf("%s: expected z near %v, got %v", id, z, got)
+		}
+	}
+
+	// Related classes end up closer than unrelated ones
+	if related, unrelated := distance(positions["Payment"], positions["Invoice"]), distance(positions["Payment"], positions["Audit"]); related >= unrelated {
+		t.Errorf("expected Payment closer to Invoice (%.2f) than to Audit (%.2f)", related, unrelated)
+	}
+}
+
+func TestForceLayoutOptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "test.extuml")
+	outputPath := filepath.Join(tmpDir, "output.gl")
+	if err := os.WriteFile(inputPath, []byte(forceLayoutInput), 0o644); err != nil {
+		t.Fatalf("failed to write test input: %v", err)
+	}
+
+	cfg := config.NewConfig()
+	for _, change := range []func(*usecase.LayoutOptions){
+		func(o *usecase.LayoutOptions) { o.Algorithm = "spiral" },
+		func(o *usecase.LayoutOptions) { o.Algorithm = "" },
+		func(o *usecase.LayoutOptions) { o.Iterations = 0 },
+		func(o *usecase.LayoutOptions) { o.Iterations = -1 },
+		func(o *usecase.LayoutOptions) { o.Energy = -0.5 },
+	} {
+		layout := usecase.DefaultLayoutOptions()
+		change(&layout)
+		if _, _, err := cfg.GenerateCtrl.Generate(inputPath, outputPath, "", "", layout); err == nil {
+			t.Errorf("expected %+v to be rejected", layout)
+		}
+	}
+
+	// An energy of 0 never stops early, so every iteration runs: the layout
+	// differs from one stopped at the default energy
+	layout := usecase.DefaultLayoutOptions()
+	layout.Energy = 0
+	exhaustive := layoutPositions(t, forceLayoutInput, layout)
+	if early := layoutPositions(t, forceLayoutInput, usecase.DefaultLayoutOptions()); reflect.DeepEqual(exhaustive, early) {
+		t.Errorf("an energy of 0 gave the same layout as the default energy")
+	}
+}
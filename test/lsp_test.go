@@ -0,0 +1,480 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/lsp"
+)
+
+const lspSource = `extuml classDiagram3D
+include "common.extuml"
+
+class Order {
+  -id: Long
+  +total(): Money
+}
+
+package billing {
+  class Invoice
+  Invoice --> Order : bills
+}
+note for Order "Totals"
+Order --> Money
+Order --> Missing
+`
+
+// lspSession frames JSON-RPC messages for a language server. Requests get
+// consecutive IDs starting at 1.
+type lspSession struct {
+	in  bytes.Buffer
+	ids int
+}
+
+func (s *lspSession) send(id *int, method string, params any) {
+	msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if id != nil {
+		msg["id"] = *id
+	}
+	body, _ := json.Marshal(msg)
+	fmt.Fprintf(&s.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *lspSession) request(method string, params any) int {
+	s.ids++
+	id := s.ids
+	s.send(&id, method, params)
+	return id
+}
+
+func (s *lspSession) notify(method string, params any) {
+	s.send(nil, method, params)
+}
+
+// lspReply is a response or notification written by the server
+type lspReply struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// readReplies splits the server output into messages
+func readReplies(t *testing.T, out []byte) []lspReply {
+	t.Helper()
+	r := bufio.NewReader(bytes.NewReader(out))
+	var replies []lspReply
+	for {
+		header, err := r.ReadString('\n')
+		if err == io.EOF {
+			return replies
+		}
+		var length int
+		if _, err := fmt.Sscanf(header, "Content-Length: %d", &length); err != nil {
+			t.Fatalf("invalid header %q: %v", header, err)
+		}
+		if blank, _ := r.ReadString('\n'); blank != "\r\n" {
+			t.Fatalf("expected a blank line after the header, got %q", blank)
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			t.Fatalf("short body: %v", err)
+		}
+		var reply lspReply
+		if err := json.Unmarshal(body, &reply); err != nil {
+			t.Fatalf("invalid message %s: %v", body, err)
+		}
+		replies = append(replies, reply)
+	}
+}
+
+func position(line, character int) map[string]any {
+	return map[string]any{"line": line, "character": character}
+}
+
+func TestLSPSession(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{"common.extuml": "extuml classDiagram3D\n\nclass Money\n"})
+	path := filepath.Join(tmpDir, "shop.extuml")
+	uri := "file://" + filepath.ToSlash(path)
+	doc := map[string]any{"uri": uri}
+	at := func(line, character int) map[string]any {
+		return map[string]any{"textDocument": doc, "position": position(line, character)}
+	}
+
+	var s lspSession
+	initialize := s.request("initialize", map[string]any{"capabilities": map[string]any{}})
+	s.notify("initialized", map[string]any{})
+	s.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "languageId": "extuml", "version": 1, "text": lspSource}})
+	keywords := s.request("textDocument/completion", at(15, 0))
+	types := s.request("textDocument/completion", at(5, 12))
+	hover := s.request("textDocument/hover", at(10, 15))
+	definition := s.request("textDocument/definition", at(13, 11))
+	references := s.request("textDocument/references", map[string]any{"textDocument": doc, "position": position(3, 7), "context": map[string]any{"includeDeclaration": true}})
+	prepare := s.request("textDocument/prepareRename", at(3, 7))
+	rename := s.request("textDocument/rename", map[string]any{"textDocument": doc, "position": position(10, 15), "newName": "Purchase"})
+	badRename := s.request("textDocument/rename", map[string]any{"textDocument": doc, "position": position(10, 15), "newName": "not valid"})
+	symbols := s.request("textDocument/documentSymbol", map[string]any{"textDocument": doc})
+	format := s.request("textDocument/formatting", map[string]any{"textDocument": doc, "options": map[string]any{"tabSize": 2}})
+	unknown := s.request("textDocument/codeLens", map[string]any{"textDocument": doc})
+	s.notify("textDocument/didChange", map[string]any{"textDocument": map[string]any{"uri": uri, "version": 2}, "contentChanges": []any{map[string]any{"text": "extuml classDiagram3D\nclass {\n"}}})
+	shutdown := s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	var out bytes.Buffer
+	if err := lsp.NewServer(os.ReadFile).Serve(&s.in, &out); err != nil {
+		t.Fatalf("serve failed: %v", err)
+	}
+
+	results := map[int]lspReply{}
+	var published []lsp.PublishDiagnosticsParams
+	for _, reply := range readReplies(t, out.Bytes()) {
+		if reply.ID != nil {
+			results[*reply.ID] = reply
+			continue
+		}
+		var params lsp.PublishDiagnosticsParams
+		if err := json.Unmarshal(reply.Params, &params); err != nil || reply.Method != "textDocument/publishDiagnostics" {
+			t.Fatalf("unexpected notification %s %s", reply.Method, reply.Params)
+		}
+		published = append(published, params)
+	}
+	decode := func(id int, v any) {
+		t.Helper()
+		reply, ok := results[id]
+		if !ok || reply.Error != nil {
+			t.Fatalf("request %d failed: %+v", id, reply)
+		}
+		if err := json.Unmarshal(reply.Result, v); err != nil {
+			t.Fatalf("request %d: invalid result %s: %v", id, reply.Result, err)
+		}
+	}
+
+	var init lsp.InitializeResult
+	decode(initialize, &init)
+	if caps := init.Capabilities; !caps.HoverProvider || !caps.DefinitionProvider || caps.RenameProvider == nil || !caps.DocumentFormattingProvider {
+		t.Errorf("expected the features to be advertised, got %+v", caps)
+	}
+
+	// Diagnostics follow every change; included classifiers are known
+	if len(published) != 2 {
+		t.Fatalf("expected diagnostics after open and change, got %+v", published)
+	}
+	if diags := published[0].Diagnostics; len(diags) != 1 || diags[0].Code != "W200" || diags[0].Range.Start != (lsp.Position{Line: 14, Character: 10}) {
+		t.Errorf("expected one warning for Missing, got %+v", diags)
+	}
+	if diags := published[1].Diagnostics; len(diags) == 0 || diags[0].Severity != lsp.SeverityError || *published[1].Version != 2 {
+		t.Errorf("expected a syntax error after the change, got %+v", published[1])
+	}
+
+	labels := func(id int) map[string]bool {
+		var items []lsp.CompletionItem
+		decode(id, &items)
+		set := map[string]bool{}
+		for _, item := range items {
+			set[item.Label] = true
+		}
+		return set
+	}
+	if got := labels(keywords); !got["class"] || !got["package"] || !got["Order"] || !got["Money"] || got["string"] {
+		t.Errorf("expected keywords and classifiers at the start of a line, got %v", got)
+	}
+	if got := labels(types); !got["string"] || !got["Money"] || got["class"] {
+		t.Errorf("expected types in a member line, got %v", got)
+	}
+
+	var h lsp.Hover
+	decode(hover, &h)
+	if !strings.Contains(h.Contents.Value, "class Order {\n  -id: Long\n  +total(): Money\n}") {
+		t.Errorf("expected the hover to show Order's members, got %q", h.Contents.Value)
+	}
+
+	var defs []lsp.Location
+	decode(definition, &defs)
+	if len(defs) != 1 || !strings.HasSuffix(defs[0].URI, "/common.extuml") || defs[0].Range.Start != (lsp.Position{Line: 2, Character: 6}) {
+		t.Errorf("expected Money to be defined in common.extuml, got %+v", defs)
+	}
+
+	var refs []lsp.Location
+	decode(references, &refs)
+	if len(refs) != 5 || refs[0].Range.Start != (lsp.Position{Line: 3, Character: 6}) {
+		t.Errorf("expected the declaration and four references of Order, got %+v", refs)
+	}
+
+	var r lsp.Range
+	decode(prepare, &r)
+	if r != (lsp.Range{Start: lsp.Position{Line: 3, Character: 6}, End: lsp.Position{Line: 3, Character: 11}}) {
+		t.Errorf("unexpected rename range %+v", r)
+	}
+
+	var edit lsp.WorkspaceEdit
+	decode(rename, &edit)
+	if edits := edit.Changes[uri]; len(edit.Changes) != 1 || len(edits) != 5 || edits[0].NewText != "Purchase" {
+		t.Errorf("expected five edits renaming Order, got %+v", edit.Changes)
+	}
+	if reply := results[badRename]; reply.Error == nil {
+		t.Error("expected renaming to an invalid name to fail")
+	}
+
+	var outline []lsp.DocumentSymbol
+	decode(symbols, &outline)
+	if len(outline) != 3 || outline[0].Name != "Order" || len(outline[0].Children) != 2 || outline[0].Children[1].Name != "total" ||
+		outline[1].Kind != lsp.SymbolPackage || outline[1].Children[0].Detail != "billing.Invoice" {
+		t.Errorf("unexpected outline %+v", outline)
+	}
+
+	// The note after the package block is set apart by a blank line
+	var edits []lsp.TextEdit
+	decode(format, &edits)
+	if len(edits) != 1 || !strings.Contains(edits[0].NewText, "}\n\nnote for Order") {
+		t.Errorf("expected the document to be formatted, got %+v", edits)
+	}
+
+	if reply := results[unknown]; reply.Error == nil || reply.Error.Code != -32601 {
+		t.Errorf("expected an unsupported method to fail, got %+v", reply)
+	}
+	if _, ok := results[shutdown]; !ok {
+		t.Error("expected shutdown to be answered")
+	}
+}
+
+func TestLSPPositionsCountUTF16(t *testing.T) {
+	src := "extuml classDiagram3D\nclass Größe\nclass 🙂Emoji\nGröße --> Missing\n"
+	uri := "untitled:sizes"
+
+	var s lspSession
+	s.request("initialize", map[string]any{})
+	s.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "version": 1, "text": src}})
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	var out bytes.Buffer
+	if err := lsp.NewServer(os.ReadFile).Serve(&s.in, &out); err != nil {
+		t.Fatalf("serve failed: %v", err)
+	}
+	var params lsp.PublishDiagnosticsParams
+	for _, reply := range readReplies(t, out.Bytes()) {
+		if reply.Method == "textDocument/publishDiagnostics" {
+			json.Unmarshal(reply.Params, &params)
+		}
+	}
+	// "Missing" starts after "Größe --> ", ten UTF-16 code units but twelve bytes
+	for _, d := range params.Diagnostics {
+		if d.Code == "W200" && d.Range.Start != (lsp.Position{Line: 3, Character: 10}) {
+			t.Errorf("expected the warning at character 10, got %+v", d.Range)
+		}
+	}
+	if len(params.Diagnostics) == 0 {
+		t.Error("expected diagnostics for the untitled document")
+	}
+}
+
+func TestLSPCompletionByDiagramType(t *testing.T) {
+	docs := map[string]string{
+		"untitled:sequence": "extuml sequenceDiagram3D\nparticipant Shop\n\n",
+		"untitled:er":       "extuml erDiagram3D\nentity Customer {\n  string name\n}\n\n",
+		"untitled:empty":    "\n",
+	}
+	at := func(uri string, line, character int) map[string]any {
+		return map[string]any{"textDocument": map[string]any{"uri": uri}, "position": position(line, character)}
+	}
+
+	var s lspSession
+	s.request("initialize", map[string]any{})
+	for uri, text := range docs {
+		s.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "version": 1, "text": text}})
+	}
+	sequence := s.request("textDocument/completion", at("untitled:sequence", 2, 0))
+	er := s.request("textDocument/completion", at("untitled:er", 4, 0))
+	attribute := s.request("textDocument/completion", at("untitled:er", 2, 9))
+	empty := s.request("textDocument/completion", at("untitled:empty", 0, 0))
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	var out bytes.Buffer
+	if err := lsp.NewServer(os.ReadFile).Serve(&s.in, &out); err != nil {
+		t.Fatalf("serve failed: %v", err)
+	}
+	labels := map[int]map[string]bool{}
+	for _, reply := range readReplies(t, out.Bytes()) {
+		if reply.ID == nil || *reply.ID < sequence || *reply.ID > empty {
+			continue
+		}
+		var items []lsp.CompletionItem
+		if err := json.Unmarshal(reply.Result, &items); err != nil {
+			t.Fatalf("request %d: invalid result %s: %v", *reply.ID, reply.Result, err)
+		}
+		labels[*reply.ID] = map[string]bool{}
+		for _, item := range items {
+			labels[*reply.ID][item.Label] = true
+		}
+	}
+
+	if got := labels[sequence]; !got["participant"] || !got["alt"] || !got["note"] || got["class"] || got["entity"] || got["extuml sequenceDiagram3D"] {
+		t.Errorf("expected sequence keywords only, got %v", got)
+	}
+	if got := labels[er]; !got["entity"] || !got["classDef"] || got["class"] || got["package"] || got["participant"] {
+		t.Errorf("expected ER keywords only, got %v", got)
+	}
+	if got := labels[attribute]; len(got) != 0 {
+		t.Errorf("expected no class member types in an entity, got %v", got)
+	}
+	// Without a header every diagram type is offered
+	if got := labels[empty]; !got["extuml classDiagram3D"] || !got["extuml sequenceDiagram3D"] || !got["extuml deploymentDiagram3D"] {
+		t.Errorf("expected a header per diagram type, got %v", got)
+	}
+}
+
+func TestLSPSymbolsByDiagramType(t *testing.T) {
+	tests := []struct {
+		uri, text string
+		at        lsp.Position // a reference
+		decl      lsp.Position // the declaration it resolves to
+		hover     string
+		refs      int // including the declaration
+		outline   []string
+	}{
+		{
+			uri:     "untitled:class",
+			text:    "extuml classDiagram3D\nclass User {\n  +created: Date = now()\n  +login(): bool\n}\nAdmin --|> User\n",
+			at:      lsp.Position{Line: 5, Character: 11},
+			decl:    lsp.Position{Line: 1, Character: 6},
+			hover:   "class User",
+			refs:    2,
+			outline: []string{"User", "created", "login"},
+		},
+		{
+			uri:     "untitled:sequence",
+			text:    "extuml sequenceDiagram3D\nparticipant Shop\nShop ->> Bank : pay\nalt ok {\n  Bank -->> Shop\n}\n",
+			at:      lsp.Position{Line: 4, Character: 12},
+			decl:    lsp.Position{Line: 1, Character: 12},
+			hover:   "participant Shop",
+			refs:    3,
+			outline: []string{"Shop", "Bank"},
+		},
+		{
+			uri:     "untitled:state",
+			text:    "extuml stateDiagram3D\nstate Active {\n  Idle --> Busy\n}\n[*] --> Active\n",
+			at:      lsp.Position{Line: 4, Character: 8},
+			decl:    lsp.Position{Line: 1, Character: 6},
+			hover:   "state Active",
+			refs:    2,
+			outline: []string{"Active", "Idle", "Busy"},
+		},
+		{
+			uri:     "untitled:er",
+			text:    "extuml erDiagram3D\nentity Customer {\n  string name\n}\nCustomer ||--o{ Order : places\n",
+			at:      lsp.Position{Line: 4, Character: 0},
+			decl:    lsp.Position{Line: 1, Character: 7},
+			hover:   "entity Customer",
+			refs:    2,
+			outline: []string{"Customer", "name", "Order"},
+		},
+		{
+			uri:     "untitled:component",
+			text:    "extuml componentDiagram3D\ncomponent Api {\n  port http\n}\nWeb --> Api.http\n",
+			at:      lsp.Position{Line: 4, Character: 12},
+			decl:    lsp.Position{Line: 2, Character: 7},
+			hover:   "port http",
+			refs:    2,
+			outline: []string{"Api", "http", "Web"},
+		},
+		{
+			uri:     "untitled:activity",
+			text:    "extuml activityDiagram3D\nstart\naction Pay\nstart --> Pay\nPay --> Ship\n",
+			at:      lsp.Position{Line: 4, Character: 0},
+			decl:    lsp.Position{Line: 2, Character: 7},
+			hover:   "action Pay",
+			refs:    3,
+			outline: []string{"start", "Pay", "Ship"},
+		},
+	}
+
+	var s lspSession
+	s.request("initialize", map[string]any{})
+	requests := map[string][5]int{}
+	for _, tt := range tests {
+		s.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": tt.uri, "version": 1, "text": tt.text}})
+		doc := map[string]any{"uri": tt.uri}
+		at := map[string]any{"textDocument": doc, "position": tt.at}
+		requests[tt.uri] = [5]int{
+			s.request("textDocument/definition", at),
+			s.request("textDocument/references", map[string]any{"textDocument": doc, "position": tt.at, "context": map[string]any{"includeDeclaration": true}}),
+			s.request("textDocument/rename", map[string]any{"textDocument": doc, "position": tt.at, "newName": "Renamed"}),
+			s.request("textDocument/documentSymbol", map[string]any{"textDocument": doc}),
+			s.request("textDocument/hover", at),
+		}
+	}
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	var out bytes.Buffer
+	if err := lsp.NewServer(os.ReadFile).Serve(&s.in, &out); err != nil {
+		t.Fatalf("serve failed: %v", err)
+	}
+	results := map[int]lspReply{}
+	for _, reply := range readReplies(t, out.Bytes()) {
+		if reply.ID != nil {
+			results[*reply.ID] = reply
+		}
+	}
+	decode := func(id int, v any) {
+		t.Helper()
+		reply, ok := results[id]
+		if !ok || reply.Error != nil {
+			t.Fatalf("request %d failed: %+v", id, reply)
+		}
+		if err := json.Unmarshal(reply.Result, v); err != nil {
+			t.Fatalf("request %d: invalid result %s: %v", id, reply.Result, err)
+		}
+	}
+	// names flattens the outline in order
+	var names func([]lsp.DocumentSymbol) []string
+	names = func(symbols []lsp.DocumentSymbol) []string {
+		var list []string
+		for _, s := range symbols {
+			list = append(append(list, s.Name), names(s.Children)...)
+		}
+		return list
+	}
+
+	for _, tt := range tests {
+		ids := requests[tt.uri]
+		var defs []lsp.Location
+		decode(ids[0], &defs)
+		if len(defs) != 1 || defs[0].Range.Start != tt.decl {
+			t.Errorf("%s: expected the definition at %+v, got %+v", tt.uri, tt.decl, defs)
+		}
+		var refs []lsp.Location
+		decode(ids[1], &refs)
+		if len(refs) != tt.refs {
+			t.Errorf("%s: expected %d references, got %+v", tt.uri, tt.refs, refs)
+		}
+		var edit lsp.WorkspaceEdit
+		decode(ids[2], &edit)
+		if edits := edit.Changes[tt.uri]; len(edits) != tt.refs {
+			t.Errorf("%s: expected %d edits, got %+v", tt.uri, tt.refs, edit.Changes)
+		}
+		var outline []lsp.DocumentSymbol
+		decode(ids[3], &outline)
+		if got := names(outline); strings.Join(got, " ") != strings.Join(tt.outline, " ") {
+			t.Errorf("%s: expected the outline %v, got %v", tt.uri, tt.outline, got)
+		}
+		var h lsp.Hover
+		decode(ids[4], &h)
+		if !strings.Contains(h.Contents.Value, tt.hover) {
+			t.Errorf("%s: expected the hover to show %q, got %q", tt.uri, tt.hover, h.Contents.Value)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/config"
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/repository"
+	"github.com/extuml/extuml/pkg/usecase"
+)
+
+func TestMermaidImport(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"zoo.mmd": `---
+title: Zoo
+---
+classDiagram-v2
+    direction RL
+    Animal <|-- Duck
+    Animal : +int age
+    Animal: +isMammal() bool
+    class Duck["Mallard"]{
+        +String beakColor
+        +swim()
+    }
+    class Duck:::water
+    classDef water fill:#9cf
+    Customer "1" --> "*" Ticket : buys
+    class Box~T~
+    <<interface>> Shape
+    Shape : +area() double
+    class Color{
+        <<enumeration>>
+        RED
+        BLUE
+    }
+    namespace Zoo {
+        class Keeper
+    }
+    Keeper o-- Duck
+    note for Duck "can swim"
+    click Duck href "https://example.com/duck" "Duck docs"
+    link Ticket "https://example.com/ticket"
+    click Animal call callback("Animal")
+`,
+	})
+
+	docs, diags, err := repository.NewMermaidRepository().Load(filepath.Join(tmpDir, "zoo.mmd"))
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("expected 1 document, got %d", len(docs))
+	}
+	doc := docs[0]
+	if doc.Meta == nil || doc.Meta.Title != "Zoo" {
+		t.Errorf("expected title from front matter, got %+v", doc.Meta)
+	}
+
+	classes := map[string]int{}
+	for i, c := range doc.Elements.Classes {
+		classes[c.ID] = i
+	}
+	// Classes used only in relationships are declared implicitly
+	for _, id := range []string{"Animal", "Duck", "Customer", "Ticket", "Box", "Zoo.Keeper"} {
+		if _, ok := classes[id]; !ok {
+			t.Errorf("expected class %s, got %v", id, classes)
+		}
+	}
+
+	animal := doc.Elements.Classes[classes["Animal"]]
+	if len(animal.Attributes) != 1 || animal.Attributes[0].Name != "age" || len(animal.Operations) != 1 {
+		t.Errorf("expected members from `Animal : ...` lines, got %+v", animal)
+	}
+
+	// Repeated declarations are merged
+	duck := doc.Elements.Classes[classes["Duck"]]
+	if duck.Name != "Mallard" || duck.URL != "https://example.com/duck" ||
+		!reflect.DeepEqual(duck.StyleClasses, []string{"water"}) || len(duck.Operations) != 1 {
+		t.Errorf("unexpected Duck: %+v", duck)
+	}
+	if ticket := doc.Elements.Classes[classes["Ticket"]]; ticket.URL != "https://example.com/ticket" {
+		t.Errorf("expected link URL on Ticket, got %q", ticket.URL)
+	}
+	if box := doc.Elements.Classes[classes["Box"]]; !reflect.DeepEqual(box.TypeParameters, []string{"T"}) {
+		t.Errorf("expected type parameter T, got %v", box.TypeParameters)
+	}
+
+	// <<interface>> and <<enumeration>> select the classifier kind
+	if len(doc.Elements.Interfaces) != 1 || doc.Elements.Interfaces[0].ID != "Shape" ||
+		len(doc.Elements.Interfaces[0].Stereotypes) != 0 || len(doc.Elements.Interfaces[0].Operations) != 1 {
+		t.Errorf("expected interface Shape, got %+v", doc.Elements.Interfaces)
+	}
+	if len(doc.Elements.Enums) != 1 || !reflect.DeepEqual(doc.Elements.Enums[0].Literals, []string{"RED", "BLUE"}) {
+		t.Errorf("expected enum Color, got %+v", doc.Elements.Enums)
+	}
+
+	rels := doc.Elements.Relationships
+	if len(rels) != 3 {
+		t.Fatalf("expected 3 relationships, got %+v", rels)
+	}
+	if rels[0].Type != "inheritance" || rels[0].From != "Duck" || rels[0].To != "Animal" {
+		t.Errorf("unexpected inheritance: %+v", rels[0])
+	}
+	if rels[1].FromMultiplicity != "1" || rels[1].ToMultiplicity != "*" || rels[1].Label != "buys" {
+		t.Errorf("unexpected association: %+v", rels[1])
+	}
+	if rels[2].Type != "aggregation" || rels[2].From != "Duck" || rels[2].To != "Zoo.Keeper" {
+		t.Errorf("unexpected aggregation: %+v", rels[2])
+	}
+	if len(doc.Elements.Notes) != 1 || doc.Elements.Notes[0].Anchor != "Duck" {
+		t.Errorf("expected note for Duck, got %+v", doc.Elements.Notes)
+	}
+}
+
+func TestGenerateMermaidMarkdownBlocks(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"design.md": "# Design\n\n" +
+			"```mermaid\nclassDiagram\n    Animal <|-- Duck\n```\n\n" +
+			"```mermaid\nflowchart LR\n    A --> B\n```\n\n" +
+			"~~~mermaid\nclassDiagram\n    Order --> Customer\n    note for Missing \"x\"\n~~~\n",
+		"empty.md": "# No diagrams\n",
+	})
+	inputPath := filepath.Join(tmpDir, "design.md")
+	outputPath := filepath.Join(tmpDir, "design.gl")
+	htmlPath := filepath.Join(tmpDir, "design.html")
+
+	cfg := config.NewConfig()
+	written, diags, err := cfg.GenerateCtrl.Generate(inputPath, outputPath, htmlPath, "", usecase.DefaultLayoutOptions())
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	// One output per classDiagram block; the flowchart is skipped
+	want := []string{
+		filepath.Join(tmpDir, "design-1.gl"), filepath.Join(tmpDir, "design-1.html"),
+		filepath.Join(tmpDir, "design-2.gl"), filepath.Join(tmpDir, "design-2.html"),
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("expected outputs %v, got %v", want, written)
+	}
+	for _, path := range want {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to be written: %v", path, err)
+		}
+	}
+
+	// Positions refer to the Markdown file
+	if len(diags) != 1 || diags[0].Code != diagnostic.CodeUnknownNoteAnchor ||
+		diags[0].Span.Start.Line != 16 || diags[0].Span.Start.Column != 14 {
+		t.Errorf("expected W201 at 16:14, got %v", diags)
+	}
+
+	// The DSL parser rejects Markdown unless the format is detected
+	if _, _, err := cfg.GenerateCtrl.Generate(inputPath, outputPath, "", "extuml", usecase.DefaultLayoutOptions()); err == nil {
+		t.Error("expected --from extuml to fail on Markdown")
+	}
+	if _, _, err := cfg.GenerateCtrl.Generate(filepath.Join(tmpDir, "empty.md"), outputPath, "", "", usecase.DefaultLayoutOptions()); err == nil {
+		t.Error("expected an error for Markdown without class diagrams")
+	}
+}
@@ -0,0 +1,164 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+func TestParserSpans(t *testing.T) {
+	src := `extuml classDiagram3D
+
+%% people
+class Person {
+  +id: int
+  @url: https://example.com
+}
+
+Person --> Address : livesAt
+`
+	file, errs := parser.Parse("spans.extuml", []byte(src))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	if file.Header == nil || file.Header.DiagramType != "classDiagram3D" {
+		t.Fatalf("expected classDiagram3D header, got %+v", file.Header)
+	}
+	if len(file.Comments) != 1 || file.Comments[0].Start.Line != 3 {
+		t.Errorf("expected comment on line 3, got %+v", file.Comments)
+	}
+	if len(file.Decls) != 2 {
+		t.Fatalf("expected 2 declarations, got %d", len(file.Decls))
+	}
+
+	class, ok := file.Decls[0].(*parser.ClassifierDecl)
+	if !ok {
+		t.Fatalf("expected classifier declaration, got %T", file.Decls[0])
+	}
+	if class.Start.Line != 4 || class.Start.Column != 1 || class.End.Line != 7 {
+		t.Errorf("unexpected class span: %v-%v", class.Start, class.End)
+	}
+	if class.Name.Start.Column != 7 || class.Name.Start.File != "spans.extuml" {
+		t.Errorf("unexpected class name position: %v", class.Name.Start)
+	}
+	if len(class.Members) != 1 || class.Members[0].Text != "+id: int" || class.Members[0].Start.Column != 3 {
+		t.Errorf("unexpected members: %+v", class.Members)
+	}
+	if len(class.Annotations) != 1 || class.Annotations[0].Value != "https://example.com" {
+		t.Errorf("unexpected annotations: %+v", class.Annotations)
+	}
+
+	rel, ok := file.Decls[1].(*parser.RelationshipDecl)
+	if !ok {
+		t.Fatalf("expected relationship declaration, got %T", file.Decls[1])
+	}
+	if rel.Operator != "-->" || rel.Label != "livesAt" || rel.Right.Start.Column != 12 {
+		t.Errorf("unexpected relationship: %+v", rel)
+	}
+}
+
+func TestParserReportsAllErrors(t *testing.T) {
+	src := `class Foo {
+  +x: int
+
+}
+}
+A -->
+note for X "unterminated
+`
+	_, errs := parser.Parse("broken.extuml", []byte(src))
+
+	expected := []struct {
+		code string
+		line int
+	}{
+		{diagnostic.CodeMissingHeader, 1},
+		{diagnostic.CodeUnexpectedCloseBrace, 5},
+		{diagnostic.CodeExpected, 6},
+		{diagnostic.CodeUnterminatedString, 7},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d:\n%v", len(expected), len(errs), errs)
+	}
+	for i, want := range expected {
+		if errs[i].Code != want.code || errs[i].Span.Start.Line != want.line {
+			t.Errorf("error %d: expected %s on line %d, got %v", i, want.code, want.line, errs[i])
+		}
+	}
+}
+
+func TestParserUnterminatedBlock(t *testing.T) {
+	src := `extuml classDiagram3D
+
+class Foo {
+  +x: int
+
+class Bar {
+}
+`
+	file, errs := parser.Parse("block.extuml", []byte(src))
+	if len(errs) != 1 || errs[0].Code != diagnostic.CodeUnterminatedBlock || errs[0].Span.Start.Line != 3 {
+		t.Fatalf("expected one E102 on line 3, got %v", errs)
+	}
+
+	// Parsing recovers and still sees the following class
+	if len(file.Decls) != 2 {
+		t.Errorf("expected 2 declarations after recovery, got %d", len(file.Decls))
+	}
+}
+
+func TestParserFrontMatterAndDirectives(t *testing.T) {
+	src := `---
+title: "Orders"
+config:
+  theme: dark
+  layout:
+    spacing: 2
+  bogus
+---
+%%{ init: { 'theme': 'forest' } }%%
+%%{ wrap }%%
+extuml classDiagram3D
+author: Sam
+colour: red
+
+class Order {
+}
+`
+	file, diags := parser.Parse("meta.extuml", []byte(src))
+	doc, lowerDiags := parser.Lower(file)
+	diags = append(diags, lowerDiags...)
+	diags.Sort()
+
+	expected := []struct {
+		code string
+		line int
+	}{
+		{diagnostic.CodeInvalidDirective, 7},
+		{diagnostic.CodeInvalidDirective, 10},
+		{diagnostic.CodeMemberOutsideBlock, 13},
+	}
+	if len(diags) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %d:\n%v", len(expected), len(diags), diags)
+	}
+	for i, want := range expected {
+		if diags[i].Code != want.code || diags[i].Span.Start.Line != want.line {
+			t.Errorf("diagnostic %d: expected %s on line %d, got %v", i, want.code, want.line, diags[i])
+		}
+	}
+
+	if file.Header == nil || file.Header.Start.Line != 11 {
+		t.Fatalf("expected the header on line 11 after the front matter")
+	}
+	if doc.Meta == nil || doc.Meta.Title != "Orders" || doc.Meta.Author != "Sam" {
+		t.Errorf("unexpected meta: %+v", doc.Meta)
+	}
+
+	// The init directive overrides the front matter
+	layout, _ := doc.Config["layout"].(map[string]any)
+	if doc.Config["theme"] != "forest" || layout["spacing"] != 2.0 {
+		t.Errorf("unexpected config: %v", doc.Config)
+	}
+}
@@ -0,0 +1,169 @@
+package test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/config"
+	"github.com/extuml/extuml/pkg/diagnostic"
+	"github.com/extuml/extuml/pkg/repository"
+	"github.com/extuml/extuml/pkg/usecase"
+)
+
+func TestPlantUMLImport(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"shop.puml": `' Legacy shop model
+@startuml
+title Shop
+skinparam class {
+  BackgroundColor PaleGreen
+}
+hide empty members
+/' the order
+   aggregate '/
+package orders {
+  abstract class Order<T> <<Entity>> #pink {
+    - id : Long
+    {static} + count : int
+    -- totals --
+    + {abstract} total() : Money
+    + String describe(int depth)
+  }
+  Order "1" *-- "many" LineItem : contains >
+}
+interface Payable
+enum Status {
+  NEW
+  PAID
+}
+class Invoice extends Document implements Payable
+class "Customer Account" as Customer
+Customer -up-> Order : places
+Invoice ..> Status
+Invoice -[#red,dashed]-> Customer
+VipCustomer --|> Customer
+note "Billed monthly" as N1
+N1 .. Invoice
+note left of Customer : Important
+note right of Payable
+  Line one
+  Line two
+end note
+@enduml
+`,
+	})
+
+	docs, diags, err := repository.NewPlantUMLRepository().Load(filepath.Join(tmpDir, "shop.puml"))
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("expected 1 document, got %d", len(docs))
+	}
+	doc := docs[0]
+	if doc.Meta == nil || doc.Meta.Title != "Shop" {
+		t.Errorf("expected title Shop, got %+v", doc.Meta)
+	}
+
+	classes := map[string]int{}
+	for i, c := range doc.Elements.Classes {
+		classes[c.ID] = i
+	}
+	for _, id := range []string{"orders.Order", "orders.LineItem", "Invoice", "Document", "Customer", "VipCustomer"} {
+		if _, ok := classes[id]; !ok {
+			t.Errorf("expected class %s, got %v", id, classes)
+		}
+	}
+
+	order := doc.Elements.Classes[classes["orders.Order"]]
+	if !order.Abstract || !reflect.DeepEqual(order.Stereotypes, []string{"Entity"}) || !reflect.DeepEqual(order.TypeParameters, []string{"T"}) {
+		t.Errorf("unexpected Order: %+v", order)
+	}
+	// Separators are dropped and modifiers apply after the visibility
+	if len(order.Attributes) != 2 || !order.Attributes[1].Static || order.Attributes[1].Visibility != "public" {
+		t.Errorf("unexpected attributes: %+v", order.Attributes)
+	}
+	if len(order.Operations) != 2 || !order.Operations[0].Abstract ||
+		order.Operations[1].Name != "describe" || order.Operations[1].ReturnType != "String" {
+		t.Errorf("unexpected operations: %+v", order.Operations)
+	}
+	if customer := doc.Elements.Classes[classes["Customer"]]; customer.Name != "Customer Account" {
+		t.Errorf("expected display name, got %q", customer.Name)
+	}
+	if len(doc.Elements.Interfaces) != 1 || len(doc.Elements.Enums) != 1 ||
+		!reflect.DeepEqual(doc.Elements.Enums[0].Literals, []string{"NEW", "PAID"}) {
+		t.Errorf("expected interface Payable and enum Status, got %+v %+v", doc.Elements.Interfaces, doc.Elements.Enums)
+	}
+	if len(doc.Elements.Packages) != 1 || doc.Elements.Packages[0].ID != "orders" {
+		t.Errorf("expected package orders, got %+v", doc.Elements.Packages)
+	}
+
+	type rel struct{ kind, from, to, label string }
+	var got []rel
+	for _, r := range doc.Elements.Relationships {
+		got = append(got, rel{r.Type, r.From, r.To, r.Label})
+	}
+	want := []rel{
+		{"composition", "orders.LineItem", "orders.Order", "contains"},
+		{"inheritance", "Invoice", "Document", ""},
+		{"realization", "Invoice", "Payable", ""},
+		{"association", "Customer", "orders.Order", "places"},
+		{"dependency", "Invoice", "Status", ""},
+		{"dependency", "Invoice", "Customer", ""},
+		{"inheritance", "VipCustomer", "Customer", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected relationships:\n got %v\nwant %v", got, want)
+	}
+	if r := doc.Elements.Relationships[0]; r.FromMultiplicity != "many" || r.ToMultiplicity != "1" {
+		t.Errorf("unexpected multiplicities: %+v", r)
+	}
+
+	// A named note linked to a class is anchored to it
+	type note struct{ anchor, text string }
+	var notes []note
+	for _, n := range doc.Elements.Notes {
+		notes = append(notes, note{n.Anchor, n.Text})
+	}
+	wantNotes := []note{{"Invoice", "Billed monthly"}, {"Customer", "Important"}, {"Payable", "Line one\nLine two"}}
+	if !reflect.DeepEqual(notes, wantNotes) {
+		t.Errorf("unexpected notes: %v", notes)
+	}
+}
+
+func TestGeneratePlantUMLBlocks(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"model.puml": "@startuml\nA --> B\n@enduml\n\n" +
+			"@startuml second\nclass C\nnote on link : ignored\n!include common.iuml\n@enduml\n",
+		"plain.puml": "class A\n",
+	})
+	inputPath := filepath.Join(tmpDir, "model.puml")
+	outputPath := filepath.Join(tmpDir, "model.gl")
+
+	cfg := config.NewConfig()
+	written, diags, err := cfg.GenerateCtrl.Generate(inputPath, outputPath, "", "", usecase.DefaultLayoutOptions())
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	want := []string{filepath.Join(tmpDir, "model-1.gl"), filepath.Join(tmpDir, "model-2.gl")}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("expected outputs %v, got %v", want, written)
+	}
+
+	// Unsupported statements are warnings with positions in the file
+	if len(diags) != 2 || diags[0].Code != diagnostic.CodeUnsupportedStatement || diags[0].Span.Start.Line != 7 ||
+		diags[1].Code != diagnostic.CodeUnsupportedStatement || diags[1].Span.Start.Line != 8 {
+		t.Errorf("expected W210 on lines 7 and 8, got %v", diags)
+	}
+
+	_, diags, err = cfg.GenerateCtrl.Generate(filepath.Join(tmpDir, "plain.puml"), outputPath, "", "", usecase.DefaultLayoutOptions())
+	if err == nil || len(diags) == 0 || diags[0].Code != diagnostic.CodeMissingHeader {
+		t.Errorf("expected E100 without @startuml, got %v, %v", err, diags)
+	}
+}
@@ -0,0 +1,362 @@
+package test
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/config"
+	"github.com/extuml/extuml/pkg/formatter"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/parser"
+	"github.com/extuml/extuml/pkg/repository"
+)
+
+const sequenceSource = `extuml sequenceDiagram3D
+title: Checkout
+
+classDef service stroke:#f80
+
+actor User as "Shopper"
+
+zone edge as "Edge" {
+  participant Web
+}
+
+zone backend {
+  participant Orders:::service
+  participant Payments as "Payment Service"
+}
+
+User ->> +Web : checkout
+Web ->> +Orders : create order
+Orders -) Payments : charge
+
+alt "paid {in full}" {
+  Payments -->> Orders : approved
+} else declined {
+  Payments --) Orders : rejected
+  note right of Orders "retry later"
+}
+
+loop every item {
+  Orders ->> Orders : reserve
+  activate Orders
+  deactivate Orders
+}
+
+Orders -->> -Web : order id
+Web -->> -User : done
+note over Web, Orders "order flow"
+
+opt {
+  Web -> Audit : log
+}
+`
+
+func TestSequenceLowering(t *testing.T) {
+	doc := lowerSource(t, "checkout.extuml", []byte(sequenceSource))
+	if doc.Diagram != extuml.DiagramSequence || doc.Elements != nil || doc.Sequence == nil {
+		t.Fatalf("expected a sequence document, got %+v", doc)
+	}
+	seq := doc.Sequence
+
+	// Audit is declared by its first use, after the declared participants
+	var ids []string
+	for _, p := range seq.Participants {
+		ids = append(ids, p.ID)
+	}
+	if want := []string{"User", "Web", "Orders", "Payments", "Audit"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected participants %v, got %v", want, ids)
+	}
+	user, orders, payments := seq.Participants[0], seq.Participants[2], seq.Participants[3]
+	if user.Type != extuml.ParticipantActor || user.Name != "Shopper" || payments.Name != "Payment Service" {
+		t.Errorf("unexpected participants %+v", seq.Participants)
+	}
+	if orders.Zone != "backend" || !reflect.DeepEqual(orders.StyleClasses, []string{"service"}) {
+		t.Errorf("expected Orders in backend with its classDef, got %+v", orders)
+	}
+	if len(seq.Zones) != 2 || seq.Zones[0].Name != "Edge" || !reflect.DeepEqual(seq.Zones[1].Participants, []string{"Orders", "Payments"}) {
+		t.Errorf("unexpected zones %+v", seq.Zones)
+	}
+
+	kinds := map[string]string{}
+	steps := map[string]int{}
+	for _, m := range seq.Messages {
+		kinds[m.Label] = m.Kind
+		steps[m.Label] = m.Step
+	}
+	if kinds["checkout"] != extuml.MessageSynchronous || kinds["charge"] != extuml.MessageAsynchronous ||
+		kinds["approved"] != extuml.MessageReturn || kinds["rejected"] != extuml.MessageAsynchronous {
+		t.Errorf("unexpected message kinds %v", kinds)
+	}
+	// Fragment headers, else and ends take a step each
+	if steps["charge"] != 2 || steps["approved"] != 4 || steps["rejected"] != 6 || steps["reserve"] != 10 || steps["log"] != 16 {
+		t.Errorf("unexpected steps %v", steps)
+	}
+	if seq.Steps != 18 {
+		t.Errorf("expected 18 steps, got %d", seq.Steps)
+	}
+
+	want := []extuml.Activation{
+		{Participant: "Web", Start: 0, End: 13},
+		{Participant: "Orders", Start: 1, End: 12},
+		{Participant: "Orders", Start: 10, End: 10, Level: 1},
+	}
+	if !reflect.DeepEqual(seq.Activations, want) {
+		t.Errorf("expected activations %+v, got %+v", want, seq.Activations)
+	}
+
+	if len(seq.Fragments) != 3 {
+		t.Fatalf("expected three fragments, got %+v", seq.Fragments)
+	}
+	alt, loop, opt := seq.Fragments[0], seq.Fragments[1], seq.Fragments[2]
+	wantOperands := []extuml.Operand{{Guard: "paid {in full}", Start: 3, End: 4}, {Guard: "declined", Start: 5, End: 7}}
+	if alt.Kind != extuml.FragmentAlt || alt.Start != 3 || alt.End != 8 || !reflect.DeepEqual(alt.Operands, wantOperands) ||
+		!reflect.DeepEqual(alt.Participants, []string{"Orders", "Payments"}) {
+		t.Errorf("unexpected alt fragment %+v", alt)
+	}
+	if loop.Kind != extuml.FragmentLoop || loop.Operands[0].Guard != "every item" || !reflect.DeepEqual(loop.Participants, []string{"Orders"}) {
+		t.Errorf("unexpected loop fragment %+v", loop)
+	}
+	if opt.Operands[0].Guard != "" || !reflect.DeepEqual(opt.Participants, []string{"Web", "Audit"}) {
+		t.Errorf("unexpected opt fragment %+v", opt)
+	}
+
+	if len(seq.Notes) != 2 || seq.Notes[0].Position != extuml.NoteRight || seq.Notes[0].Step != 7 ||
+		!reflect.DeepEqual(seq.Notes[1].Participants, []string{"Web", "Orders"}) {
+		t.Errorf("unexpected notes %+v", seq.Notes)
+	}
+}
+
+func TestSequenceNestedFragments(t *testing.T) {
+	doc := lowerSource(t, "nested.extuml", []byte(`extuml sequenceDiagram3D
+A ->> B : start
+loop retries {
+  alt ok {
+    B -->> A : done
+  }
+  else {
+    B -->> A : retry
+  }
+}
+`))
+	fragments := doc.Sequence.Fragments
+	if len(fragments) != 2 || fragments[0].Kind != "loop" || fragments[1].Level != 1 || len(fragments[1].Operands) != 2 {
+		t.Fatalf("expected an alt with an else nested in a loop, got %+v", fragments)
+	}
+	if fragments[1].Start <= fragments[0].Start || fragments[1].End >= fragments[0].End {
+		t.Errorf("expected the alt inside the loop, got %+v", fragments)
+	}
+}
+
+func TestSequenceDiagnostics(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, code string
+	}{
+		{"unknown diagram type", "extuml ganttDiagram3D\n", "E110"},
+		{"relationship in a sequence", "extuml sequenceDiagram3D\nA --|> B\n", "E103"},
+		{"message arrow in a class diagram", "extuml classDiagram3D\nclass A\nclass B\nA ->> B\n", "E103"},
+		{"else after loop", "extuml sequenceDiagram3D\nloop {\n  A ->> B\n} else {\n}\n", "E103"},
+		{"stray else", "extuml sequenceDiagram3D\nelse {\n  A ->> B\n}\n", "E103"},
+		{"participant in a fragment", "extuml sequenceDiagram3D\nopt {\n  participant A\n}\n", "E103"},
+		{"message in a zone", "extuml sequenceDiagram3D\nzone z {\n  A ->> B\n}\n", "E103"},
+		{"unclosed fragment", "extuml sequenceDiagram3D\nalt x {\n  A ->> B\n", "E102"},
+		{"note beside two participants", "extuml sequenceDiagram3D\nnote left of A, B \"n\"\n", "E103"},
+		{"duplicate participant", "extuml sequenceDiagram3D\nparticipant A\nactor A\n", "E200"},
+		{"deactivate inactive", "extuml sequenceDiagram3D\nA ->> B\ndeactivate B\n", "W211"},
+		{"never deactivated", "extuml sequenceDiagram3D\nA ->> +B\n", "W211"},
+		{"unknown style class", "extuml sequenceDiagram3D\nparticipant A:::missing\n", "W208"},
+	} {
+		diags := parseAndLower("seq.extuml", tc.src)
+		found := false
+		for _, d := range diags {
+			found = found || d.Code == tc.code
+		}
+		if !found {
+			t.Errorf("%s: expected %s, got %v", tc.name, tc.code, diags)
+		}
+	}
+
+	// Participants need no declaration and styles apply to them
+	if diags := parseAndLower("seq.extuml", "extuml sequenceDiagram3D\nA ->> B\nstyle B fill:#0f0\ncssClass \"A\" x\nclassDef x stroke:red\n"); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
+
+func TestSequenceIncludes(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFiles(t, tmpDir, map[string]string{
+		"main.extuml":   "extuml sequenceDiagram3D\ninclude \"actors.extuml\"\nUser ->> Shop : buy\n",
+		"actors.extuml": "extuml sequenceDiagram3D\nactor User\nparticipant Shop\n",
+		"mixed.extuml":  "extuml sequenceDiagram3D\ninclude \"model.extuml\"\n",
+		"model.extuml":  "extuml classDiagram3D\nclass Order\n",
+		"class.extuml":  "extuml classDiagram3D\ninclude \"actors.extuml\"\nclass Order\n",
+	})
+
+	extumlRepo := repository.NewExtumlRepository()
+	doc, diags, err := extumlRepo.Load(filepath.Join(tmpDir, "main.extuml"))
+	if err != nil || len(diags) != 0 {
+		t.Fatalf("load failed: %v %v", err, diags)
+	}
+	if seq := doc.Sequence; len(seq.Participants) != 2 || seq.Participants[0].Type != extuml.ParticipantActor || len(seq.Messages) != 1 {
+		t.Errorf("expected the included participants, got %+v", seq)
+	}
+
+	// Diagrams of different types cannot include each other
+	for _, name := range []string{"mixed.extuml", "class.extuml"} {
+		_, diags, err := extumlRepo.Load(filepath.Join(tmpDir, name))
+		if err == nil || len(diags) != 1 || diags[0].Code != "E206" {
+			t.Errorf("%s: expected the include to be rejected, got %v", name, diags)
+		}
+	}
+}
+
+func TestGenerateSequence(t *testing.T) {
+	tmpDir := t.TempDir()
+	asset, byType, _ := generateScene(t, tmpDir, "checkout.extuml", sequenceSource)
+
+	extras := asset.Asset.Extras.(map[string]any)
+	if ext := extras["extuml"].(map[string]any); ext["diagram"] != "sequence" || ext["title"] != "Checkout" || extras["camera"] == nil {
+		t.Errorf("unexpected asset extras %v", extras)
+	}
+
+	positions := map[string][]float64{}
+	for _, node := range asset.Nodes {
+		extras, _ := node.Extras.(map[string]any)
+		e, _ := extras["extuml"].(map[string]any)
+		if id, ok := e["id"].(string); ok {
+			positions[id] = node.Translation
+		}
+	}
+	for kind, count := range map[string]int{"participant": 4, "actor": 1, "lifeline": 5, "activation": 3, "message": 9, "fragment": 3, "note": 2, "zone": 2} {
+		if len(byType[kind]) != count {
+			t.Errorf("expected %d %s nodes, got %d", count, kind, len(byType[kind]))
+		}
+	}
+
+	// Participants stand in a row, each zone at a depth of its own, and time
+	// runs down
+	if user, web, orders := positions["User"], positions["Web"], positions["Orders"]; !(user[0] < web[0] && web[0] < orders[0]) ||
+		user[2] != 0 || web[2] == user[2] || orders[2] == web[2] {
+		t.Errorf("unexpected participant positions %v %v %v", user, web, orders)
+	}
+	if positions["msg_1"][1] <= positions["msg_2"][1] {
+		t.Errorf("expected later messages lower, got %v and %v", positions["msg_1"], positions["msg_2"])
+	}
+
+	msg := byType["message"][2]
+	if msg["kind"] != "asynchronous" || msg["from"] != "Orders" || msg["to"] != "Payments" || msg["label"] != "charge" || msg["step"] != 2.0 {
+		t.Errorf("unexpected message extras %v", msg)
+	}
+	alt := byType["fragment"][0]
+	if operands, _ := alt["operands"].([]any); alt["kind"] != "alt" || len(operands) != 2 || operands[1].(map[string]any)["guard"] != "declined" {
+		t.Errorf("unexpected fragment extras %v", alt)
+	}
+	if zone := byType["zone"][1]; zone["id"] != "backend" || len(zone["participants"].([]any)) != 2 {
+		t.Errorf("unexpected zone extras %v", zone)
+	}
+
+	// The exporters write class diagrams only
+	cfg := config.NewConfig()
+	if _, _, err := cfg.ExportCtrl.Export(filepath.Join(tmpDir, "checkout.extuml"), filepath.Join(tmpDir, "out.mmd"), "", ""); err == nil || !strings.Contains(err.Error(), "only class diagrams") {
+		t.Errorf("expected exporting a sequence diagram to fail, got %v", err)
+	}
+}
+
+func TestFormatSequence(t *testing.T) {
+	src := `extuml sequenceDiagram3D
+participant  Web as Shop:::svc
+zone   backend{
+participant Orders
+}
+Web->>+Orders:create
+alt x > 0 {
+    %% approved
+  Orders-->>-Web
+}
+else "no {stock}" {
+opt{
+Orders-)Web : later
+}
+}
+note left of  Web   "n"
+activate  Web
+`
+	want := `extuml sequenceDiagram3D
+
+participant Web as "Shop":::svc
+
+zone backend {
+  participant Orders
+}
+
+Web ->> +Orders : create
+
+alt x > 0 {
+  %% approved
+  Orders -->> -Web
+} else "no {stock}" {
+  opt {
+    Orders -) Web : later
+  }
+}
+
+note left of Web "n"
+activate Web
+`
+	out, diags := formatter.Source("seq.extuml", []byte(src))
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if string(out) != want {
+		t.Errorf("unexpected formatting:\n%s\nwant:\n%s", out, want)
+	}
+	if again, _ := formatter.Source("seq.extuml", out); string(again) != string(out) {
+		t.Errorf("formatting again changed the source:\n%s", again)
+	}
+
+	for name, src := range map[string][]byte{"seq.extuml": []byte(src), "checkout.extuml": []byte(sequenceSource)} {
+		file, _ := parser.Parse(name, src)
+		before, _ := parser.Lower(file)
+		out, _ := formatter.Source(name, src)
+		file, _ = parser.Parse(name, out)
+		after, _ := parser.Lower(file)
+		if !reflect.DeepEqual(before, after) {
+			t.Errorf("%s: document changed by formatting\n got %+v\nwant %+v", name, after, before)
+		}
+	}
+}
+
+func TestFormatSequenceQuotedGuard(t *testing.T) {
+	src := `extuml sequenceDiagram3D
+
+alt body == "{}" {
+  A ->> B
+} else "{x}" {
+  B ->> A
+}
+`
+	out, diags := formatter.Source("seq.extuml", []byte(src))
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if string(out) != src {
+		t.Errorf("unexpected formatting:\n%s\nwant:\n%s", out, src)
+	}
+	if again, _ := formatter.Source("seq.extuml", out); string(again) != string(out) {
+		t.Errorf("formatting again changed the source:\n%s", again)
+	}
+
+	file, _ := parser.Parse("seq.extuml", []byte(src))
+	before, _ := parser.Lower(file)
+	file, _ = parser.Parse("seq.extuml", out)
+	after, _ := parser.Lower(file)
+	if !reflect.DeepEqual(before, after) {
+		t.Errorf("document changed by formatting\n got %+v\nwant %+v", after, before)
+	}
+	if guard := before.Sequence.Fragments[0].Operands[0].Guard; guard != `body == "{}"` {
+		t.Errorf("unexpected guard %q", guard)
+	}
+}
@@ -0,0 +1,227 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/extuml/extuml/pkg/formatter"
+	"github.com/extuml/extuml/pkg/model/extuml"
+	"github.com/extuml/extuml/pkg/model/gltf"
+	"github.com/extuml/extuml/pkg/parser"
+)
+
+const stateSource = `extuml stateDiagram3D
+title: Order lifecycle
+
+classDef waiting fill:#fc6
+
+state "Awaiting payment" as Paying:::waiting
+state Check <<choice>>
+
+[*] --> Created
+Created --> Paying : submit [items > 0] / reserve()
+Paying --> Check : paid
+Paying --> Paying : retry [attempts < 3]
+Check --> Fulfilment : [in stock]
+Check --> Cancelled : [else] / refund()
+
+state Fulfilment {
+  [*] --> Picking
+  Picking --> Packed
+  --
+  [*] --> Invoicing
+  Invoicing --> Invoiced : / send(to/from)
+  state Delivery {
+    Shipping --> Delivered
+  }
+}
+
+state Split <<fork>>
+state Merge <<join>>
+Fulfilment --> Split
+Split --> Merge
+Merge --> [*]
+Cancelled --> [*]
+note for Check "stock is checked per warehouse"
+`
+
+func TestStateLowering(t *testing.T) {
+	doc := lowerSource(t, "order.extuml", []byte(stateSource))
+	if doc.Diagram != extuml.DiagramState || doc.Elements != nil || doc.StateMachine == nil {
+		t.Fatalf("expected a state machine document, got %+v", doc)
+	}
+	sm := doc.StateMachine
+
+	states := map[string]extuml.State{}
+	for _, s := range sm.States {
+		states[s.ID] = s
+	}
+	if paying := states["Paying"]; paying.Name != "Awaiting payment" || !reflect.DeepEqual(paying.StyleClasses, []string{"waiting"}) {
+		t.Errorf("unexpected state %+v", paying)
+	}
+	for id, kind := range map[string]string{
+		"Check": extuml.StateChoice, "Split": extuml.StateFork, "Merge": extuml.StateJoin,
+		"initial": extuml.StateInitial, "final": extuml.StateFinal, "Created": extuml.StateSimple,
+	} {
+		if states[id].Type != kind {
+			t.Errorf("expected %s to be a %s state, got %+v", id, kind, states[id])
+		}
+	}
+
+	// Nested states are qualified by their composite state, and each region
+	// has pseudo-states of its own
+	if f := states["Fulfilment"]; f.Regions != 2 || f.Parent != "" {
+		t.Errorf("unexpected composite state %+v", f)
+	}
+	for id, want := range map[string]extuml.State{
+		"Fulfilment.initial":            {Parent: "Fulfilment", Region: 0},
+		"Fulfilment.Picking":            {Parent: "Fulfilment", Region: 0},
+		"Fulfilment.initial_2":          {Parent: "Fulfilment", Region: 1},
+		"Fulfilment.Invoiced":           {Parent: "Fulfilment", Region: 1},
+		"Fulfilment.Delivery":           {Parent: "Fulfilment", Region: 1, Regions: 1},
+		"Fulfilment.Delivery.Delivered": {Parent: "Fulfilment.Delivery"},
+	} {
+		got, ok := states[id]
+		if !ok || got.Parent != want.Parent || got.Region != want.Region || got.Regions != want.Regions {
+			t.Errorf("unexpected state %s: %+v", id, got)
+		}
+	}
+
+	if len(sm.Transitions) != 15 {
+		t.Fatalf("expected 15 transitions, got %+v", sm.Transitions)
+	}
+	for i, want := range map[int]extuml.Transition{
+		1:  {From: "Created", To: "Paying", Event: "submit", Guard: "items > 0", Action: "reserve()"},
+		3:  {From: "Paying", To: "Paying", Event: "retry", Guard: "attempts < 3"},
+		5:  {From: "Check", To: "Cancelled", Guard: "else", Action: "refund()"},
+		9:  {From: "Fulfilment.Invoicing", To: "Fulfilment.Invoiced", Action: "send(to/from)"},
+		14: {From: "Cancelled", To: "final"},
+	} {
+		want.ID = sm.Transitions[i].ID
+		if sm.Transitions[i] != want {
+			t.Errorf("transition %d: expected %+v, got %+v", i, want, sm.Transitions[i])
+		}
+	}
+	if len(sm.Notes) != 1 || sm.Notes[0].Anchor != "Check" {
+		t.Errorf("unexpected notes %+v", sm.Notes)
+	}
+}
+
+func TestStateDiagnostics(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, code string
+	}{
+		{"relationship arrow", "extuml stateDiagram3D\nA --|> B\n", "E103"},
+		{"region separator at the top level", "extuml stateDiagram3D\nA --> B\n--\n", "E103"},
+		{"unknown state kind", "extuml stateDiagram3D\nstate A <<history>>\n", "E103"},
+		{"choice with a body", "extuml stateDiagram3D\nstate A <<choice>> {\n}\n", "E103"},
+		{"include in a composite state", "extuml stateDiagram3D\nstate A {\n  include \"b.extuml\"\n}\n", "E103"},
+		{"unclosed composite state", "extuml stateDiagram3D\nstate A {\n  B --> C\n", "E102"},
+		{"duplicate state", "extuml stateDiagram3D\nstate A\nstate A\n", "E200"},
+		{"ambiguous state", "extuml stateDiagram3D\nstate A {\n  state X\n}\nstate B {\n  state X\n}\nX --> A\n", "W206"},
+		{"unknown note anchor", "extuml stateDiagram3D\nnote for A \"n\"\n", "W201"},
+		{"unknown style class", "extuml stateDiagram3D\nstate A:::missing\n", "W208"},
+	} {
+		diags := parseAndLower("state.extuml", tc.src)
+		found := false
+		for _, d := range diags {
+			found = found || d.Code == tc.code
+		}
+		if !found {
+			t.Errorf("%s: expected %s, got %v", tc.name, tc.code, diags)
+		}
+	}
+
+	if diags := parseAndLower("state.extuml", "extuml stateDiagram3D\nA --> B\nstyle B fill:#0f0\ncssClass \"A\" x\nclassDef x stroke:red\n"); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
+
+func TestGenerateStateMachine(t *testing.T) {
+	asset, byType, nodes := generateScene(t, t.TempDir(), "order.extuml", stateSource)
+	if ext := asset.Asset.Extras.(map[string]any)["extuml"].(map[string]any); ext["diagram"] != "state" {
+		t.Errorf("unexpected asset extras %v", ext)
+	}
+	for kind, count := range map[string]int{"state": 11, "initial": 3, "final": 1, "choice": 1, "fork": 1, "join": 1, "region": 3, "transition": 15} {
+		if len(byType[kind]) != count {
+			t.Errorf("expected %d %s nodes, got %d", count, kind, len(byType[kind]))
+		}
+	}
+
+	// Composite states hold their nested states, with their regions stacked
+	// front to back
+	fulfilment := nodes["Fulfilment"]
+	if fulfilment.Mesh != nil || !containsNode(asset, fulfilment, "Fulfilment.Picking") || !containsNode(asset, nodes["Fulfilment.Delivery"], "Fulfilment.Delivery.Delivered") {
+		t.Errorf("expected Fulfilment to be a parent node of its states, got %+v", fulfilment)
+	}
+	if front, back := nodes["Fulfilment_region_1"].Translation, nodes["Fulfilment_region_2"].Translation; front[2] <= back[2] {
+		t.Errorf("expected the first region in front, got %v and %v", front, back)
+	}
+	if created, paying := nodes["Created"].Translation, nodes["Paying"].Translation; created[0] >= paying[0] || nodes["initial"].Translation[0] >= created[0] {
+		t.Errorf("expected states in columns from the initial state, got %v and %v", created, paying)
+	}
+
+	for _, e := range byType["transition"] {
+		if e["from"] == "Created" && (e["event"] != "submit" || e["guard"] != "items > 0" || e["action"] != "reserve()") {
+			t.Errorf("unexpected transition extras %v", e)
+		}
+	}
+}
+
+// containsNode reports whether the node called name is a child of parent
+func containsNode(asset gltf.GLTFAsset, parent gltf.Node, name string) bool {
+	for _, child := range parent.Children {
+		if asset.Nodes[child].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFormatState(t *testing.T) {
+	src := `extuml stateDiagram3D
+state   "Awaiting payment" as Paying:::waiting
+[*]-->Paying
+state Fulfilment{
+    %% picking
+  [*] --> Picking
+Picking-->Packed:done
+  --
+  [*] --> Invoicing
+}
+state Check<<choice>>
+`
+	want := `extuml stateDiagram3D
+
+state Paying as "Awaiting payment":::waiting
+[*] --> Paying
+
+state Fulfilment {
+  %% picking
+  [*] --> Picking
+  Picking --> Packed : done
+  --
+  [*] --> Invoicing
+}
+
+state Check <<choice>>
+`
+	out, diags := formatter.Source("state.extuml", []byte(src))
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if string(out) != want {
+		t.Errorf("unexpected formatting:\n%s\nwant:\n%s", out, want)
+	}
+
+	for name, src := range map[string][]byte{"state.extuml": []byte(src), "order.extuml": []byte(stateSource)} {
+		file, _ := parser.Parse(name, src)
+		before, _ := parser.Lower(file)
+		out, _ := formatter.Source(name, src)
+		file, _ = parser.Parse(name, out)
+		after, _ := parser.Lower(file)
+		if !reflect.DeepEqual(before, after) {
+			t.Errorf("%s: document changed by formatting\n got %+v\nwant %+v", name, after, before)
+		}
+	}
+}